@@ -8441,7 +8441,10 @@ type WindowsDesktopCertRequest struct {
 	// CRLEndpoint is the address of the CRL for this certificate.
 	CRLEndpoint string `protobuf:"bytes,2,opt,name=CRLEndpoint,proto3" json:"CRLEndpoint,omitempty"`
 	// TTL is the certificate validity period.
-	TTL                  Duration `protobuf:"varint,3,opt,name=TTL,proto3,casttype=Duration" json:"TTL,omitempty"`
+	TTL Duration `protobuf:"varint,3,opt,name=TTL,proto3,casttype=Duration" json:"TTL,omitempty"`
+	// SkewAllowance is the amount of time to backdate the certificate's
+	// NotBefore time in order to tolerate minor clock skew on the Windows host.
+	SkewAllowance        Duration `protobuf:"varint,4,opt,name=SkewAllowance,proto3,casttype=Duration" json:"SkewAllowance,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -8501,6 +8504,13 @@ func (m *WindowsDesktopCertRequest) GetTTL() Duration {
 	return 0
 }
 
+func (m *WindowsDesktopCertRequest) GetSkewAllowance() Duration {
+	if m != nil {
+		return m.SkewAllowance
+	}
+	return 0
+}
+
 // WindowsDesktopCertResponse contains the signed Windows RDP certificate.
 type WindowsDesktopCertResponse struct {
 	// Cert is the signed certificate in PEM format.
@@ -32535,6 +32545,11 @@ func (m *WindowsDesktopCertRequest) MarshalToSizedBuffer(dAtA []byte) (int, erro
 		i -= len(m.XXX_unrecognized)
 		copy(dAtA[i:], m.XXX_unrecognized)
 	}
+	if m.SkewAllowance != 0 {
+		i = encodeVarintAuthservice(dAtA, i, uint64(m.SkewAllowance))
+		i--
+		dAtA[i] = 0x20
+	}
 	if m.TTL != 0 {
 		i = encodeVarintAuthservice(dAtA, i, uint64(m.TTL))
 		i--
@@ -40252,6 +40267,9 @@ func (m *WindowsDesktopCertRequest) Size() (n int) {
 	if m.TTL != 0 {
 		n += 1 + sovAuthservice(uint64(m.TTL))
 	}
+	if m.SkewAllowance != 0 {
+		n += 1 + sovAuthservice(uint64(m.SkewAllowance))
+	}
 	if m.XXX_unrecognized != nil {
 		n += len(m.XXX_unrecognized)
 	}
@@ -58005,6 +58023,25 @@ func (m *WindowsDesktopCertRequest) Unmarshal(dAtA []byte) error {
 					break
 				}
 			}
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SkewAllowance", wireType)
+			}
+			m.SkewAllowance = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowAuthservice
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.SkewAllowance |= Duration(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipAuthservice(dAtA[iNdEx:])
@@ -231,6 +231,12 @@ func Ping(cfg *Config) (*PingResponse, error) {
 		return nil, trace.Wrap(err, "cannot parse server response; is %q a Teleport server?", "https://"+cfg.ProxyAddr)
 	}
 
+	// The Date header carries the proxy's clock with second resolution,
+	// which is enough to detect meaningful clock skew on the client.
+	if date, err := http.ParseTime(resp.Header.Get("Date")); err == nil {
+		pr.ServerTime = date
+	}
+
 	return pr, nil
 }
 
@@ -291,6 +297,11 @@ type PingResponse struct {
 	// reserved: license_warnings ([]string)
 	// AutomaticUpgrades describes whether agents should automatically upgrade.
 	AutomaticUpgrades bool `json:"automatic_upgrades"`
+
+	// ServerTime is the time the proxy reported when it answered the ping,
+	// taken from the Date header of the response rather than the response
+	// body. It is zero when the proxy did not report a time.
+	ServerTime time.Time `json:"-"`
 }
 
 // PingErrorResponse contains the error message if the requested connector
@@ -398,14 +398,32 @@ func (p *Profile) SaveToDir(dir string, makeCurrent bool) error {
 	return nil
 }
 
-// saveToFile saves this profile to the specified file.
-func (p *Profile) saveToFile(filepath string) error {
+// saveToFile saves this profile to the specified file. The profile is first
+// written to a temporary file in the same directory and then renamed into
+// place, so that a failed write cannot leave a corrupt profile behind.
+func (p *Profile) saveToFile(path string) error {
 	bytes, err := yaml.Marshal(&p)
 	if err != nil {
 		return trace.Wrap(err)
 	}
-	if err = os.WriteFile(filepath, bytes, 0o660); err != nil {
-		return trace.Wrap(err)
+	tempFile, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return trace.ConvertSystemError(err)
+	}
+	defer os.Remove(tempFile.Name())
+	if err := tempFile.Chmod(0o660); err != nil {
+		tempFile.Close()
+		return trace.ConvertSystemError(err)
+	}
+	if _, err := tempFile.Write(bytes); err != nil {
+		tempFile.Close()
+		return trace.ConvertSystemError(err)
+	}
+	if err := tempFile.Close(); err != nil {
+		return trace.ConvertSystemError(err)
+	}
+	if err := os.Rename(tempFile.Name(), path); err != nil {
+		return trace.ConvertSystemError(err)
 	}
 	return nil
 }
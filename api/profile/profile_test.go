@@ -58,6 +58,11 @@ func TestProfileBasics(t *testing.T) {
 	_, err = os.Stat(filepath.Join(dir, p.Name()+".yaml"))
 	require.NoError(t, err)
 
+	// the save is atomic, so no temporary files should remain
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
 	// try to save to non-existent dir, should get an error
 	err = p.SaveToDir("/bad/directory/", false)
 	require.Error(t, err)
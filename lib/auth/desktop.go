@@ -23,6 +23,7 @@ import (
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"strconv"
+	"time"
 
 	"github.com/gravitational/trace"
 
@@ -32,6 +33,10 @@ import (
 	"github.com/gravitational/teleport/lib/tlsca"
 )
 
+// maxCertificateSkew caps the not-before backdating that can be requested
+// to compensate for clock drift on the Windows host.
+const maxCertificateSkew = 10 * time.Minute
+
 // GenerateWindowsDesktopCert generates client certificate for Windows RDP
 // authentication.
 func (a *Server) GenerateWindowsDesktopCert(ctx context.Context, req *proto.WindowsDesktopCertRequest) (*proto.WindowsDesktopCertResponse, error) {
@@ -39,6 +44,12 @@ func (a *Server) GenerateWindowsDesktopCert(ctx context.Context, req *proto.Wind
 		return nil, trace.AccessDenied(
 			"this Teleport cluster is not licensed for desktop access, please contact the cluster administrator")
 	}
+	if req.SkewAllowance < 0 {
+		return nil, trace.BadParameter("certificate skew allowance must not be negative, got %v", req.SkewAllowance.Get())
+	}
+	if req.SkewAllowance.Get() > maxCertificateSkew {
+		return nil, trace.BadParameter("certificate skew allowance %v exceeds the maximum of %v", req.SkewAllowance.Get(), maxCertificateSkew)
+	}
 	csr, err := tlsca.ParseCertificateRequestPEM(req.CSR)
 	if err != nil {
 		return nil, trace.Wrap(err)
@@ -74,6 +85,11 @@ func (a *Server) GenerateWindowsDesktopCert(ctx context.Context, req *proto.Wind
 		// CRL is required for Windows smartcard certs.
 		CRLDistributionPoints: []string{req.CRLEndpoint},
 	}
+	if req.SkewAllowance != 0 {
+		// Backdate the certificate so that hosts with skewed clocks don't
+		// reject it as not yet valid.
+		certReq.NotBefore = a.clock.Now().UTC().Add(-req.SkewAllowance.Get())
+	}
 
 	limitExceeded, err := a.desktopsLimitExceeded(ctx)
 	if err != nil {
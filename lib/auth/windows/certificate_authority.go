@@ -20,8 +20,14 @@ package windows
 
 import (
 	"context"
+	"crypto/x509"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/gravitational/trace"
+	"github.com/jonboulle/clockwork"
 	"github.com/sirupsen/logrus"
 
 	"github.com/gravitational/teleport/api/types"
@@ -30,6 +36,9 @@ import (
 
 // NewCertificateStoreClient returns a new structure for modifying windows certificates in a Windows CA.
 func NewCertificateStoreClient(cfg CertificateStoreConfig) *CertificateStoreClient {
+	if cfg.Clock == nil {
+		cfg.Clock = clockwork.NewRealClock()
+	}
 	return &CertificateStoreClient{cfg: cfg}
 }
 
@@ -50,25 +59,57 @@ type CertificateStoreConfig struct {
 	ClusterName string
 	// LC is the LDAPClient
 	LC *LDAPClient
+	// ReadOnly disables all LDAP writes. Instead of publishing the CRL,
+	// the client verifies that the objects it would have published are
+	// already present, for environments that provision them out of band.
+	ReadOnly bool
+	// PublishNTAuth makes the client maintain the cluster CA certificates
+	// in the domain's NTAuthCertificates entry, replacing superseded
+	// certificates after a CA rotation. When unset, the CA must be
+	// imported into the NTAuth store manually.
+	PublishNTAuth bool
+	// Clock is used to evaluate the CA rotation grace period.
+	// Defaults to the real clock.
+	Clock clockwork.Clock
 }
 
-// Update publishes an empty certificate revocation list to LDAP.
+// Update publishes an empty certificate revocation list to LDAP. In
+// read-only mode it performs no writes and instead verifies that the CRL
+// has been pre-provisioned.
 func (c *CertificateStoreClient) Update(ctx context.Context) error {
 	caType := types.UserCA
 
+	// Reconcile the NTAuth store first: the CRL is only useful once the CA
+	// itself is trusted for smart card logins. This also picks up CA
+	// rotations, since Update runs both at startup and on the periodic
+	// CRL refresh.
+	if c.cfg.PublishNTAuth {
+		if err := c.SyncNTAuth(ctx); err != nil {
+			return trace.Wrap(err, "synchronizing the NTAuth store")
+		}
+	}
+
+	if c.cfg.ReadOnly {
+		if err := c.verifyCRL(ctx, caType); err != nil {
+			return trace.Wrap(err)
+		}
+		c.cfg.Log.Info("LDAP is read-only, verified the pre-provisioned CRL instead of publishing")
+		return nil
+	}
+
 	crlDER, err := c.cfg.AccessPoint.GenerateCertAuthorityCRL(ctx, caType)
 	if err != nil {
 		return trace.Wrap(err, "generating CRL")
 	}
 
-	// TODO(zmb3): check for the presence of Teleport's CA in the NTAuth store
-
 	// To make the CA trusted, we need 3 things:
 	// 1. put the CA cert into the Trusted Certification Authorities in Group Policy
 	// 2. put the CA cert into NTAuth store in LDAP
 	// 3. put the CRL of the CA into a dedicated LDAP entry
 	//
-	// #1 and #2 are done manually as part of the set up process (see public docs).
+	// #1 is done manually as part of the set up process (see public docs).
+	// #2 is done manually as well, unless PublishNTAuth is set, in which
+	// case SyncNTAuth above keeps the store up to date.
 	// Below we do #3.
 	if err := c.updateCRL(ctx, crlDER, caType); err != nil {
 		return trace.Wrap(err, "updating CRL over LDAP")
@@ -76,6 +117,93 @@ func (c *CertificateStoreClient) Update(ctx context.Context) error {
 	return nil
 }
 
+// CRLInfo summarizes the CRL currently published in LDAP.
+type CRLInfo struct {
+	// DN is the LDAP distinguished name the CRL was read from.
+	DN string
+	// ThisUpdate is the time the published CRL was issued.
+	ThisUpdate time.Time
+	// NextUpdate is the time by which a fresh CRL should be published.
+	NextUpdate time.Time
+	// RevokedCertificates is the number of revoked serials listed on the CRL.
+	RevokedCertificates int
+}
+
+// ReadCRL reads back the certificateRevocationList attribute from the CRL
+// entry that Teleport publishes with [CertificateStoreClient.Update] and
+// summarizes the parsed CRL, so that admins can confirm the CRL in Active
+// Directory matches what Teleport generated without querying LDAP by hand.
+func (c *CertificateStoreClient) ReadCRL(ctx context.Context) (*CRLInfo, error) {
+	dn := crlDN(c.cfg.ClusterName, c.cfg.LDAPConfig, types.UserCA)
+
+	entries, err := c.cfg.LC.Read(dn, "cRLDistributionPoint", []string{"certificateRevocationList"})
+	if err != nil {
+		return nil, trace.Wrap(err, "reading CRL entry %q", dn)
+	}
+	for _, entry := range entries {
+		if !strings.EqualFold(entry.DN, dn) {
+			continue
+		}
+		der := entry.GetRawAttributeValue("certificateRevocationList")
+		if len(der) == 0 {
+			break
+		}
+		crl, err := x509.ParseRevocationList(der)
+		if err != nil {
+			return nil, trace.Wrap(err, "parsing CRL at %q", dn)
+		}
+		return &CRLInfo{
+			DN:                  dn,
+			ThisUpdate:          crl.ThisUpdate,
+			NextUpdate:          crl.NextUpdate,
+			RevokedCertificates: len(crl.RevokedCertificateEntries),
+		}, nil
+	}
+
+	return nil, trace.NotFound("no CRL published at %q", dn)
+}
+
+// checkCRLContainer verifies that the entry at containerDN is a proper
+// container object. A previous partially-failed run may have left an entry
+// with the wrong object class at the container DN, in which case the error
+// tells the admin how to recover. The object class of an existing AD entry
+// cannot be changed, so there is no safe automatic repair.
+func (c *CertificateStoreClient) checkCRLContainer(containerDN string) error {
+	entries, err := c.cfg.LC.ReadWithFilter(containerDN, fmt.Sprintf("(%s=*)", AttrObjectClass), []string{AttrObjectClass})
+	if err != nil {
+		return trace.Wrap(err, "reading CRL container")
+	}
+
+	for _, entry := range entries {
+		if !strings.EqualFold(entry.DN, containerDN) {
+			continue
+		}
+		if slices.Contains(entry.GetAttributeValues(AttrObjectClass), ClassContainer) {
+			return nil
+		}
+		return trace.BadParameter(
+			"LDAP entry %q exists but is not a container (objectClass %v), "+
+				"likely left over from a previous failed run; remove the entry and try again",
+			containerDN, entry.GetAttributeValues(AttrObjectClass))
+	}
+
+	return trace.NotFound("LDAP container %q not found", containerDN)
+}
+
+// verifyCRL checks that the CRL container and the CRL entry that Update
+// would normally publish are already present in LDAP. It is used in
+// read-only mode, where those objects must be provisioned out of band.
+func (c *CertificateStoreClient) verifyCRL(ctx context.Context, caType types.CertAuthType) error {
+	containerDN := crlContainerDN(c.cfg.LDAPConfig, caType)
+	if err := c.checkCRLContainer(containerDN); err != nil {
+		return trace.Wrap(err, "LDAP is read-only and the CRL container %q was not pre-provisioned", containerDN)
+	}
+	if _, err := c.ReadCRL(ctx); err != nil {
+		return trace.Wrap(err, "LDAP is read-only and no CRL was pre-provisioned")
+	}
+	return nil
+}
+
 func (c *CertificateStoreClient) updateCRL(ctx context.Context, crlDER []byte, caType types.CertAuthType) error {
 	// Publish the CRL for current cluster CA. For trusted clusters, their
 	// respective windows_desktop_services will publish CRLs of their CAs so we
@@ -98,6 +226,14 @@ func (c *CertificateStoreClient) updateCRL(ctx context.Context, crlDER []byte, c
 		return trace.Wrap(err, "creating CRL container")
 	}
 
+	// CreateContainer is a no-op if an entry already exists at the container
+	// DN, even if that entry is not actually a container. Verify the container
+	// before publishing the CRL below it, otherwise the add fails in
+	// confusing ways.
+	if err := c.checkCRLContainer(containerDN); err != nil {
+		return trace.Wrap(err)
+	}
+
 	// Create the CRL object itself.
 	if err := c.cfg.LC.Create(
 		crlDN,
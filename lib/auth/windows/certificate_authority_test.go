@@ -0,0 +1,362 @@
+// Teleport
+// Copyright (C) 2024 Gravitational, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package windows
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gravitational/teleport/api/types"
+)
+
+// crlContainerTestClient returns a canned set of entries for any search.
+type crlContainerTestClient struct {
+	ldap.Client
+
+	entries []*ldap.Entry
+}
+
+func (c *crlContainerTestClient) SearchWithPaging(req *ldap.SearchRequest, pageSize uint32) (*ldap.SearchResult, error) {
+	return &ldap.SearchResult{Entries: c.entries}, nil
+}
+
+func (c *crlContainerTestClient) Close() error { return nil }
+
+func TestCheckCRLContainer(t *testing.T) {
+	cfg := LDAPConfig{Domain: "test.example.com"}
+	containerDN := crlContainerDN(cfg, types.UserCA)
+
+	for _, test := range []struct {
+		name      string
+		entries   []*ldap.Entry
+		assertErr require.ErrorAssertionFunc
+	}{
+		{
+			name: "well-formed container",
+			entries: []*ldap.Entry{
+				ldap.NewEntry(containerDN, map[string][]string{
+					AttrObjectClass: {"top", ClassContainer},
+				}),
+			},
+			assertErr: require.NoError,
+		},
+		{
+			name: "entry with wrong object class",
+			entries: []*ldap.Entry{
+				ldap.NewEntry(containerDN, map[string][]string{
+					AttrObjectClass: {"top", "cRLDistributionPoint"},
+				}),
+			},
+			assertErr: func(t require.TestingT, err error, i ...interface{}) {
+				require.True(t, trace.IsBadParameter(err), "expected BadParameter error, got %v", err)
+			},
+		},
+		{
+			name:    "container missing",
+			entries: nil,
+			assertErr: func(t require.TestingT, err error, i ...interface{}) {
+				require.True(t, trace.IsNotFound(err), "expected NotFound error, got %v", err)
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			lc := &LDAPClient{}
+			lc.SetClient(&crlContainerTestClient{entries: test.entries})
+
+			c := NewCertificateStoreClient(CertificateStoreConfig{
+				LDAPConfig: cfg,
+				LC:         lc,
+			})
+			test.assertErr(t, c.checkCRLContainer(containerDN))
+		})
+	}
+}
+
+// crlPublishTestClient accepts writes and reports a well-formed CRL
+// container for any search. When crlExists is set, adds below the
+// container fail with "entry already exists" to force the update path.
+type crlPublishTestClient struct {
+	ldap.Client
+
+	containerDN string
+	crlExists   bool
+}
+
+func (c *crlPublishTestClient) Add(req *ldap.AddRequest) error {
+	if c.crlExists && req.DN != c.containerDN {
+		return ldap.NewError(ldap.LDAPResultEntryAlreadyExists, errors.New("entry already exists"))
+	}
+	return nil
+}
+
+func (c *crlPublishTestClient) Modify(req *ldap.ModifyRequest) error { return nil }
+
+func (c *crlPublishTestClient) SearchWithPaging(req *ldap.SearchRequest, pageSize uint32) (*ldap.SearchResult, error) {
+	return &ldap.SearchResult{Entries: []*ldap.Entry{
+		ldap.NewEntry(c.containerDN, map[string][]string{
+			AttrObjectClass: {"top", ClassContainer},
+		}),
+	}}, nil
+}
+
+func (c *crlPublishTestClient) Close() error { return nil }
+
+// ldapWriteSummary is the portion of an [LDAPWrite] that tests assert on.
+type ldapWriteSummary struct {
+	op      string
+	dn      string
+	success bool
+}
+
+// TestUpdateCRLReportsWrites verifies that publishing a CRL reports every
+// LDAP write through the client's OnWrite callback.
+func TestUpdateCRLReportsWrites(t *testing.T) {
+	cfg := LDAPConfig{Domain: "test.example.com"}
+	containerDN := crlContainerDN(cfg, types.UserCA)
+	crlDN := crlDN("example", cfg, types.UserCA)
+
+	for _, test := range []struct {
+		name       string
+		crlExists  bool
+		wantWrites []ldapWriteSummary
+	}{
+		{
+			name: "fresh publish",
+			wantWrites: []ldapWriteSummary{
+				{op: WriteOpCreate, dn: containerDN, success: true},
+				{op: WriteOpCreate, dn: crlDN, success: true},
+			},
+		},
+		{
+			name:      "existing CRL is updated",
+			crlExists: true,
+			wantWrites: []ldapWriteSummary{
+				{op: WriteOpCreate, dn: containerDN, success: true},
+				{op: WriteOpCreate, dn: crlDN, success: false},
+				{op: WriteOpUpdate, dn: crlDN, success: true},
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var writes []ldapWriteSummary
+			lc := &LDAPClient{
+				OnWrite: func(w LDAPWrite) {
+					writes = append(writes, ldapWriteSummary{op: w.Op, dn: w.DN, success: w.Err == nil})
+				},
+			}
+			lc.SetClient(&crlPublishTestClient{containerDN: containerDN, crlExists: test.crlExists})
+
+			c := NewCertificateStoreClient(CertificateStoreConfig{
+				LDAPConfig:  cfg,
+				ClusterName: "example",
+				Log:         logrus.New(),
+				LC:          lc,
+			})
+			require.NoError(t, c.updateCRL(context.Background(), []byte("crl-der"), types.UserCA))
+			require.Equal(t, test.wantWrites, writes)
+		})
+	}
+}
+
+// TestReadOnlyMode verifies that a read-only certificate store client
+// performs no LDAP writes and instead checks that the CRL objects have
+// been pre-provisioned.
+func TestReadOnlyMode(t *testing.T) {
+	cfg := LDAPConfig{Domain: "test.example.com"}
+	containerDN := crlContainerDN(cfg, types.UserCA)
+	dn := crlDN("example", cfg, types.UserCA)
+
+	containerEntry := ldap.NewEntry(containerDN, map[string][]string{
+		AttrObjectClass: {"top", ClassContainer},
+	})
+	crlEntry := &ldap.Entry{
+		DN: dn,
+		Attributes: []*ldap.EntryAttribute{{
+			Name:       "certificateRevocationList",
+			ByteValues: [][]byte{makeTestCRL(t, time.Now(), time.Now().Add(time.Hour))},
+		}},
+	}
+
+	for _, test := range []struct {
+		name      string
+		entries   []*ldap.Entry
+		assertErr require.ErrorAssertionFunc
+	}{
+		{
+			name:      "pre-provisioned CRL is accepted",
+			entries:   []*ldap.Entry{containerEntry, crlEntry},
+			assertErr: require.NoError,
+		},
+		{
+			name:    "missing CRL is an error",
+			entries: []*ldap.Entry{containerEntry},
+			assertErr: func(t require.TestingT, err error, i ...interface{}) {
+				require.ErrorContains(t, err, "no CRL was pre-provisioned")
+			},
+		},
+		{
+			name:    "missing container is an error",
+			entries: nil,
+			assertErr: func(t require.TestingT, err error, i ...interface{}) {
+				require.ErrorContains(t, err, "CRL container")
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var writes []ldapWriteSummary
+			lc := &LDAPClient{
+				OnWrite: func(w LDAPWrite) {
+					writes = append(writes, ldapWriteSummary{op: w.Op, dn: w.DN, success: w.Err == nil})
+				},
+			}
+			lc.SetClient(&crlContainerTestClient{entries: test.entries})
+
+			c := NewCertificateStoreClient(CertificateStoreConfig{
+				LDAPConfig:  cfg,
+				ClusterName: "example",
+				Log:         logrus.New(),
+				LC:          lc,
+				ReadOnly:    true,
+			})
+			test.assertErr(t, c.Update(context.Background()))
+			require.Empty(t, writes)
+		})
+	}
+}
+
+// makeTestCRL builds a CRL signed by a throwaway CA with the given
+// revoked serial numbers.
+func makeTestCRL(t *testing.T, thisUpdate, nextUpdate time.Time, revokedSerials ...int64) []byte {
+	t.Helper()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		SubjectKeyId:          []byte{1, 2, 3, 4},
+		NotBefore:             thisUpdate,
+		NotAfter:              thisUpdate.Add(24 * time.Hour),
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	caCert, err := x509.ParseCertificate(caDER)
+	require.NoError(t, err)
+
+	revoked := make([]x509.RevocationListEntry, 0, len(revokedSerials))
+	for _, serial := range revokedSerials {
+		revoked = append(revoked, x509.RevocationListEntry{
+			SerialNumber:   big.NewInt(serial),
+			RevocationTime: thisUpdate,
+		})
+	}
+	crlDER, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:                    big.NewInt(1),
+		ThisUpdate:                thisUpdate,
+		NextUpdate:                nextUpdate,
+		RevokedCertificateEntries: revoked,
+	}, caCert, caKey)
+	require.NoError(t, err)
+
+	return crlDER
+}
+
+// TestReadCRL verifies that the CRL published in LDAP can be read back
+// and summarized.
+func TestReadCRL(t *testing.T) {
+	cfg := LDAPConfig{Domain: "test.example.com"}
+	dn := crlDN("example", cfg, types.UserCA)
+
+	thisUpdate := time.Now().UTC().Truncate(time.Second)
+	nextUpdate := thisUpdate.Add(time.Hour)
+	crlDER := makeTestCRL(t, thisUpdate, nextUpdate, 42, 43)
+
+	for _, test := range []struct {
+		name      string
+		entries   []*ldap.Entry
+		assertErr require.ErrorAssertionFunc
+		assert    func(t *testing.T, info *CRLInfo)
+	}{
+		{
+			name: "published CRL",
+			entries: []*ldap.Entry{{
+				DN: dn,
+				Attributes: []*ldap.EntryAttribute{{
+					Name:       "certificateRevocationList",
+					ByteValues: [][]byte{crlDER},
+				}},
+			}},
+			assertErr: require.NoError,
+			assert: func(t *testing.T, info *CRLInfo) {
+				require.Equal(t, dn, info.DN)
+				require.True(t, info.ThisUpdate.Equal(thisUpdate), "ThisUpdate %v != %v", info.ThisUpdate, thisUpdate)
+				require.True(t, info.NextUpdate.Equal(nextUpdate), "NextUpdate %v != %v", info.NextUpdate, nextUpdate)
+				require.Equal(t, 2, info.RevokedCertificates)
+			},
+		},
+		{
+			name:    "no CRL published",
+			entries: nil,
+			assertErr: func(t require.TestingT, err error, i ...interface{}) {
+				require.True(t, trace.IsNotFound(err), "expected NotFound error, got %v", err)
+			},
+		},
+		{
+			name: "malformed CRL",
+			entries: []*ldap.Entry{{
+				DN: dn,
+				Attributes: []*ldap.EntryAttribute{{
+					Name:       "certificateRevocationList",
+					ByteValues: [][]byte{[]byte("not a CRL")},
+				}},
+			}},
+			assertErr: require.Error,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			lc := &LDAPClient{}
+			lc.SetClient(&crlContainerTestClient{entries: test.entries})
+
+			c := NewCertificateStoreClient(CertificateStoreConfig{
+				LDAPConfig:  cfg,
+				ClusterName: "example",
+				LC:          lc,
+			})
+			info, err := c.ReadCRL(context.Background())
+			test.assertErr(t, err)
+			if test.assert != nil {
+				test.assert(t, info)
+			}
+		})
+	}
+}
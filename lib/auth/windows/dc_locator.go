@@ -0,0 +1,103 @@
+/*
+ * Teleport
+ * Copyright (C) 2024  Gravitational, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package windows
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gravitational/trace"
+)
+
+// LookupSRVFunc resolves DNS SRV records. It matches the signature of
+// [net.Resolver.LookupSRV].
+type LookupSRVFunc func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+
+// DomainControllerLocator discovers the LDAP servers of an Active Directory
+// domain through the DNS SRV records that domain controllers register
+// (_ldap._tcp.<domain> and, per site, _ldap._tcp.<site>._sites.<domain>).
+// Callers try the returned addresses in order, giving failover across
+// controllers instead of pinning to a single configured address.
+type DomainControllerLocator struct {
+	// Site optionally names the AD site this service runs in. When set,
+	// controllers registered for the site are preferred over the
+	// domain-wide list, which keeps LDAP traffic off far-away controllers.
+	Site string
+	// LookupSRV resolves SRV records. Defaults to net.DefaultResolver.
+	// Note that hosts discovered via AD-integrated DNS may require a
+	// resolver pointed at the domain controllers.
+	LookupSRV LookupSRVFunc
+}
+
+// LocateLDAPServers returns the host:port addresses of the domain's LDAP
+// servers in preference order: controllers of the configured site first,
+// then the domain-wide ones, each group ordered by SRV priority and weight
+// and deduplicated. A failed site lookup is not fatal as long as the
+// domain-wide lookup yields results.
+func (l *DomainControllerLocator) LocateLDAPServers(ctx context.Context, domain string) ([]string, error) {
+	lookup := l.LookupSRV
+	if lookup == nil {
+		lookup = net.DefaultResolver.LookupSRV
+	}
+
+	var addrs []string
+	seen := make(map[string]struct{})
+	appendRecords := func(records []*net.SRV) {
+		sortSRV(records)
+		for _, r := range records {
+			addr := net.JoinHostPort(strings.TrimSuffix(r.Target, "."), strconv.Itoa(int(r.Port)))
+			if _, ok := seen[addr]; ok {
+				continue
+			}
+			seen[addr] = struct{}{}
+			addrs = append(addrs, addr)
+		}
+	}
+
+	if l.Site != "" {
+		if _, records, err := lookup(ctx, "ldap", "tcp", l.Site+"._sites."+domain); err == nil {
+			appendRecords(records)
+		}
+	}
+
+	_, records, err := lookup(ctx, "ldap", "tcp", domain)
+	if err != nil && len(addrs) == 0 {
+		return nil, trace.Wrap(err, "locating domain controllers for %v", domain)
+	}
+	appendRecords(records)
+
+	if len(addrs) == 0 {
+		return nil, trace.NotFound("no domain controllers found for %v", domain)
+	}
+	return addrs, nil
+}
+
+// sortSRV orders records by ascending priority, then descending weight, the
+// order in which SRV targets should be attempted.
+func sortSRV(records []*net.SRV) {
+	sort.SliceStable(records, func(i, j int) bool {
+		if records[i].Priority != records[j].Priority {
+			return records[i].Priority < records[j].Priority
+		}
+		return records[i].Weight > records[j].Weight
+	})
+}
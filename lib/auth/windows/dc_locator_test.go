@@ -0,0 +1,123 @@
+/*
+ * Teleport
+ * Copyright (C) 2024  Gravitational, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package windows
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/gravitational/trace"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocateLDAPServers(t *testing.T) {
+	ctx := context.Background()
+	srv := func(target string, port, priority, weight uint16) *net.SRV {
+		return &net.SRV{Target: target, Port: port, Priority: priority, Weight: weight}
+	}
+
+	t.Run("orders by priority then weight", func(t *testing.T) {
+		locator := &DomainControllerLocator{
+			LookupSRV: func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+				require.Equal(t, "ldap", service)
+				require.Equal(t, "tcp", proto)
+				require.Equal(t, "example.com", name)
+				return "", []*net.SRV{
+					srv("dc3.example.com.", 636, 10, 0),
+					srv("dc1.example.com.", 636, 0, 50),
+					srv("dc2.example.com.", 636, 0, 100),
+				}, nil
+			},
+		}
+
+		addrs, err := locator.LocateLDAPServers(ctx, "example.com")
+		require.NoError(t, err)
+		require.Equal(t, []string{
+			"dc2.example.com:636",
+			"dc1.example.com:636",
+			"dc3.example.com:636",
+		}, addrs)
+	})
+
+	t.Run("prefers controllers of the configured site", func(t *testing.T) {
+		locator := &DomainControllerLocator{
+			Site: "NYC",
+			LookupSRV: func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+				switch name {
+				case "NYC._sites.example.com":
+					return "", []*net.SRV{srv("dc2.example.com.", 636, 0, 0)}, nil
+				case "example.com":
+					return "", []*net.SRV{
+						srv("dc1.example.com.", 636, 0, 0),
+						srv("dc2.example.com.", 636, 0, 0),
+					}, nil
+				}
+				return "", nil, trace.NotFound("unexpected lookup %v", name)
+			},
+		}
+
+		// The site controller comes first and isn't repeated in the
+		// domain-wide remainder.
+		addrs, err := locator.LocateLDAPServers(ctx, "example.com")
+		require.NoError(t, err)
+		require.Equal(t, []string{
+			"dc2.example.com:636",
+			"dc1.example.com:636",
+		}, addrs)
+	})
+
+	t.Run("failed site lookup falls back to the domain-wide list", func(t *testing.T) {
+		locator := &DomainControllerLocator{
+			Site: "NYC",
+			LookupSRV: func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+				if name == "example.com" {
+					return "", []*net.SRV{srv("dc1.example.com.", 636, 0, 0)}, nil
+				}
+				return "", nil, trace.NotFound("no records for %v", name)
+			},
+		}
+
+		addrs, err := locator.LocateLDAPServers(ctx, "example.com")
+		require.NoError(t, err)
+		require.Equal(t, []string{"dc1.example.com:636"}, addrs)
+	})
+
+	t.Run("failed lookup is an error", func(t *testing.T) {
+		locator := &DomainControllerLocator{
+			LookupSRV: func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+				return "", nil, trace.ConnectionProblem(nil, "DNS unreachable")
+			},
+		}
+
+		_, err := locator.LocateLDAPServers(ctx, "example.com")
+		require.Error(t, err)
+	})
+
+	t.Run("no records is an error", func(t *testing.T) {
+		locator := &DomainControllerLocator{
+			LookupSRV: func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+				return "", nil, nil
+			},
+		}
+
+		_, err := locator.LocateLDAPServers(ctx, "example.com")
+		require.True(t, trace.IsNotFound(err))
+	})
+}
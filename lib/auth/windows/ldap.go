@@ -22,11 +22,13 @@ import (
 	"crypto/x509"
 	"errors"
 	"fmt"
+	"os"
 	"strings"
 	"sync"
 
 	"github.com/go-ldap/ldap/v3"
 	"github.com/gravitational/trace"
+	"github.com/sirupsen/logrus"
 
 	"github.com/gravitational/teleport/api/types"
 )
@@ -41,6 +43,22 @@ type LDAPConfig struct {
 	// Username is an LDAP username, like "EXAMPLE\Administrator", where
 	// "EXAMPLE" is the NetBIOS version of Domain.
 	Username string //nolint:unused // False-positive
+	// BindDN is an optional distinguished name or UPN used to authenticate
+	// the LDAP session. If empty, the authentication identity is derived
+	// from Username.
+	BindDN string //nolint:unused // False-positive
+	// Password is an optional password for simple-bind authentication of
+	// the LDAP session. Prefer PasswordFile or PasswordEnv over setting
+	// the password directly, so the secret isn't embedded in the
+	// configuration. When no password source is configured, the session is
+	// authenticated with the TLS client certificate alone.
+	Password string //nolint:unused // False-positive
+	// PasswordFile is an optional path to a file containing the bind
+	// password. Mutually exclusive with Password and PasswordEnv.
+	PasswordFile string //nolint:unused // False-positive
+	// PasswordEnv is an optional name of an environment variable holding
+	// the bind password. Mutually exclusive with Password and PasswordFile.
+	PasswordEnv string //nolint:unused // False-positive
 	// SID is the SID for the user specified by Username.
 	SID string //nolint:unused // False-positive
 	// InsecureSkipVerify decides whether we skip verifying with the LDAP server's CA when making the LDAPS connection.
@@ -59,8 +77,44 @@ func (cfg LDAPConfig) Check() error {
 	if cfg.Domain == "" {
 		return trace.BadParameter("missing Domain in LDAPConfig")
 	}
-	if cfg.Username == "" {
-		return trace.BadParameter("missing Username in LDAPConfig")
+	if cfg.Username == "" && cfg.BindDN == "" {
+		return trace.BadParameter("missing Username and BindDN in LDAPConfig (at least one is required)")
+	}
+	sources := 0
+	for _, set := range []bool{cfg.Password != "", cfg.PasswordFile != "", cfg.PasswordEnv != ""} {
+		if set {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return trace.BadParameter("LDAPConfig must specify exactly one of Password, PasswordFile and PasswordEnv")
+	}
+	return nil
+}
+
+// CheckAndSetDefaults verifies this LDAPConfig and resolves the bind
+// password from its configured source, if any, so that consumers only
+// ever read Password.
+func (cfg *LDAPConfig) CheckAndSetDefaults() error {
+	if err := cfg.Check(); err != nil {
+		return trace.Wrap(err)
+	}
+	switch {
+	case cfg.PasswordFile != "":
+		pw, err := os.ReadFile(cfg.PasswordFile)
+		if err != nil {
+			return trace.Wrap(trace.ConvertSystemError(err), "reading PasswordFile from LDAPConfig")
+		}
+		cfg.Password = strings.TrimSpace(string(pw))
+		if cfg.Password == "" {
+			return trace.BadParameter("PasswordFile %q in LDAPConfig is empty", cfg.PasswordFile)
+		}
+	case cfg.PasswordEnv != "":
+		pw, ok := os.LookupEnv(cfg.PasswordEnv)
+		if !ok {
+			return trace.BadParameter("environment variable %q named by PasswordEnv in LDAPConfig is not set", cfg.PasswordEnv)
+		}
+		cfg.Password = pw
 	}
 	return nil
 }
@@ -94,6 +148,9 @@ const (
 	ClassContainer = "container"
 	// ClassGMSA is the object class for group managed service accounts in Active Directory.
 	ClassGMSA = "msDS-GroupManagedServiceAccount"
+	// ClassCertificationAuthority is the object class for certification
+	// authority entries, including the NTAuthCertificates store.
+	ClassCertificationAuthority = "certificationAuthority"
 
 	// AccountTypeUser is the SAM account type for user accounts.
 	// See https://learn.microsoft.com/en-us/windows/win32/adschema/a-samaccounttype
@@ -126,6 +183,9 @@ const (
 	AttrObjectCategory = "objectCategory"
 	// AttrObjectClass is the object class of an LDAP object
 	AttrObjectClass = "objectClass"
+	// AttrCACertificate holds the DER-encoded CA certificates of a
+	// certification authority entry
+	AttrCACertificate = "cACertificate"
 )
 
 // searchPageSize is desired page size for LDAP search. In Active Directory the default search size limit is 1000 entries,
@@ -135,6 +195,26 @@ const searchPageSize = 1000
 
 // Note: if you want to browse LDAP on the Windows machine, run ADSIEdit.msc.
 
+const (
+	// WriteOpCreate indicates that an LDAP write added a new entry.
+	WriteOpCreate = "create"
+	// WriteOpUpdate indicates that an LDAP write modified an existing entry.
+	WriteOpUpdate = "update"
+)
+
+// LDAPWrite describes a single write operation performed against the LDAP
+// server. It deliberately carries only the operation, the target DN and the
+// result - never attribute values - so that it can be reported to the audit
+// log without leaking certificate or CRL contents.
+type LDAPWrite struct {
+	// DN is the distinguished name of the entry that was written.
+	DN string
+	// Op is the operation performed, one of [WriteOpCreate] or [WriteOpUpdate].
+	Op string
+	// Err is the result of the operation (nil on success).
+	Err error
+}
+
 // LDAPClient is a windows LDAP client.
 //
 // It does not automatically detect when the underlying connection
@@ -144,10 +224,77 @@ type LDAPClient struct {
 	// Cfg is the LDAPConfig
 	Cfg LDAPConfig
 
+	// Log is an optional logger. When set to debug level, every LDAP
+	// add/modify/search is logged with its parameters, which helps when
+	// diagnosing Active Directory issues.
+	Log logrus.FieldLogger
+
+	// OnWrite is an optional callback invoked after every LDAP write
+	// operation, whether it succeeded or not. It is used to report writes
+	// made against Active Directory to the audit log.
+	OnWrite func(LDAPWrite)
+
+	// MaxConcurrentRequests caps the number of LDAP operations issued to
+	// the server at the same time. Operations over the limit block until a
+	// slot frees up. Values below 1 are treated as 1, which preserves the
+	// historical behavior of fully serialized requests.
+	MaxConcurrentRequests int
+
+	// semOnce initializes sem on first use, after which
+	// MaxConcurrentRequests must not be changed.
+	semOnce sync.Once
+	// sem is a semaphore limiting in-flight LDAP operations.
+	sem chan struct{}
+
+	// mu guards client.
 	mu     sync.Mutex
 	client ldap.Client
 }
 
+// acquire reserves a slot in the concurrency limiter, blocking if too many
+// operations are already in flight, and returns the current client along
+// with a function releasing the slot.
+func (c *LDAPClient) acquire() (ldap.Client, func()) {
+	c.semOnce.Do(func() {
+		limit := c.MaxConcurrentRequests
+		if limit < 1 {
+			limit = 1
+		}
+		c.sem = make(chan struct{}, limit)
+	})
+	c.sem <- struct{}{}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.client, func() { <-c.sem }
+}
+
+// logger returns the configured logger, falling back to the standard one.
+func (c *LDAPClient) logger() logrus.FieldLogger {
+	if c.Log != nil {
+		return c.Log
+	}
+	return logrus.StandardLogger()
+}
+
+// reportWrite invokes the OnWrite callback, if one is set.
+func (c *LDAPClient) reportWrite(dn, op string, err error) {
+	if c.OnWrite != nil {
+		c.OnWrite(LDAPWrite{DN: dn, Op: op, Err: err})
+	}
+}
+
+// attributeSizes returns the total value size per attribute, for logging.
+func attributeSizes(attrs map[string][]string) map[string]int {
+	sizes := make(map[string]int, len(attrs))
+	for name, values := range attrs {
+		for _, v := range values {
+			sizes[name] += len(v)
+		}
+	}
+	return sizes
+}
+
 // SetClient sets the underlying ldap.Client
 func (c *LDAPClient) SetClient(client ldap.Client) {
 	c.mu.Lock()
@@ -188,6 +335,8 @@ func convertLDAPError(err error) error {
 					"the LDAP server did not accept Teleport's client certificate, " +
 						"has the Teleport CA been imported correctly?")
 			}
+		case ldap.LDAPResultInvalidCredentials:
+			return trace.AccessDenied("invalid credentials: %v", err)
 		case ldap.LDAPResultEntryAlreadyExists:
 			return trace.AlreadyExists("LDAP object already exists: %v", err)
 		case ldap.LDAPResultConstraintViolation:
@@ -214,10 +363,16 @@ func (c *LDAPClient) ReadWithFilter(dn string, filter string, attrs []string) ([
 		attrs,
 		nil, // no Controls
 	)
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	c.logger().WithFields(logrus.Fields{
+		"dn":     dn,
+		"filter": filter,
+		"attrs":  attrs,
+	}).Debug("LDAP search")
 
-	res, err := c.client.SearchWithPaging(req, searchPageSize)
+	client, release := c.acquire()
+	defer release()
+
+	res, err := client.SearchWithPaging(req, searchPageSize)
 	if err != nil {
 		return nil, trace.Wrap(convertLDAPError(err), "fetching LDAP object %q with filter %q", dn, filter)
 	}
@@ -252,12 +407,21 @@ func (c *LDAPClient) Create(dn string, class string, attrs map[string][]string)
 	}
 	req.Attribute("objectClass", []string{class})
 
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	c.logger().WithFields(logrus.Fields{
+		"dn":          dn,
+		"objectClass": class,
+		"attr_sizes":  attributeSizes(attrs),
+	}).Debug("LDAP add")
+
+	client, release := c.acquire()
+	defer release()
 
-	if err := c.client.Add(req); err != nil {
-		return trace.Wrap(convertLDAPError(err), "error creating LDAP object %q", dn)
+	if err := client.Add(req); err != nil {
+		err = convertLDAPError(err)
+		c.reportWrite(dn, WriteOpCreate, err)
+		return trace.Wrap(err, "error creating LDAP object %q", dn)
 	}
+	c.reportWrite(dn, WriteOpCreate, nil)
 	return nil
 }
 
@@ -287,12 +451,20 @@ func (c *LDAPClient) Update(dn string, replaceAttrs map[string][]string) error {
 		req.Replace(k, v)
 	}
 
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	c.logger().WithFields(logrus.Fields{
+		"dn":         dn,
+		"attr_sizes": attributeSizes(replaceAttrs),
+	}).Debug("LDAP modify")
+
+	client, release := c.acquire()
+	defer release()
 
-	if err := c.client.Modify(req); err != nil {
-		return trace.Wrap(convertLDAPError(err), "updating %q", dn)
+	if err := client.Modify(req); err != nil {
+		err = convertLDAPError(err)
+		c.reportWrite(dn, WriteOpUpdate, err)
+		return trace.Wrap(err, "updating %q", dn)
 	}
+	c.reportWrite(dn, WriteOpUpdate, nil)
 	return nil
 }
 
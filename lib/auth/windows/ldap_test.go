@@ -0,0 +1,123 @@
+// Teleport
+// Copyright (C) 2024 Gravitational, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package windows
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// nopLDAPClient accepts any add request without talking to a server.
+type nopLDAPClient struct {
+	ldap.Client
+}
+
+func (c *nopLDAPClient) Add(req *ldap.AddRequest) error { return nil }
+
+func (c *nopLDAPClient) Close() error { return nil }
+
+// TestLDAPDebugLogging verifies that LDAP adds are logged at debug level
+// with the DN, object class and attribute sizes being written.
+func TestLDAPDebugLogging(t *testing.T) {
+	const dn = "CN=Teleport,CN=CDP,CN=Public Key Services,CN=Services,CN=Configuration,DC=example,DC=com"
+
+	logger, hook := logtest.NewNullLogger()
+	logger.SetLevel(logrus.DebugLevel)
+
+	lc := &LDAPClient{Log: logger}
+	lc.SetClient(&nopLDAPClient{})
+
+	require.NoError(t, lc.Create(dn, ClassContainer, map[string][]string{
+		"certificateRevocationList": {"aaaa"},
+	}))
+
+	var entry *logrus.Entry
+	for _, e := range hook.AllEntries() {
+		if e.Message == "LDAP add" {
+			entry = e
+		}
+	}
+	require.NotNil(t, entry, "expected a debug log entry for the LDAP add")
+	require.Equal(t, logrus.DebugLevel, entry.Level)
+	require.Equal(t, dn, entry.Data["dn"])
+	require.Equal(t, ClassContainer, entry.Data["objectClass"])
+	require.Equal(t, map[string]int{"certificateRevocationList": 4}, entry.Data["attr_sizes"])
+}
+
+// blockingLDAPClient tracks how many Add calls are in flight at once and
+// blocks each one until the test releases them.
+type blockingLDAPClient struct {
+	ldap.Client
+
+	inFlight atomic.Int32
+	peak     atomic.Int32
+	release  chan struct{}
+}
+
+func (c *blockingLDAPClient) Add(req *ldap.AddRequest) error {
+	n := c.inFlight.Add(1)
+	defer c.inFlight.Add(-1)
+	for {
+		peak := c.peak.Load()
+		if n <= peak || c.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	<-c.release
+	return nil
+}
+
+func (c *blockingLDAPClient) Close() error { return nil }
+
+// TestLDAPMaxConcurrentRequests verifies that the client never issues more
+// than the configured number of simultaneous LDAP operations, queuing the
+// rest.
+func TestLDAPMaxConcurrentRequests(t *testing.T) {
+	const limit = 2
+	const ops = 8
+
+	fake := &blockingLDAPClient{release: make(chan struct{})}
+	lc := &LDAPClient{MaxConcurrentRequests: limit}
+	lc.SetClient(fake)
+
+	var wg sync.WaitGroup
+	for i := 0; i < ops; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, lc.Create("CN=test,DC=example,DC=com", ClassContainer, nil))
+		}()
+	}
+
+	// Wait for the limiter to fill up, then let all operations through.
+	require.Eventually(t, func() bool {
+		return fake.inFlight.Load() == limit
+	}, 5*time.Second, 10*time.Millisecond)
+	close(fake.release)
+	wg.Wait()
+
+	require.Equal(t, int32(limit), fake.peak.Load(),
+		"expected exactly %d operations in flight at peak", limit)
+}
@@ -0,0 +1,170 @@
+/*
+ * Teleport
+ * Copyright (C) 2024  Gravitational, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package windows
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"strings"
+
+	"github.com/gravitational/trace"
+
+	"github.com/gravitational/teleport/api/types"
+	"github.com/gravitational/teleport/lib/tlsca"
+)
+
+// ntAuthDN returns the distinguished name of the NTAuthCertificates entry
+// of the domain, which holds the CA certificates trusted for smart card
+// authentication.
+func ntAuthDN(cfg LDAPConfig) string {
+	return "CN=NTAuthCertificates,CN=Public Key Services,CN=Services,CN=Configuration," + cfg.DomainDN()
+}
+
+// SyncNTAuth reconciles the NTAuthCertificates entry with the active user
+// CA of the cluster. Certificates rotated in are published right away,
+// while certificates of this cluster's CA that were rotated out are only
+// removed once the rotation grace period has passed, so that user
+// certificates issued by the previous CA keep working for the remainder of
+// the rotation. Certificates of other issuers are left untouched.
+//
+// In read-only mode no writes are performed; instead SyncNTAuth verifies
+// that the active CA certificates have been imported out of band.
+func (c *CertificateStoreClient) SyncNTAuth(ctx context.Context) error {
+	ca, err := c.cfg.AccessPoint.GetCertAuthority(ctx, types.CertAuthID{
+		Type:       types.UserCA,
+		DomainName: c.cfg.ClusterName,
+	}, false)
+	if err != nil {
+		return trace.Wrap(err, "fetching user CA")
+	}
+
+	var active [][]byte
+	for _, keyPair := range ca.GetTrustedTLSKeyPairs() {
+		cert, err := tlsca.ParseCertificatePEM(keyPair.Cert)
+		if err != nil {
+			return trace.Wrap(err, "parsing user CA certificate")
+		}
+		active = append(active, cert.Raw)
+	}
+
+	dn := ntAuthDN(c.cfg.LDAPConfig)
+	published, err := c.readNTAuth(dn)
+	if err != nil && !trace.IsNotFound(err) {
+		return trace.Wrap(err)
+	}
+	entryMissing := trace.IsNotFound(err)
+
+	if c.cfg.ReadOnly {
+		for _, der := range active {
+			if !containsCert(published, der) {
+				return trace.BadParameter(
+					"LDAP is read-only and the cluster CA is not present in %q; import the CA into the NTAuth store out of band",
+					dn)
+			}
+		}
+		c.cfg.Log.Info("LDAP is read-only, verified that the cluster CA is present in the NTAuth store")
+		return nil
+	}
+
+	keep, removed := c.retainedNTAuthCerts(ca, published, active)
+
+	var added int
+	for _, der := range active {
+		if !containsCert(keep, der) {
+			keep = append(keep, der)
+			added++
+		}
+	}
+
+	if added == 0 && removed == 0 && !entryMissing {
+		return nil
+	}
+
+	values := make([]string, 0, len(keep))
+	for _, der := range keep {
+		values = append(values, string(der))
+	}
+	attrs := map[string][]string{AttrCACertificate: values}
+	if entryMissing {
+		if err := c.cfg.LC.Create(dn, ClassCertificationAuthority, attrs); err != nil {
+			return trace.Wrap(err, "creating NTAuth store %q", dn)
+		}
+	} else if err := c.cfg.LC.Update(dn, attrs); err != nil {
+		return trace.Wrap(err, "updating NTAuth store %q", dn)
+	}
+	c.cfg.Log.Infof("Published the cluster CA to the NTAuth store (%v added, %v superseded removed)", added, removed)
+	return nil
+}
+
+// retainedNTAuthCerts decides which of the already-published certificates
+// stay in the NTAuth set. Certificates of other issuers always stay.
+// Certificates of this cluster's CA that are no longer active stay only
+// until the rotation grace period expires.
+func (c *CertificateStoreClient) retainedNTAuthCerts(ca types.CertAuthority, published, active [][]byte) (keep [][]byte, removed int) {
+	rotation := ca.GetRotation()
+	graceDeadline := rotation.LastRotated.Add(rotation.GracePeriod.Duration())
+
+	for _, der := range published {
+		if containsCert(active, der) {
+			keep = append(keep, der)
+			continue
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil || cert.Subject.CommonName != c.cfg.ClusterName {
+			// Not a certificate this cluster manages.
+			keep = append(keep, der)
+			continue
+		}
+		if c.cfg.Clock.Now().Before(graceDeadline) {
+			// Superseded by a rotation, but user certificates issued by the
+			// previous CA may still be in use until the grace period ends.
+			keep = append(keep, der)
+			continue
+		}
+		removed++
+		c.cfg.Log.Infof("Removing superseded CA certificate (serial %v) from the NTAuth store", cert.SerialNumber)
+	}
+	return keep, removed
+}
+
+// readNTAuth reads the raw certificates currently published at the NTAuth
+// entry. It returns a NotFound error when the entry does not exist.
+func (c *CertificateStoreClient) readNTAuth(dn string) ([][]byte, error) {
+	entries, err := c.cfg.LC.Read(dn, ClassCertificationAuthority, []string{AttrCACertificate})
+	if err != nil {
+		return nil, trace.Wrap(err, "reading NTAuth store %q", dn)
+	}
+	for _, entry := range entries {
+		if !strings.EqualFold(entry.DN, dn) {
+			continue
+		}
+		return entry.GetRawAttributeValues(AttrCACertificate), nil
+	}
+	return nil, trace.NotFound("NTAuth store %q not found", dn)
+}
+
+func containsCert(certs [][]byte, der []byte) bool {
+	for _, cert := range certs {
+		if bytes.Equal(cert, der) {
+			return true
+		}
+	}
+	return false
+}
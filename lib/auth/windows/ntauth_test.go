@@ -0,0 +1,225 @@
+/*
+ * Teleport
+ * Copyright (C) 2024  Gravitational, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package windows
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/jonboulle/clockwork"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gravitational/teleport/api/types"
+	"github.com/gravitational/teleport/lib/auth"
+)
+
+// ntAuthTestAccessPoint serves a single canned certificate authority.
+type ntAuthTestAccessPoint struct {
+	auth.WindowsDesktopAccessPoint
+
+	ca types.CertAuthority
+}
+
+func (a *ntAuthTestAccessPoint) GetCertAuthority(ctx context.Context, id types.CertAuthID, loadKeys bool) (types.CertAuthority, error) {
+	return a.ca, nil
+}
+
+// ntAuthTestClient serves canned entries for searches and records the
+// attribute values of writes against the NTAuth entry.
+type ntAuthTestClient struct {
+	ldap.Client
+
+	entries []*ldap.Entry
+
+	addedCerts    []string
+	modifiedCerts []string
+}
+
+func (c *ntAuthTestClient) SearchWithPaging(req *ldap.SearchRequest, pageSize uint32) (*ldap.SearchResult, error) {
+	return &ldap.SearchResult{Entries: c.entries}, nil
+}
+
+func (c *ntAuthTestClient) Add(req *ldap.AddRequest) error {
+	for _, attr := range req.Attributes {
+		if attr.Type == AttrCACertificate {
+			c.addedCerts = attr.Vals
+		}
+	}
+	return nil
+}
+
+func (c *ntAuthTestClient) Modify(req *ldap.ModifyRequest) error {
+	for _, change := range req.Changes {
+		if change.Modification.Type == AttrCACertificate {
+			c.modifiedCerts = change.Modification.Vals
+		}
+	}
+	return nil
+}
+
+func (c *ntAuthTestClient) Close() error { return nil }
+
+// makeTestCACert builds a self-signed CA certificate whose subject common
+// name matches the given cluster name. It returns the DER and PEM
+// encodings.
+func makeTestCACert(t *testing.T, commonName string) ([]byte, []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: commonName},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	return der, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestSyncNTAuth(t *testing.T) {
+	cfg := LDAPConfig{Domain: "test.example.com"}
+	dn := ntAuthDN(cfg)
+	clock := clockwork.NewFakeClockAt(time.Now())
+
+	activeDER, activePEM := makeTestCACert(t, "example")
+	supersededDER, _ := makeTestCACert(t, "example")
+	foreignDER, _ := makeTestCACert(t, "other-issuer")
+
+	newCA := func(t *testing.T, lastRotated time.Time, gracePeriod time.Duration) types.CertAuthority {
+		ca, err := types.NewCertAuthority(types.CertAuthoritySpecV2{
+			Type:        types.UserCA,
+			ClusterName: "example",
+			ActiveKeys: types.CAKeySet{
+				TLS: []*types.TLSKeyPair{{Cert: activePEM}},
+			},
+			Rotation: &types.Rotation{
+				LastRotated: lastRotated,
+				GracePeriod: types.Duration(gracePeriod),
+			},
+		})
+		require.NoError(t, err)
+		return ca
+	}
+
+	ntAuthEntry := func(certs ...[]byte) *ldap.Entry {
+		values := make([][]byte, 0, len(certs))
+		values = append(values, certs...)
+		return &ldap.Entry{
+			DN: dn,
+			Attributes: []*ldap.EntryAttribute{{
+				Name:       AttrCACertificate,
+				ByteValues: values,
+			}},
+		}
+	}
+
+	newStoreClient := func(ca types.CertAuthority, client *ntAuthTestClient) *CertificateStoreClient {
+		lc := &LDAPClient{}
+		lc.SetClient(client)
+		return NewCertificateStoreClient(CertificateStoreConfig{
+			AccessPoint:   &ntAuthTestAccessPoint{ca: ca},
+			LDAPConfig:    cfg,
+			Log:           logrus.New(),
+			ClusterName:   "example",
+			LC:            lc,
+			PublishNTAuth: true,
+			Clock:         clock,
+		})
+	}
+
+	t.Run("missing CA is published, foreign certs stay", func(t *testing.T) {
+		client := &ntAuthTestClient{entries: []*ldap.Entry{ntAuthEntry(foreignDER)}}
+		c := newStoreClient(newCA(t, time.Time{}, 0), client)
+
+		require.NoError(t, c.SyncNTAuth(context.Background()))
+		require.Equal(t, []string{string(foreignDER), string(activeDER)}, client.modifiedCerts)
+	})
+
+	t.Run("missing NTAuth entry is created", func(t *testing.T) {
+		client := &ntAuthTestClient{}
+		c := newStoreClient(newCA(t, time.Time{}, 0), client)
+
+		require.NoError(t, c.SyncNTAuth(context.Background()))
+		require.Equal(t, []string{string(activeDER)}, client.addedCerts)
+	})
+
+	t.Run("up-to-date store is left alone", func(t *testing.T) {
+		client := &ntAuthTestClient{entries: []*ldap.Entry{ntAuthEntry(activeDER, foreignDER)}}
+		c := newStoreClient(newCA(t, time.Time{}, 0), client)
+
+		require.NoError(t, c.SyncNTAuth(context.Background()))
+		require.Nil(t, client.modifiedCerts)
+		require.Nil(t, client.addedCerts)
+	})
+
+	t.Run("rotation publishes the new CA and keeps the old during grace", func(t *testing.T) {
+		client := &ntAuthTestClient{entries: []*ldap.Entry{ntAuthEntry(supersededDER)}}
+		c := newStoreClient(newCA(t, clock.Now(), time.Hour), client)
+
+		require.NoError(t, c.SyncNTAuth(context.Background()))
+		require.Equal(t, []string{string(supersededDER), string(activeDER)}, client.modifiedCerts)
+	})
+
+	t.Run("superseded cert is removed after the grace period", func(t *testing.T) {
+		client := &ntAuthTestClient{entries: []*ldap.Entry{ntAuthEntry(supersededDER, foreignDER)}}
+		c := newStoreClient(newCA(t, clock.Now().Add(-2*time.Hour), time.Hour), client)
+
+		require.NoError(t, c.SyncNTAuth(context.Background()))
+		require.Equal(t, []string{string(foreignDER), string(activeDER)}, client.modifiedCerts)
+	})
+
+	t.Run("read-only mode verifies instead of writing", func(t *testing.T) {
+		client := &ntAuthTestClient{entries: []*ldap.Entry{ntAuthEntry(foreignDER)}}
+		lc := &LDAPClient{}
+		lc.SetClient(client)
+		c := NewCertificateStoreClient(CertificateStoreConfig{
+			AccessPoint:   &ntAuthTestAccessPoint{ca: newCA(t, time.Time{}, 0)},
+			LDAPConfig:    cfg,
+			Log:           logrus.New(),
+			ClusterName:   "example",
+			LC:            lc,
+			PublishNTAuth: true,
+			ReadOnly:      true,
+			Clock:         clock,
+		})
+
+		err := c.SyncNTAuth(context.Background())
+		require.ErrorContains(t, err, "read-only")
+		require.Nil(t, client.modifiedCerts)
+		require.Nil(t, client.addedCerts)
+	})
+}
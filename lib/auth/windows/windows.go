@@ -28,6 +28,9 @@ import (
 	"encoding/json"
 	"encoding/pem"
 	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gravitational/trace"
@@ -46,6 +49,59 @@ const (
 	CertTTL = 5 * time.Minute
 )
 
+// CertValidityMode controls how the validity window requested for a desktop
+// certificate is derived from the TTL and skew allowance.
+type CertValidityMode string
+
+const (
+	// CertValidityExact requests the TTL and skew allowance as given.
+	// It is the default.
+	CertValidityExact CertValidityMode = "exact"
+	// CertValidityRoundMinute rounds the TTL and skew allowance up to whole
+	// minutes, for compliance regimes that want validity windows aligned to
+	// minute boundaries. The certificate API expresses the window as
+	// durations relative to issuance time, so the rounding applies to the
+	// durations rather than to absolute timestamps.
+	CertValidityRoundMinute CertValidityMode = "round-minute"
+)
+
+// CheckCertValidityMode verifies that mode is a known certificate validity
+// mode. The zero value is allowed and means CertValidityExact.
+func CheckCertValidityMode(mode CertValidityMode) error {
+	switch mode {
+	case "", CertValidityExact, CertValidityRoundMinute:
+		return nil
+	default:
+		return trace.BadParameter("unsupported certificate validity mode %q", mode)
+	}
+}
+
+// requestedValidity computes the TTL and skew allowance to request from the
+// auth server according to req.ValidityMode.
+func requestedValidity(req *GenerateCredentialsRequest) (ttl, skew time.Duration, err error) {
+	if req.SkewAllowance < 0 {
+		return 0, 0, trace.BadParameter("certificate skew allowance must not be negative, got %v", req.SkewAllowance)
+	}
+	switch req.ValidityMode {
+	case "", CertValidityExact:
+		return req.TTL, req.SkewAllowance, nil
+	case CertValidityRoundMinute:
+		if req.TTL <= 0 {
+			return 0, 0, trace.BadParameter("certificate validity mode %q requires an explicit TTL", req.ValidityMode)
+		}
+		return roundUpToMinute(req.TTL), roundUpToMinute(req.SkewAllowance), nil
+	default:
+		return 0, 0, trace.BadParameter("unsupported certificate validity mode %q", req.ValidityMode)
+	}
+}
+
+func roundUpToMinute(d time.Duration) time.Duration {
+	if rem := d % time.Minute; rem != 0 {
+		return d - rem + time.Minute
+	}
+	return d
+}
+
 type certRequest struct {
 	csrPEM      []byte
 	crlEndpoint string
@@ -66,7 +122,41 @@ func createUsersExtension(groups []string) (pkix.Extension, error) {
 	}, nil
 }
 
+// desktopScopeExtension encodes the name of the desktop the certificate is
+// restricted to. The auth server copies CSR extensions into the certificate
+// verbatim, so the issued credential stays visibly tied to its target.
+func desktopScopeExtension(desktop string) (pkix.Extension, error) {
+	value, err := json.Marshal(struct {
+		Desktop string `json:"desktop"`
+	}{desktop})
+	if err != nil {
+		return pkix.Extension{}, trace.Wrap(err)
+	}
+	return pkix.Extension{
+		Id:    tlsca.DesktopScopeExtensionOID,
+		Value: value,
+	}, nil
+}
+
+// CheckSignatureAlgorithm verifies that alg can sign a CSR backed by the
+// RSA key used for desktop certificates. The zero value is allowed and
+// leaves the choice to crypto/x509.
+func CheckSignatureAlgorithm(alg x509.SignatureAlgorithm) error {
+	switch alg {
+	case x509.UnknownSignatureAlgorithm,
+		x509.SHA256WithRSA, x509.SHA384WithRSA, x509.SHA512WithRSA,
+		x509.SHA256WithRSAPSS, x509.SHA384WithRSAPSS, x509.SHA512WithRSAPSS:
+		return nil
+	default:
+		return trace.BadParameter("signature algorithm %v is not compatible with the RSA key used for desktop certificates", alg)
+	}
+}
+
 func getCertRequest(req *GenerateCredentialsRequest) (*certRequest, error) {
+	if err := CheckSignatureAlgorithm(req.SignatureAlgorithm); err != nil {
+		return nil, trace.Wrap(err)
+	}
+
 	// Important: rdpclient currently only supports 2048-bit RSA keys.
 	// If you switch the key type here, update handle_general_authentication in
 	// rdp/rdpclient/src/piv.rs accordingly.
@@ -80,12 +170,20 @@ func getCertRequest(req *GenerateCredentialsRequest) (*certRequest, error) {
 	// Generate the Windows-compatible certificate, see
 	// https://docs.microsoft.com/en-us/troubleshoot/windows-server/windows-security/enabling-smart-card-logon-third-party-certification-authorities
 	// for requirements.
-	san, err := SubjectAltNameExtension(req.Username, req.Domain)
+	sanOID := UPNOtherNameOID
+	if req.SANOtherNameOID != "" {
+		sanOID, err = ParseOID(req.SANOtherNameOID)
+		if err != nil {
+			return nil, trace.BadParameter("invalid SAN otherName OID: %v", err)
+		}
+	}
+	san, err := subjectAltNameExtension(req.Username, req.Domain, sanOID)
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
 	csr := &x509.CertificateRequest{
-		Subject: pkix.Name{CommonName: req.Username},
+		Subject:            pkix.Name{CommonName: req.Username},
+		SignatureAlgorithm: req.SignatureAlgorithm,
 		// We have to pass SAN and ExtKeyUsage as raw extensions because
 		// crypto/x509 doesn't support what we need:
 		// - x509.ExtKeyUsage doesn't have the Smartcard Logon variant
@@ -123,26 +221,150 @@ func getCertRequest(req *GenerateCredentialsRequest) (*certRequest, error) {
 		})
 	}
 
+	if len(req.ExtraCDPs) > 0 {
+		for _, cdp := range req.ExtraCDPs {
+			u, err := url.Parse(cdp)
+			if err != nil {
+				return nil, trace.BadParameter("invalid CRL distribution point %q: %v", cdp, err)
+			}
+			if u.Scheme == "" {
+				return nil, trace.BadParameter("CRL distribution point %q is missing a scheme", cdp)
+			}
+		}
+		// The cert generation API carries a single CRL endpoint, so when
+		// there are extra distribution points the complete list rides in
+		// the CSR instead. The auth server copies CSR extensions into the
+		// certificate verbatim, and the presence of this extension keeps
+		// it from adding a second one for the single endpoint.
+		cdps, err := crlDistributionPointsExtension(append([]string{crlEndpoint(req)}, req.ExtraCDPs...))
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		csr.ExtraExtensions = append(csr.ExtraExtensions, cdps)
+	}
+
+	if req.CertificateTemplateOID != "" {
+		template, err := certificateTemplateExtension(req.CertificateTemplateOID)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		csr.ExtraExtensions = append(csr.ExtraExtensions, template)
+	}
+
+	if req.DesktopScope != "" {
+		scope, err := desktopScopeExtension(req.DesktopScope)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		csr.ExtraExtensions = append(csr.ExtraExtensions, scope)
+	}
+
 	csrBytes, err := x509.CreateCertificateRequest(rand.Reader, csr, rsaKey)
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
 	csrPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrBytes})
-	// Note: this CRL DN may or may not be the same DN published in updateCRL.
-	//
-	// There can be multiple AD domains connected to Teleport. Each
-	// windows_desktop_service is connected to a single AD domain and publishes
-	// CRLs in it. Each service can also handle RDP connections for a different
-	// domain, with the assumption that some other windows_desktop_service
-	// published a CRL there.
-	crlDN := crlDN(req.ClusterName, req.LDAPConfig, req.CAType)
 	return &certRequest{
 		csrPEM:      csrPEM,
-		crlEndpoint: fmt.Sprintf("ldap:///%s?certificateRevocationList?base?objectClass=cRLDistributionPoint", crlDN),
+		crlEndpoint: crlEndpoint(req),
 		keyDER:      keyDER,
 	}, nil
 }
 
+// crlEndpoint returns the LDAP URI pointing at the CRL that Teleport
+// publishes with updateCRL.
+//
+// Note: this CRL DN may or may not be the same DN published in updateCRL.
+//
+// There can be multiple AD domains connected to Teleport. Each
+// windows_desktop_service is connected to a single AD domain and publishes
+// CRLs in it. Each service can also handle RDP connections for a different
+// domain, with the assumption that some other windows_desktop_service
+// published a CRL there.
+func crlEndpoint(req *GenerateCredentialsRequest) string {
+	crlDN := crlDN(req.ClusterName, req.LDAPConfig, req.CAType)
+	return fmt.Sprintf("ldap:///%s?certificateRevocationList?base?objectClass=cRLDistributionPoint", crlDN)
+}
+
+// crlDistributionPointsExtension builds a CRL distribution points extension
+// containing a distribution point for each of the given endpoints, so that
+// Windows can fall back among them when checking revocation.
+func crlDistributionPointsExtension(endpoints []string) (pkix.Extension, error) {
+	points := make([]distributionPoint, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		points = append(points, distributionPoint{
+			DistributionPoint: distributionPointName{
+				FullName: []asn1.RawValue{
+					{Class: asn1.ClassContextSpecific, Tag: 6, Bytes: []byte(endpoint)},
+				},
+			},
+		})
+	}
+	value, err := asn1.Marshal(points)
+	if err != nil {
+		return pkix.Extension{}, trace.Wrap(err)
+	}
+	return pkix.Extension{Id: CRLDistributionPointsOID, Value: value}, nil
+}
+
+// ParseOID parses a dotted-decimal object identifier such as
+// "1.3.6.1.4.1.311.20.2.3".
+func ParseOID(oid string) (asn1.ObjectIdentifier, error) {
+	parts := strings.Split(oid, ".")
+	if len(parts) < 2 {
+		return nil, trace.BadParameter("OID %q must contain at least two components", oid)
+	}
+	id := make(asn1.ObjectIdentifier, 0, len(parts))
+	for _, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil, trace.BadParameter("OID %q contains an invalid component %q", oid, part)
+		}
+		id = append(id, n)
+	}
+	return id, nil
+}
+
+// ParseCertificateTemplateOID parses a dotted-decimal Active Directory
+// certificate template object identifier, such as
+// "1.3.6.1.4.1.311.21.8.12345678.1234567.1234567.1234567.1234.1.1234567".
+func ParseCertificateTemplateOID(oid string) (asn1.ObjectIdentifier, error) {
+	id, err := ParseOID(oid)
+	if err != nil {
+		return nil, trace.BadParameter("invalid certificate template OID: %v", err)
+	}
+	return id, nil
+}
+
+// certificateTemplateExtension builds a szOID_CERTIFICATE_TEMPLATE extension
+// identifying the AD certificate template with the given OID, in the
+// CertificateTemplateOID format from MS-WCCE section 2.2.2.7.7.2. The
+// optional template version numbers are omitted.
+func certificateTemplateExtension(oid string) (pkix.Extension, error) {
+	id, err := ParseCertificateTemplateOID(oid)
+	if err != nil {
+		return pkix.Extension{}, trace.Wrap(err)
+	}
+	value, err := asn1.Marshal(struct {
+		TemplateID asn1.ObjectIdentifier
+	}{id})
+	if err != nil {
+		return pkix.Extension{}, trace.Wrap(err)
+	}
+	return pkix.Extension{Id: CertificateTemplateExtensionOID, Value: value}, nil
+}
+
+// Types for ASN.1 CRL distribution point serialization, mirroring the
+// layout in RFC 5280 section 4.2.1.13.
+
+type distributionPoint struct {
+	DistributionPoint distributionPointName `asn1:"optional,tag:0"`
+}
+
+type distributionPointName struct {
+	FullName []asn1.RawValue `asn1:"optional,tag:0"`
+}
+
 // AuthInterface is a subset of auth.ClientI
 type AuthInterface interface {
 	// GenerateDatabaseCert generates a database certificate for windows SQL Server
@@ -164,6 +386,12 @@ type GenerateCredentialsRequest struct {
 	Domain string
 	// TTL is the ttl for the certificate
 	TTL time.Duration
+	// SkewAllowance is the amount of time the certificate's NotBefore time
+	// is backdated to tolerate minor clock skew on the Windows host
+	SkewAllowance time.Duration
+	// ValidityMode controls how the requested validity window is computed
+	// from TTL and SkewAllowance. The zero value is CertValidityExact.
+	ValidityMode CertValidityMode
 	// ClusterName is the local cluster name
 	ClusterName string
 	// ActiveDirectorySID is the SID of the Windows user
@@ -177,6 +405,31 @@ type GenerateCredentialsRequest struct {
 	// CAType is the certificate authority type used to generate the certificate.
 	// This is used to proper generate the CRL LDAP path.
 	CAType types.CertAuthType
+	// ExtraCDPs are additional CRL distribution points to include in the
+	// issued certificate alongside the Teleport-published one, for example
+	// the CDP of a native Active Directory CA. Windows falls back among
+	// the listed distribution points when checking revocation.
+	ExtraCDPs []string
+	// SignatureAlgorithm is the algorithm used to sign the CSR, for CAs or
+	// policies that require a specific digest. It must be compatible with
+	// the RSA key backing the certificate. When unset, crypto/x509 picks
+	// its default for the key type (SHA256WithRSA).
+	SignatureAlgorithm x509.SignatureAlgorithm
+	// CertificateTemplateOID is the dotted-decimal object identifier of an
+	// Active Directory certificate template. When set, it is encoded in the
+	// szOID_CERTIFICATE_TEMPLATE extension of the CSR, for AD CAs that map
+	// smartcard logon certificates to a template.
+	CertificateTemplateOID string
+	// SANOtherNameOID is the dotted-decimal object identifier of the
+	// otherName SAN entry carrying the user identity, for AD setups that
+	// map identities by a non-standard OID. When empty, the standard UPN
+	// OID is used.
+	SANOtherNameOID string
+	// DesktopScope restricts the issued certificate to a single desktop.
+	// When set (!= ""), the desktop name is embedded in a Teleport-specific
+	// certificate extension, tying the short-lived credential to its target
+	// so that it cannot be replayed against other desktops.
+	DesktopScope string
 	// CreateUser specifies if Windows user should be created if missing
 	CreateUser bool
 	// Groups are groups that user should be member of
@@ -189,6 +442,10 @@ type GenerateCredentialsRequest struct {
 // Directory. See:
 // https://docs.microsoft.com/en-us/windows/security/identity-protection/smart-cards/smart-card-certificate-requirements-and-enumeration
 func GenerateWindowsDesktopCredentials(ctx context.Context, req *GenerateCredentialsRequest) (certDER, keyDER []byte, err error) {
+	ttl, skew, err := requestedValidity(req)
+	if err != nil {
+		return nil, nil, trace.Wrap(err)
+	}
 	certReq, err := getCertRequest(req)
 	if err != nil {
 		return nil, nil, trace.Wrap(err)
@@ -203,8 +460,9 @@ func GenerateWindowsDesktopCredentials(ctx context.Context, req *GenerateCredent
 		// Using ldap:///distinguished_name_and_parameters (with empty
 		// domain_controller_addr) will cause Windows to fetch the CRL from any
 		// of its current domain controllers.
-		CRLEndpoint: certReq.crlEndpoint,
-		TTL:         proto.Duration(req.TTL),
+		CRLEndpoint:   certReq.crlEndpoint,
+		TTL:           proto.Duration(ttl),
+		SkewAllowance: proto.Duration(skew),
 	})
 	if err != nil {
 		return nil, nil, trace.Wrap(err)
@@ -274,6 +532,10 @@ var (
 	// certificate's subject alternative name extension
 	SubjectAltNameExtensionOID = asn1.ObjectIdentifier{2, 5, 29, 17}
 
+	// CRLDistributionPointsOID is the object identifier for a
+	// certificate's CRL distribution points extension
+	CRLDistributionPointsOID = asn1.ObjectIdentifier{2, 5, 29, 31}
+
 	// ClientAuthenticationOID is the object idnetifier that is used to
 	// include client SSL authentication in a certificate's enhanced
 	// key usage
@@ -287,6 +549,11 @@ var (
 	// the user principal name in a certificate's subject alternative name
 	UPNOtherNameOID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 20, 2, 3}
 
+	// CertificateTemplateExtensionOID is the object identifier of the
+	// szOID_CERTIFICATE_TEMPLATE extension, which names the Active
+	// Directory certificate template a certificate was issued from.
+	CertificateTemplateExtensionOID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 21, 7}
+
 	// ADUserMappingExtensionOID is the Active Directory SID extension for mapping certificates
 	// to their user's Active Directory SID. This value goes in the Id field of the pkix.Extension.
 	// See https://go.microsoft.com/fwlink/?linkid=2189925.
@@ -313,8 +580,15 @@ var EnhancedKeyUsageExtension = pkix.Extension{
 	}(),
 }
 
-// SubjectAltNameExtension fills in the SAN for a Windows certificate
+// SubjectAltNameExtension fills in the SAN for a Windows certificate using
+// the standard UPN otherName OID.
 func SubjectAltNameExtension(user, domain string) (pkix.Extension, error) {
+	return subjectAltNameExtension(user, domain, UPNOtherNameOID)
+}
+
+// subjectAltNameExtension fills in the SAN for a Windows certificate,
+// carrying the user identity in an otherName entry with the given OID.
+func subjectAltNameExtension(user, domain string, oid asn1.ObjectIdentifier) (pkix.Extension, error) {
 	// Setting otherName SAN according to
 	// https://samfira.com/2020/05/16/golang-x-509-certificates-and-othername/
 	//
@@ -325,7 +599,7 @@ func SubjectAltNameExtension(user, domain string) (pkix.Extension, error) {
 	ext.Value, err = asn1.Marshal(
 		SubjectAltName[upn]{
 			OtherName: otherName[upn]{
-				OID: UPNOtherNameOID,
+				OID: oid,
 				Value: upn{
 					Value: fmt.Sprintf("%s@%s", user, domain), // TODO(zmb3): sanitize username to avoid domain spoofing
 				},
@@ -22,13 +22,21 @@ import (
 	"context"
 	"crypto/x509"
 	"encoding/asn1"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/gravitational/trace"
 	"github.com/stretchr/testify/require"
 
+	"github.com/gravitational/teleport/api/client/proto"
 	"github.com/gravitational/teleport/api/types"
 	"github.com/gravitational/teleport/lib/auth"
+	"github.com/gravitational/teleport/lib/services"
+	"github.com/gravitational/teleport/lib/tlsca"
 )
 
 // TestGenerateCredentials verifies that the smartcard certificates generated
@@ -72,6 +80,7 @@ func TestGenerateCredentials(t *testing.T) {
 	for _, test := range []struct {
 		name               string
 		activeDirectorySID string
+		extraCDPs          []string
 	}{
 		{
 			name:               "no ad sid",
@@ -81,6 +90,13 @@ func TestGenerateCredentials(t *testing.T) {
 			name:               "with ad sid",
 			activeDirectorySID: testSid,
 		},
+		{
+			name: "with extra CDPs",
+			extraCDPs: []string{
+				"ldap:///CN=Example CA,CN=CDP,CN=Public Key Services,CN=Services,CN=Configuration,DC=test,DC=example,DC=com?certificateRevocationList?base?objectClass=cRLDistributionPoint",
+				"http://crl.example.com/Example%20CA.crl",
+			},
+		},
 	} {
 		t.Run(test.name, func(t *testing.T) {
 			certb, keyb, err := GenerateWindowsDesktopCredentials(ctx, &GenerateCredentialsRequest{
@@ -91,6 +107,7 @@ func TestGenerateCredentials(t *testing.T) {
 				ActiveDirectorySID: test.activeDirectorySID,
 				LDAPConfig:         ldapConfig,
 				AuthClient:         client,
+				ExtraCDPs:          test.extraCDPs,
 			})
 			require.NoError(t, err)
 			require.NotNil(t, certb)
@@ -103,6 +120,11 @@ func TestGenerateCredentials(t *testing.T) {
 			require.Equal(t, user, cert.Subject.CommonName)
 			require.Contains(t, cert.CRLDistributionPoints,
 				`ldap:///CN=test,CN=Teleport,CN=CDP,CN=Public Key Services,CN=Services,CN=Configuration,DC=test,DC=example,DC=com?certificateRevocationList?base?objectClass=cRLDistributionPoint`)
+			// extra CDPs are carried through to the issued certificate
+			// so that Windows can fall back among them
+			for _, cdp := range test.extraCDPs {
+				require.Contains(t, cert.CRLDistributionPoints, cdp)
+			}
 
 			foundKeyUsage := false
 			foundAltName := false
@@ -141,6 +163,382 @@ func TestGenerateCredentials(t *testing.T) {
 	}
 }
 
+func TestExtraCDPValidation(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		cdp  string
+	}{
+		{
+			name: "not a URI",
+			cdp:  "://bad",
+		},
+		{
+			name: "missing scheme",
+			cdp:  "crl.example.com/example.crl",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := getCertRequest(&GenerateCredentialsRequest{
+				Username:   "test-user",
+				Domain:     "test.example.com",
+				LDAPConfig: LDAPConfig{Domain: "test.example.com"},
+				ExtraCDPs:  []string{test.cdp},
+			})
+			require.Error(t, err)
+			require.True(t, trace.IsBadParameter(err))
+		})
+	}
+}
+
+// TestCSRSignatureAlgorithm verifies that a configured signature algorithm
+// is used to sign the CSR and that algorithms incompatible with the RSA key
+// are rejected.
+func TestCSRSignatureAlgorithm(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		algorithm x509.SignatureAlgorithm
+		wantAlg   x509.SignatureAlgorithm
+		wantErr   bool
+	}{
+		{
+			name:    "default",
+			wantAlg: x509.SHA256WithRSA,
+		},
+		{
+			name:      "SHA384",
+			algorithm: x509.SHA384WithRSA,
+			wantAlg:   x509.SHA384WithRSA,
+		},
+		{
+			name:      "SHA512",
+			algorithm: x509.SHA512WithRSA,
+			wantAlg:   x509.SHA512WithRSA,
+		},
+		{
+			name:      "incompatible with RSA key",
+			algorithm: x509.ECDSAWithSHA256,
+			wantErr:   true,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			certReq, err := getCertRequest(&GenerateCredentialsRequest{
+				Username:           "test-user",
+				Domain:             "test.example.com",
+				LDAPConfig:         LDAPConfig{Domain: "test.example.com"},
+				SignatureAlgorithm: test.algorithm,
+			})
+			if test.wantErr {
+				require.Error(t, err)
+				require.True(t, trace.IsBadParameter(err))
+				return
+			}
+			require.NoError(t, err)
+
+			block, _ := pem.Decode(certReq.csrPEM)
+			require.NotNil(t, block)
+			csr, err := x509.ParseCertificateRequest(block.Bytes)
+			require.NoError(t, err)
+			require.NoError(t, csr.CheckSignature())
+			require.Equal(t, test.wantAlg, csr.SignatureAlgorithm)
+		})
+	}
+}
+
+// fakeDesktopCertIssuer records the certificate request it receives and
+// returns a canned certificate.
+type fakeDesktopCertIssuer struct {
+	req *proto.WindowsDesktopCertRequest
+}
+
+func (f *fakeDesktopCertIssuer) GenerateWindowsDesktopCert(ctx context.Context, req *proto.WindowsDesktopCertRequest) (*proto.WindowsDesktopCertResponse, error) {
+	f.req = req
+	return &proto.WindowsDesktopCertResponse{
+		Cert: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("fake")}),
+	}, nil
+}
+
+func (f *fakeDesktopCertIssuer) GenerateDatabaseCert(context.Context, *proto.DatabaseCertRequest) (*proto.DatabaseCertResponse, error) {
+	return nil, trace.NotImplemented("not implemented")
+}
+
+func (f *fakeDesktopCertIssuer) GetCertAuthority(context.Context, types.CertAuthID, bool) (types.CertAuthority, error) {
+	return nil, trace.NotImplemented("not implemented")
+}
+
+func (f *fakeDesktopCertIssuer) GetClusterName(...services.MarshalOption) (types.ClusterName, error) {
+	return nil, trace.NotImplemented("not implemented")
+}
+
+// TestCertValidityModes verifies that each validity mode produces the
+// expected requested validity window and that invalid combinations are
+// rejected.
+func TestCertValidityModes(t *testing.T) {
+	ctx := context.Background()
+
+	for _, test := range []struct {
+		name     string
+		mode     CertValidityMode
+		ttl      time.Duration
+		skew     time.Duration
+		wantTTL  time.Duration
+		wantSkew time.Duration
+		wantErr  string
+	}{
+		{
+			name:     "default is exact",
+			ttl:      5*time.Minute + 30*time.Second,
+			skew:     30 * time.Second,
+			wantTTL:  5*time.Minute + 30*time.Second,
+			wantSkew: 30 * time.Second,
+		},
+		{
+			name:     "exact",
+			mode:     CertValidityExact,
+			ttl:      5*time.Minute + 30*time.Second,
+			skew:     30 * time.Second,
+			wantTTL:  5*time.Minute + 30*time.Second,
+			wantSkew: 30 * time.Second,
+		},
+		{
+			name:     "rounded up to whole minutes",
+			mode:     CertValidityRoundMinute,
+			ttl:      5*time.Minute + 30*time.Second,
+			skew:     30 * time.Second,
+			wantTTL:  6 * time.Minute,
+			wantSkew: time.Minute,
+		},
+		{
+			name:     "already aligned durations are kept",
+			mode:     CertValidityRoundMinute,
+			ttl:      5 * time.Minute,
+			wantTTL:  5 * time.Minute,
+			wantSkew: 0,
+		},
+		{
+			name:    "rounding requires an explicit TTL",
+			mode:    CertValidityRoundMinute,
+			wantErr: "requires an explicit TTL",
+		},
+		{
+			name:    "negative skew allowance",
+			ttl:     5 * time.Minute,
+			skew:    -time.Second,
+			wantErr: "must not be negative",
+		},
+		{
+			name:    "unknown mode",
+			mode:    "aligned-hour",
+			ttl:     5 * time.Minute,
+			wantErr: "unsupported certificate validity mode",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			issuer := &fakeDesktopCertIssuer{}
+			_, _, err := GenerateWindowsDesktopCredentials(ctx, &GenerateCredentialsRequest{
+				Username:      "test-user",
+				Domain:        "test.example.com",
+				TTL:           test.ttl,
+				SkewAllowance: test.skew,
+				ValidityMode:  test.mode,
+				ClusterName:   "test",
+				LDAPConfig:    LDAPConfig{Domain: "test.example.com"},
+				AuthClient:    issuer,
+			})
+			if test.wantErr != "" {
+				require.True(t, trace.IsBadParameter(err), "expected BadParameter, got %v", err)
+				require.ErrorContains(t, err, test.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.NotNil(t, issuer.req)
+			require.Equal(t, test.wantTTL, issuer.req.TTL.Get())
+			require.Equal(t, test.wantSkew, issuer.req.SkewAllowance.Get())
+		})
+	}
+}
+
+// TestCertificateTemplateExtension verifies that a configured certificate
+// template OID ends up in the CSR as a szOID_CERTIFICATE_TEMPLATE extension
+// and that malformed OIDs are rejected.
+func TestCertificateTemplateExtension(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		oid     string
+		wantErr bool
+	}{
+		{
+			name: "valid template OID",
+			oid:  "1.3.6.1.4.1.311.21.8.12345678.1234567.1234567.1234567.1234.1.1234567",
+		},
+		{
+			name:    "not dotted-decimal",
+			oid:     "TeleportSmartcardLogon",
+			wantErr: true,
+		},
+		{
+			name:    "empty component",
+			oid:     "1..2",
+			wantErr: true,
+		},
+		{
+			name:    "single component",
+			oid:     "1",
+			wantErr: true,
+		},
+		{
+			name:    "negative component",
+			oid:     "1.-2.3",
+			wantErr: true,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			certReq, err := getCertRequest(&GenerateCredentialsRequest{
+				Username:               "test-user",
+				Domain:                 "test.example.com",
+				LDAPConfig:             LDAPConfig{Domain: "test.example.com"},
+				CertificateTemplateOID: test.oid,
+			})
+			if test.wantErr {
+				require.Error(t, err)
+				require.True(t, trace.IsBadParameter(err))
+				return
+			}
+			require.NoError(t, err)
+
+			block, _ := pem.Decode(certReq.csrPEM)
+			require.NotNil(t, block)
+			csr, err := x509.ParseCertificateRequest(block.Bytes)
+			require.NoError(t, err)
+
+			wantID, err := ParseCertificateTemplateOID(test.oid)
+			require.NoError(t, err)
+			found := false
+			for _, ext := range csr.Extensions {
+				if !ext.Id.Equal(CertificateTemplateExtensionOID) {
+					continue
+				}
+				found = true
+				var template struct {
+					TemplateID asn1.ObjectIdentifier
+				}
+				_, err := asn1.Unmarshal(ext.Value, &template)
+				require.NoError(t, err)
+				require.Equal(t, wantID, template.TemplateID)
+			}
+			require.True(t, found, "szOID_CERTIFICATE_TEMPLATE extension not found in CSR")
+		})
+	}
+}
+
+// TestDesktopScopeExtension verifies that a requested desktop scope ends up
+// in the CSR as an extension tied to the target desktop and that no such
+// extension is added when the scope is unset. The auth server copies CSR
+// extensions into the certificate verbatim, so the CSR is what matters.
+func TestDesktopScopeExtension(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		scope string
+	}{
+		{
+			name:  "scoped to a desktop",
+			scope: "desktop-1",
+		},
+		{
+			name: "no scope",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			certReq, err := getCertRequest(&GenerateCredentialsRequest{
+				Username:     "test-user",
+				Domain:       "test.example.com",
+				LDAPConfig:   LDAPConfig{Domain: "test.example.com"},
+				DesktopScope: test.scope,
+			})
+			require.NoError(t, err)
+
+			block, _ := pem.Decode(certReq.csrPEM)
+			require.NotNil(t, block)
+			csr, err := x509.ParseCertificateRequest(block.Bytes)
+			require.NoError(t, err)
+
+			found := false
+			for _, ext := range csr.Extensions {
+				if !ext.Id.Equal(tlsca.DesktopScopeExtensionOID) {
+					continue
+				}
+				found = true
+				var scope struct {
+					Desktop string `json:"desktop"`
+				}
+				require.NoError(t, json.Unmarshal(ext.Value, &scope))
+				require.Equal(t, test.scope, scope.Desktop)
+			}
+			require.Equal(t, test.scope != "", found)
+		})
+	}
+}
+
+// TestSANOtherNameOID verifies that the otherName SAN entry uses the
+// configured OID, defaulting to the standard UPN OID, and that malformed
+// OIDs are rejected.
+func TestSANOtherNameOID(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		oid     string
+		wantOID asn1.ObjectIdentifier
+		wantErr bool
+	}{
+		{
+			name:    "default",
+			wantOID: UPNOtherNameOID,
+		},
+		{
+			name:    "custom OID",
+			oid:     "1.3.6.1.4.1.311.25.99",
+			wantOID: asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 25, 99},
+		},
+		{
+			name:    "malformed OID",
+			oid:     "1.bad.3",
+			wantErr: true,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			certReq, err := getCertRequest(&GenerateCredentialsRequest{
+				Username:        "test-user",
+				Domain:          "test.example.com",
+				LDAPConfig:      LDAPConfig{Domain: "test.example.com"},
+				SANOtherNameOID: test.oid,
+			})
+			if test.wantErr {
+				require.Error(t, err)
+				require.True(t, trace.IsBadParameter(err))
+				return
+			}
+			require.NoError(t, err)
+
+			block, _ := pem.Decode(certReq.csrPEM)
+			require.NotNil(t, block)
+			csr, err := x509.ParseCertificateRequest(block.Bytes)
+			require.NoError(t, err)
+
+			found := false
+			for _, ext := range csr.Extensions {
+				if !ext.Id.Equal(SubjectAltNameExtensionOID) {
+					continue
+				}
+				found = true
+				var san SubjectAltName[upn]
+				_, err := asn1.Unmarshal(ext.Value, &san)
+				require.NoError(t, err)
+				require.Equal(t, test.wantOID, san.OtherName.OID)
+				require.Equal(t, "test-user@test.example.com", san.OtherName.Value.Value)
+			}
+			require.True(t, found, "SAN extension not found in CSR")
+		})
+	}
+}
+
 func TestCRLDN(t *testing.T) {
 	for _, test := range []struct {
 		name        string
@@ -179,3 +577,94 @@ func TestCRLDN(t *testing.T) {
 		})
 	}
 }
+
+func TestLDAPConfigCheck(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		cfg    LDAPConfig
+		assert require.ErrorAssertionFunc
+	}{
+		{
+			name: "OK - username",
+			cfg: LDAPConfig{
+				Addr:     "ldap.example.com:636",
+				Domain:   "example.com",
+				Username: `EXAMPLE\svc-teleport`,
+			},
+			assert: require.NoError,
+		},
+		{
+			name: "OK - bind DN only",
+			cfg: LDAPConfig{
+				Addr:   "ldap.example.com:636",
+				Domain: "example.com",
+				BindDN: "CN=svc-teleport,DC=example,DC=com",
+			},
+			assert: require.NoError,
+		},
+		{
+			name: "NOK - missing username and bind DN",
+			cfg: LDAPConfig{
+				Addr:   "ldap.example.com:636",
+				Domain: "example.com",
+			},
+			assert: require.Error,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			test.assert(t, test.cfg.Check())
+		})
+	}
+}
+
+// TestLDAPConfigPasswordSources verifies that the bind password is loaded
+// from the configured source and that the sources are mutually exclusive.
+func TestLDAPConfigPasswordSources(t *testing.T) {
+	base := LDAPConfig{
+		Addr:     "ldap.example.com:636",
+		Domain:   "example.com",
+		Username: `EXAMPLE\svc-teleport`,
+	}
+
+	t.Run("file", func(t *testing.T) {
+		passwordFile := filepath.Join(t.TempDir(), "ldap-password")
+		require.NoError(t, os.WriteFile(passwordFile, []byte("hunter2\n"), 0o600))
+		cfg := base
+		cfg.PasswordFile = passwordFile
+		require.NoError(t, cfg.CheckAndSetDefaults())
+		require.Equal(t, "hunter2", cfg.Password)
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		cfg := base
+		cfg.PasswordFile = filepath.Join(t.TempDir(), "does-not-exist")
+		require.Error(t, cfg.CheckAndSetDefaults())
+	})
+
+	t.Run("env", func(t *testing.T) {
+		t.Setenv("TEST_LDAP_PASSWORD", "hunter2")
+		cfg := base
+		cfg.PasswordEnv = "TEST_LDAP_PASSWORD"
+		require.NoError(t, cfg.CheckAndSetDefaults())
+		require.Equal(t, "hunter2", cfg.Password)
+	})
+
+	t.Run("unset env", func(t *testing.T) {
+		cfg := base
+		cfg.PasswordEnv = "TEST_LDAP_PASSWORD_UNSET"
+		require.Error(t, cfg.CheckAndSetDefaults())
+	})
+
+	t.Run("multiple sources", func(t *testing.T) {
+		cfg := base
+		cfg.Password = "hunter2"
+		cfg.PasswordEnv = "TEST_LDAP_PASSWORD"
+		require.Error(t, cfg.CheckAndSetDefaults())
+	})
+
+	t.Run("no source", func(t *testing.T) {
+		cfg := base
+		require.NoError(t, cfg.CheckAndSetDefaults())
+		require.Empty(t, cfg.Password)
+	})
+}
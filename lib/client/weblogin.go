@@ -257,6 +257,12 @@ type SSHLoginSSO struct {
 	// default (not currently implemented), or set to 'none' to suppress
 	// browser opening entirely.
 	Browser string
+	// BrowserLauncher is an optional callback invoked with the clickable
+	// login URL instead of opening the system browser. It allows embedding
+	// applications to control how the SSO URL is presented to the user, e.g.
+	// in headless or sandboxed environments. If the callback returns an
+	// error, the login is aborted.
+	BrowserLauncher func(url string) error
 }
 
 // SSHLoginDirect contains SSH login parameters for direct (user/pass/OTP)
@@ -409,6 +415,15 @@ func SSHAgentSSOLogin(ctx context.Context, login SSHLoginSSO, config *Redirector
 
 	clickableURL := rd.ClickableURL()
 
+	// When the caller injected its own launcher, hand the URL over and skip
+	// both the system browser and the stderr prompts.
+	if login.BrowserLauncher != nil {
+		if err := login.BrowserLauncher(clickableURL); err != nil {
+			return nil, trace.Wrap(err)
+		}
+		return waitForResponse(ctx, rd)
+	}
+
 	// If a command was found to launch the browser, create and start it.
 	var execCmd *exec.Cmd
 	if login.Browser != teleport.BrowserNone {
@@ -448,6 +463,12 @@ func SSHAgentSSOLogin(ctx context.Context, login SSHLoginSSO, config *Redirector
 		fmt.Fprintf(os.Stderr, "clicking on the link:\n %v\n", clickableURL)
 	}
 
+	return waitForResponse(ctx, rd)
+}
+
+// waitForResponse waits for the login response delivered to the redirector's
+// callback endpoint.
+func waitForResponse(ctx context.Context, rd *Redirector) (*auth.SSHLoginResponse, error) {
 	select {
 	case err := <-rd.ErrorC():
 		log.Debugf("Got an error: %v.", err)
@@ -21,9 +21,11 @@ package client_test
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
 	"time"
@@ -32,6 +34,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/gravitational/teleport/api/client/proto"
+	"github.com/gravitational/teleport/api/constants"
 	"github.com/gravitational/teleport/api/types"
 	wancli "github.com/gravitational/teleport/lib/auth/webauthncli"
 	wantypes "github.com/gravitational/teleport/lib/auth/webauthntypes"
@@ -244,3 +247,39 @@ func (p *customPromptLogin) PromptTouch() (wancli.TouchAcknowledger, error) {
 func (p *customPromptLogin) PromptCredential(deviceCreds []*wancli.CredentialInfo) (*wancli.CredentialInfo, error) {
 	return nil, nil
 }
+
+// TestSSHAgentSSOLoginBrowserLauncher verifies that an injected browser
+// launcher receives the clickable login URL instead of the system browser
+// being opened.
+func TestSSHAgentSSOLoginBrowserLauncher(t *testing.T) {
+	t.Parallel()
+
+	launcherErr := errors.New("login aborted by the test launcher")
+	var launchedURL string
+
+	_, err := client.SSHAgentSSOLogin(context.Background(), client.SSHLoginSSO{
+		SSHLogin: client.SSHLogin{
+			ProxyAddr: "localhost:3080",
+			PubKey:    []byte("ssh-rsa fake-public-key"),
+			Insecure:  true,
+		},
+		ConnectorID: "github",
+		Protocol:    constants.Github,
+		BrowserLauncher: func(url string) error {
+			launchedURL = url
+			return launcherErr
+		},
+	}, &client.RedirectorConfig{
+		// Skip the network round trip to a real proxy; the redirect URL is
+		// never followed because the launcher aborts the login.
+		SSOLoginConsoleRequestFn: func(req client.SSOLoginConsoleReq) (*client.SSOLoginConsoleResponse, error) {
+			return &client.SSOLoginConsoleResponse{RedirectURL: req.RedirectURL}, nil
+		},
+	})
+	require.ErrorIs(t, err, launcherErr)
+
+	// The launcher received the URL of the local callback redirector.
+	parsedURL, err := url.Parse(launchedURL)
+	require.NoError(t, err)
+	require.NotEmpty(t, parsedURL.Host)
+}
@@ -2109,6 +2109,9 @@ func applyWindowsDesktopConfig(fc *FileConfig, cfg *servicecfg.Config) error {
 	cfg.WindowsDesktop.LDAP = servicecfg.LDAPConfig{
 		Addr:               fc.WindowsDesktop.LDAP.Addr,
 		Username:           fc.WindowsDesktop.LDAP.Username,
+		BindDN:             fc.WindowsDesktop.LDAP.BindDN,
+		PasswordFile:       fc.WindowsDesktop.LDAP.PasswordFile,
+		PasswordEnv:        fc.WindowsDesktop.LDAP.PasswordEnv,
 		SID:                fc.WindowsDesktop.LDAP.SID,
 		Domain:             fc.WindowsDesktop.LDAP.Domain,
 		InsecureSkipVerify: fc.WindowsDesktop.LDAP.InsecureSkipVerify,
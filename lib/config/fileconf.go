@@ -2383,6 +2383,17 @@ type LDAPConfig struct {
 	Domain string `yaml:"domain"`
 	// Username for LDAP authentication.
 	Username string `yaml:"username"`
+	// BindDN is an optional distinguished name or UPN used to authenticate
+	// the LDAP session. If empty, the authentication identity is derived
+	// from Username.
+	BindDN string `yaml:"bind_dn,omitempty"`
+	// PasswordFile is an optional path to a file containing the password
+	// for simple-bind authentication of the LDAP session.
+	PasswordFile string `yaml:"password_file,omitempty"`
+	// PasswordEnv is an optional name of an environment variable holding
+	// the password for simple-bind authentication of the LDAP session.
+	// Mutually exclusive with PasswordFile.
+	PasswordEnv string `yaml:"password_env,omitempty"`
 	// SID is the Security Identifier for the service account specified by Username.
 	SID string `yaml:"sid"`
 	// InsecureSkipVerify decides whether whether we skip verifying with the LDAP server's CA when making the LDAPS connection.
@@ -141,6 +141,19 @@ type LDAPConfig struct {
 	Domain string
 	// Username for LDAP authentication.
 	Username string
+	// BindDN is an optional distinguished name or UPN used to authenticate
+	// the LDAP session. If empty, the authentication identity is derived
+	// from Username.
+	BindDN string
+	// Password is an optional password for simple-bind authentication of
+	// the LDAP session.
+	Password string
+	// PasswordFile is an optional path to a file containing the bind
+	// password. Mutually exclusive with Password and PasswordEnv.
+	PasswordFile string
+	// PasswordEnv is an optional name of an environment variable holding
+	// the bind password. Mutually exclusive with Password and PasswordFile.
+	PasswordEnv string
 	// SID is the SID for the user specified by Username.
 	SID string
 	// InsecureSkipVerify decides whether whether we skip verifying with the LDAP server's CA when making the LDAPS connection.
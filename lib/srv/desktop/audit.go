@@ -20,6 +20,10 @@ package desktop
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
 	"time"
 
 	"github.com/gravitational/trace"
@@ -27,6 +31,7 @@ import (
 
 	"github.com/gravitational/teleport/api/types"
 	"github.com/gravitational/teleport/api/types/events"
+	"github.com/gravitational/teleport/lib/auth/windows"
 	libevents "github.com/gravitational/teleport/lib/events"
 	"github.com/gravitational/teleport/lib/srv/desktop/tdp"
 	"github.com/gravitational/teleport/lib/tlsca"
@@ -46,9 +51,36 @@ type desktopSessionAuditor struct {
 	clusterName        string
 	desktopServiceUUID string
 
+	// certSerial and certFingerprint identify the certificate issued for
+	// this session, for correlation with the Windows event log entry of
+	// the logon. They are empty until a certificate is issued.
+	certSerial      string
+	certFingerprint string
+
 	auditCache sharedDirectoryAuditCache
 }
 
+// certSerialLabel and certFingerprintLabel carry the issued certificate
+// details in the session start event. The event schema has no dedicated
+// fields for them, so they ride along in the labels map under internal keys.
+const (
+	certSerialLabel      = types.TeleportInternalLabelPrefix + "cert-serial"
+	certFingerprintLabel = types.TeleportInternalLabelPrefix + "cert-fingerprint"
+)
+
+// recordCertificate captures the serial number and SHA-256 fingerprint of
+// the certificate issued for the session, so that the session start event
+// can be correlated with the logon recorded in the Windows event log.
+func (d *desktopSessionAuditor) recordCertificate(certDER []byte) {
+	cert, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		return
+	}
+	sum := sha256.Sum256(certDER)
+	d.certSerial = cert.SerialNumber.String()
+	d.certFingerprint = hex.EncodeToString(sum[:])
+}
+
 func (d *desktopSessionAuditor) getSessionMetadata() events.SessionMetadata {
 	return events.SessionMetadata{
 		SessionID:        d.sessionID,
@@ -110,6 +142,18 @@ func (d *desktopSessionAuditor) makeSessionStart(err error) *events.WindowsDeskt
 		DesktopLabels:         d.desktop.GetAllLabels(),
 	}
 
+	if d.certSerial != "" {
+		// Copy the labels before annotating them so the desktop resource's
+		// own label map stays untouched.
+		labels := make(map[string]string, len(event.DesktopLabels)+2)
+		for k, v := range event.DesktopLabels {
+			labels[k] = v
+		}
+		labels[certSerialLabel] = d.certSerial
+		labels[certFingerprintLabel] = d.certFingerprint
+		event.DesktopLabels = labels
+	}
+
 	if err != nil {
 		event.Code = libevents.DesktopSessionStartFailureCode
 		event.Error = trace.Unwrap(err).Error()
@@ -489,3 +533,102 @@ const (
 	alreadyExistsStatusMessage = "item already exists"
 	unknownErrStatusMsg        = "unknown error"
 )
+
+// ldapWriteEventType identifies audit events describing writes this service
+// makes against Active Directory. There is no dedicated protobuf event for
+// LDAP writes yet, so they are emitted in the [events.Unknown] envelope with
+// this type recorded in UnknownType, mirroring how the audit pipeline handles
+// other events that lack a protobuf definition.
+const ldapWriteEventType = "windows.desktop.ldap.write"
+
+// ldapWriteEventData is the JSON payload of an LDAP write audit event.
+// Attribute values (certificates, CRL contents) are deliberately excluded.
+type ldapWriteEventData struct {
+	// Op is the LDAP operation performed, "create" or "update".
+	Op string `json:"op"`
+	// DN is the distinguished name of the entry that was written.
+	DN string `json:"dn"`
+	// Success indicates whether the write succeeded.
+	Success bool `json:"success"`
+	// Error is the error message for failed writes.
+	Error string `json:"error,omitempty"`
+}
+
+// makeLDAPWriteEvent builds an audit event describing a single write this
+// service performed against the LDAP server.
+func (s *WindowsService) makeLDAPWriteEvent(w windows.LDAPWrite) *events.Unknown {
+	data := ldapWriteEventData{
+		Op:      w.Op,
+		DN:      w.DN,
+		Success: w.Err == nil,
+	}
+	if w.Err != nil {
+		data.Error = w.Err.Error()
+	}
+	payload, err := json.Marshal(data)
+	if err != nil {
+		s.cfg.Log.WithError(err).Warn("Failed to encode LDAP write audit event")
+	}
+
+	return &events.Unknown{
+		Metadata: events.Metadata{
+			Type:        libevents.UnknownEvent,
+			Code:        libevents.UnknownCode,
+			ClusterName: s.clusterName,
+			Time:        s.cfg.Clock.Now().UTC(),
+		},
+		UnknownType: ldapWriteEventType,
+		Data:        string(payload),
+	}
+}
+
+// onLDAPWrite reports a write made against the LDAP server (CRL publishing,
+// container creation) to the audit log. Only the operation, target DN and
+// result are recorded; attribute values never leave the service.
+func (s *WindowsService) onLDAPWrite(w windows.LDAPWrite) {
+	s.emit(s.closeCtx, s.makeLDAPWriteEvent(w))
+}
+
+// desktopSessionUsageEventType identifies audit events summarizing the
+// resource usage of a completed desktop session. The session.end protobuf
+// event has no fields for usage data, so the summary is emitted in the
+// [events.Unknown] envelope right after session.end, like LDAP writes.
+const desktopSessionUsageEventType = "windows.desktop.session.usage"
+
+// sessionUsageEventData is the JSON payload of a session usage audit event.
+type sessionUsageEventData struct {
+	// SessionID identifies the desktop session the usage belongs to.
+	SessionID string `json:"sid"`
+	// DurationSeconds is how long the session lasted.
+	DurationSeconds float64 `json:"duration_seconds"`
+	// TxBytes is the number of TDP bytes sent to the desktop client.
+	TxBytes uint64 `json:"tx_bytes"`
+	// RxBytes is the number of TDP bytes received from the desktop client.
+	RxBytes uint64 `json:"rx_bytes"`
+}
+
+// makeSessionUsageEvent builds an audit event summarizing the bandwidth
+// and duration of a completed desktop session.
+func (s *WindowsService) makeSessionUsageEvent(d *desktopSessionAuditor, txBytes, rxBytes uint64) *events.Unknown {
+	now := d.clock.Now().UTC()
+	payload, err := json.Marshal(sessionUsageEventData{
+		SessionID:       d.sessionID,
+		DurationSeconds: now.Sub(d.startTime).Seconds(),
+		TxBytes:         txBytes,
+		RxBytes:         rxBytes,
+	})
+	if err != nil {
+		s.cfg.Log.WithError(err).Warn("Failed to encode session usage audit event")
+	}
+
+	return &events.Unknown{
+		Metadata: events.Metadata{
+			Type:        libevents.UnknownEvent,
+			Code:        libevents.UnknownCode,
+			ClusterName: d.clusterName,
+			Time:        now,
+		},
+		UnknownType: desktopSessionUsageEventType,
+		Data:        string(payload),
+	}
+}
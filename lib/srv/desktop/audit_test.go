@@ -19,7 +19,15 @@
 package desktop
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
 	"io"
+	"math/big"
 	"testing"
 	"time"
 
@@ -170,6 +178,33 @@ func TestSessionStartEvent(t *testing.T) {
 	}
 }
 
+// TestSessionStartEventCertificate verifies that the serial number and
+// fingerprint of the issued certificate make it into the session start
+// event without mutating the desktop resource's labels.
+func TestSessionStartEventCertificate(t *testing.T) {
+	_, audit := setup(testDesktop)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1234567890),
+		Subject:      pkix.Name{CommonName: "Administrator"},
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	audit.recordCertificate(certDER)
+	startEvent := audit.makeSessionStart(nil)
+
+	require.Equal(t, "1234567890", startEvent.DesktopLabels[certSerialLabel])
+	sum := sha256.Sum256(certDER)
+	require.Equal(t, hex.EncodeToString(sum[:]), startEvent.DesktopLabels[certFingerprintLabel])
+
+	// The annotation must not leak into the desktop's own labels.
+	require.NotContains(t, testDesktop.GetAllLabels(), certSerialLabel)
+}
+
 func TestSessionEndEvent(t *testing.T) {
 
 	id, audit := setup(testDesktop)
@@ -0,0 +1,134 @@
+/*
+ * Teleport
+ * Copyright (C) 2024  Gravitational, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package desktop
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gravitational/trace"
+
+	"github.com/gravitational/teleport/api/types"
+	"github.com/gravitational/teleport/lib/services"
+)
+
+const (
+	// denialReasonNoDesktopAccess indicates that none of the user's roles
+	// grant access to the desktop at all, typically because no role's
+	// labels match it.
+	denialReasonNoDesktopAccess = "no-desktop-access"
+	// denialReasonLoginNotAllowed indicates that the user's roles do grant
+	// access to the desktop, just not with the requested Windows login.
+	denialReasonLoginNotAllowed = "login-not-allowed"
+)
+
+// authorizationError is a denied desktop authorization. It carries a
+// machine-readable reason and a suggested remediation, and its user
+// message is delivered to the client over TDP (see handleConnection) so
+// that the UI can explain the denial instead of showing a bare
+// "access denied".
+type authorizationError struct {
+	// Reason is one of the denialReason constants.
+	Reason string
+	// Desktop is the name of the desktop the connection targeted.
+	Desktop string
+	// Login is the denied Windows login, set when the denial is
+	// login-specific.
+	Login string
+	// Remediation suggests how the user can regain access.
+	Remediation string
+}
+
+func (e *authorizationError) Error() string {
+	if e.Login != "" {
+		return fmt.Sprintf("access to desktop %q as Windows login %q denied: %v", e.Desktop, e.Login, e.Reason)
+	}
+	return fmt.Sprintf("access to desktop %q denied: %v", e.Desktop, e.Reason)
+}
+
+// Unwrap exposes an access-denied error so that existing callers which
+// classify authorization failures with trace.IsAccessDenied keep working.
+func (e *authorizationError) Unwrap() error {
+	return &trace.AccessDeniedError{Message: e.Error()}
+}
+
+// UserMessage implements [trace.UserMessager]. The message reaches the
+// client UI over TDP.
+func (e *authorizationError) UserMessage() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Access denied (%s): ", e.Reason)
+	if e.Login != "" {
+		fmt.Fprintf(&sb, "your roles do not allow the Windows login %q on desktop %q. ", e.Login, e.Desktop)
+	} else {
+		fmt.Fprintf(&sb, "your roles do not allow access to desktop %q. ", e.Desktop)
+	}
+	sb.WriteString(e.Remediation)
+	return sb.String()
+}
+
+// explainAccessDenied turns a generic access-denied result from the
+// access checker into an authorizationError, distinguishing a user whose
+// roles don't reach the desktop at all from one whose roles merely
+// disallow the requested Windows login.
+func explainAccessDenied(checker services.AccessChecker, state services.AccessState, desktop types.WindowsDesktop, login string) error {
+	if err := checker.CheckAccess(desktop, state); err != nil {
+		return trace.Wrap(&authorizationError{
+			Reason:      denialReasonNoDesktopAccess,
+			Desktop:     desktop.GetName(),
+			Remediation: "Ask your cluster administrator to extend your roles, or submit an access request for a role that includes this desktop.",
+		})
+	}
+
+	remediation := "Ask your cluster administrator to add the login to your allowed Windows logins, or submit an access request for a role that allows it."
+	if logins, err := checker.GetAllowedLoginsForResource(desktop); err == nil && len(logins) > 0 {
+		remediation = fmt.Sprintf("Retry with one of your allowed logins: %v.", strings.Join(logins, ", "))
+	}
+	return trace.Wrap(&authorizationError{
+		Reason:      denialReasonLoginNotAllowed,
+		Desktop:     desktop.GetName(),
+		Login:       login,
+		Remediation: remediation,
+	})
+}
+
+// ListDesktopsForUser returns the subset of registered Windows desktops the
+// user behind the given access checker can access. It applies the same
+// label-based RBAC check that authorizes a connection, so a desktop appears
+// in the result exactly when a connection to it would pass authorization
+// (possibly still subject to the Windows login check).
+func (s *WindowsService) ListDesktopsForUser(ctx context.Context, checker services.AccessChecker, state services.AccessState) ([]types.WindowsDesktop, error) {
+	desktops, err := s.cfg.AccessPoint.GetWindowsDesktops(ctx, types.WindowsDesktopFilter{})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	allowed := make([]types.WindowsDesktop, 0, len(desktops))
+	for _, desktop := range desktops {
+		if err := checker.CheckAccess(desktop, state); err != nil {
+			if trace.IsAccessDenied(err) {
+				continue
+			}
+			return nil, trace.Wrap(err)
+		}
+		allowed = append(allowed, desktop)
+	}
+
+	return allowed, nil
+}
@@ -0,0 +1,130 @@
+/*
+ * Teleport
+ * Copyright (C) 2024  Gravitational, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package desktop
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gravitational/trace"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gravitational/teleport/api/types"
+	"github.com/gravitational/teleport/lib/auth"
+	"github.com/gravitational/teleport/lib/services"
+)
+
+func newDesktopAccessChecker(t *testing.T, conditions types.RoleConditions) services.AccessChecker {
+	t.Helper()
+	role, err := types.NewRole("desktop-access", types.RoleSpecV6{Allow: conditions})
+	require.NoError(t, err)
+	accessInfo := &services.AccessInfo{Roles: []string{role.GetName()}}
+	return services.NewAccessCheckerWithRoleSet(accessInfo, "cluster", services.NewRoleSet(role))
+}
+
+// TestExplainAccessDenied verifies that denied connections are mapped to
+// distinct, actionable denial reasons depending on whether the user's
+// roles miss the desktop entirely or merely disallow the requested login.
+func TestExplainAccessDenied(t *testing.T) {
+	desktop, err := types.NewWindowsDesktopV3("win-prod",
+		map[string]string{"env": "prod"},
+		types.WindowsDesktopSpecV3{Addr: "win-prod.example.com:3389"})
+	require.NoError(t, err)
+
+	t.Run("missing label", func(t *testing.T) {
+		checker := newDesktopAccessChecker(t, types.RoleConditions{
+			WindowsDesktopLabels: types.Labels{"env": {"dev"}},
+			WindowsDesktopLogins: []string{"Administrator"},
+		})
+
+		err := explainAccessDenied(checker, services.AccessState{}, desktop, "Administrator")
+		require.True(t, trace.IsAccessDenied(err))
+
+		var authzErr *authorizationError
+		require.ErrorAs(t, err, &authzErr)
+		require.Equal(t, denialReasonNoDesktopAccess, authzErr.Reason)
+		require.Contains(t, authzErr.UserMessage(), "access request")
+	})
+
+	t.Run("missing login", func(t *testing.T) {
+		checker := newDesktopAccessChecker(t, types.RoleConditions{
+			WindowsDesktopLabels: types.Labels{"env": {"prod"}},
+			WindowsDesktopLogins: []string{"Administrator"},
+		})
+
+		err := explainAccessDenied(checker, services.AccessState{}, desktop, "root")
+		require.True(t, trace.IsAccessDenied(err))
+
+		var authzErr *authorizationError
+		require.ErrorAs(t, err, &authzErr)
+		require.Equal(t, denialReasonLoginNotAllowed, authzErr.Reason)
+		require.Equal(t, "root", authzErr.Login)
+		// The remediation suggests the logins the roles do allow.
+		require.Contains(t, authzErr.UserMessage(), "Administrator")
+	})
+}
+
+// desktopListAccessPoint serves a canned set of desktops for listing.
+type desktopListAccessPoint struct {
+	auth.WindowsDesktopAccessPoint
+
+	desktops []types.WindowsDesktop
+}
+
+func (a *desktopListAccessPoint) GetWindowsDesktops(ctx context.Context, filter types.WindowsDesktopFilter) ([]types.WindowsDesktop, error) {
+	return a.desktops, nil
+}
+
+// TestListDesktopsForUser verifies that the listing applies the same RBAC
+// check as connection authorization, returning only the desktops the user's
+// roles reach.
+func TestListDesktopsForUser(t *testing.T) {
+	newDesktop := func(name string, labels map[string]string) types.WindowsDesktop {
+		desktop, err := types.NewWindowsDesktopV3(name, labels,
+			types.WindowsDesktopSpecV3{Addr: name + ".example.com:3389"})
+		require.NoError(t, err)
+		return desktop
+	}
+
+	prod := newDesktop("win-prod", map[string]string{"env": "prod"})
+	dev := newDesktop("win-dev", map[string]string{"env": "dev"})
+	staging := newDesktop("win-staging", map[string]string{"env": "staging"})
+
+	s := &WindowsService{
+		cfg: WindowsServiceConfig{
+			AccessPoint: &desktopListAccessPoint{
+				desktops: []types.WindowsDesktop{prod, dev, staging},
+			},
+		},
+	}
+
+	checker := newDesktopAccessChecker(t, types.RoleConditions{
+		WindowsDesktopLabels: types.Labels{"env": {"prod", "staging"}},
+		WindowsDesktopLogins: []string{"Administrator"},
+	})
+
+	allowed, err := s.ListDesktopsForUser(context.Background(), checker, services.AccessState{})
+	require.NoError(t, err)
+
+	names := make([]string, 0, len(allowed))
+	for _, desktop := range allowed {
+		names = append(names, desktop.GetName())
+	}
+	require.ElementsMatch(t, []string{"win-prod", "win-staging"}, names)
+}
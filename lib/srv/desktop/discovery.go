@@ -61,7 +61,7 @@ func (s *WindowsService) startDesktopDiscovery() error {
 	go func() {
 		// reconcile once before starting the ticker, so that desktops show up immediately
 		// (we still have a small delay to give the LDAP client time to initialize)
-		time.Sleep(15 * time.Second)
+		s.cfg.Clock.Sleep(15 * time.Second)
 		if err := reconciler.Reconcile(s.closeCtx); err != nil && !errors.Is(err, context.Canceled) {
 			s.cfg.Log.Errorf("desktop reconciliation failed: %v", err)
 		}
@@ -189,7 +189,56 @@ const dnsQueryTimeout = 5 * time.Second
 // lookupDesktop does a DNS lookup for the provided hostname.
 // It checks using the default system resolver first, and falls
 // back to the configured LDAP server if the system resolver fails.
+//
+// Addresses of the preferred address family are returned when present,
+// otherwise addresses of the alternate family are used as a fallback
+// (happy-eyeballs style), so that a host with both A and AAAA records
+// doesn't end up routed over an unreachable path.
 func (s *WindowsService) lookupDesktop(ctx context.Context, hostname string) ([]string, error) {
+	primary, fallback := dnsNetworksForFamily(s.cfg.AddressFamily)
+
+	addrs := s.lookupDesktopAddrs(ctx, hostname, primary)
+	if len(addrs) == 0 {
+		addrs = s.lookupDesktopAddrs(ctx, hostname, fallback)
+	}
+	if len(addrs) > 0 {
+		return addrs, nil
+	}
+
+	return nil, trace.Errorf("could not resolve %v in time", hostname)
+}
+
+// dnsNetworksForFamily converts an address family preference into the
+// primary and fallback networks to pass to net.Resolver.LookupNetIP.
+func dnsNetworksForFamily(family AddressFamily) (primary, fallback string) {
+	if family == AddressFamilyIPv6 {
+		return "ip6", "ip4"
+	}
+	return "ip4", "ip6"
+}
+
+// filterAddrsByNetwork returns the subset of addrs that belong to the
+// requested network ("ip4" or "ip6"). Even when a single address family
+// is requested it's possible to get IPv4 addresses mapped to IPv6
+// addresses, so those are unmapped and treated as IPv4 here.
+func filterAddrsByNetwork(addrs []netip.Addr, network string) []netip.Addr {
+	result := make([]netip.Addr, 0, len(addrs))
+	for _, addr := range addrs {
+		isV4 := addr.Is4() || addr.Is4In6()
+		switch {
+		case network == "ip4" && isV4:
+			result = append(result, addr.Unmap())
+		case network == "ip6" && !isV4:
+			result = append(result, addr)
+		}
+	}
+	return result
+}
+
+// lookupDesktopAddrs resolves the hostname to addresses of a single
+// address family, querying the default system resolver and the
+// LDAP server's DNS in parallel.
+func (s *WindowsService) lookupDesktopAddrs(ctx context.Context, hostname, network string) []string {
 	stringAddrs := func(addrs []netip.Addr) []string {
 		result := make([]string, 0, len(addrs))
 		for _, addr := range addrs {
@@ -204,22 +253,13 @@ func (s *WindowsService) lookupDesktop(ctx context.Context, hostname string) ([]
 			tctx, cancel := context.WithTimeout(ctx, dnsQueryTimeout)
 			defer cancel()
 
-			addrs, err := resolver.LookupNetIP(tctx, "ip4", hostname)
+			addrs, err := resolver.LookupNetIP(tctx, network, hostname)
 			if err != nil {
 				s.cfg.Log.Debugf("DNS lookup for %v failed with %s resolver: %v",
 					hostname, resolverName, err)
 			}
 
-			// even though we requested "ip4" it's possible to get IPv4
-			// addresses mapped to IPv6 addresses, so we unmap them here
-			result := make([]netip.Addr, 0, len(addrs))
-			for _, addr := range addrs {
-				if addr.Is4() || addr.Is4In6() {
-					result = append(result, addr.Unmap())
-				}
-			}
-
-			ch <- result
+			ch <- filterAddrsByNetwork(addrs, network)
 		}()
 		return ch
 	}
@@ -231,19 +271,14 @@ func (s *WindowsService) lookupDesktop(ctx context.Context, hostname string) ([]
 	// wait for the default resolver to return (or time out)
 	addrs := <-defaultResult
 	if len(addrs) > 0 {
-		return stringAddrs(addrs), nil
+		return stringAddrs(addrs)
 	}
 
 	// If we didn't get a result from the default resolver,
 	// use the result from the LDAP resolver.
 	// This shouldn't block for very long, since both operations
 	// started at the same time with the same timeout.
-	addrs = <-ldapResult
-	if len(addrs) > 0 {
-		return stringAddrs(addrs), nil
-	}
-
-	return nil, trace.Errorf("could not resolve %v in time", hostname)
+	return stringAddrs(<-ldapResult)
 }
 
 // ldapEntryToWindowsDesktop generates the Windows Desktop resource
@@ -283,6 +318,8 @@ func (s *WindowsService) ldapEntryToWindowsDesktop(ctx context.Context, entry *l
 		name += "-" + hex.EncodeToString(guid[:4])
 	}
 
+	s.applyReachabilityLabel(labels, addr.String())
+
 	desktop, err := types.NewWindowsDesktopV3(
 		name,
 		labels,
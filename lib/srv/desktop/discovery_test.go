@@ -23,6 +23,7 @@ import (
 	"errors"
 	"io"
 	"net"
+	"net/netip"
 	"strconv"
 	"testing"
 	"time"
@@ -173,3 +174,65 @@ func TestDNSErrors(t *testing.T) {
 	require.Less(t, time.Since(start), dnsQueryTimeout-1*time.Second)
 	require.Error(t, err)
 }
+
+// TestAddressFamilyPreference verifies that the configured address family
+// preference determines which resolved addresses are used and that the
+// alternate family remains available as a fallback.
+func TestAddressFamilyPreference(t *testing.T) {
+	// a mix of IPv4, IPv4-mapped, and IPv6 addresses for a dual-stack host
+	addrs := []netip.Addr{
+		netip.MustParseAddr("192.0.2.1"),
+		netip.MustParseAddr("::ffff:192.0.2.2"),
+		netip.MustParseAddr("2001:db8::1"),
+	}
+
+	for _, test := range []struct {
+		desc          string
+		family        AddressFamily
+		primary       string
+		fallback      string
+		primaryAddrs  []string
+		fallbackAddrs []string
+	}{
+		{
+			desc:          "auto prefers IPv4",
+			family:        AddressFamilyAuto,
+			primary:       "ip4",
+			fallback:      "ip6",
+			primaryAddrs:  []string{"192.0.2.1", "192.0.2.2"},
+			fallbackAddrs: []string{"2001:db8::1"},
+		},
+		{
+			desc:          "explicit IPv4",
+			family:        AddressFamilyIPv4,
+			primary:       "ip4",
+			fallback:      "ip6",
+			primaryAddrs:  []string{"192.0.2.1", "192.0.2.2"},
+			fallbackAddrs: []string{"2001:db8::1"},
+		},
+		{
+			desc:          "explicit IPv6",
+			family:        AddressFamilyIPv6,
+			primary:       "ip6",
+			fallback:      "ip4",
+			primaryAddrs:  []string{"2001:db8::1"},
+			fallbackAddrs: []string{"192.0.2.1", "192.0.2.2"},
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			primary, fallback := dnsNetworksForFamily(test.family)
+			require.Equal(t, test.primary, primary)
+			require.Equal(t, test.fallback, fallback)
+
+			toStrings := func(addrs []netip.Addr) []string {
+				result := make([]string, 0, len(addrs))
+				for _, addr := range addrs {
+					result = append(result, addr.String())
+				}
+				return result
+			}
+			require.Equal(t, test.primaryAddrs, toStrings(filterAddrsByNetwork(addrs, primary)))
+			require.Equal(t, test.fallbackAddrs, toStrings(filterAddrsByNetwork(addrs, fallback)))
+		})
+	}
+}
@@ -0,0 +1,139 @@
+// Teleport
+// Copyright (C) 2024 Gravitational, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package desktop
+
+import (
+	"context"
+	"sync"
+
+	"github.com/gravitational/trace"
+
+	"github.com/gravitational/teleport/api/types/events"
+	libevents "github.com/gravitational/teleport/lib/events"
+)
+
+// subscriberBufferSize is the number of recording events buffered per live
+// viewer. Subscribers that fall further behind have events dropped rather
+// than stalling the session.
+const subscriberBufferSize = 64
+
+// recordingTee wraps a session recorder and fans out every recorded event
+// to live subscribers, so that viewers can follow an in-progress session
+// without waiting for the stored recording.
+type recordingTee struct {
+	libevents.SessionPreparerRecorder
+
+	mu     sync.Mutex
+	nextID uint64
+	subs   map[uint64]chan events.AuditEvent
+}
+
+func newRecordingTee(recorder libevents.SessionPreparerRecorder) *recordingTee {
+	return &recordingTee{
+		SessionPreparerRecorder: recorder,
+		subs:                    make(map[uint64]chan events.AuditEvent),
+	}
+}
+
+// RecordEvent records the event and distributes it to all subscribers.
+func (t *recordingTee) RecordEvent(ctx context.Context, event events.PreparedSessionEvent) error {
+	if err := t.SessionPreparerRecorder.RecordEvent(ctx, event); err != nil {
+		return trace.Wrap(err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, sub := range t.subs {
+		select {
+		case sub <- event.GetAuditEvent():
+		default: // drop the event rather than stall the session
+		}
+	}
+	return nil
+}
+
+// subscribe registers a new subscriber and returns its channel along with
+// a function that cancels the subscription.
+func (t *recordingTee) subscribe() (<-chan events.AuditEvent, func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	id := t.nextID
+	t.nextID++
+	sub := make(chan events.AuditEvent, subscriberBufferSize)
+	t.subs[id] = sub
+
+	return sub, func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if _, ok := t.subs[id]; ok {
+			delete(t.subs, id)
+			close(sub)
+		}
+	}
+}
+
+// closeSubscribers closes all subscriber channels, signaling that the
+// session has ended.
+func (t *recordingTee) closeSubscribers() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for id, sub := range t.subs {
+		delete(t.subs, id)
+		close(sub)
+	}
+}
+
+// registerLiveRecording wraps the session recorder in a tee that live
+// viewers can subscribe to for the duration of the session.
+func (s *WindowsService) registerLiveRecording(sessionID string, recorder libevents.SessionPreparerRecorder) *recordingTee {
+	tee := newRecordingTee(recorder)
+	s.mu.Lock()
+	s.liveRecordings[sessionID] = tee
+	s.mu.Unlock()
+	return tee
+}
+
+// unregisterLiveRecording removes the tee for the given session and
+// disconnects any remaining subscribers.
+func (s *WindowsService) unregisterLiveRecording(sessionID string) {
+	s.mu.Lock()
+	tee := s.liveRecordings[sessionID]
+	delete(s.liveRecordings, sessionID)
+	s.mu.Unlock()
+
+	if tee != nil {
+		tee.closeSubscribers()
+	}
+}
+
+// SubscribeToSessionRecording returns a channel that receives the recording
+// events of an in-progress desktop session as they are produced. The channel
+// is closed when the session ends. The returned function cancels the
+// subscription and must be called when the viewer disconnects.
+func (s *WindowsService) SubscribeToSessionRecording(sessionID string) (<-chan events.AuditEvent, func(), error) {
+	s.mu.Lock()
+	tee := s.liveRecordings[sessionID]
+	s.mu.Unlock()
+
+	if tee == nil {
+		return nil, nil, trace.NotFound("no live desktop session with ID %q", sessionID)
+	}
+
+	sub, cancel := tee.subscribe()
+	return sub, cancel, nil
+}
@@ -0,0 +1,96 @@
+// Teleport
+// Copyright (C) 2024 Gravitational, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package desktop
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gravitational/trace"
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gravitational/teleport/api/types/events"
+	libevents "github.com/gravitational/teleport/lib/events"
+	"github.com/gravitational/teleport/lib/events/eventstest"
+)
+
+// TestLiveRecordingSubscribers simulates a live desktop session and verifies
+// that subscribers receive the recording events emitted while the session is
+// in progress.
+func TestLiveRecordingSubscribers(t *testing.T) {
+	const sessionID = "session-1"
+
+	s := &WindowsService{
+		cfg:            WindowsServiceConfig{Clock: clockwork.NewFakeClock()},
+		liveRecordings: make(map[string]*recordingTee),
+	}
+
+	emitter := &eventstest.MockRecorderEmitter{}
+	recorder := s.registerLiveRecording(sessionID, libevents.WithNoOpPreparer(emitter))
+
+	sub1, cancel1, err := s.SubscribeToSessionRecording(sessionID)
+	require.NoError(t, err)
+	sub2, cancel2, err := s.SubscribeToSessionRecording(sessionID)
+	require.NoError(t, err)
+	t.Cleanup(cancel2)
+
+	recordFrame := func(payload []byte) {
+		frame := &events.DesktopRecording{
+			Metadata: events.Metadata{Type: libevents.DesktopRecordingEvent},
+			Message:  payload,
+		}
+		require.NoError(t, libevents.SetupAndRecordEvent(context.Background(), recorder, frame))
+	}
+
+	receiveFrame := func(sub <-chan events.AuditEvent) *events.DesktopRecording {
+		select {
+		case e := <-sub:
+			dr, ok := e.(*events.DesktopRecording)
+			require.True(t, ok)
+			return dr
+		default:
+			t.Fatal("subscriber did not receive the recorded frame")
+			return nil
+		}
+	}
+
+	// Both subscribers receive the frame, and the underlying recorder
+	// still captures it.
+	recordFrame([]byte{0x01})
+	require.Equal(t, []byte{0x01}, receiveFrame(sub1).Message)
+	require.Equal(t, []byte{0x01}, receiveFrame(sub2).Message)
+	require.NotNil(t, emitter.LastEvent())
+
+	// A disconnected viewer is cleaned up: its channel is closed and
+	// subsequent frames go only to the remaining subscriber.
+	cancel1()
+	_, open := <-sub1
+	require.False(t, open)
+
+	recordFrame([]byte{0x02})
+	require.Equal(t, []byte{0x02}, receiveFrame(sub2).Message)
+
+	// Ending the session disconnects the remaining subscribers and
+	// removes the tee.
+	s.unregisterLiveRecording(sessionID)
+	_, open = <-sub2
+	require.False(t, open)
+
+	_, _, err = s.SubscribeToSessionRecording(sessionID)
+	require.True(t, trace.IsNotFound(err))
+}
@@ -0,0 +1,173 @@
+// Teleport
+// Copyright (C) 2024 Gravitational, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package desktop
+
+import (
+	"context"
+	"sync"
+
+	"github.com/gravitational/trace"
+
+	"github.com/gravitational/teleport/api/types"
+	"github.com/gravitational/teleport/lib/auth"
+)
+
+// moderatedSession gates an RDP session on the presence of the moderators
+// required by the session owner's roles. The session is held before any
+// input reaches the Windows host until the require policies are fulfilled,
+// and is terminated when a required moderator leaves.
+type moderatedSession struct {
+	evaluator auth.SessionAccessEvaluator
+	// cancel terminates the RDP session. It is the same cancel func that
+	// the connection monitor uses, so moderated sessions are torn down
+	// exactly like locked ones.
+	cancel context.CancelFunc
+
+	mu           sync.Mutex
+	participants map[string]auth.SessionAccessContext
+	released     bool
+	releaseC     chan struct{}
+}
+
+// newModeratedSession creates a moderation gate for a session owned by
+// owner, evaluated against the policy sets of the owner's roles.
+func newModeratedSession(policySets []*types.SessionTrackerPolicySet, owner string, cancel context.CancelFunc) *moderatedSession {
+	return &moderatedSession{
+		evaluator:    auth.NewSessionAccessEvaluator(policySets, types.WindowsDesktopSessionKind, owner),
+		cancel:       cancel,
+		participants: make(map[string]auth.SessionAccessContext),
+		releaseC:     make(chan struct{}),
+	}
+}
+
+// isModerated returns true if the session requires moderation.
+func (m *moderatedSession) isModerated() bool {
+	return m.evaluator.IsModerated()
+}
+
+// requirements describes what is needed for the session to start.
+func (m *moderatedSession) requirements() string {
+	return m.evaluator.PrettyRequirementsList()
+}
+
+// waitForModerators blocks until the required moderators have joined or
+// the session context is canceled. It returns immediately for sessions
+// that don't require moderation.
+func (m *moderatedSession) waitForModerators(ctx context.Context) error {
+	if !m.isModerated() {
+		return nil
+	}
+
+	select {
+	case <-m.releaseC:
+		return nil
+	case <-ctx.Done():
+		return trace.Wrap(ctx.Err())
+	}
+}
+
+// join adds a participant to the session and releases the gate if the
+// require policies are now fulfilled.
+func (m *moderatedSession) join(participant auth.SessionAccessContext) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.participants[participant.Username] = participant
+	return trace.Wrap(m.checkPolicyLocked())
+}
+
+// leave removes a participant from the session. If the session had
+// already started and the require policies are no longer fulfilled,
+// the session is terminated.
+func (m *moderatedSession) leave(username string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.participants, username)
+	return trace.Wrap(m.checkPolicyLocked())
+}
+
+func (m *moderatedSession) checkPolicyLocked() error {
+	participants := make([]auth.SessionAccessContext, 0, len(m.participants))
+	for _, participant := range m.participants {
+		participants = append(participants, participant)
+	}
+
+	fulfilled, _, err := m.evaluator.FulfilledFor(participants)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	switch {
+	case fulfilled && !m.released:
+		m.released = true
+		close(m.releaseC)
+	case !fulfilled && m.released:
+		// A required moderator left a session that was already running.
+		// RDP input cannot be paused mid-session the way a terminal can,
+		// so the session is terminated instead.
+		m.cancel()
+	}
+
+	return nil
+}
+
+// registerModeratedSession exposes a session's moderation gate so that
+// participants can join and leave it out-of-band.
+func (s *WindowsService) registerModeratedSession(sessionID string, m *moderatedSession) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.moderatedSessions[sessionID] = m
+}
+
+// unregisterModeratedSession removes a session's moderation gate.
+func (s *WindowsService) unregisterModeratedSession(sessionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.moderatedSessions, sessionID)
+}
+
+// JoinSession adds a participant to an active moderated session. Joining
+// as a moderator may release a session that is waiting to start.
+func (s *WindowsService) JoinSession(sessionID string, participant auth.SessionAccessContext) error {
+	m, err := s.findModeratedSession(sessionID)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(m.join(participant))
+}
+
+// LeaveSession removes a participant from an active moderated session.
+// If a required moderator leaves a running session, the session is
+// terminated.
+func (s *WindowsService) LeaveSession(sessionID, username string) error {
+	m, err := s.findModeratedSession(sessionID)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(m.leave(username))
+}
+
+func (s *WindowsService) findModeratedSession(sessionID string) (*moderatedSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, ok := s.moderatedSessions[sessionID]
+	if !ok {
+		return nil, trace.NotFound("no moderated desktop session with ID %q", sessionID)
+	}
+	return m, nil
+}
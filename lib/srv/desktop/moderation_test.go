@@ -0,0 +1,113 @@
+// Teleport
+// Copyright (C) 2024 Gravitational, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package desktop
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gravitational/trace"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gravitational/teleport/api/types"
+	"github.com/gravitational/teleport/lib/auth"
+)
+
+// moderationTestPolicy returns the policy sets of a session owner whose
+// roles require one moderator, along with a participant that fulfills the
+// requirement.
+func moderationTestPolicy(t *testing.T) ([]*types.SessionTrackerPolicySet, auth.SessionAccessContext) {
+	hostRole, err := types.NewRole("host", types.RoleSpecV6{})
+	require.NoError(t, err)
+	hostRole.SetSessionRequirePolicies([]*types.SessionRequirePolicy{{
+		Name:    "require one moderator",
+		Filter:  `contains(user.roles, "moderator")`,
+		Kinds:   []string{string(types.WindowsDesktopSessionKind)},
+		Modes:   []string{string(types.SessionModeratorMode)},
+		Count:   1,
+		OnLeave: string(types.OnSessionLeaveTerminate),
+	}})
+
+	moderatorRole, err := types.NewRole("moderator", types.RoleSpecV6{})
+	require.NoError(t, err)
+	moderatorRole.SetSessionJoinPolicies([]*types.SessionJoinPolicy{{
+		Name:  "join desktop sessions",
+		Roles: []string{hostRole.GetName()},
+		Kinds: []string{string(types.WindowsDesktopSessionKind)},
+		Modes: []string{string(types.SessionModeratorMode)},
+	}})
+
+	policySet := hostRole.GetSessionPolicySet()
+	moderator := auth.SessionAccessContext{
+		Username: "moderator-1",
+		Roles:    []types.Role{moderatorRole},
+		Mode:     types.SessionModeratorMode,
+	}
+
+	return []*types.SessionTrackerPolicySet{&policySet}, moderator
+}
+
+func TestModeratedSessionReleasesOnJoin(t *testing.T) {
+	policySets, moderator := moderationTestPolicy(t)
+	m := newModeratedSession(policySets, "alice", func() {
+		t.Error("session must not be terminated during this test")
+	})
+	require.True(t, m.isModerated())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// The gate stays closed until the moderator joins.
+	shortCtx, shortCancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer shortCancel()
+	require.Error(t, m.waitForModerators(shortCtx))
+
+	require.NoError(t, m.join(moderator))
+	require.NoError(t, m.waitForModerators(ctx))
+}
+
+func TestModeratedSessionTerminatesOnLeave(t *testing.T) {
+	policySets, moderator := moderationTestPolicy(t)
+	terminated := make(chan struct{})
+	m := newModeratedSession(policySets, "alice", func() { close(terminated) })
+
+	require.NoError(t, m.join(moderator))
+	require.NoError(t, m.waitForModerators(context.Background()))
+
+	require.NoError(t, m.leave(moderator.Username))
+	select {
+	case <-terminated:
+	default:
+		t.Fatal("expected the session to be terminated after the moderator left")
+	}
+}
+
+func TestUnmoderatedSessionDoesNotBlock(t *testing.T) {
+	m := newModeratedSession(nil, "alice", func() {
+		t.Error("session must not be terminated during this test")
+	})
+	require.False(t, m.isModerated())
+	require.NoError(t, m.waitForModerators(context.Background()))
+}
+
+func TestJoinUnknownSession(t *testing.T) {
+	s := &WindowsService{moderatedSessions: make(map[string]*moderatedSession)}
+	err := s.JoinSession("missing", auth.SessionAccessContext{Username: "moderator-1"})
+	require.Error(t, err)
+	require.True(t, trace.IsNotFound(err))
+}
@@ -0,0 +1,184 @@
+// Teleport
+// Copyright (C) 2024 Gravitational, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package desktop
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"github.com/gravitational/trace"
+)
+
+// outputBudget caps the total amount of desktop output that can be in
+// flight across all sessions on this service. Each session is entitled to
+// an equal share of the budget: when the budget is exhausted, writes from
+// sessions over their share block until earlier output drains, while
+// sessions under their share are never held up by a heavier neighbor.
+type outputBudget struct {
+	// limit is the service-wide cap in bytes.
+	limit int64
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	inFlight int64
+	sessions map[*sessionBudget]struct{}
+}
+
+// newOutputBudget creates a budget capped at limit bytes. A zero limit
+// means output is unbounded, in which case nil is returned; a nil budget
+// is valid and never throttles anything.
+func newOutputBudget(limit int64) *outputBudget {
+	if limit == 0 {
+		return nil
+	}
+	b := &outputBudget{
+		limit:    limit,
+		sessions: make(map[*sessionBudget]struct{}),
+	}
+	b.cond = sync.NewCond(&b.mu)
+	return b
+}
+
+// addSession registers a session drawing from this budget and returns its
+// accounting handle. The handle must be closed when the session ends so
+// that the remaining sessions get a larger share.
+func (b *outputBudget) addSession() *sessionBudget {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	sb := &sessionBudget{budget: b}
+	b.sessions[sb] = struct{}{}
+	return sb
+}
+
+// wrap charges conn's writes against the budget for the lifetime of the
+// connection. It returns conn unchanged when there is no budget.
+func (b *outputBudget) wrap(ctx context.Context, conn net.Conn) net.Conn {
+	if b == nil {
+		return conn
+	}
+	return &budgetedConn{Conn: conn, ctx: ctx, session: b.addSession()}
+}
+
+// sessionBudget tracks a single session's portion of the service-wide
+// output budget.
+type sessionBudget struct {
+	budget *outputBudget
+	used   int64
+	closed bool
+}
+
+// acquire charges n bytes against the session, blocking while the
+// service-wide budget is exhausted and this session is over its fair
+// share. It fails if the session is closed or ctx is canceled before
+// the bytes can be charged.
+func (s *sessionBudget) acquire(ctx context.Context, n int64) error {
+	if s == nil {
+		return nil
+	}
+	b := s.budget
+
+	// Wake the wait loop below if the context is canceled. The lock is
+	// taken here so the broadcast can't slip in between the context check
+	// and the wait.
+	stop := context.AfterFunc(ctx, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.cond.Broadcast()
+	})
+	defer stop()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for {
+		if s.closed {
+			return trace.Wrap(net.ErrClosed)
+		}
+		if err := ctx.Err(); err != nil {
+			return trace.Wrap(err)
+		}
+		// Light sessions are allowed to overshoot an exhausted budget by
+		// up to their fair share, which bounds total memory at twice the
+		// limit in the worst case but guarantees that no session can be
+		// starved by a heavier one.
+		share := b.limit / int64(len(b.sessions))
+		if b.inFlight+n <= b.limit || s.used+n <= share {
+			s.used += n
+			b.inFlight += n
+			return nil
+		}
+		b.cond.Wait()
+	}
+}
+
+// release refunds n bytes previously charged with acquire.
+func (s *sessionBudget) release(n int64) {
+	if s == nil {
+		return
+	}
+	b := s.budget
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s.used -= n
+	b.inFlight -= n
+	b.cond.Broadcast()
+}
+
+// close removes the session from the budget, refunding anything it still
+// holds and failing any of its writes blocked on the budget.
+func (s *sessionBudget) close() {
+	if s == nil {
+		return
+	}
+	b := s.budget
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	b.inFlight -= s.used
+	s.used = 0
+	delete(b.sessions, s)
+	b.cond.Broadcast()
+}
+
+// budgetedConn charges every write against the service-wide output
+// budget for the duration of the write, so that output stuck behind a
+// slow client counts against the budget until it drains.
+type budgetedConn struct {
+	net.Conn
+	ctx     context.Context
+	session *sessionBudget
+}
+
+func (c *budgetedConn) Write(p []byte) (int, error) {
+	n := int64(len(p))
+	if err := c.session.acquire(c.ctx, n); err != nil {
+		return 0, trace.Wrap(err)
+	}
+	defer c.session.release(n)
+	return c.Conn.Write(p)
+}
+
+func (c *budgetedConn) Close() error {
+	c.session.close()
+	return c.Conn.Close()
+}
@@ -0,0 +1,86 @@
+// Teleport
+// Copyright (C) 2024 Gravitational, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package desktop
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutputBudgetThrottlesHeavySession(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	b := newOutputBudget(1000)
+	heavy := b.addSession()
+	light := b.addSession()
+
+	// The heavy session can use the whole budget while nobody else needs it.
+	require.NoError(t, heavy.acquire(ctx, 900))
+
+	// With the budget exhausted and the heavy session over its 500 byte
+	// share, its next write blocks.
+	acquired := make(chan error, 1)
+	go func() {
+		acquired <- heavy.acquire(ctx, 200)
+	}()
+	select {
+	case err := <-acquired:
+		t.Fatalf("expected the heavy session to be throttled, got %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// The light session stays under its share, so it is not held up by
+	// the heavy one.
+	require.NoError(t, light.acquire(ctx, 200))
+
+	// Once the heavy session's earlier output drains, its blocked write
+	// goes through.
+	heavy.release(900)
+	select {
+	case err := <-acquired:
+		require.NoError(t, err)
+	case <-ctx.Done():
+		t.Fatal("the throttled write was not released after output drained")
+	}
+}
+
+func TestOutputBudgetCancel(t *testing.T) {
+	b := newOutputBudget(100)
+	sb := b.addSession()
+	require.NoError(t, sb.acquire(context.Background(), 100))
+
+	// A throttled write fails when the session context is canceled.
+	ctx, cancel := context.WithCancel(context.Background())
+	acquired := make(chan error, 1)
+	go func() {
+		acquired <- sb.acquire(ctx, 100)
+	}()
+	cancel()
+	require.ErrorIs(t, <-acquired, context.Canceled)
+}
+
+func TestOutputBudgetUnlimited(t *testing.T) {
+	// A zero cap disables the budget entirely.
+	b := newOutputBudget(0)
+	require.Nil(t, b)
+	sb := b.addSession()
+	require.NoError(t, sb.acquire(context.Background(), 1<<40))
+}
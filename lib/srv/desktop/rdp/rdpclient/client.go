@@ -226,6 +226,14 @@ func (c *Client) readClientUsername() error {
 			continue
 		}
 		c.cfg.Log.Debugf("Got RDP username %q", u.Username)
+		if u.Username == "" {
+			if c.cfg.DefaultLogin == "" {
+				return trace.BadParameter("no Windows login was requested and no default login is available for this desktop")
+			}
+			c.cfg.Log.Debugf("Client did not specify a login, using default %q", c.cfg.DefaultLogin)
+			c.username = c.cfg.DefaultLogin
+			return nil
+		}
 		c.username = u.Username
 		return nil
 	}
@@ -1011,6 +1019,6 @@ func (c *Client) GetClientLastActive() time.Time {
 // UpdateClientActivity updates the client activity timestamp.
 func (c *Client) UpdateClientActivity() {
 	c.clientActivityMu.Lock()
-	c.clientLastActive = time.Now().UTC()
+	c.clientLastActive = c.cfg.Clock.Now().UTC()
 	c.clientActivityMu.Unlock()
 }
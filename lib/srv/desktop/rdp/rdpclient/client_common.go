@@ -21,10 +21,14 @@ package rdpclient
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"image/png"
+	"strings"
 	"time"
 
 	"github.com/gravitational/trace"
+	"github.com/jonboulle/clockwork"
 	"github.com/sirupsen/logrus"
 
 	"github.com/gravitational/teleport/lib/srv/desktop/tdp"
@@ -41,6 +45,11 @@ type Config struct {
 	// AuthorizeFn is called to authorize a user connecting to a Windows desktop.
 	AuthorizeFn func(login string) error
 
+	// DefaultLogin is the Windows login to use when the client doesn't
+	// specify one. If it is also empty, a connection without an explicit
+	// login is rejected.
+	DefaultLogin string
+
 	// Conn handles TDP messages between Windows Desktop Service
 	// and a Teleport Proxy.
 	Conn *tdp.Conn
@@ -56,21 +65,107 @@ type Config struct {
 	// directory sharing.
 	AllowDirectorySharing bool
 
+	// AllowPrinter indicates whether the RDP connection should enable
+	// printer redirection. It is only honored by client implementations
+	// that support the printer virtual channel; the built-in client
+	// does not.
+	AllowPrinter bool
+
+	// AllowAudio indicates whether the RDP connection should enable audio
+	// output redirection. It is only honored by client implementations
+	// that support the audio virtual channel; the built-in client
+	// does not.
+	AllowAudio bool
+
 	// ShowDesktopWallpaper determines whether desktop sessions will show a
 	// user-selected wallpaper vs a system-default, single-color wallpaper.
 	ShowDesktopWallpaper bool
 
+	// RestrictedAdminMode requests Windows Restricted Admin mode during the
+	// RDP security negotiation, for hardened hosts that refuse plain
+	// credential delegation. Hosts that don't support the mode reject the
+	// negotiation; [Config.ConnectError] turns that rejection into an
+	// actionable error.
+	RestrictedAdminMode bool
+
+	// PerformanceProfile selects a preset of RDP performance flags. The
+	// zero value means the balanced broadband profile. See
+	// [Config.PerformanceFlags].
+	PerformanceProfile PerformanceProfile
+
+	// CustomPerformanceFlags is the flag bitmask used when
+	// PerformanceProfile is [PerformanceProfileCustom]. It is ignored for
+	// every other profile.
+	CustomPerformanceFlags PerformanceFlags
+
 	// Width and Height optionally override the dimensions received from
 	// the browser and force the session to use a particular size.
 	Width, Height uint32
 
+	// HostCertPins is the set of acceptable SHA-256 fingerprints
+	// (hex-encoded, optionally colon-separated) for the TLS certificate
+	// presented by the RDP server. When empty, any server certificate is
+	// accepted, matching the traditional behavior.
+	HostCertPins []string
+
 	// Log is the logger for status messages.
 	Log logrus.FieldLogger
+
+	// Clock is the source of time for activity timestamps, so that tests
+	// can substitute a fake clock. Defaults to the real clock.
+	Clock clockwork.Clock
 }
 
 // GenerateUserCertFn generates user certificates for RDP authentication.
 type GenerateUserCertFn func(ctx context.Context, username string, ttl time.Duration) (certDER, keyDER []byte, err error)
 
+// NormalizeCertPin converts a SHA-256 certificate fingerprint to its
+// canonical form: lowercase hex with no separators. It returns an error
+// if the fingerprint isn't a valid SHA-256 digest.
+func NormalizeCertPin(pin string) (string, error) {
+	normalized := strings.ToLower(strings.ReplaceAll(pin, ":", ""))
+	if _, err := hex.DecodeString(normalized); err != nil || len(normalized) != sha256.Size*2 {
+		return "", trace.BadParameter("certificate pin %q is not a SHA-256 fingerprint", pin)
+	}
+	return normalized, nil
+}
+
+// VerifyServerCert checks the DER-encoded TLS certificate presented by the
+// RDP server against the configured pins. It is a no-op when no pins are
+// configured.
+func (c *Config) VerifyServerCert(der []byte) error {
+	if len(c.HostCertPins) == 0 {
+		return nil
+	}
+	fingerprint := sha256.Sum256(der)
+	got := hex.EncodeToString(fingerprint[:])
+	for _, pin := range c.HostCertPins {
+		normalized, err := NormalizeCertPin(pin)
+		if err != nil {
+			// Invalid pins are rejected when the service config is
+			// validated; skip them here so they can never match.
+			continue
+		}
+		if normalized == got {
+			return nil
+		}
+	}
+	return trace.AccessDenied("certificate presented by the RDP server at %v does not match any configured pin", c.Addr)
+}
+
+// ConnectError annotates a connection failure with the configured auth
+// mode. When Restricted Admin mode was requested, a failed connection
+// commonly means the host doesn't support the mode, so the error says so
+// instead of surfacing a generic protocol error.
+func (c *Config) ConnectError(err error) error {
+	if err == nil || !c.RestrictedAdminMode {
+		return trace.Wrap(err)
+	}
+	return trace.ConnectionProblem(err,
+		"could not connect to the RDP server at %v with Restricted Admin mode; if the host does not support the mode, enable it on the host or turn it off in the desktop service configuration",
+		c.Addr)
+}
+
 //nolint:unused // used in client.go that is behind desktop_access_rdp build flag
 func (c *Config) checkAndSetDefaults() error {
 	if c.Addr == "" {
@@ -85,12 +180,18 @@ func (c *Config) checkAndSetDefaults() error {
 	if c.AuthorizeFn == nil {
 		return trace.BadParameter("missing AuthorizeFn in rdpclient.Config")
 	}
+	if err := CheckPerformanceProfile(c.PerformanceProfile); err != nil {
+		return trace.Wrap(err)
+	}
 	if c.Encoder == nil {
 		c.Encoder = tdp.PNGEncoder()
 	}
 	if c.Log == nil {
 		c.Log = logrus.New()
 	}
+	if c.Clock == nil {
+		c.Clock = clockwork.NewRealClock()
+	}
 	c.Log = c.Log.WithField("rdp-addr", c.Addr)
 	return nil
 }
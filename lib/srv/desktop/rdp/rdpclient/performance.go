@@ -0,0 +1,107 @@
+/*
+ * Teleport
+ * Copyright (C) 2024  Gravitational, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package rdpclient
+
+import "github.com/gravitational/trace"
+
+// PerformanceFlags is a bitmask of RDP performance flags, which turn off
+// costly visual features to optimize the session for constrained links.
+// The values match the performanceFlags field of the
+// TS_EXTENDED_INFO_PACKET in MS-RDPBCGR.
+type PerformanceFlags uint32
+
+const (
+	// PerfDisableWallpaper disables the desktop wallpaper.
+	PerfDisableWallpaper PerformanceFlags = 0x0001
+	// PerfDisableFullWindowDrag shows only the window outline while dragging.
+	PerfDisableFullWindowDrag PerformanceFlags = 0x0002
+	// PerfDisableMenuAnimations disables menu open/close animations.
+	PerfDisableMenuAnimations PerformanceFlags = 0x0004
+	// PerfDisableTheming disables user interface themes.
+	PerfDisableTheming PerformanceFlags = 0x0008
+	// PerfDisableCursorShadow disables the shadow under the mouse cursor.
+	PerfDisableCursorShadow PerformanceFlags = 0x0020
+	// PerfDisableCursorSettings disables cursor blinking.
+	PerfDisableCursorSettings PerformanceFlags = 0x0040
+	// PerfEnableFontSmoothing enables font anti-aliasing.
+	PerfEnableFontSmoothing PerformanceFlags = 0x0080
+	// PerfEnableDesktopComposition enables desktop composition effects.
+	PerfEnableDesktopComposition PerformanceFlags = 0x0100
+)
+
+// PerformanceProfile names a preset of RDP performance flags.
+type PerformanceProfile string
+
+const (
+	// PerformanceProfileLAN enables every visual feature; suitable for
+	// fast, low-latency links.
+	PerformanceProfileLAN PerformanceProfile = "lan"
+	// PerformanceProfileBroadband is a balanced profile which keeps font
+	// smoothing but drops the costlier visual features. It is the default.
+	PerformanceProfileBroadband PerformanceProfile = "broadband"
+	// PerformanceProfileLowBandwidth disables every optional visual
+	// feature to minimize bandwidth usage.
+	PerformanceProfileLowBandwidth PerformanceProfile = "low-bandwidth"
+	// PerformanceProfileCustom uses the flags from
+	// Config.CustomPerformanceFlags verbatim.
+	PerformanceProfileCustom PerformanceProfile = "custom"
+)
+
+// CheckPerformanceProfile validates a performance profile name. The empty
+// string is allowed and means the default (broadband) profile.
+func CheckPerformanceProfile(profile PerformanceProfile) error {
+	switch profile {
+	case "", PerformanceProfileLAN, PerformanceProfileBroadband,
+		PerformanceProfileLowBandwidth, PerformanceProfileCustom:
+		return nil
+	default:
+		return trace.BadParameter("unsupported RDP performance profile %q", profile)
+	}
+}
+
+// flags returns the flag preset for the profile. The custom flags are only
+// consulted for [PerformanceProfileCustom].
+func (p PerformanceProfile) flags(custom PerformanceFlags) PerformanceFlags {
+	switch p {
+	case PerformanceProfileLAN:
+		return PerfEnableFontSmoothing | PerfEnableDesktopComposition
+	case PerformanceProfileLowBandwidth:
+		return PerfDisableWallpaper | PerfDisableFullWindowDrag |
+			PerfDisableMenuAnimations | PerfDisableTheming |
+			PerfDisableCursorShadow | PerfDisableCursorSettings
+	case PerformanceProfileCustom:
+		return custom
+	default: // broadband
+		return PerfDisableWallpaper | PerfDisableFullWindowDrag |
+			PerfDisableMenuAnimations | PerfEnableFontSmoothing
+	}
+}
+
+// PerformanceFlags resolves the configured performance profile to the flag
+// bitmask sent to the RDP server. The ShowDesktopWallpaper setting takes
+// precedence over the wallpaper bit of the selected profile.
+func (c *Config) PerformanceFlags() PerformanceFlags {
+	flags := c.PerformanceProfile.flags(c.CustomPerformanceFlags)
+	if c.ShowDesktopWallpaper {
+		flags &^= PerfDisableWallpaper
+	} else {
+		flags |= PerfDisableWallpaper
+	}
+	return flags
+}
@@ -0,0 +1,148 @@
+/*
+ * Teleport
+ * Copyright (C) 2024  Gravitational, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package desktop
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+)
+
+// reusableRDPClient is implemented by RDP clients whose connection can
+// survive the end of a session and be handed to the next one. The CGO
+// client doesn't support this yet, so pooling only applies to backends
+// that do.
+type reusableRDPClient interface {
+	RDPClient
+	io.Closer
+	// Reusable reports whether the client is still healthy enough to
+	// serve another session.
+	Reusable() bool
+}
+
+// rdpPoolKey scopes pooled connections. A warm connection may only be
+// reused by the same Teleport user connecting to the same desktop with
+// the same Windows login; connections are never shared across users or
+// logins.
+type rdpPoolKey struct {
+	teleportUser string
+	windowsLogin string
+	desktopAddr  string
+}
+
+// pooledRDPClient is a warm connection waiting to be picked up, along
+// with the time it was last used for idle eviction.
+type pooledRDPClient struct {
+	client   reusableRDPClient
+	lastUsed time.Time
+}
+
+// rdpConnPool keeps authenticated RDP connections warm for a short idle
+// window so that high-frequency short interactions with the same desktop
+// don't pay the full handshake cost each time. Connections idle longer
+// than the window are closed.
+type rdpConnPool struct {
+	clock clockwork.Clock
+	idle  time.Duration
+
+	mu    sync.Mutex
+	conns map[rdpPoolKey]*pooledRDPClient
+}
+
+func newRDPConnPool(clock clockwork.Clock, idleTimeout time.Duration) *rdpConnPool {
+	return &rdpConnPool{
+		clock: clock,
+		idle:  idleTimeout,
+		conns: make(map[rdpPoolKey]*pooledRDPClient),
+	}
+}
+
+// take removes and returns the warm connection for the key, if there is
+// one and it hasn't been idle longer than the eviction window. Stale
+// connections are closed on the spot.
+func (p *rdpConnPool) take(key rdpPoolKey) (reusableRDPClient, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	entry, ok := p.conns[key]
+	if !ok {
+		return nil, false
+	}
+	delete(p.conns, key)
+
+	if p.clock.Now().Sub(entry.lastUsed) > p.idle {
+		entry.client.Close()
+		return nil, false
+	}
+	return entry.client, true
+}
+
+// put stores a warm connection for later reuse. If a connection is
+// already pooled for the key, the displaced one is closed.
+func (p *rdpConnPool) put(key rdpPoolKey, client reusableRDPClient) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if existing, ok := p.conns[key]; ok {
+		existing.client.Close()
+	}
+	p.conns[key] = &pooledRDPClient{
+		client:   client,
+		lastUsed: p.clock.Now(),
+	}
+}
+
+// evictIdle closes and removes all connections that have been idle
+// longer than the eviction window.
+func (p *rdpConnPool) evictIdle() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.clock.Now()
+	for key, entry := range p.conns {
+		if now.Sub(entry.lastUsed) > p.idle {
+			entry.client.Close()
+			delete(p.conns, key)
+		}
+	}
+}
+
+// run periodically evicts idle connections until the context is
+// canceled, then closes whatever is left in the pool.
+func (p *rdpConnPool) run(ctx context.Context) {
+	t := p.clock.NewTicker(p.idle)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.Chan():
+			p.evictIdle()
+		case <-ctx.Done():
+			p.mu.Lock()
+			for key, entry := range p.conns {
+				entry.client.Close()
+				delete(p.conns, key)
+			}
+			p.mu.Unlock()
+			return
+		}
+	}
+}
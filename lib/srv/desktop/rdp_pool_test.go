@@ -0,0 +1,116 @@
+/*
+ * Teleport
+ * Copyright (C) 2024  Gravitational, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package desktop
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeReusableRDPClient is a reusableRDPClient for pool tests.
+type fakeReusableRDPClient struct {
+	username string
+	closed   bool
+	reusable bool
+}
+
+func (f *fakeReusableRDPClient) Run(ctx context.Context) error { return nil }
+func (f *fakeReusableRDPClient) GetClientUsername() string     { return f.username }
+func (f *fakeReusableRDPClient) GetClientLastActive() time.Time {
+	return time.Time{}
+}
+func (f *fakeReusableRDPClient) UpdateClientActivity() {}
+func (f *fakeReusableRDPClient) Close() error {
+	f.closed = true
+	return nil
+}
+func (f *fakeReusableRDPClient) Reusable() bool { return f.reusable }
+
+func TestRDPPoolReuseWithinWindow(t *testing.T) {
+	clock := clockwork.NewFakeClock()
+	pool := newRDPConnPool(clock, time.Minute)
+
+	key := rdpPoolKey{
+		teleportUser: "alice",
+		windowsLogin: "Administrator",
+		desktopAddr:  "desktop.example.com:3389",
+	}
+	client := &fakeReusableRDPClient{username: "Administrator", reusable: true}
+	pool.put(key, client)
+
+	// Within the idle window the same scope gets the warm connection back.
+	clock.Advance(30 * time.Second)
+	got, ok := pool.take(key)
+	require.True(t, ok)
+	require.Same(t, client, got)
+	require.False(t, client.closed)
+
+	// The connection was taken out of the pool, so a second take misses.
+	_, ok = pool.take(key)
+	require.False(t, ok)
+
+	// A different scope never sees the connection.
+	pool.put(key, client)
+	otherUser := key
+	otherUser.teleportUser = "bob"
+	_, ok = pool.take(otherUser)
+	require.False(t, ok)
+	otherLogin := key
+	otherLogin.windowsLogin = "alice.ad"
+	_, ok = pool.take(otherLogin)
+	require.False(t, ok)
+}
+
+func TestRDPPoolEvictionAfterIdle(t *testing.T) {
+	clock := clockwork.NewFakeClock()
+	pool := newRDPConnPool(clock, time.Minute)
+
+	key := rdpPoolKey{
+		teleportUser: "alice",
+		windowsLogin: "Administrator",
+		desktopAddr:  "desktop.example.com:3389",
+	}
+
+	// A take after the idle window closes the stale connection.
+	client := &fakeReusableRDPClient{username: "Administrator", reusable: true}
+	pool.put(key, client)
+	clock.Advance(time.Minute + time.Second)
+	_, ok := pool.take(key)
+	require.False(t, ok)
+	require.True(t, client.closed)
+
+	// The periodic eviction closes stale connections too.
+	client = &fakeReusableRDPClient{username: "Administrator", reusable: true}
+	pool.put(key, client)
+	clock.Advance(time.Minute + time.Second)
+	pool.evictIdle()
+	require.True(t, client.closed)
+	require.Empty(t, pool.conns)
+
+	// Pooling a connection for a scope that already has one closes the
+	// displaced connection.
+	displaced := &fakeReusableRDPClient{username: "Administrator", reusable: true}
+	pool.put(key, displaced)
+	pool.put(key, &fakeReusableRDPClient{username: "Administrator", reusable: true})
+	require.True(t, displaced.closed)
+}
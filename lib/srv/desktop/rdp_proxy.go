@@ -0,0 +1,75 @@
+/*
+ * Teleport
+ * Copyright (C) 2024  Gravitational, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package desktop
+
+import (
+	"context"
+	"net"
+	"net/url"
+
+	"github.com/gravitational/trace"
+
+	apiclient "github.com/gravitational/teleport/api/client"
+	"github.com/gravitational/teleport/lib/utils"
+)
+
+// startRDPProxyRelay returns the address the RDP backend should dial in
+// order to reach the desktop at targetAddr through the configured HTTP
+// CONNECT proxy. The backend dials its target itself, so the connection
+// can't be pre-established on its behalf; instead a relay is opened on
+// localhost, and the first connection accepted there is tunneled to the
+// desktop through the proxy. The relay accepts a single connection and
+// shuts down when the session context is canceled.
+func (s *WindowsService) startRDPProxyRelay(ctx context.Context, targetAddr string) (string, error) {
+	proxyURL, err := url.Parse(s.cfg.RDPProxyURL)
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+
+	// Unblock the Accept below if the backend never dials the relay.
+	go func() {
+		<-ctx.Done()
+		lis.Close()
+	}()
+
+	go func() {
+		local, err := lis.Accept()
+		lis.Close()
+		if err != nil {
+			return
+		}
+		remote, err := apiclient.DialProxy(ctx, proxyURL, targetAddr)
+		if err != nil {
+			s.cfg.Log.WithError(err).Warnf("Failed to connect to desktop %v through the RDP proxy at %v",
+				targetAddr, proxyURL.Host)
+			local.Close()
+			return
+		}
+		if err := utils.ProxyConn(ctx, local, remote); err != nil && !utils.IsOKNetworkError(err) {
+			s.cfg.Log.WithError(err).Debugf("RDP proxy relay for desktop %v closed", targetAddr)
+		}
+	}()
+
+	return lis.Addr().String(), nil
+}
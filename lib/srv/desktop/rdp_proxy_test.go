@@ -0,0 +1,119 @@
+/*
+ * Teleport
+ * Copyright (C) 2024  Gravitational, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package desktop
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeConnectProxy is a minimal HTTP CONNECT proxy. It accepts a single
+// connection, records the CONNECT target, and tunnels the rest of the
+// connection to it.
+func fakeConnectProxy(t *testing.T) (addr string, targets <-chan string) {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { lis.Close() })
+
+	ch := make(chan string, 1)
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		req, err := http.ReadRequest(bufio.NewReader(conn))
+		if err != nil || req.Method != http.MethodConnect {
+			conn.Write([]byte("HTTP/1.1 400 Bad Request\r\n\r\n"))
+			return
+		}
+		ch <- req.Host
+
+		upstream, err := net.Dial("tcp", req.Host)
+		if err != nil {
+			conn.Write([]byte("HTTP/1.1 502 Bad Gateway\r\n\r\n"))
+			return
+		}
+		defer upstream.Close()
+		if _, err := conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n")); err != nil {
+			return
+		}
+
+		go io.Copy(upstream, conn)
+		io.Copy(conn, upstream)
+	}()
+
+	return lis.Addr().String(), ch
+}
+
+func TestRDPDialThroughConnectProxy(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	// A fake desktop that echoes whatever it receives.
+	desktop, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { desktop.Close() })
+	go func() {
+		conn, err := desktop.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.Copy(conn, conn)
+	}()
+
+	proxyAddr, targets := fakeConnectProxy(t)
+	s := &WindowsService{
+		cfg: WindowsServiceConfig{
+			Log:         logrus.New(),
+			RDPProxyURL: "http://" + proxyAddr,
+		},
+	}
+
+	relayAddr, err := s.startRDPProxyRelay(ctx, desktop.Addr().String())
+	require.NoError(t, err)
+	require.NotEqual(t, desktop.Addr().String(), relayAddr)
+
+	// Dialing the relay (as the RDP backend would) reaches the desktop.
+	conn, err := net.Dial("tcp", relayAddr)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	msg := []byte("rdp-hello")
+	_, err = conn.Write(msg)
+	require.NoError(t, err)
+	echo := make([]byte, len(msg))
+	_, err = io.ReadFull(conn, echo)
+	require.NoError(t, err)
+	require.Equal(t, msg, echo)
+
+	// The connection went through the proxy and targeted the desktop.
+	require.Equal(t, desktop.Addr().String(), <-targets)
+}
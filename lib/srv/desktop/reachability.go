@@ -0,0 +1,121 @@
+/*
+ * Teleport
+ * Copyright (C) 2024  Gravitational, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package desktop
+
+import (
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/gravitational/trace"
+
+	"github.com/gravitational/teleport/api/types"
+)
+
+// ReachableLabel is applied to announced desktops with the outcome of the
+// last reachability probe ("true" or "false"), so that the UI can gray out
+// hosts that don't accept RDP connections. It is only present when the
+// probe is enabled.
+const ReachableLabel = types.TeleportNamespace + "/reachable"
+
+const (
+	// defaultProbeInterval is how long a probe result is served from the
+	// cache before the address is dialed again.
+	defaultProbeInterval = 5 * time.Minute
+	// minProbeInterval is the lowest configurable probe interval. It keeps
+	// the probe from turning every heartbeat into a connection attempt
+	// against the fleet.
+	minProbeInterval = 30 * time.Second
+	// defaultProbeTimeout bounds a single connection attempt.
+	defaultProbeTimeout = 5 * time.Second
+)
+
+// ReachabilityProbeConfig configures the optional probe that checks whether
+// announced desktops accept TCP connections on their RDP port.
+type ReachabilityProbeConfig struct {
+	// Enabled turns the probe on. When disabled (the default), desktops are
+	// announced without a reachability label.
+	Enabled bool
+	// Interval is the minimum time between two probes of the same address.
+	// In between, the cached result is reused. Defaults to 5 minutes and
+	// may not be lower than 30 seconds.
+	Interval time.Duration
+	// Timeout bounds a single connection attempt.
+	// Defaults to 5 seconds.
+	Timeout time.Duration
+}
+
+// CheckAndSetDefaults checks the configuration for its validity and sets default values if needed
+func (c *ReachabilityProbeConfig) CheckAndSetDefaults() error {
+	if c.Interval == 0 {
+		c.Interval = defaultProbeInterval
+	}
+	if c.Interval < minProbeInterval {
+		return trace.BadParameter("reachability probe interval %v is too low, minimum is %v", c.Interval, minProbeInterval)
+	}
+	if c.Timeout == 0 {
+		c.Timeout = defaultProbeTimeout
+	}
+	return nil
+}
+
+// probeResult is a cached reachability probe outcome.
+type probeResult struct {
+	reachable bool
+	at        time.Time
+}
+
+// probeReachability reports whether addr accepts TCP connections. Each
+// address is dialed at most once per probe interval; in between, the cached
+// result is returned, so frequent announces don't flood hosts with
+// connection attempts.
+func (s *WindowsService) probeReachability(addr string) bool {
+	now := s.cfg.Clock.Now()
+
+	s.probeMu.Lock()
+	if cached, ok := s.probeResults[addr]; ok && now.Sub(cached.at) < s.cfg.ReachabilityProbe.Interval {
+		s.probeMu.Unlock()
+		return cached.reachable
+	}
+	s.probeMu.Unlock()
+
+	conn, err := net.DialTimeout("tcp", addr, s.cfg.ReachabilityProbe.Timeout)
+	reachable := err == nil
+	if conn != nil {
+		conn.Close()
+	}
+
+	s.probeMu.Lock()
+	if s.probeResults == nil {
+		s.probeResults = make(map[string]probeResult)
+	}
+	s.probeResults[addr] = probeResult{reachable: reachable, at: now}
+	s.probeMu.Unlock()
+
+	return reachable
+}
+
+// applyReachabilityLabel stamps labels with the probe outcome for addr.
+// It is a no-op when the probe is disabled.
+func (s *WindowsService) applyReachabilityLabel(labels map[string]string, addr string) {
+	if !s.cfg.ReachabilityProbe.Enabled {
+		return
+	}
+	labels[ReachableLabel] = strconv.FormatBool(s.probeReachability(addr))
+}
@@ -0,0 +1,109 @@
+/*
+ * Teleport
+ * Copyright (C) 2024  Gravitational, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package desktop
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gravitational/trace"
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReachabilityProbeConfigDefaults(t *testing.T) {
+	cfg := ReachabilityProbeConfig{}
+	require.NoError(t, cfg.CheckAndSetDefaults())
+	require.Equal(t, defaultProbeInterval, cfg.Interval)
+	require.Equal(t, defaultProbeTimeout, cfg.Timeout)
+
+	tooLow := ReachabilityProbeConfig{Interval: time.Second}
+	err := tooLow.CheckAndSetDefaults()
+	require.True(t, trace.IsBadParameter(err))
+}
+
+func TestProbeReachability(t *testing.T) {
+	newService := func(clock clockwork.Clock) *WindowsService {
+		return &WindowsService{
+			cfg: WindowsServiceConfig{
+				Clock: clock,
+				ReachabilityProbe: ReachabilityProbeConfig{
+					Enabled:  true,
+					Interval: time.Minute,
+					Timeout:  2 * time.Second,
+				},
+			},
+		}
+	}
+
+	t.Run("reachable host", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		t.Cleanup(func() { ln.Close() })
+
+		s := newService(clockwork.NewFakeClockAt(time.Now()))
+		labels := map[string]string{}
+		s.applyReachabilityLabel(labels, ln.Addr().String())
+		require.Equal(t, "true", labels[ReachableLabel])
+	})
+
+	t.Run("refused host", func(t *testing.T) {
+		// Grab a free port, then close the listener so that connection
+		// attempts are refused.
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		addr := ln.Addr().String()
+		require.NoError(t, ln.Close())
+
+		s := newService(clockwork.NewFakeClockAt(time.Now()))
+		labels := map[string]string{}
+		s.applyReachabilityLabel(labels, addr)
+		require.Equal(t, "false", labels[ReachableLabel])
+	})
+
+	t.Run("results are cached per interval", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		addr := ln.Addr().String()
+
+		clock := clockwork.NewFakeClockAt(time.Now())
+		s := newService(clock)
+		require.True(t, s.probeReachability(addr))
+
+		// The host goes away, but within the interval the cached result
+		// is served without dialing again.
+		require.NoError(t, ln.Close())
+		require.True(t, s.probeReachability(addr))
+
+		// Past the interval, the next probe dials again and observes
+		// the refused connection.
+		clock.Advance(2 * time.Minute)
+		require.False(t, s.probeReachability(addr))
+	})
+
+	t.Run("disabled probe applies no label", func(t *testing.T) {
+		s := newService(clockwork.NewFakeClockAt(time.Now()))
+		s.cfg.ReachabilityProbe.Enabled = false
+
+		labels := map[string]string{}
+		s.applyReachabilityLabel(labels, "127.0.0.1:1")
+		require.Empty(t, labels)
+	})
+}
@@ -0,0 +1,140 @@
+/*
+ * Teleport
+ * Copyright (C) 2024  Gravitational, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package desktop
+
+import (
+	"github.com/gravitational/trace"
+)
+
+// Reconfigure applies the runtime-adjustable subset of a new configuration
+// to a running service, leaving active sessions untouched. The following
+// settings take effect for new connections:
+//
+//   - MaxConcurrentConnections
+//   - ConnectTimeout
+//   - CertSkewAllowance
+//   - DesktopLookupRetries
+//   - LDAP credentials (Username, BindDN, SID and the password source)
+//   - Heartbeat.StaticHosts
+//
+// An unset mutable value keeps its current setting. Changing anything else
+// requires a restart: such configurations are rejected without applying
+// any of the new settings.
+func (s *WindowsService) Reconfigure(cfg WindowsServiceConfig) error {
+	s.mu.Lock()
+	cur := s.cfg
+	ldapConfigured := s.ldapConfigured
+	s.mu.Unlock()
+
+	// Unset values keep the current setting, mirroring how
+	// CheckAndSetDefaults fills in defaults at construction time.
+	if cfg.ConnectTimeout == 0 {
+		cfg.ConnectTimeout = cur.ConnectTimeout
+	}
+	if cfg.DesktopLookupRetries == 0 {
+		cfg.DesktopLookupRetries = cur.DesktopLookupRetries
+	}
+	if cfg.AddressFamily == "" {
+		cfg.AddressFamily = cur.AddressFamily
+	}
+	if cfg.SNISuffix == "" {
+		cfg.SNISuffix = cur.SNISuffix
+	}
+
+	// Validate the mutable subset before touching anything.
+	if cfg.CertSkewAllowance < 0 {
+		return trace.BadParameter("WindowsServiceConfig contains a negative certificate skew allowance %v", cfg.CertSkewAllowance)
+	}
+	if cfg.MaxConcurrentConnections < 0 {
+		return trace.BadParameter("WindowsServiceConfig contains a negative connection cap %v", cfg.MaxConcurrentConnections)
+	}
+	if cfg.DesktopLookupRetries < 0 {
+		return trace.BadParameter("WindowsServiceConfig contains a negative desktop lookup retry count %v", cfg.DesktopLookupRetries)
+	}
+	if cfg.LDAPConfig.Addr != "" {
+		if err := cfg.LDAPConfig.CheckAndSetDefaults(); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+
+	if err := checkImmutableConfig(cur, cfg); err != nil {
+		return trace.Wrap(err)
+	}
+
+	ldapChanged := cfg.LDAPConfig != cur.LDAPConfig
+
+	s.mu.Lock()
+	s.cfg.MaxConcurrentConnections = cfg.MaxConcurrentConnections
+	s.cfg.ConnectTimeout = cfg.ConnectTimeout
+	s.cfg.CertSkewAllowance = cfg.CertSkewAllowance
+	s.cfg.DesktopLookupRetries = cfg.DesktopLookupRetries
+	s.cfg.LDAPConfig = cfg.LDAPConfig
+	s.cfg.Heartbeat.StaticHosts = cfg.Heartbeat.StaticHosts
+	s.mu.Unlock()
+
+	// New LDAP credentials only matter once the LDAP connection exists;
+	// reconnect so the next operation uses them.
+	if ldapChanged && ldapConfigured {
+		if err := s.initializeLDAP(); err != nil {
+			return trace.Wrap(err, "reconnecting LDAP with the new credentials")
+		}
+	}
+
+	return nil
+}
+
+// checkImmutableConfig verifies that cfg differs from the service's
+// current configuration only in settings that can be applied at runtime.
+// Everything checked here is wired into long-lived components when the
+// service is constructed, so changing it requires a restart.
+func checkImmutableConfig(cur, next WindowsServiceConfig) error {
+	for _, f := range []struct {
+		name    string
+		changed bool
+	}{
+		{"DataDir", next.DataDir != cur.DataDir},
+		{"Hostname", next.Hostname != cur.Hostname},
+		{"Heartbeat.HostUUID", next.Heartbeat.HostUUID != cur.Heartbeat.HostUUID},
+		{"Heartbeat.PublicAddr", next.Heartbeat.PublicAddr != cur.Heartbeat.PublicAddr},
+		{"Heartbeat.BatchStaticHosts", next.Heartbeat.BatchStaticHosts != cur.Heartbeat.BatchStaticHosts},
+		{"AddressFamily", next.AddressFamily != cur.AddressFamily},
+		{"SNISuffix", next.SNISuffix != cur.SNISuffix},
+		{"EnableProxyProtocol", next.EnableProxyProtocol != cur.EnableProxyProtocol},
+		{"ShowDesktopWallpaper", next.ShowDesktopWallpaper != cur.ShowDesktopWallpaper},
+		{"MaxOutputBuffered", next.MaxOutputBuffered != cur.MaxOutputBuffered},
+		{"ConnectionPoolIdleTimeout", next.ConnectionPoolIdleTimeout != cur.ConnectionPoolIdleTimeout},
+		{"RDPProxyURL", next.RDPProxyURL != cur.RDPProxyURL},
+		{"RequireHostCertPins", next.RequireHostCertPins != cur.RequireHostCertPins},
+		{"LDAPConfig.Addr", next.LDAPConfig.Addr != cur.LDAPConfig.Addr},
+		{"LDAPConfig.Domain", next.LDAPConfig.Domain != cur.LDAPConfig.Domain},
+		{"PKIDomain", next.PKIDomain != cur.PKIDomain},
+		{"DiscoveryBaseDN", next.DiscoveryBaseDN != cur.DiscoveryBaseDN},
+		{"CRLValidity", next.CRLValidity != cur.CRLValidity},
+		{"CRLRefreshInterval", next.CRLRefreshInterval != cur.CRLRefreshInterval},
+		{"CertSignatureAlgorithm", next.CertSignatureAlgorithm != cur.CertSignatureAlgorithm},
+		{"LoginTrait", next.LoginTrait != cur.LoginTrait},
+		{"SkipADUserLookup", next.SkipADUserLookup != cur.SkipADUserLookup},
+		{"LDAPReadOnly", next.LDAPReadOnly != cur.LDAPReadOnly},
+	} {
+		if f.changed {
+			return trace.BadParameter("%v cannot be changed at runtime, restart the service to apply it", f.name)
+		}
+	}
+	return nil
+}
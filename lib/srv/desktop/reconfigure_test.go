@@ -0,0 +1,145 @@
+/*
+ * Teleport
+ * Copyright (C) 2024  Gravitational, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package desktop
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gravitational/teleport/lib/auth"
+	"github.com/gravitational/teleport/lib/limiter"
+)
+
+// dialBurst opens count connections to addr and returns how many of them
+// the server closed immediately.
+func dialBurst(t *testing.T, addr string, count int) int {
+	t.Helper()
+
+	conns := make([]net.Conn, 0, count)
+	for i := 0; i < count; i++ {
+		conn, err := net.Dial("tcp", addr)
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+		conns = append(conns, conn)
+	}
+
+	closed := 0
+	for _, conn := range conns {
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+		if _, err := conn.Read(make([]byte, 1)); errors.Is(err, io.EOF) {
+			closed++
+		}
+	}
+	return closed
+}
+
+// TestReconfigureConnectionCap verifies that raising the connection cap
+// via Reconfigure takes effect for new connections without restarting
+// the accept loop.
+func TestReconfigureConnectionCap(t *testing.T) {
+	connLimiter, err := limiter.NewConnectionsLimiter(limiter.Config{})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	s := &WindowsService{
+		cfg: WindowsServiceConfig{
+			Log:                      logrus.New(),
+			Clock:                    clockwork.NewRealClock(),
+			TLS:                      &tls.Config{},
+			ConnLimiter:              connLimiter,
+			MaxConcurrentConnections: 1,
+		},
+		middleware: &auth.Middleware{},
+		closeCtx:   ctx,
+		close:      cancel,
+	}
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { lis.Close() })
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Serve(lis)
+	}()
+
+	// With a cap of one, a burst of three connections that never speak TLS
+	// leaves one holding its handler and two closed immediately.
+	require.Equal(t, 2, dialBurst(t, lis.Addr().String(), 3))
+
+	next := s.cfg
+	next.MaxConcurrentConnections = 3
+	require.NoError(t, s.Reconfigure(next))
+
+	// The raised cap applies to new connections: with one handler still
+	// held from the first burst, two of three now fit under the cap.
+	require.Equal(t, 1, dialBurst(t, lis.Addr().String(), 3))
+
+	lis.Close()
+	<-done
+}
+
+// TestReconfigureRejectsImmutableChanges verifies that settings which are
+// wired into the service at construction time cannot be changed live.
+func TestReconfigureRejectsImmutableChanges(t *testing.T) {
+	s := &WindowsService{
+		cfg: WindowsServiceConfig{
+			Log:      logrus.New(),
+			Clock:    clockwork.NewRealClock(),
+			Hostname: "desktop-service",
+		},
+	}
+
+	t.Run("hostname", func(t *testing.T) {
+		next := s.cfg
+		next.Hostname = "renamed"
+		err := s.Reconfigure(next)
+		require.Error(t, err)
+		require.ErrorContains(t, err, "Hostname")
+	})
+
+	t.Run("LDAP address", func(t *testing.T) {
+		next := s.cfg
+		next.LDAPConfig.Addr = "ldap.example.com:636"
+		next.LDAPConfig.Domain = "example.com"
+		next.LDAPConfig.Username = `EXAMPLE\svc-teleport`
+		err := s.Reconfigure(next)
+		require.Error(t, err)
+		require.ErrorContains(t, err, "LDAPConfig.Addr")
+	})
+
+	t.Run("mutable change applies", func(t *testing.T) {
+		next := s.cfg
+		next.DesktopLookupRetries = 5
+		require.NoError(t, s.Reconfigure(next))
+		require.Equal(t, 5, s.cfg.DesktopLookupRetries)
+	})
+}
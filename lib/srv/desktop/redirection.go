@@ -0,0 +1,61 @@
+/*
+ * Teleport
+ * Copyright (C) 2024  Gravitational, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package desktop
+
+import (
+	"github.com/gravitational/teleport/api/types"
+)
+
+// redirectionPolicy describes which device redirections a desktop session
+// may use. The zero value disables all redirection.
+type redirectionPolicy struct {
+	// Clipboard allows clipboard sharing between the user's workstation
+	// and the desktop.
+	Clipboard bool
+	// DirectorySharing allows sharing local directories with the desktop.
+	DirectorySharing bool
+	// Printer allows redirecting local printers to the desktop.
+	Printer bool
+	// Audio allows redirecting desktop audio output to the workstation.
+	Audio bool
+}
+
+// resolveRedirectionPolicy computes the effective redirection policy from
+// the user's roles. Combining roles can only narrow the policy: a
+// redirection is enabled only when every role allows it, and a user with no
+// roles gets no redirection at all. Printer and audio redirection have no
+// corresponding role option yet, so no role can grant them and they stay
+// disabled.
+func resolveRedirectionPolicy(roles []types.Role) redirectionPolicy {
+	if len(roles) == 0 {
+		return redirectionPolicy{}
+	}
+
+	policy := redirectionPolicy{
+		Clipboard:        true,
+		DirectorySharing: true,
+	}
+	for _, role := range roles {
+		opts := role.GetOptions()
+		policy.Clipboard = policy.Clipboard && types.BoolDefaultTrue(opts.DesktopClipboard)
+		policy.DirectorySharing = policy.DirectorySharing && types.BoolDefaultTrue(opts.DesktopDirectorySharing)
+	}
+
+	return policy
+}
@@ -0,0 +1,77 @@
+/*
+ * Teleport
+ * Copyright (C) 2024  Gravitational, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package desktop
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gravitational/teleport/api/types"
+)
+
+func newRoleWithOptions(t *testing.T, name string, opts types.RoleOptions) types.Role {
+	t.Helper()
+	role, err := types.NewRole(name, types.RoleSpecV6{Options: opts})
+	require.NoError(t, err)
+	return role
+}
+
+// TestResolveRedirectionPolicy verifies that the redirection policies of
+// multiple roles combine to the most restrictive effective set.
+func TestResolveRedirectionPolicy(t *testing.T) {
+	permissive := newRoleWithOptions(t, "permissive", types.RoleOptions{})
+	noClipboard := newRoleWithOptions(t, "no-clipboard", types.RoleOptions{
+		DesktopClipboard: types.NewBoolOption(false),
+	})
+	noDirectorySharing := newRoleWithOptions(t, "no-directory-sharing", types.RoleOptions{
+		DesktopDirectorySharing: types.NewBoolOption(false),
+	})
+
+	for _, test := range []struct {
+		name  string
+		roles []types.Role
+		want  redirectionPolicy
+	}{
+		{
+			name:  "no roles allows nothing",
+			roles: nil,
+			want:  redirectionPolicy{},
+		},
+		{
+			name:  "permissive roles",
+			roles: []types.Role{permissive},
+			want:  redirectionPolicy{Clipboard: true, DirectorySharing: true},
+		},
+		{
+			name:  "one role disables clipboard",
+			roles: []types.Role{permissive, noClipboard},
+			want:  redirectionPolicy{DirectorySharing: true},
+		},
+		{
+			name:  "restrictions from different roles accumulate",
+			roles: []types.Role{permissive, noClipboard, noDirectorySharing},
+			want:  redirectionPolicy{},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.want, resolveRedirectionPolicy(test.roles))
+		})
+	}
+}
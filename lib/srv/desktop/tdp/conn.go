@@ -24,6 +24,7 @@ import (
 	"io"
 	"net"
 	"sync"
+	"sync/atomic"
 
 	"github.com/gravitational/trace"
 )
@@ -49,6 +50,11 @@ type Conn struct {
 	// a conn that provides these fields
 	localAddr  net.Addr
 	remoteAddr net.Addr
+
+	// bytesRead and bytesWritten count the bytes pumped through the
+	// connection in either direction, for per-session usage reporting.
+	bytesRead    atomic.Uint64
+	bytesWritten atomic.Uint64
 }
 
 // NewConn creates a new Conn on top of a ReadWriter, for example a TCP
@@ -56,9 +62,9 @@ type Conn struct {
 // then its LocalAddr() and RemoteAddr() will apply to this Conn.
 func NewConn(rwc io.ReadWriteCloser) *Conn {
 	c := &Conn{
-		rwc:  rwc,
-		bufr: bufio.NewReader(rwc),
+		rwc: rwc,
 	}
+	c.bufr = bufio.NewReader(countingReader{r: rwc, n: &c.bytesRead})
 
 	if tc, ok := rwc.(srvTrackingConn); ok {
 		c.localAddr = tc.LocalAddr()
@@ -96,6 +102,16 @@ func (c *Conn) ReadMessage() (Message, error) {
 	return m, trace.Wrap(err)
 }
 
+// NextMessageType returns the type of the next incoming message without
+// consuming it. It blocks until at least one byte is available.
+func (c *Conn) NextMessageType() (MessageType, error) {
+	b, err := c.bufr.Peek(1)
+	if err != nil {
+		return 0, trace.Wrap(err)
+	}
+	return MessageType(b[0]), nil
+}
+
 // WriteMessage sends a message to the connection.
 func (c *Conn) WriteMessage(m Message) error {
 	buf, err := m.Encode()
@@ -103,13 +119,37 @@ func (c *Conn) WriteMessage(m Message) error {
 		return trace.Wrap(err)
 	}
 
-	_, err = c.rwc.Write(buf)
+	n, err := c.rwc.Write(buf)
+	c.bytesWritten.Add(uint64(n))
 	if c.OnSend != nil {
 		c.OnSend(m, buf)
 	}
 	return trace.Wrap(err)
 }
 
+// BytesReceived returns the number of bytes read from the connection
+// so far.
+func (c *Conn) BytesReceived() uint64 {
+	return c.bytesRead.Load()
+}
+
+// BytesSent returns the number of bytes written to the connection so far.
+func (c *Conn) BytesSent() uint64 {
+	return c.bytesWritten.Load()
+}
+
+// countingReader counts the bytes pulled from the underlying reader.
+type countingReader struct {
+	r io.Reader
+	n *atomic.Uint64
+}
+
+func (c countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n.Add(uint64(n))
+	return n, err
+}
+
 // ReadClientScreenSpec reads the next message from the connection, expecting
 // it to be a ClientScreenSpec. If it is not, an error is returned.
 func (c *Conn) ReadClientScreenSpec() (*ClientScreenSpec, error) {
@@ -65,6 +65,35 @@ func TestTDPConnTracksLocalRemoteAddrs(t *testing.T) {
 	}
 }
 
+// TestConnByteCounters verifies that a TDP connection counts the bytes
+// pumped through it in either direction.
+func TestConnByteCounters(t *testing.T) {
+	c := NewConn(&fakeConn{Buffer: &bytes.Buffer{}})
+
+	msgs := []Message{
+		MouseMove{X: 1, Y: 2},
+		MouseButton{Button: LeftMouseButton, State: ButtonPressed},
+		ClipboardData([]byte("copied text")),
+	}
+	var want uint64
+	for _, m := range msgs {
+		b, err := m.Encode()
+		require.NoError(t, err)
+		want += uint64(len(b))
+		require.NoError(t, c.WriteMessage(m))
+	}
+	require.Equal(t, want, c.BytesSent())
+	require.Zero(t, c.BytesReceived())
+
+	// The fake connection is a buffer, so everything written above can
+	// be read back.
+	for range msgs {
+		_, err := c.ReadMessage()
+		require.NoError(t, err)
+	}
+	require.Equal(t, want, c.BytesReceived())
+}
+
 type fakeConn struct {
 	*bytes.Buffer
 }
@@ -0,0 +1,122 @@
+/*
+ * Teleport
+ * Copyright (C) 2024  Gravitational, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package tdp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/gravitational/trace"
+)
+
+// Version identifies a revision of the desktop protocol.
+type Version byte
+
+const (
+	// MinServerVersion is the oldest protocol version this service accepts.
+	MinServerVersion = Version(1)
+	// MaxServerVersion is the newest protocol version this service speaks.
+	MaxServerVersion = Version(1)
+)
+
+// Capabilities is a bit set of optional protocol features.
+type Capabilities uint32
+
+const (
+	// CapabilityClipboard indicates support for clipboard sharing.
+	CapabilityClipboard Capabilities = 1 << iota
+	// CapabilityResize indicates support for mid-session screen resizing.
+	CapabilityResize
+	// CapabilityRecording indicates support for session recording.
+	CapabilityRecording
+)
+
+// ServerCapabilities is the feature set implemented by this service.
+const ServerCapabilities = CapabilityClipboard | CapabilityResize | CapabilityRecording
+
+// ClientHello opens the protocol handshake and advertises the range of
+// versions and the feature set the client supports.
+// | message type (35) | min_version byte | max_version byte | capabilities uint32 |
+type ClientHello struct {
+	MinVersion   Version
+	MaxVersion   Version
+	Capabilities Capabilities
+}
+
+func (h ClientHello) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	buf.WriteByte(byte(TypeClientHello))
+	buf.WriteByte(byte(h.MinVersion))
+	buf.WriteByte(byte(h.MaxVersion))
+	writeUint32(buf, uint32(h.Capabilities))
+	return buf.Bytes(), nil
+}
+
+func decodeClientHello(in io.Reader) (ClientHello, error) {
+	var h ClientHello
+	err := binary.Read(in, binary.BigEndian, &h)
+	return h, trace.Wrap(err)
+}
+
+// ServerHello completes the protocol handshake with the negotiated
+// version and the intersection of the advertised feature sets.
+// | message type (36) | version byte | capabilities uint32 |
+type ServerHello struct {
+	Version      Version
+	Capabilities Capabilities
+}
+
+func (h ServerHello) Encode() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	buf.WriteByte(byte(TypeServerHello))
+	buf.WriteByte(byte(h.Version))
+	writeUint32(buf, uint32(h.Capabilities))
+	return buf.Bytes(), nil
+}
+
+func decodeServerHello(in io.Reader) (ServerHello, error) {
+	var h ServerHello
+	err := binary.Read(in, binary.BigEndian, &h)
+	return h, trace.Wrap(err)
+}
+
+// Negotiate selects the highest protocol version supported by both the
+// client and this service, along with the features common to both.
+func Negotiate(hello ClientHello) (ServerHello, error) {
+	if hello.MinVersion > hello.MaxVersion {
+		return ServerHello{}, trace.BadParameter(
+			"client hello contains an invalid version range %d-%d", hello.MinVersion, hello.MaxVersion)
+	}
+	if hello.MaxVersion < MinServerVersion || hello.MinVersion > MaxServerVersion {
+		return ServerHello{}, trace.BadParameter(
+			"client supports desktop protocol versions %d-%d, but this service supports versions %d-%d",
+			hello.MinVersion, hello.MaxVersion, MinServerVersion, MaxServerVersion)
+	}
+
+	version := MaxServerVersion
+	if hello.MaxVersion < version {
+		version = hello.MaxVersion
+	}
+
+	return ServerHello{
+		Version:      version,
+		Capabilities: hello.Capabilities & ServerCapabilities,
+	}, nil
+}
@@ -0,0 +1,78 @@
+/*
+ * Teleport
+ * Copyright (C) 2024  Gravitational, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package tdp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiate(t *testing.T) {
+	for _, test := range []struct {
+		desc      string
+		hello     ClientHello
+		want      ServerHello
+		errAssert require.ErrorAssertionFunc
+	}{
+		{
+			desc:  "matched version",
+			hello: ClientHello{MinVersion: 1, MaxVersion: 1, Capabilities: ServerCapabilities},
+			want:  ServerHello{Version: 1, Capabilities: ServerCapabilities},
+		},
+		{
+			desc: "client supports newer versions than the server",
+			hello: ClientHello{
+				MinVersion:   1,
+				MaxVersion:   9,
+				Capabilities: CapabilityClipboard | CapabilityResize,
+			},
+			want: ServerHello{Version: MaxServerVersion, Capabilities: CapabilityClipboard | CapabilityResize},
+		},
+		{
+			desc:  "capabilities are intersected",
+			hello: ClientHello{MinVersion: 1, MaxVersion: 1, Capabilities: CapabilityClipboard | 1<<30},
+			want:  ServerHello{Version: 1, Capabilities: CapabilityClipboard},
+		},
+		{
+			desc:  "client too new",
+			hello: ClientHello{MinVersion: MaxServerVersion + 1, MaxVersion: MaxServerVersion + 2},
+			errAssert: func(t require.TestingT, err error, _ ...interface{}) {
+				require.ErrorContains(t, err, "this service supports versions")
+			},
+		},
+		{
+			desc:  "invalid version range",
+			hello: ClientHello{MinVersion: 2, MaxVersion: 1},
+			errAssert: func(t require.TestingT, err error, _ ...interface{}) {
+				require.ErrorContains(t, err, "invalid version range")
+			},
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			got, err := Negotiate(test.hello)
+			if test.errAssert != nil {
+				test.errAssert(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.want, got)
+		})
+	}
+}
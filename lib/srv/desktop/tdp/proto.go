@@ -82,6 +82,8 @@ const (
 	TypeSyncKeys                        = MessageType(32)
 	TypeSharedDirectoryTruncateRequest  = MessageType(33)
 	TypeSharedDirectoryTruncateResponse = MessageType(34)
+	TypeClientHello                     = MessageType(35)
+	TypeServerHello                     = MessageType(36)
 )
 
 // Message is a Go representation of a desktop protocol message.
@@ -182,6 +184,10 @@ func decodeMessage(firstByte byte, in byteReader) (Message, error) {
 		return decodeSharedDirectoryTruncateRequest(in)
 	case TypeSharedDirectoryTruncateResponse:
 		return decodeSharedDirectoryTruncateResponse(in)
+	case TypeClientHello:
+		return decodeClientHello(in)
+	case TypeServerHello:
+		return decodeServerHello(in)
 	default:
 		return nil, trace.BadParameter("unsupported desktop protocol message type %d", firstByte)
 	}
@@ -57,6 +57,8 @@ func TestEncodeDecode(t *testing.T) {
 		ClientUsername{Username: "admin"},
 		MouseWheel{Axis: HorizontalWheelAxis, Delta: -123},
 		Error{Message: "An error occurred"},
+		ClientHello{MinVersion: 1, MaxVersion: 2, Capabilities: CapabilityClipboard | CapabilityResize},
+		ServerHello{Version: 1, Capabilities: CapabilityRecording},
 	} {
 		t.Run(fmt.Sprintf("%T", m), func(t *testing.T) {
 			buf, err := m.Encode()
@@ -25,6 +25,8 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"net/url"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -33,7 +35,10 @@ import (
 	"github.com/go-ldap/ldap/v3"
 	"github.com/gravitational/trace"
 	"github.com/jonboulle/clockwork"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	oteltrace "go.opentelemetry.io/otel/trace"
 
 	"github.com/gravitational/teleport"
 	apidefaults "github.com/gravitational/teleport/api/defaults"
@@ -47,6 +52,9 @@ import (
 	"github.com/gravitational/teleport/lib/events/recorder"
 	"github.com/gravitational/teleport/lib/limiter"
 	"github.com/gravitational/teleport/lib/modules"
+	"github.com/gravitational/teleport/lib/multiplexer"
+	"github.com/gravitational/teleport/lib/observability/metrics"
+	"github.com/gravitational/teleport/lib/observability/tracing"
 	"github.com/gravitational/teleport/lib/reversetunnel"
 	"github.com/gravitational/teleport/lib/service/servicecfg"
 	"github.com/gravitational/teleport/lib/services"
@@ -87,6 +95,93 @@ const (
 	// if it times out. It is set lower than windowsDesktopServiceCertRetryInterval
 	// because LDAP timeouts may indicate a temporary issue.
 	ldapTimeoutRetryInterval = 10 * time.Second
+
+	// defaultRDPConnectTimeout is the default timeout for establishing an
+	// RDP connection to a Windows host. It bounds connection establishment
+	// only and is separate from the client idle timeout enforced by the
+	// connection monitor.
+	defaultRDPConnectTimeout = 30 * time.Second
+
+	// drainCheckInterval is how often a draining service checks whether
+	// all of its active sessions have ended.
+	drainCheckInterval = time.Second
+
+	// defaultLoginLabel is the desktop label that specifies the Windows
+	// login to use when the client doesn't request one.
+	defaultLoginLabel = "login"
+
+	// adAccountCacheTTL is how long Active Directory account lookup results
+	// (both hits and misses) are cached, to avoid an LDAP roundtrip for
+	// every certificate issued.
+	adAccountCacheTTL = 30 * time.Second
+
+	// defaultDesktopLookupRetries is the default number of times a desktop
+	// lookup is retried when the desktop is not found.
+	defaultDesktopLookupRetries = 1
+
+	// desktopLookupRetryInterval is how long to wait between desktop
+	// lookup attempts when the desktop is not found.
+	desktopLookupRetryInterval = 250 * time.Millisecond
+
+	// acceptBackoffInterval is how long Serve waits before retrying after
+	// a temporary accept error such as running out of file descriptors.
+	acceptBackoffInterval = 100 * time.Millisecond
+
+	// desktopALPNProtocol is the ALPN protocol name desktop connections
+	// use to select this service when its listener shares a port with
+	// other protocols via ALPN routing.
+	desktopALPNProtocol = "teleport-windowsdesktop"
+)
+
+var heartbeatsMissed = prometheus.NewCounter(
+	prometheus.CounterOpts{
+		Name: teleport.MetricWindowsDesktopHeartbeatsMissed,
+		Help: "Number of heartbeats the Windows desktop service failed to send",
+	},
+)
+
+var sessionTxBytes = prometheus.NewCounter(
+	prometheus.CounterOpts{
+		Name: teleport.MetricWindowsDesktopSessionTxBytes,
+		Help: "Number of TDP bytes sent to desktop clients",
+	},
+)
+
+var sessionRxBytes = prometheus.NewCounter(
+	prometheus.CounterOpts{
+		Name: teleport.MetricWindowsDesktopSessionRxBytes,
+		Help: "Number of TDP bytes received from desktop clients",
+	},
+)
+
+var sessionDuration = prometheus.NewHistogram(
+	prometheus.HistogramOpts{
+		Name: teleport.MetricWindowsDesktopSessionDuration,
+		Help: "Duration of completed desktop sessions",
+		// Desktop sessions tend to be interactive and long-lived, so the
+		// buckets span seconds to a full working day.
+		Buckets: prometheus.ExponentialBuckets(1, 4, 9),
+	},
+)
+
+var acceptErrors = prometheus.NewCounter(
+	prometheus.CounterOpts{
+		Name: teleport.MetricWindowsDesktopAcceptErrors,
+		Help: "Number of failed attempts to accept an incoming connection",
+	},
+)
+
+// AddressFamily is a preference for resolving desktop hostnames to
+// IPv4 or IPv6 addresses.
+type AddressFamily string
+
+const (
+	// AddressFamilyAuto prefers IPv4 addresses and falls back to IPv6.
+	AddressFamilyAuto AddressFamily = "auto"
+	// AddressFamilyIPv4 prefers IPv4 addresses and falls back to IPv6.
+	AddressFamilyIPv4 AddressFamily = "ipv4"
+	// AddressFamilyIPv6 prefers IPv6 addresses and falls back to IPv4.
+	AddressFamilyIPv6 AddressFamily = "ipv6"
 )
 
 // ComputerAttributes are the attributes we fetch when discovering
@@ -111,14 +206,31 @@ var ComputerAttributes = []string{
 type WindowsService struct {
 	cfg        WindowsServiceConfig
 	middleware *auth.Middleware
+	tracer     oteltrace.Tracer
 
 	ca *windows.CertificateStoreClient
 	lc *windows.LDAPClient
 
-	mu              sync.Mutex // mu protects the fields that follow
-	ldapConfigured  bool
-	ldapInitialized bool
-	ldapCertRenew   *time.Timer
+	mu               sync.Mutex // mu protects the fields that follow
+	ldapConfigured   bool
+	ldapInitialized  bool
+	ldapLastError    error
+	ldapCertRenew    *time.Timer
+	heartbeatHealthy bool
+	// draining is set when graceful shutdown begins. A draining service
+	// reports not ready and rejects new connections while existing
+	// sessions run to completion.
+	draining       bool
+	activeSessions map[string]desktopSession
+	// liveRecordings tees the recording stream of in-progress sessions
+	// to live viewers, keyed by session ID.
+	liveRecordings map[string]*recordingTee
+	// moderatedSessions holds the moderation gates of active sessions
+	// that require moderators, keyed by session ID.
+	moderatedSessions map[string]*moderatedSession
+	// activeConnections counts the goroutines currently handling incoming
+	// connections, bounded by MaxConcurrentConnections.
+	activeConnections int
 
 	// lastDisoveryResults stores the results of the most recent LDAP search
 	// when desktop discovery is enabled.
@@ -140,10 +252,39 @@ type WindowsService struct {
 	// creating shared directory audit events.
 	auditCache sharedDirectoryAuditCache
 
+	// adAccountCache caches the results of Active Directory account
+	// lookups performed prior to issuing certificates.
+	adAccountCache *utils.FnCache
+
+	// probeMu guards probeResults, the cache of reachability probe
+	// outcomes keyed by desktop address.
+	probeMu      sync.Mutex
+	probeResults map[string]probeResult
+
+	// outputBudget caps the total desktop output in flight across all
+	// sessions. It is nil when no cap is configured.
+	outputBudget *outputBudget
+
+	// rdpPool keeps authenticated RDP connections warm between sessions.
+	// It is nil when pooling is not configured.
+	rdpPool *rdpConnPool
+
 	closeCtx context.Context
 	close    func()
 }
 
+// RDPClient is the subset of the RDP client API used to run a desktop
+// session. It is implemented by [*rdpclient.Client]. An alternate
+// implementation can be plugged in via [WindowsServiceConfig.NewRDPClient],
+// which allows the connection path to be exercised without a real RDP stack.
+type RDPClient interface {
+	srv.ActivityTracker
+	// Run starts the client and blocks until the session ends.
+	Run(ctx context.Context) error
+	// GetClientUsername returns the Windows username for the session.
+	GetClientUsername() string
+}
+
 // WindowsServiceConfig contains all necessary configuration values for a
 // WindowsService.
 type WindowsServiceConfig struct {
@@ -152,6 +293,10 @@ type WindowsServiceConfig struct {
 	// Clock provides current time.
 	Clock   clockwork.Clock
 	DataDir string
+	// TracerProvider creates the tracer used to emit spans covering the
+	// stages of a desktop connection. If nil, a no-op provider is used
+	// and no spans are emitted.
+	TracerProvider oteltrace.TracerProvider
 	// Authorizer is used to authorize requests.
 	Authorizer authz.Authorizer
 	// LockWatcher is used to monitor for new locks.
@@ -170,12 +315,167 @@ type WindowsServiceConfig struct {
 	Heartbeat HeartbeatConfig
 	// HostLabelsFn gets labels that should be applied to a Windows host.
 	HostLabelsFn func(host string) map[string]string
+	// ReachabilityProbe configures the optional probe that checks whether
+	// announced desktops accept TCP connections on their RDP port.
+	ReachabilityProbe ReachabilityProbeConfig
 	// ShowDesktopWallpaper determines whether desktop sessions will show a
 	// user-selected wallpaper vs a system-default, single-color wallpaper.
 	ShowDesktopWallpaper bool
+	// PerformanceProfile selects the preset of RDP performance flags used
+	// for desktop sessions. The zero value means the balanced broadband
+	// profile.
+	PerformanceProfile rdpclient.PerformanceProfile
+	// CustomPerformanceFlags is the RDP performance flag bitmask used when
+	// PerformanceProfile is set to the custom profile.
+	CustomPerformanceFlags rdpclient.PerformanceFlags
+	// RestrictedAdminMode requests Windows Restricted Admin mode during the
+	// RDP security negotiation, for hardened hosts that refuse plain
+	// credential delegation. Hosts that don't support the mode reject the
+	// connection.
+	RestrictedAdminMode bool
+	// ConnectTimeout is the maximum amount of time to wait for the RDP
+	// connection to a Windows host to be established. If zero, it defaults
+	// to defaultRDPConnectTimeout.
+	ConnectTimeout time.Duration
+	// AddressFamily indicates whether IPv4 or IPv6 addresses are preferred
+	// when resolving desktop hostnames. When the preferred family yields no
+	// results, the alternate family is used as a fallback. If empty, it
+	// defaults to AddressFamilyAuto.
+	AddressFamily AddressFamily
+	// SNISuffix is the expected suffix of the TLS server name on incoming
+	// connections. The target desktop name is obtained by trimming this
+	// suffix, and connections with a non-matching server name are rejected.
+	// If empty, it defaults to the SNISuffix package constant.
+	SNISuffix string
+	// CertSkewAllowance is the amount of time by which issued smartcard
+	// certificates are backdated to tolerate clock skew on the Windows host.
+	// If zero, the cluster's default backdating is used.
+	CertSkewAllowance time.Duration
+	// CertValidityMode controls how the validity window of issued smartcard
+	// certificates is computed from the session TTL and skew allowance:
+	// exact, or rounded up to whole minutes. The zero value means exact.
+	CertValidityMode windows.CertValidityMode
+	// CRLValidity is the desired validity period for the certificate
+	// revocation lists published to the LDAP server. Setting it enables
+	// periodic CRL regeneration at CRLRefreshInterval. The auth API issues
+	// CRLs with a fixed validity, so until it accepts a custom one this
+	// value only drives the refresh cadence. If zero, the CRL is published
+	// once when the LDAP connection is initialized.
+	CRLValidity time.Duration
+	// CRLRefreshInterval is how often the CRL is regenerated and
+	// republished. If zero, it defaults to half of CRLValidity so that a
+	// fresh CRL is always published well before the previous one expires.
+	// It has no effect when CRLValidity is zero.
+	CRLRefreshInterval time.Duration
+	// ExtraCDPs are additional CRL distribution points included in issued
+	// certificates alongside the Teleport-published one, for example the
+	// CDP of the native Active Directory CA. Windows falls back among the
+	// listed distribution points when checking revocation.
+	ExtraCDPs []string
+	// CertSignatureAlgorithm is the algorithm used to sign certificate
+	// requests for Windows logins, for AD CAs or policies that require a
+	// specific digest. When unset, crypto/x509 picks its default for the
+	// key type.
+	CertSignatureAlgorithm x509.SignatureAlgorithm
+	// CertificateTemplateOID is the dotted-decimal OID of an Active
+	// Directory certificate template to name in issued certificates, for
+	// AD CAs that map smartcard logon certificates to a template. Empty
+	// means no template extension is added.
+	CertificateTemplateOID string
+	// SANOtherNameOID is the dotted-decimal OID of the otherName SAN
+	// entry carrying the user identity in issued certificates, for AD
+	// setups that map identities by a non-standard OID. Empty means the
+	// standard UPN OID.
+	SANOtherNameOID string
+	// MaxOutputBuffered caps the total number of bytes of desktop output
+	// that may be in flight across all sessions on this service. When the
+	// cap is reached, sessions over their fair share of it are throttled
+	// until earlier output drains, so one session streaming to a slow
+	// client cannot starve the others. Zero means no cap.
+	MaxOutputBuffered int64
+	// DesktopLookupRetries is the number of times a desktop lookup is
+	// retried when the desktop is not found, smoothing over cache
+	// propagation delays right after discovery registers a desktop. If
+	// zero, it defaults to defaultDesktopLookupRetries.
+	DesktopLookupRetries int
+	// MaxConcurrentConnections caps the number of goroutines concurrently
+	// handling incoming connections, bounding goroutine growth during a
+	// connection flood before the per-IP limiter runs. Connections over
+	// the cap are closed immediately. Zero means no cap.
+	MaxConcurrentConnections int
+	// HostCertificatePins maps a desktop address (as it appears in the
+	// desktop spec) to the SHA-256 fingerprints of TLS certificates the
+	// RDP server at that address is allowed to present. Desktops without
+	// an entry are not verified unless RequireHostCertPins is set.
+	HostCertificatePins map[string][]string
+	// RequireHostCertPins rejects connections to desktops that don't have
+	// a certificate pin configured, preventing a spoofed host from
+	// accepting the smartcard certificate.
+	RequireHostCertPins bool
+	// LoginTrait names the identity trait that supplies the default
+	// Windows login, so that SSO-provisioned users (Okta, Azure AD) map
+	// onto their AD accounts. Identities without a value for the trait
+	// fall back to their Teleport username. When empty, the default login
+	// comes from the desktop's label or the user's roles.
+	LoginTrait string
+	// EnableProxyProtocol indicates that the service runs behind a TCP load
+	// balancer which prepends a PROXY protocol v1 or v2 header to each
+	// connection. When set, the header is parsed before the TLS handshake so
+	// that connection limiting and audit events see the real client address
+	// instead of the load balancer's.
+	EnableProxyProtocol bool
 	// LDAPConfig contains parameters for connecting to an LDAP server.
 	// LDAP functionality is disabled if Addr is empty.
 	windows.LDAPConfig
+	// LDAPConnector overrides the way the service connects to the LDAP
+	// server. If nil, the address from LDAPConfig is dialed directly.
+	// Tests use this to supply a fake LDAP client.
+	LDAPConnector func(tlsConfig *tls.Config) (ldap.Client, error)
+	// LDAPLocator, when set, discovers the domain's controllers via DNS SRV
+	// records and connects to them in preference order instead of pinning
+	// to LDAPConfig.Addr. The configured address, if any, remains the
+	// fallback of last resort.
+	LDAPLocator *windows.DomainControllerLocator
+	// NewRDPClient overrides the way RDP clients are constructed.
+	// If nil, it defaults to [rdpclient.New]. Tests use this to supply
+	// a fake RDP backend.
+	NewRDPClient func(cfg rdpclient.Config) (RDPClient, error)
+	// ConnectionPoolIdleTimeout enables pooling of RDP connections. When a
+	// session ends with a backend that supports reuse, the connection is
+	// kept warm for this long and handed to the next session from the same
+	// user with the same Windows login on the same desktop. Zero disables
+	// pooling.
+	ConnectionPoolIdleTimeout time.Duration
+	// RDPProxyURL optionally routes RDP connections to desktops through an
+	// HTTP CONNECT proxy at this URL. Set this when desktops are only
+	// reachable through a proxy from the network the service runs in.
+	// When empty, desktops are dialed directly.
+	RDPProxyURL string
+	// SkipCRLPublish disables publishing the certificate revocation list
+	// to the LDAP server when the LDAP connection is initialized.
+	// This should only be set in tests.
+	SkipCRLPublish bool
+	// RequireCRLPublish makes a failed CRL publish fatal even when the auth
+	// server doesn't support CRL generation. By default such auth servers
+	// only cause a warning and CRL publishing is skipped, so that the
+	// service can still start against an older auth server.
+	RequireCRLPublish bool
+	// LDAPReadOnly makes the service perform no LDAP writes. Instead of
+	// publishing the CRL, the service verifies that the CRL objects have
+	// been pre-provisioned and fails if they are missing. Set this when
+	// the LDAP service account cannot be granted write access.
+	LDAPReadOnly bool
+	// PublishNTAuth makes the service maintain the cluster CA certificates
+	// in the domain's NTAuthCertificates entry instead of requiring a
+	// manual import. Superseded certificates are removed after a CA
+	// rotation once the rotation grace period has passed.
+	PublishNTAuth bool
+	// SkipADUserLookup disables the LDAP account lookup that is performed
+	// before issuing certificates for Active Directory desktops. Set this
+	// in environments where the service account does not have permission
+	// to read user objects. When set, certificates are issued without an
+	// Active Directory SID.
+	SkipADUserLookup bool
 	// PKIDomain optionally configures a separate Active Directory domain
 	// for PKI operations. If empty, the domain from the LDAP config is used.
 	// This can be useful for cases where PKI is configured in a root domain
@@ -206,10 +506,18 @@ type HeartbeatConfig struct {
 	HostUUID string
 	// PublicAddr is the public address of this service.
 	PublicAddr string
-	// OnHeartbeat is called after each heartbeat attempt.
+	// OnHeartbeat is called after each heartbeat attempt. If nil, a default
+	// implementation is used which tracks heartbeat health internally.
 	OnHeartbeat func(error)
 	// StaticHosts is an optional list of static Windows hosts to register
 	StaticHosts []servicecfg.WindowsHost
+	// BatchStaticHosts registers all static hosts from a single routine on
+	// a shared announce schedule instead of one heartbeat goroutine per
+	// host. With hundreds of static hosts this reduces the load on the
+	// auth server: the desktop listing used to resolve names for unnamed
+	// hosts is fetched once per cycle rather than once per host. Per-host
+	// expiry semantics are unchanged.
+	BatchStaticHosts bool
 }
 
 func (cfg *WindowsServiceConfig) checkAndSetDiscoveryDefaults() error {
@@ -231,6 +539,45 @@ func (cfg *WindowsServiceConfig) checkAndSetDiscoveryDefaults() error {
 	return nil
 }
 
+// checkAndSetCRLDefaults validates the CRL validity and refresh interval
+// and keeps the two consistent with each other.
+func (cfg *WindowsServiceConfig) checkAndSetCRLDefaults() error {
+	if cfg.CRLValidity < 0 {
+		return trace.BadParameter("WindowsServiceConfig contains a negative CRL validity %v", cfg.CRLValidity)
+	}
+	if cfg.CRLRefreshInterval < 0 {
+		return trace.BadParameter("WindowsServiceConfig contains a negative CRL refresh interval %v", cfg.CRLRefreshInterval)
+	}
+	if cfg.CRLRefreshInterval == 0 && cfg.CRLValidity != 0 {
+		// Refresh well before the previous CRL expires.
+		cfg.CRLRefreshInterval = cfg.CRLValidity / 2
+	}
+	if cfg.CRLValidity != 0 && cfg.CRLRefreshInterval > cfg.CRLValidity {
+		cfg.Log.Warnf("CRL refresh interval %v exceeds the CRL validity %v, the published CRL may expire before it is replaced",
+			cfg.CRLRefreshInterval, cfg.CRLValidity)
+	}
+	for _, cdp := range cfg.ExtraCDPs {
+		u, err := url.Parse(cdp)
+		if err != nil {
+			return trace.BadParameter("WindowsServiceConfig contains an invalid CRL distribution point %q: %v", cdp, err)
+		}
+		if u.Scheme == "" {
+			return trace.BadParameter("WindowsServiceConfig contains a CRL distribution point %q without a scheme", cdp)
+		}
+	}
+	if cfg.CertificateTemplateOID != "" {
+		if _, err := windows.ParseCertificateTemplateOID(cfg.CertificateTemplateOID); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	if cfg.SANOtherNameOID != "" {
+		if _, err := windows.ParseOID(cfg.SANOtherNameOID); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+	return nil
+}
+
 func (cfg *WindowsServiceConfig) CheckAndSetDefaults() error {
 	if cfg.Log == nil {
 		cfg.Log = logrus.New().WithField(teleport.ComponentKey, teleport.ComponentWindowsDesktop)
@@ -238,6 +585,83 @@ func (cfg *WindowsServiceConfig) CheckAndSetDefaults() error {
 	if cfg.Clock == nil {
 		cfg.Clock = clockwork.NewRealClock()
 	}
+	if cfg.TracerProvider == nil {
+		cfg.TracerProvider = tracing.NoopProvider()
+	}
+	if cfg.ConnectTimeout == 0 {
+		cfg.ConnectTimeout = defaultRDPConnectTimeout
+	}
+	switch cfg.AddressFamily {
+	case "":
+		cfg.AddressFamily = AddressFamilyAuto
+	case AddressFamilyAuto, AddressFamilyIPv4, AddressFamilyIPv6:
+	default:
+		return trace.BadParameter("WindowsServiceConfig contains an invalid address family %q", cfg.AddressFamily)
+	}
+	if cfg.SNISuffix == "" {
+		cfg.SNISuffix = SNISuffix
+	}
+	if cfg.CertSkewAllowance < 0 {
+		return trace.BadParameter("WindowsServiceConfig contains a negative certificate skew allowance %v", cfg.CertSkewAllowance)
+	}
+	if err := windows.CheckCertValidityMode(cfg.CertValidityMode); err != nil {
+		return trace.Wrap(err)
+	}
+	if err := rdpclient.CheckPerformanceProfile(cfg.PerformanceProfile); err != nil {
+		return trace.Wrap(err)
+	}
+	if cfg.MaxOutputBuffered < 0 {
+		return trace.BadParameter("WindowsServiceConfig contains a negative output buffer cap %v", cfg.MaxOutputBuffered)
+	}
+	if cfg.MaxConcurrentConnections < 0 {
+		return trace.BadParameter("WindowsServiceConfig contains a negative connection cap %v", cfg.MaxConcurrentConnections)
+	}
+	if err := windows.CheckSignatureAlgorithm(cfg.CertSignatureAlgorithm); err != nil {
+		return trace.Wrap(err)
+	}
+	if cfg.DesktopLookupRetries < 0 {
+		return trace.BadParameter("WindowsServiceConfig contains a negative desktop lookup retry count %v", cfg.DesktopLookupRetries)
+	}
+	if cfg.DesktopLookupRetries == 0 {
+		cfg.DesktopLookupRetries = defaultDesktopLookupRetries
+	}
+	if cfg.ConnectionPoolIdleTimeout < 0 {
+		return trace.BadParameter("WindowsServiceConfig contains a negative connection pool idle timeout %v", cfg.ConnectionPoolIdleTimeout)
+	}
+	if cfg.RDPProxyURL != "" {
+		u, err := url.Parse(cfg.RDPProxyURL)
+		if err != nil {
+			return trace.BadParameter("WindowsServiceConfig contains an invalid RDP proxy URL %q: %v", cfg.RDPProxyURL, err)
+		}
+		switch u.Scheme {
+		case "http", "https":
+		default:
+			return trace.BadParameter("WindowsServiceConfig contains an RDP proxy URL %q with unsupported scheme %q, only HTTP CONNECT proxies are supported", cfg.RDPProxyURL, u.Scheme)
+		}
+	}
+	for addr, pins := range cfg.HostCertificatePins {
+		for i, pin := range pins {
+			normalized, err := rdpclient.NormalizeCertPin(pin)
+			if err != nil {
+				return trace.Wrap(err, "WindowsServiceConfig contains an invalid certificate pin for %v", addr)
+			}
+			pins[i] = normalized
+		}
+	}
+	if err := cfg.checkAndSetCRLDefaults(); err != nil {
+		return trace.Wrap(err)
+	}
+	if cfg.NewRDPClient == nil {
+		cfg.NewRDPClient = func(c rdpclient.Config) (RDPClient, error) {
+			//nolint:staticcheck // SA4023. False positive, depends on build tags.
+			client, err := rdpclient.New(c)
+			//nolint:staticcheck // SA4023.
+			if err != nil {
+				return nil, trace.Wrap(err)
+			}
+			return client, nil
+		}
+	}
 	if cfg.Authorizer == nil {
 		return trace.BadParameter("WindowsServiceConfig is missing Authorizer")
 	}
@@ -262,8 +686,11 @@ func (cfg *WindowsServiceConfig) CheckAndSetDefaults() error {
 	if err := cfg.Heartbeat.CheckAndSetDefaults(); err != nil {
 		return trace.Wrap(err)
 	}
+	if err := cfg.ReachabilityProbe.CheckAndSetDefaults(); err != nil {
+		return trace.Wrap(err)
+	}
 	if cfg.LDAPConfig.Addr != "" {
-		if err := cfg.LDAPConfig.Check(); err != nil {
+		if err := cfg.LDAPConfig.CheckAndSetDefaults(); err != nil {
 			return trace.Wrap(err)
 		}
 	}
@@ -284,9 +711,6 @@ func (cfg *HeartbeatConfig) CheckAndSetDefaults() error {
 	if cfg.PublicAddr == "" {
 		return trace.BadParameter("HeartbeatConfig is missing PublicAddr")
 	}
-	if cfg.OnHeartbeat == nil {
-		return trace.BadParameter("HeartbeatConfig is missing OnHeartbeat")
-	}
 	return nil
 }
 
@@ -299,6 +723,10 @@ func NewWindowsService(cfg WindowsServiceConfig) (*WindowsService, error) {
 		return nil, trace.Wrap(err)
 	}
 
+	if err := metrics.RegisterPrometheusCollectors(heartbeatsMissed, sessionTxBytes, sessionRxBytes, sessionDuration, acceptErrors); err != nil {
+		return nil, trace.Wrap(err)
+	}
+
 	// It's possible to provide a CA certificate for the LDAP server
 	// and to skip TLS valdiation, though this may be an error, so try
 	// to warn the user.
@@ -342,18 +770,44 @@ func NewWindowsService(cfg WindowsServiceConfig) (*WindowsService, error) {
 	}
 
 	ctx, close := context.WithCancel(context.Background())
+
+	adAccountCache, err := utils.NewFnCache(utils.FnCacheConfig{
+		TTL:     adAccountCacheTTL,
+		Clock:   cfg.Clock,
+		Context: ctx,
+	})
+	if err != nil {
+		close()
+		return nil, trace.Wrap(err)
+	}
+
 	s := &WindowsService{
-		cfg: cfg,
+		cfg:    cfg,
+		tracer: cfg.TracerProvider.Tracer(teleport.ComponentWindowsDesktop),
 		middleware: &auth.Middleware{
 			ClusterName:   clustername.GetClusterName(),
 			AcceptedUsage: []string{teleport.UsageWindowsDesktopOnly},
 		},
-		dnsResolver: resolver,
-		lc:          &windows.LDAPClient{Cfg: cfg.LDAPConfig},
-		clusterName: clusterName.GetClusterName(),
-		closeCtx:    ctx,
-		close:       close,
-		auditCache:  newSharedDirectoryAuditCache(),
+		dnsResolver:       resolver,
+		lc:                &windows.LDAPClient{Cfg: cfg.LDAPConfig, Log: cfg.Log},
+		clusterName:       clusterName.GetClusterName(),
+		closeCtx:          ctx,
+		close:             close,
+		auditCache:        newSharedDirectoryAuditCache(),
+		activeSessions:    make(map[string]desktopSession),
+		liveRecordings:    make(map[string]*recordingTee),
+		moderatedSessions: make(map[string]*moderatedSession),
+		adAccountCache:    adAccountCache,
+		outputBudget:      newOutputBudget(cfg.MaxOutputBuffered),
+	}
+
+	// Report any writes this service makes against the LDAP server
+	// (CRL publishing, container creation) to the audit log.
+	s.lc.OnWrite = s.onLDAPWrite
+
+	if s.cfg.ConnectionPoolIdleTimeout != 0 {
+		s.rdpPool = newRDPConnPool(s.cfg.Clock, s.cfg.ConnectionPoolIdleTimeout)
+		go s.rdpPool.run(s.closeCtx)
 	}
 
 	caLDAPConfig := s.cfg.LDAPConfig
@@ -363,11 +817,14 @@ func NewWindowsService(cfg WindowsServiceConfig) (*WindowsService, error) {
 	s.cfg.Log.Infof("Windows PKI will be performed against %v", caLDAPConfig.Domain)
 
 	s.ca = windows.NewCertificateStoreClient(windows.CertificateStoreConfig{
-		AccessPoint: s.cfg.AccessPoint,
-		LDAPConfig:  caLDAPConfig,
-		Log:         s.cfg.Log,
-		ClusterName: s.clusterName,
-		LC:          s.lc,
+		AccessPoint:   s.cfg.AccessPoint,
+		LDAPConfig:    caLDAPConfig,
+		Log:           s.cfg.Log,
+		ClusterName:   s.clusterName,
+		LC:            s.lc,
+		ReadOnly:      s.cfg.LDAPReadOnly,
+		PublishNTAuth: s.cfg.PublishNTAuth,
+		Clock:         s.cfg.Clock,
 	})
 
 	if caLDAPConfig.Addr != "" {
@@ -378,6 +835,14 @@ func NewWindowsService(cfg WindowsServiceConfig) (*WindowsService, error) {
 		if err := s.initializeLDAP(); err != nil {
 			s.cfg.Log.WithError(err).Error("initializing LDAP client, will retry")
 		}
+
+		if !s.cfg.SkipCRLPublish && s.cfg.CRLValidity != 0 {
+			s.startCRLRefreshLoop()
+		}
+	}
+
+	if s.cfg.Heartbeat.OnHeartbeat == nil {
+		s.cfg.Heartbeat.OnHeartbeat = s.defaultOnHeartbeat
 	}
 
 	ok := false
@@ -409,6 +874,27 @@ func NewWindowsService(cfg WindowsServiceConfig) (*WindowsService, error) {
 	return s, nil
 }
 
+// startCRLRefreshLoop periodically regenerates the CRL and republishes it
+// to the LDAP server. The CRL is published once when the LDAP connection is
+// initialized, so the loop only runs for operators who configured a CRL
+// validity and therefore expect a tighter revocation window.
+func (s *WindowsService) startCRLRefreshLoop() {
+	go func() {
+		t := s.cfg.Clock.NewTicker(s.cfg.CRLRefreshInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.Chan():
+				if err := s.ca.Update(s.closeCtx); err != nil {
+					s.cfg.Log.WithError(err).Warn("refreshing CRL, will retry at the next interval")
+				}
+			case <-s.closeCtx.Done():
+				return
+			}
+		}
+	}()
+}
+
 func (s *WindowsService) newSessionRecorder(recConfig types.SessionRecordingConfig, sessionID string) (libevents.SessionPreparerRecorder, error) {
 	return recorder.New(recorder.Config{
 		SessionID:    session.ID(sessionID),
@@ -427,10 +913,14 @@ func (s *WindowsService) newSessionRecorder(recConfig types.SessionRecordingConf
 }
 
 func (s *WindowsService) tlsConfigForLDAP() (*tls.Config, error) {
-	// trim NETBIOS name from username
-	user := s.cfg.Username
-	if i := strings.LastIndex(s.cfg.Username, `\`); i != -1 {
-		user = user[i+1:]
+	// The authentication identity defaults to the configured username with
+	// any NETBIOS prefix trimmed, unless an explicit bind DN was provided.
+	user := s.cfg.BindDN
+	if user == "" {
+		user = s.cfg.Username
+		if i := strings.LastIndex(s.cfg.Username, `\`); i != -1 {
+			user = user[i+1:]
+		}
 	}
 	if s.cfg.SID == "" {
 		s.cfg.Log.Warnf(`Your LDAP config is missing the SID of the user you're
@@ -493,6 +983,7 @@ func (s *WindowsService) initializeLDAP() error {
 	if err != nil {
 		s.mu.Lock()
 		s.ldapInitialized = false
+		s.ldapLastError = err
 		// in the case where we're not licensed for desktop access, we retry less frequently,
 		// since this is likely not an intermittent error that will resolve itself quickly
 		s.scheduleNextLDAPCertRenewalLocked(windowsDesktopServiceCertRetryInterval * 3)
@@ -500,14 +991,11 @@ func (s *WindowsService) initializeLDAP() error {
 		return trace.Wrap(err)
 	}
 
-	conn, err := ldap.DialURL(
-		"ldaps://"+s.cfg.Addr,
-		ldap.DialWithDialer(&net.Dialer{Timeout: ldapDialTimeout}),
-		ldap.DialWithTLSConfig(tc),
-	)
+	conn, err := s.connectLDAP(tc)
 	if err != nil {
 		s.mu.Lock()
 		s.ldapInitialized = false
+		s.ldapLastError = err
 
 		// failures due to timeouts might be transient, so retry more frequently
 		retryAfter := windowsDesktopServiceCertRetryInterval
@@ -520,21 +1008,136 @@ func (s *WindowsService) initializeLDAP() error {
 		return trace.Wrap(err, "dial")
 	}
 
-	conn.SetTimeout(ldapRequestTimeout)
 	s.lc.SetClient(conn)
 
-	if err := s.ca.Update(s.closeCtx); err != nil {
-		return trace.Wrap(err)
+	if !s.cfg.SkipCRLPublish {
+		if err := s.ca.Update(s.closeCtx); err != nil {
+			// Older auth servers don't implement CRL generation. Unless the
+			// operator explicitly requires CRL publishing, degrade gracefully
+			// so that the service can still start against such auth servers.
+			if s.cfg.RequireCRLPublish || !trace.IsNotImplemented(err) {
+				s.mu.Lock()
+				s.ldapLastError = err
+				s.mu.Unlock()
+				return trace.Wrap(err)
+			}
+			s.cfg.Log.WithError(err).Warn("The auth server does not support CRL generation, skipping CRL publishing. Upgrade the auth server to enable revocation checking for Windows logins.")
+		}
 	}
 
 	s.mu.Lock()
 	s.ldapInitialized = true
+	s.ldapLastError = nil
 	s.scheduleNextLDAPCertRenewalLocked(windowsDesktopServiceCertTTL / 3)
 	s.mu.Unlock()
 
 	return nil
 }
 
+// connectLDAP establishes a connection to an LDAP server, using the
+// connector from the service configuration if one was provided. Otherwise
+// the candidate addresses from ldapAddrs are dialed in order, failing over
+// to the next controller when one doesn't respond.
+func (s *WindowsService) connectLDAP(tlsConfig *tls.Config) (ldap.Client, error) {
+	if s.cfg.LDAPConnector != nil {
+		conn, err := s.cfg.LDAPConnector(tlsConfig)
+		return conn, trace.Wrap(err)
+	}
+
+	addrs, err := s.ldapAddrs()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	var errs []error
+	for _, addr := range addrs {
+		conn, err := s.dialLDAP(addr, tlsConfig)
+		if err != nil {
+			errs = append(errs, trace.Wrap(err, "connecting to LDAP server %v", addr))
+			continue
+		}
+		return conn, nil
+	}
+	return nil, trace.NewAggregate(errs...)
+}
+
+// ldapAddrs returns the LDAP server addresses to try in order. Without a
+// locator this is just the configured address. With one, the discovered
+// controllers come first (nearest site first) and the configured address
+// is kept as the fallback of last resort.
+func (s *WindowsService) ldapAddrs() ([]string, error) {
+	if s.cfg.LDAPLocator == nil {
+		return []string{s.cfg.Addr}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(s.closeCtx, ldapDialTimeout)
+	defer cancel()
+
+	addrs, err := s.cfg.LDAPLocator.LocateLDAPServers(ctx, s.cfg.Domain)
+	if err != nil {
+		s.cfg.Log.WithError(err).Warn("Domain controller discovery failed, falling back to the configured LDAP address")
+		return []string{s.cfg.Addr}, nil
+	}
+	if !slices.Contains(addrs, s.cfg.Addr) {
+		addrs = append(addrs, s.cfg.Addr)
+	}
+	return addrs, nil
+}
+
+// dialLDAP connects to a single LDAP server over LDAPS and authenticates
+// the session.
+func (s *WindowsService) dialLDAP(addr string, tlsConfig *tls.Config) (ldap.Client, error) {
+	conn, err := ldap.DialURL(
+		"ldaps://"+addr,
+		ldap.DialWithDialer(&net.Dialer{Timeout: ldapDialTimeout}),
+		ldap.DialWithTLSConfig(tlsConfig),
+	)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	conn.SetTimeout(ldapRequestTimeout)
+
+	// A configured password switches the session to simple-bind
+	// authentication. Without one, the TLS client certificate presented
+	// during the handshake authenticates the session.
+	if s.cfg.Password != "" {
+		if err := s.bindLDAP(conn); err != nil {
+			conn.Close()
+			return nil, trace.Wrap(err)
+		}
+	}
+
+	return conn, nil
+}
+
+// bindLDAP authenticates the LDAP session with a simple bind. The bind sends
+// the service account password over the connection, so it refuses to proceed
+// unless TLS is verifiably active: even though the connection is dialed over
+// LDAPS, an attacker who strips or downgrades the TLS layer in transit must
+// not be able to coax the password out in plaintext.
+func (s *WindowsService) bindLDAP(conn ldap.Client) error {
+	state, ok := conn.TLSConnectionState()
+	switch {
+	case !ok:
+		return trace.AccessDenied("refusing LDAP bind: connection is not protected by TLS")
+	case !state.HandshakeComplete:
+		return trace.AccessDenied("refusing LDAP bind: TLS handshake did not complete")
+	case state.Version < tls.VersionTLS12:
+		return trace.AccessDenied("refusing LDAP bind: connection negotiated %s, TLS 1.2 or higher is required", tls.VersionName(state.Version))
+	}
+
+	bindDN := s.cfg.BindDN
+	if bindDN == "" {
+		bindDN = s.cfg.Username
+	}
+	if err := conn.Bind(bindDN, s.cfg.Password); err != nil {
+		return trace.Wrap(err, "LDAP bind as %q", bindDN)
+	}
+
+	return nil
+}
+
 // scheduleNextLDAPCertRenewalLocked schedules a renewal of our LDAP credentials
 // after some amount of time has elapsed. If an existing renewal is already
 // scheduled, it is canceled and this new one takes its place.
@@ -553,6 +1156,18 @@ func (s *WindowsService) scheduleNextLDAPCertRenewalLocked(after time.Duration)
 	}
 }
 
+// defaultOnHeartbeat is used when no OnHeartbeat callback is configured.
+// It updates the service's internal heartbeat health state and counts
+// failed heartbeat attempts.
+func (s *WindowsService) defaultOnHeartbeat(err error) {
+	if err != nil {
+		heartbeatsMissed.Inc()
+	}
+	s.mu.Lock()
+	s.heartbeatHealthy = err == nil
+	s.mu.Unlock()
+}
+
 func (s *WindowsService) startServiceHeartbeat() error {
 	heartbeat, err := srv.NewHeartbeat(srv.HeartbeatConfig{
 		Context:         s.closeCtx,
@@ -585,6 +1200,13 @@ func (s *WindowsService) startServiceHeartbeat() error {
 // should eventually get cleaned up. But they should exist as long as the
 // service itself is running.
 func (s *WindowsService) startStaticHostHeartbeats() error {
+	if len(s.cfg.Heartbeat.StaticHosts) == 0 {
+		return nil
+	}
+	if s.cfg.Heartbeat.BatchStaticHosts {
+		go s.runBatchedStaticHostHeartbeats()
+		return nil
+	}
 	for _, host := range s.cfg.Heartbeat.StaticHosts {
 		if err := s.startStaticHostHeartbeat(host); err != nil {
 			return err
@@ -593,6 +1215,64 @@ func (s *WindowsService) startStaticHostHeartbeats() error {
 	return nil
 }
 
+// runBatchedStaticHostHeartbeats announces all static hosts on a shared
+// schedule. Expiry is still set per host at announce time, so the hosts of
+// a service that disappears age out exactly as they do with per-host
+// heartbeats.
+func (s *WindowsService) runBatchedStaticHostHeartbeats() {
+	period := apidefaults.ServerAnnounceTTL/2 + utils.RandomDuration(apidefaults.ServerAnnounceTTL/10)
+	t := s.cfg.Clock.NewTicker(period)
+	defer t.Stop()
+	for {
+		err := s.announceStaticHosts(s.closeCtx)
+		if err != nil {
+			s.cfg.Log.WithError(err).Error("Failed to announce static hosts")
+		}
+		s.cfg.Heartbeat.OnHeartbeat(err)
+
+		select {
+		case <-t.Chan():
+		case <-s.closeCtx.Done():
+			return
+		}
+	}
+}
+
+// announceStaticHosts upserts a desktop for every static host. Names for
+// hosts that don't configure one are resolved from a single listing of the
+// existing desktops instead of one scan per host.
+func (s *WindowsService) announceStaticHosts(ctx context.Context) error {
+	// Only unnamed hosts need the desktop listing; skip the read when
+	// every host sets an explicit name.
+	var existing []types.WindowsDesktop
+	for _, host := range s.cfg.Heartbeat.StaticHosts {
+		if host.Name == "" {
+			var err error
+			existing, err = s.cfg.AccessPoint.GetWindowsDesktops(ctx, types.WindowsDesktopFilter{})
+			if err != nil {
+				return trace.Wrap(err)
+			}
+			break
+		}
+	}
+	nameForAddr := func(addr string) (string, error) {
+		return s.nameForStaticHostIn(addr, existing), nil
+	}
+
+	var errs []error
+	for _, host := range s.cfg.Heartbeat.StaticHosts {
+		desktop, err := s.staticHostDesktop(host, s.cfg.HostLabelsFn, nameForAddr)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := s.cfg.AccessPoint.UpsertWindowsDesktop(ctx, desktop); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return trace.NewAggregate(errs...)
+}
+
 // startStaticHostHeartbeats spawns heartbeat goroutine for single host
 func (s *WindowsService) startStaticHostHeartbeat(host servicecfg.WindowsHost) error {
 	heartbeat, err := srv.NewHeartbeat(srv.HeartbeatConfig{
@@ -632,33 +1312,246 @@ func (s *WindowsService) Close() error {
 	return nil
 }
 
+// GracefulClose begins draining the service: it immediately stops
+// reporting ready so that load balancers route no new connections here,
+// then waits for the active sessions to end before closing. The provided
+// context bounds the wait; when it expires, the remaining sessions are
+// aborted.
+func (s *WindowsService) GracefulClose(ctx context.Context) error {
+	s.mu.Lock()
+	s.draining = true
+	s.mu.Unlock()
+
+	t := s.cfg.Clock.NewTicker(drainCheckInterval)
+	defer t.Stop()
+	for {
+		s.mu.Lock()
+		active := len(s.activeSessions)
+		s.mu.Unlock()
+		if active == 0 {
+			return trace.Wrap(s.Close())
+		}
+
+		select {
+		case <-t.Chan():
+		case <-ctx.Done():
+			s.cfg.Log.Warnf("Drain deadline reached, aborting %v remaining desktop sessions", active)
+			return trace.Wrap(s.Close())
+		case <-s.closeCtx.Done():
+			return nil
+		}
+	}
+}
+
+// ServiceHealth is a point-in-time health summary of the service,
+// suitable for wiring into liveness and readiness probes.
+type ServiceHealth struct {
+	// HeartbeatHealthy is true when the most recent service heartbeat
+	// succeeded, meaning the service can reach the cluster. It is only
+	// meaningful when the default heartbeat callback is in use.
+	HeartbeatHealthy bool
+	// Ready is true when the service is accepting new connections. It
+	// flips to false as soon as graceful shutdown begins, while existing
+	// sessions are still being served, so that load balancers stop
+	// routing new connections to a draining service.
+	Ready bool
+	// LDAP describes the state of the LDAP connection.
+	LDAP LDAPHealth
+}
+
+// LDAPHealth is the LDAP section of a health report. When initialization
+// failed, Detail names the specific Active Directory-side problem and
+// Remediation suggests how to resolve it.
+type LDAPHealth struct {
+	// Configured is true when the service is configured to connect to an
+	// LDAP server.
+	Configured bool
+	// Connected is true when the LDAP connection is initialized. It is
+	// always false when LDAP is not configured.
+	Connected bool
+	// Detail describes the most recent LDAP initialization failure, if any.
+	Detail string
+	// Remediation is an operator-facing hint for resolving the failure
+	// described by Detail.
+	Remediation string
+}
+
+// Health reports the service's current health.
+func (s *WindowsService) Health() ServiceHealth {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	detail, remediation := ldapHealthDetail(s.ldapLastError)
+	return ServiceHealth{
+		HeartbeatHealthy: s.heartbeatHealthy,
+		Ready:            !s.draining && (!s.ldapConfigured || s.ldapInitialized),
+		LDAP: LDAPHealth{
+			Configured:  s.ldapConfigured,
+			Connected:   s.ldapInitialized,
+			Detail:      detail,
+			Remediation: remediation,
+		},
+	}
+}
+
+// ldapHealthDetail maps the most recent LDAP initialization failure to an
+// operator-facing problem description and remediation hint. It relies on
+// LDAP result codes having been converted to typed errors when the failure
+// was recorded.
+func ldapHealthDetail(err error) (detail, remediation string) {
+	switch {
+	case err == nil:
+		return "", ""
+	case errors.Is(err, context.DeadlineExceeded):
+		return fmt.Sprintf("the LDAP server did not respond in time: %v", err),
+			"check that the LDAP address is correct and that no firewall drops traffic between this service and the domain controller"
+	case trace.IsConnectionProblem(err):
+		return fmt.Sprintf("the LDAP server is unreachable: %v", err),
+			"check that the LDAP address is correct and that the domain controller is up"
+	case trace.IsAccessDenied(err):
+		return fmt.Sprintf("the LDAP server rejected Teleport's credentials: %v", err),
+			"ensure that the Teleport CA is imported into the NTAuth store and that the service account has sufficient rights"
+	case trace.IsNotFound(err):
+		return fmt.Sprintf("an expected LDAP object is missing: %v", err),
+			"ensure that the CDP container for this cluster exists, or re-run the Teleport Active Directory setup"
+	default:
+		return err.Error(), "consult the service logs for details"
+	}
+}
+
 // Serve starts serving TLS connections for plainLis. plainLis should be a TCP
-// listener and Serve will handle TLS internally.
+// listener and Serve will handle TLS internally. If EnableProxyProtocol is
+// set, a PROXY protocol header is parsed from each connection before the TLS
+// handshake.
 func (s *WindowsService) Serve(plainLis net.Listener) error {
-	lis := tls.NewListener(plainLis, s.cfg.TLS)
-	defer lis.Close()
+	defer plainLis.Close()
+
+	// Participate in ALPN negotiation so that the listener can share a
+	// port with other protocols: desktop connections select this service
+	// by negotiating desktopALPNProtocol, while clients that do not
+	// request ALPN keep working as on a dedicated listener.
+	if !slices.Contains(s.cfg.TLS.NextProtos, desktopALPNProtocol) {
+		s.cfg.TLS.NextProtos = append(s.cfg.TLS.NextProtos, desktopALPNProtocol)
+	}
+
 	for {
 		select {
 		case <-s.closeCtx.Done():
 			return trace.Wrap(s.closeCtx.Err())
 		default:
 		}
-		conn, err := lis.Accept()
+		conn, err := plainLis.Accept()
 		if err != nil {
 			if utils.IsOKNetworkError(err) || trace.IsConnectionProblem(err) {
 				return nil
 			}
+			acceptErrors.Inc()
+			// Temporary failures such as running out of file descriptors
+			// tend to resolve on their own, so back off briefly instead of
+			// spinning on the failing listener.
+			if isTemporaryAcceptError(err) {
+				s.cfg.Log.WithError(err).Warning("Temporary failure accepting connection, backing off")
+				select {
+				case <-s.closeCtx.Done():
+					return trace.Wrap(s.closeCtx.Err())
+				case <-s.cfg.Clock.After(acceptBackoffInterval):
+				}
+				continue
+			}
 			return trace.Wrap(err)
 		}
-		proxyConn, ok := conn.(*tls.Conn)
-		if !ok {
-			return trace.ConnectionProblem(nil, "Got %T from TLS listener, expected *tls.Conn", conn)
+
+		if !s.acquireConnSlot() {
+			s.cfg.Log.Warningf("Connection cap reached, closing connection from %v", conn.RemoteAddr())
+			conn.Close()
+			continue
 		}
 
-		go s.handleConnection(proxyConn)
+		go func() {
+			defer s.releaseConnSlot()
+			if s.cfg.EnableProxyProtocol {
+				muxConn, err := stripProxyProtocolHeader(conn)
+				if err != nil {
+					s.cfg.Log.WithError(err).Warning("Failed to read PROXY protocol header, closing connection")
+					conn.Close()
+					return
+				}
+				conn = muxConn
+			}
+			tlsConn := tls.Server(conn, s.cfg.TLS)
+			if err := s.negotiateALPN(tlsConn); err != nil {
+				s.cfg.Log.WithError(err).Debug("Ignoring connection that did not negotiate the desktop ALPN protocol")
+				tlsConn.Close()
+				return
+			}
+			s.handleConnection(tlsConn)
+		}()
 	}
 }
 
+// negotiateALPN completes the TLS handshake on conn and verifies that the
+// connection selected the desktop service: either by negotiating
+// desktopALPNProtocol or by not requesting ALPN at all. A connection that
+// negotiated a different protocol was routed here by mistake and must not
+// be treated as desktop mTLS.
+func (s *WindowsService) negotiateALPN(conn *tls.Conn) error {
+	if err := conn.HandshakeContext(s.closeCtx); err != nil {
+		return trace.Wrap(err)
+	}
+	switch proto := conn.ConnectionState().NegotiatedProtocol; proto {
+	case "", desktopALPNProtocol:
+		return nil
+	default:
+		return trace.BadParameter("connection negotiated unexpected ALPN protocol %q", proto)
+	}
+}
+
+// acquireConnSlot reserves a slot for a connection handler goroutine,
+// honoring the MaxConcurrentConnections cap. The cap is read under the
+// lock so that Reconfigure can change it for new connections.
+func (s *WindowsService) acquireConnSlot() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if max := s.cfg.MaxConcurrentConnections; max > 0 && s.activeConnections >= max {
+		return false
+	}
+	s.activeConnections++
+	return true
+}
+
+// releaseConnSlot returns the slot reserved by acquireConnSlot.
+func (s *WindowsService) releaseConnSlot() {
+	s.mu.Lock()
+	s.activeConnections--
+	s.mu.Unlock()
+}
+
+// isTemporaryAcceptError reports whether an accept error is transient and
+// worth retrying after a short backoff.
+func isTemporaryAcceptError(err error) bool {
+	var netErr net.Error
+	//nolint:staticcheck // SA1019. Temporary is deprecated, but it is still
+	// the signal net.Listener implementations use for transient accept
+	// failures such as ENFILE.
+	return errors.As(err, &netErr) && netErr.Temporary()
+}
+
+// stripProxyProtocolHeader reads an optional PROXY protocol v1 or v2 header
+// from conn. The returned connection reports the client address advertised in
+// the header, if one was present.
+func stripProxyProtocolHeader(conn net.Conn) (net.Conn, error) {
+	muxConn := multiplexer.NewConn(conn)
+	proto, err := muxConn.Detect()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if proto == multiplexer.ProtoProxy || proto == multiplexer.ProtoProxyV2 {
+		if _, err := muxConn.ReadProxyLine(); err != nil {
+			return nil, trace.Wrap(err)
+		}
+	}
+	return muxConn, nil
+}
+
 func (s *WindowsService) readyForConnections() bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -679,13 +1572,43 @@ func (s *WindowsService) ldapReady() bool {
 	return s.ldapInitialized
 }
 
+// desktopNameFromSNI extracts the target desktop name from the TLS server
+// name by trimming the expected SNI suffix. Server names that don't end in
+// the suffix are rejected.
+func desktopNameFromSNI(serverName, suffix string) (string, error) {
+	name := strings.TrimSuffix(serverName, suffix)
+	if name == serverName || name == "" {
+		return "", trace.BadParameter("server name %q does not match expected SNI suffix %q", serverName, suffix)
+	}
+	return name, nil
+}
+
+// startSpan starts a tracing span with the given name and attributes.
+// Services constructed without a tracer, as tests do, fall back to a
+// no-op tracer.
+func (s *WindowsService) startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, oteltrace.Span) {
+	tracer := s.tracer
+	if tracer == nil {
+		tracer = tracing.NoopProvider().Tracer(teleport.ComponentWindowsDesktop)
+	}
+	return tracer.Start(ctx, name, oteltrace.WithAttributes(attrs...))
+}
+
 // handleConnection handles TLS connections from a Teleport proxy.
 // It authenticates and authorizes the connection, and then begins
 // translating the TDP messages from the proxy into native RDP.
 func (s *WindowsService) handleConnection(proxyConn *tls.Conn) {
 	log := s.cfg.Log
 
-	tdpConn := tdp.NewConn(proxyConn)
+	// Root span for the connection, so that authentication, authorization,
+	// credential generation and the RDP dial all hang off a single trace.
+	spanCtx, span := s.startSpan(s.closeCtx, "windowsDesktop/handleConnection")
+	defer span.End()
+
+	// Charge this connection's writes against the service-wide output
+	// budget so that one session streaming to a slow client cannot
+	// consume unbounded memory.
+	tdpConn := tdp.NewConn(s.outputBudget.wrap(s.closeCtx, proxyConn))
 	defer tdpConn.Close()
 
 	// Inline function to enforce that we are centralizing TDP Error sending in this function.
@@ -695,6 +1618,18 @@ func (s *WindowsService) handleConnection(proxyConn *tls.Conn) {
 		}
 	}
 
+	// a draining service serves its existing sessions to completion but
+	// takes on no new ones
+	s.mu.Lock()
+	draining := s.draining
+	s.mu.Unlock()
+	if draining {
+		const msg = "This service is shutting down and is not accepting new connections."
+		log.Info(msg)
+		sendTDPError(msg)
+		return
+	}
+
 	// don't handle connections until the LDAP initialization retry loop has succeeded
 	// (it would fail anyway, but this presents a better error to the user)
 	if !s.readyForConnections() {
@@ -720,44 +1655,57 @@ func (s *WindowsService) handleConnection(proxyConn *tls.Conn) {
 	defer s.cfg.ConnLimiter.ReleaseConnection(remoteAddr)
 
 	// Authenticate the client.
-	ctx, err := s.middleware.WrapContextWithUser(s.closeCtx, proxyConn)
+	_, authnSpan := s.startSpan(spanCtx, "windowsDesktop/authenticate")
+	ctx, err := s.middleware.WrapContextWithUser(spanCtx, proxyConn)
 	if err != nil {
+		authnSpan.RecordError(err)
+		authnSpan.End()
 		log.WithError(err).Warning("mTLS authentication failed for incoming connection")
 		sendTDPError("Connection authentication failed.")
 		return
 	}
+	authnSpan.End()
 	log.Debug("Authenticated Windows desktop connection")
 
+	_, authzSpan := s.startSpan(ctx, "windowsDesktop/authorize")
 	authContext, err := s.cfg.Authorizer.Authorize(ctx)
 	if err != nil {
+		authzSpan.RecordError(err)
+		authzSpan.End()
 		log.WithError(err).Warning("authorization failed for Windows desktop connection")
 		sendTDPError("Connection authorization failed.")
 		return
 	}
+	authzSpan.End()
 
 	// Fetch the target desktop info. Name of the desktop is passed via SNI.
-	desktopName := strings.TrimSuffix(proxyConn.ConnectionState().ServerName, SNISuffix)
+	serverName := proxyConn.ConnectionState().ServerName
+	desktopName, err := desktopNameFromSNI(serverName, s.cfg.SNISuffix)
+	if err != nil {
+		log.WithError(err).Warningf("rejecting connection with unexpected SNI %q", serverName)
+		sendTDPError("Connection was rejected due to an unexpected server name.")
+		return
+	}
 	log = log.WithField("desktop-name", desktopName)
 
-	desktops, err := s.cfg.AccessPoint.GetWindowsDesktops(ctx,
-		types.WindowsDesktopFilter{HostID: s.cfg.Heartbeat.HostUUID, Name: desktopName})
+	desktop, err := s.findDesktop(ctx, desktopName)
 	if err != nil {
+		if trace.IsNotFound(err) {
+			log.Errorf("desktop %v/%v not found", s.cfg.Heartbeat.HostUUID, desktopName)
+			sendTDPError(fmt.Sprintf("Could not find desktop %v.", desktopName))
+			return
+		}
 		log.WithError(err).Warning("Failed to fetch desktop by name")
 		sendTDPError("Teleport failed to find the requested desktop in its database.")
 		return
 	}
-	if len(desktops) == 0 {
-		log.Errorf("desktop %v/%v not found", s.cfg.Heartbeat.HostUUID, desktopName)
-		sendTDPError(fmt.Sprintf("Could not find desktop %v.", desktopName))
-		return
-	}
-	desktop := desktops[0]
 
 	log = log.WithField("desktop-addr", desktop.GetAddr())
 	log.Debug("Connecting to Windows desktop")
 	defer log.Debug("Windows desktop disconnected")
 
 	if err := s.connectRDP(ctx, log, tdpConn, desktop, authContext); err != nil {
+		span.RecordError(err)
 		log.Errorf("RDP connection failed: %v", err)
 		msg := "RDP connection failed."
 		var um trace.UserMessager
@@ -769,9 +1717,130 @@ func (s *WindowsService) handleConnection(proxyConn *tls.Conn) {
 	}
 }
 
+// findDesktop fetches the desktop with the given name registered to this
+// service. When the desktop is not found it retries the lookup a
+// configurable number of times with a short backoff, to smooth over cache
+// propagation delays right after discovery registers a desktop.
+func (s *WindowsService) findDesktop(ctx context.Context, desktopName string) (types.WindowsDesktop, error) {
+	filter := types.WindowsDesktopFilter{HostID: s.cfg.Heartbeat.HostUUID, Name: desktopName}
+	for attempt := 0; ; attempt++ {
+		desktops, err := s.cfg.AccessPoint.GetWindowsDesktops(ctx, filter)
+		switch {
+		case err != nil && !trace.IsNotFound(err):
+			return nil, trace.Wrap(err)
+		case err == nil && len(desktops) > 0:
+			return desktops[0], nil
+		}
+		if attempt >= s.cfg.DesktopLookupRetries {
+			return nil, trace.NotFound("windows desktop %v/%v not found", s.cfg.Heartbeat.HostUUID, desktopName)
+		}
+		select {
+		case <-s.cfg.Clock.After(desktopLookupRetryInterval):
+		case <-ctx.Done():
+			return nil, trace.Wrap(ctx.Err())
+		}
+	}
+}
+
+// negotiateTDP performs the desktop protocol handshake on tdpConn,
+// agreeing on the highest mutually supported version and the features
+// common to both sides. Incompatible clients are rejected with an error
+// carrying a user-facing message. Clients that don't begin with a
+// ClientHello skip the handshake entirely.
+func (s *WindowsService) negotiateTDP(tdpConn *tdp.Conn) error {
+	mt, err := tdpConn.NextMessageType()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	if mt != tdp.TypeClientHello {
+		return nil
+	}
+
+	msg, err := tdpConn.ReadMessage()
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	hello, ok := msg.(tdp.ClientHello)
+	if !ok {
+		return trace.BadParameter("expected ClientHello, got %T", msg)
+	}
+
+	serverHello, err := tdp.Negotiate(hello)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.Wrap(tdpConn.WriteMessage(serverHello))
+}
+
+// loginGetter returns the logins a user's roles allow for a resource.
+// It is implemented by [services.AccessChecker].
+type loginGetter interface {
+	GetAllowedLoginsForResource(resource services.AccessCheckable) ([]string, error)
+}
+
+// pickWindowsLogin resolves the Windows login for a session, in order of
+// precedence: the login explicitly requested by the client, the desktop's
+// login label, and finally the only login the user's roles allow for the
+// desktop. An error is returned when none of them yields a login.
+func pickWindowsLogin(requested string, desktop types.WindowsDesktop, logins loginGetter) (string, error) {
+	if requested != "" {
+		return requested, nil
+	}
+	if login := desktop.GetAllLabels()[defaultLoginLabel]; login != "" {
+		return login, nil
+	}
+	allowed, err := logins.GetAllowedLoginsForResource(desktop)
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	if len(allowed) == 1 && allowed[0] != types.Wildcard {
+		return allowed[0], nil
+	}
+	return "", trace.BadParameter("no Windows login was requested and no default login could be determined for desktop %q", desktop.GetName())
+}
+
+// defaultWindowsLogin resolves the Windows login to use when the client
+// doesn't request one. With LoginTrait configured, the login comes from the
+// identity's trait (or its Teleport username when the trait is absent), so
+// the certificate's UPN names the user's AD account. Otherwise the desktop
+// label and role-based defaults from pickWindowsLogin apply.
+func (s *WindowsService) defaultWindowsLogin(identity *tlsca.Identity, desktop types.WindowsDesktop, logins loginGetter) (string, error) {
+	if s.cfg.LoginTrait != "" {
+		for _, v := range identity.Traits[s.cfg.LoginTrait] {
+			if v != "" {
+				return v, nil
+			}
+		}
+		return identity.Username, nil
+	}
+	return pickWindowsLogin("", desktop, logins)
+}
+
+// hostCertPins returns the certificate pins configured for the desktop.
+// In strict mode (RequireHostCertPins) desktops without a pin are
+// rejected; otherwise they are connected to without verification.
+func (s *WindowsService) hostCertPins(desktop types.WindowsDesktop) ([]string, error) {
+	pins := s.cfg.HostCertificatePins[desktop.GetAddr()]
+	if len(pins) == 0 && s.cfg.RequireHostCertPins {
+		return nil, trace.AccessDenied("no RDP certificate pin is configured for desktop %q and pinning is required", desktop.GetName())
+	}
+	return pins, nil
+}
+
 func (s *WindowsService) connectRDP(ctx context.Context, log logrus.FieldLogger, tdpConn *tdp.Conn, desktop types.WindowsDesktop, authCtx *authz.Context) error {
 	identity := authCtx.Identity.GetIdentity()
 
+	ctx, span := s.startSpan(ctx, "windowsDesktop/connectRDP",
+		attribute.String("desktop_addr", desktop.GetAddr()))
+	defer span.End()
+
+	// Negotiate a protocol version and feature set before anything else.
+	// Clients that predate the handshake don't send a hello and are
+	// assumed to speak the original protocol.
+	if err := s.negotiateTDP(tdpConn); err != nil {
+		return trace.Wrap(err)
+	}
+
 	netConfig, err := s.cfg.AccessPoint.GetClusterNetworkingConfig(ctx)
 	if err != nil {
 		return trace.Wrap(err)
@@ -788,6 +1857,7 @@ func (s *WindowsService) connectRDP(ctx context.Context, log logrus.FieldLogger,
 	}
 
 	sessionID := session.NewID()
+	span.SetAttributes(attribute.String("session_id", string(sessionID)))
 
 	// in order for the session to be recorded, the cluster's session recording mode must
 	// not be "off" and the user's roles must enable recording
@@ -812,12 +1882,58 @@ func (s *WindowsService) connectRDP(ctx context.Context, log logrus.FieldLogger,
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
+	s.registerSession(string(sessionID), identity.Username, desktop.GetName(), cancel)
+	defer s.unregisterSession(string(sessionID))
+
+	// If the user's roles require a moderator to be present, hold the
+	// session here, before the RDP connection is even attempted, until the
+	// required moderators have joined.
+	modSession := newModeratedSession(authCtx.Checker.SessionPolicySets(), identity.Username, cancel)
+	if modSession.isModerated() {
+		s.registerModeratedSession(string(sessionID), modSession)
+		defer s.unregisterModeratedSession(string(sessionID))
+
+		msg := "Waiting for required participants to join: " + modSession.requirements()
+		if err := tdpConn.SendNotification(msg, tdp.SeverityInfo); err != nil {
+			return trace.Wrap(err)
+		}
+		if err := modSession.waitForModerators(ctx); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+
 	authorize := func(login string) error {
 		state := authCtx.GetAccessState(authPref)
-		return authCtx.Checker.CheckAccess(
+		err := authCtx.Checker.CheckAccess(
 			desktop,
 			state,
 			services.NewWindowsLoginMatcher(login))
+		if trace.IsAccessDenied(err) {
+			// Replace the generic denial with a typed error that tells
+			// the client UI why the connection was denied and what to do
+			// about it.
+			return trace.Wrap(explainAccessDenied(authCtx.Checker, state, desktop, login))
+		}
+		return trace.Wrap(err)
+	}
+
+	// Resolve a fallback login up front so that clients that don't
+	// specify one can still connect. It's fine if there is no default;
+	// the connection is rejected later only if the client omits the
+	// login too.
+	fallbackLogin, _ := s.defaultWindowsLogin(&identity, desktop, authCtx.Checker)
+
+	// The effective session TTL is the smaller of the service default and
+	// any maximum session TTL imposed by the user's roles.
+	certTTL := authCtx.Checker.AdjustSessionTTL(windows.CertTTL)
+
+	// Device redirection is governed by a single policy resolved from the
+	// user's roles, where combining roles can only narrow what is allowed.
+	redirection := resolveRedirectionPolicy(authCtx.Checker.Roles())
+
+	hostCertPins, err := s.hostCertPins(desktop)
+	if err != nil {
+		return trace.Wrap(err)
 	}
 
 	recorder, err := s.newSessionRecorder(recConfig, string(sessionID))
@@ -837,6 +1953,11 @@ func (s *WindowsService) connectRDP(ctx context.Context, log logrus.FieldLogger,
 		}()
 	}()
 
+	// Tee the recording so that live viewers can subscribe to the session's
+	// recording stream while it is still in progress.
+	recorder = s.registerLiveRecording(string(sessionID), recorder)
+	defer s.unregisterLiveRecording(string(sessionID))
+
 	// We won't have the windows username until we start to read from the websocket,
 	// but we need to start emitting audit events now. Create an auditor without
 	// specifying the username (we'll update it soon as we have it).
@@ -854,26 +1975,93 @@ func (s *WindowsService) connectRDP(ctx context.Context, log logrus.FieldLogger,
 	// it's important that we set the OnSend and OnRecv handlers prior to
 	// initializing the client so that we capture all relevant data in the
 	// session recording
-	delay := timer()
+	delay := timer(s.cfg.Clock)
 	tdpConn.OnSend = s.makeTDPSendHandler(ctx, recorder, delay, tdpConn, audit)
 	tdpConn.OnRecv = s.makeTDPReceiveHandler(ctx, recorder, delay, tdpConn, audit)
 	width, height := desktop.GetScreenSize()
-	//nolint:staticcheck // SA4023. False positive, depends on build tags.
-	rdpc, err := rdpclient.New(rdpclient.Config{
-		Log: log,
-		GenerateUserCert: func(ctx context.Context, username string, ttl time.Duration) (certDER, keyDER []byte, err error) {
-			return s.generateUserCert(ctx, username, ttl, desktop, createUsers, groups)
-		},
-		CertTTL:               windows.CertTTL,
-		Addr:                  addr.String(),
-		Conn:                  tdpConn,
-		AuthorizeFn:           authorize,
-		AllowClipboard:        authCtx.Checker.DesktopClipboard(),
-		AllowDirectorySharing: authCtx.Checker.DesktopDirectorySharing(),
-		ShowDesktopWallpaper:  s.cfg.ShowDesktopWallpaper,
-		Width:                 width,
-		Height:                height,
-	})
+
+	// If pooling is enabled, a warm connection from a previous session may
+	// be available. Reuse is scoped to the same Teleport user with the same
+	// Windows login on the same desktop, and access is re-checked against
+	// the current RBAC state in case roles changed since the connection was
+	// pooled.
+	var rdpc RDPClient
+	poolKey := rdpPoolKey{
+		teleportUser: identity.Username,
+		windowsLogin: fallbackLogin,
+		desktopAddr:  addr.String(),
+	}
+	if s.rdpPool != nil && fallbackLogin != "" {
+		if pooled, ok := s.rdpPool.take(poolKey); ok {
+			if err := authorize(pooled.GetClientUsername()); err == nil {
+				rdpc = pooled
+			} else {
+				pooled.Close()
+			}
+		}
+	}
+	if rdpc == nil {
+		// The dial span covers the TCP/RDP connection establishment and
+		// the handshake performed by the RDP backend.
+		_, dialSpan := s.startSpan(ctx, "windowsDesktop/rdpConnect",
+			attribute.String("session_id", string(sessionID)))
+		// When an HTTP CONNECT proxy is configured the RDP backend can't
+		// dial the desktop directly, so point it at a local relay that
+		// tunnels the connection through the proxy.
+		dialAddr := addr.String()
+		if s.cfg.RDPProxyURL != "" {
+			dialAddr, err = s.startRDPProxyRelay(ctx, addr.String())
+		}
+		if err == nil {
+			rdpConfig := rdpclient.Config{
+				Log: log,
+				GenerateUserCert: func(ctx context.Context, username string, ttl time.Duration) (certDER, keyDER []byte, err error) {
+					certDER, keyDER, err = s.generateUserCert(ctx, username, ttl, desktop, createUsers, groups)
+					if err == nil {
+						// Capture the issued certificate so the session
+						// start event can be correlated with the logon
+						// in the Windows event log.
+						audit.recordCertificate(certDER)
+						log.WithFields(logrus.Fields{
+							"cert_serial":      audit.certSerial,
+							"cert_fingerprint": audit.certFingerprint,
+						}).Infof("Issued certificate for Windows user %v", username)
+					}
+					return certDER, keyDER, err
+				},
+				CertTTL:                certTTL,
+				Addr:                   dialAddr,
+				Conn:                   tdpConn,
+				AuthorizeFn:            authorize,
+				DefaultLogin:           fallbackLogin,
+				AllowClipboard:         redirection.Clipboard,
+				AllowDirectorySharing:  redirection.DirectorySharing,
+				AllowPrinter:           redirection.Printer,
+				AllowAudio:             redirection.Audio,
+				ShowDesktopWallpaper:   s.cfg.ShowDesktopWallpaper,
+				PerformanceProfile:     s.cfg.PerformanceProfile,
+				CustomPerformanceFlags: s.cfg.CustomPerformanceFlags,
+				RestrictedAdminMode:    s.cfg.RestrictedAdminMode,
+				Width:                  width,
+				Height:                 height,
+				HostCertPins:           hostCertPins,
+				Clock:                  s.cfg.Clock,
+			}
+			//nolint:staticcheck // SA4023. False positive, depends on build tags.
+			rdpc, err = newRDPClientWithTimeout(s.cfg.Clock, s.cfg.ConnectTimeout, func() (RDPClient, error) {
+				return s.cfg.NewRDPClient(rdpConfig)
+			})
+			//nolint:staticcheck // SA4023. False positive, depends on build tags.
+			if err != nil {
+				err = rdpConfig.ConnectError(err)
+			}
+		}
+		//nolint:staticcheck // SA4023. False positive, depends on build tags.
+		if err != nil {
+			dialSpan.RecordError(err)
+		}
+		dialSpan.End()
+	}
 	// before we check the error above, we grab the windows user so that
 	// future audit events include the proper username
 	var windowsUser string
@@ -934,10 +2122,28 @@ func (s *WindowsService) connectRDP(ctx context.Context, log logrus.FieldLogger,
 
 	err = rdpc.Run(ctx)
 
+	// If the backend supports reuse, keep the authenticated connection
+	// warm for the next session from the same user with the same login.
+	if s.rdpPool != nil && err == nil {
+		if rc, ok := rdpc.(reusableRDPClient); ok && rc.Reusable() {
+			s.rdpPool.put(rdpPoolKey{
+				teleportUser: identity.Username,
+				windowsLogin: windowsUser,
+				desktopAddr:  addr.String(),
+			}, rc)
+		}
+	}
+
+	txBytes, rxBytes := tdpConn.BytesSent(), tdpConn.BytesReceived()
+	sessionTxBytes.Add(float64(txBytes))
+	sessionRxBytes.Add(float64(rxBytes))
+	sessionDuration.Observe(s.cfg.Clock.Now().Sub(audit.startTime).Seconds())
+
 	// ctx may have been canceled, so emit with a separate context
 	endEvent := audit.makeSessionEnd(recordSession)
 	s.record(context.Background(), recorder, endEvent)
 	s.emit(context.Background(), endEvent)
+	s.emit(context.Background(), s.makeSessionUsageEvent(audit, txBytes, rxBytes))
 
 	return trace.Wrap(err)
 }
@@ -1093,38 +2299,53 @@ func (s *WindowsService) staticHostHeartbeatInfo(host servicecfg.WindowsHost,
 	getHostLabels func(string) map[string]string,
 ) func() (types.Resource, error) {
 	return func() (types.Resource, error) {
-		addr := host.Address.String()
-		labels := getHostLabels(addr)
-		for k, v := range host.Labels {
-			labels[k] = v
-		}
-		name := host.Name
-		if name == "" {
-			var err error
-			name, err = s.nameForStaticHost(addr)
-			if err != nil {
-				return nil, trace.Wrap(err)
-			}
-		}
-		labels[types.OriginLabel] = types.OriginConfigFile
-		labels[types.ADLabel] = strconv.FormatBool(host.AD)
-		desktop, err := types.NewWindowsDesktopV3(
-			name,
-			labels,
-			types.WindowsDesktopSpecV3{
-				Addr:   addr,
-				Domain: s.cfg.Domain,
-				HostID: s.cfg.Heartbeat.HostUUID,
-				NonAD:  !host.AD,
-			})
+		desktop, err := s.staticHostDesktop(host, getHostLabels, s.nameForStaticHost)
 		if err != nil {
 			return nil, trace.Wrap(err)
 		}
-		desktop.SetExpiry(s.cfg.Clock.Now().UTC().Add(apidefaults.ServerAnnounceTTL))
 		return desktop, nil
 	}
 }
 
+// staticHostDesktop builds the Windows Desktop resource announced for a
+// static host. nameForAddr resolves a name for hosts that don't configure
+// one.
+func (s *WindowsService) staticHostDesktop(host servicecfg.WindowsHost,
+	getHostLabels func(string) map[string]string,
+	nameForAddr func(string) (string, error),
+) (types.WindowsDesktop, error) {
+	addr := host.Address.String()
+	labels := getHostLabels(addr)
+	for k, v := range host.Labels {
+		labels[k] = v
+	}
+	name := host.Name
+	if name == "" {
+		var err error
+		name, err = nameForAddr(addr)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+	}
+	labels[types.OriginLabel] = types.OriginConfigFile
+	labels[types.ADLabel] = strconv.FormatBool(host.AD)
+	s.applyReachabilityLabel(labels, addr)
+	desktop, err := types.NewWindowsDesktopV3(
+		name,
+		labels,
+		types.WindowsDesktopSpecV3{
+			Addr:   addr,
+			Domain: s.cfg.Domain,
+			HostID: s.cfg.Heartbeat.HostUUID,
+			NonAD:  !host.AD,
+		})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	desktop.SetExpiry(s.cfg.Clock.Now().UTC().Add(apidefaults.ServerAnnounceTTL))
+	return desktop, nil
+}
+
 // nameForStaticHost attempts to find the name of an existing Windows desktop
 // with the same address. If no matching address is found, a new name is
 // generated.
@@ -1139,9 +2360,15 @@ func (s *WindowsService) nameForStaticHost(addr string) (string, error) {
 	if err != nil {
 		return "", trace.Wrap(err)
 	}
+	return s.nameForStaticHostIn(addr, desktops), nil
+}
+
+// nameForStaticHostIn resolves addr against an already-fetched desktop
+// list, generating a new name when no existing desktop shares the address.
+func (s *WindowsService) nameForStaticHostIn(addr string, desktops []types.WindowsDesktop) string {
 	for _, d := range desktops {
 		if d.GetAddr() == addr {
-			return d.GetName(), nil
+			return d.GetName()
 		}
 	}
 
@@ -1151,20 +2378,50 @@ func (s *WindowsService) nameForStaticHost(addr string) (string, error) {
 	}
 	parts := strings.Split(s.cfg.Heartbeat.HostUUID, "-")
 	prefix := parts[len(parts)-1]
-	return prefix + "-static-" + strings.ReplaceAll(host, ".", "-"), nil
+	return prefix + "-static-" + strings.ReplaceAll(host, ".", "-")
+}
+
+// newRDPClientWithTimeout enforces a timeout on the blocking rdpclient.New
+// call, which establishes the RDP connection. Without it, a host that accepts
+// the TCP connection but stalls the RDP negotiation would hang the connection
+// goroutine indefinitely.
+func newRDPClientWithTimeout(clock clockwork.Clock, timeout time.Duration, newClient func() (RDPClient, error)) (RDPClient, error) {
+	type result struct {
+		client RDPClient
+		err    error
+	}
+
+	// resultC is buffered so that the goroutine below doesn't leak
+	// if the timeout fires first.
+	resultC := make(chan result, 1)
+	go func() {
+		client, err := newClient()
+		resultC <- result{client, err}
+	}()
+
+	select {
+	case r := <-resultC:
+		return r.client, r.err
+	case <-clock.After(timeout):
+		// rdpclient.New can't be interrupted, so the goroutine above may
+		// continue to block, but it will be able to deliver its result
+		// and terminate if the connection attempt ever completes.
+		return nil, trace.ConnectionProblem(context.DeadlineExceeded,
+			"RDP connection was not established within %v", timeout)
+	}
 }
 
 // timer returns a closure that on each call returns the
 // number of milliseconds that have elapsed since the first call.
 // it returns 0 on the very first call.
-func timer() func() int64 {
+func timer(clock clockwork.Clock) func() int64 {
 	var first time.Time
 	return func() int64 {
 		if first.IsZero() {
-			first = time.Now()
+			first = clock.Now()
 			return 0
 		}
-		return int64(time.Since(first) / time.Millisecond)
+		return int64(clock.Now().Sub(first) / time.Millisecond)
 	}
 }
 
@@ -1172,7 +2429,29 @@ func timer() func() int64 {
 // optionally querying LDAP for the user's Security Identifier.
 func (s *WindowsService) generateUserCert(ctx context.Context, username string, ttl time.Duration, desktop types.WindowsDesktop, createUsers bool, groups []string) (certDER, keyDER []byte, err error) {
 	var activeDirectorySID string
-	if !desktop.NonAD() {
+	if !desktop.NonAD() && !s.cfg.SkipADUserLookup {
+		activeDirectorySID, err = s.lookupADAccount(ctx, username)
+		if err != nil {
+			return nil, nil, trace.Wrap(err)
+		}
+	}
+	return s.generateCredentials(ctx, generateCredentialsRequest{
+		username:           username,
+		domain:             desktop.GetDomain(),
+		ttl:                ttl,
+		activeDirectorySID: activeDirectorySID,
+		createUser:         createUsers,
+		groups:             groups,
+		desktopName:        desktop.GetName(),
+	})
+}
+
+// lookupADAccount searches LDAP for the Active Directory account matching
+// the given username and returns its security identifier. A NotFound error
+// is returned if no such account exists. Results (including failed lookups)
+// are cached briefly to avoid an LDAP roundtrip for every certificate issued.
+func (s *WindowsService) lookupADAccount(ctx context.Context, username string) (string, error) {
+	sid, err := utils.FnCacheGet(ctx, s.adAccountCache, username, func(ctx context.Context) (string, error) {
 		// Find the user's SID
 		filter := windows.CombineLDAPFilters([]string{
 			fmt.Sprintf("(%s=%s)", windows.AttrSAMAccountType, windows.AccountTypeUser),
@@ -1189,27 +2468,21 @@ func (s *WindowsService) generateUserCert(ctx context.Context, username string,
 			entries, err = s.lc.ReadWithFilter(s.cfg.LDAPConfig.DomainDN(), filter, []string{windows.AttrObjectSid})
 		}
 		if err != nil {
-			return nil, nil, trace.Wrap(err)
+			return "", trace.Wrap(err)
 		}
 		if len(entries) == 0 {
-			return nil, nil, trace.NotFound("could not find Windows account %q", username)
+			return "", trace.NotFound("could not find Windows account %q", username)
 		} else if len(entries) > 1 {
 			s.cfg.Log.Warnf("found multiple entries for username %q, taking the first", username)
 		}
-		activeDirectorySID, err = windows.ADSIDStringFromLDAPEntry(entries[0])
+		sid, err := windows.ADSIDStringFromLDAPEntry(entries[0])
 		if err != nil {
-			return nil, nil, trace.Wrap(err)
+			return "", trace.Wrap(err)
 		}
-		s.cfg.Log.Debugf("Found objectSid %v for Windows username %v", activeDirectorySID, username)
-	}
-	return s.generateCredentials(ctx, generateCredentialsRequest{
-		username:           username,
-		domain:             desktop.GetDomain(),
-		ttl:                ttl,
-		activeDirectorySID: activeDirectorySID,
-		createUser:         createUsers,
-		groups:             groups,
+		s.cfg.Log.Debugf("Found objectSid %v for Windows username %v", sid, username)
+		return sid, nil
 	})
+	return sid, trace.Wrap(err)
 }
 
 // generateCredentialsRequest are the request parameters for generating a windows cert/key pair
@@ -1228,6 +2501,10 @@ type generateCredentialsRequest struct {
 	createUser bool
 	// groups are groups that user should be member of
 	groups []string
+	// desktopName is the name of the desktop the certificate is issued for.
+	// If specified (!= ""), the certificate is scoped to that desktop and
+	// cannot be replayed against other desktops.
+	desktopName string
 }
 
 // generateCredentials generates a private key / certificate pair for the given
@@ -1236,6 +2513,15 @@ type generateCredentialsRequest struct {
 // Directory. See:
 // https://docs.microsoft.com/en-us/windows/security/identity-protection/smart-cards/smart-card-certificate-requirements-and-enumeration
 func (s *WindowsService) generateCredentials(ctx context.Context, request generateCredentialsRequest) (certDER, keyDER []byte, err error) {
+	ctx, span := s.startSpan(ctx, "windowsDesktop/generateCredentials",
+		attribute.String("windows_domain", request.domain))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+		}
+		span.End()
+	}()
+
 	// If PKI domain has been overridden, make sure we pass that through
 	// to the cert request, otherwise the CRL in the cert we issue will
 	// point at the wrong domain.
@@ -1245,17 +2531,158 @@ func (s *WindowsService) generateCredentials(ctx context.Context, request genera
 	}
 
 	return windows.GenerateWindowsDesktopCredentials(ctx, &windows.GenerateCredentialsRequest{
-		CAType:             types.UserCA,
-		Username:           request.username,
-		Domain:             request.domain,
-		TTL:                request.ttl,
-		ClusterName:        s.clusterName,
-		ActiveDirectorySID: request.activeDirectorySID,
-		LDAPConfig:         lc,
-		AuthClient:         s.cfg.AuthClient,
-		CreateUser:         request.createUser,
-		Groups:             request.groups,
+		CAType:                 types.UserCA,
+		Username:               request.username,
+		Domain:                 request.domain,
+		TTL:                    request.ttl,
+		SkewAllowance:          s.cfg.CertSkewAllowance,
+		ValidityMode:           s.cfg.CertValidityMode,
+		ClusterName:            s.clusterName,
+		ActiveDirectorySID:     request.activeDirectorySID,
+		LDAPConfig:             lc,
+		AuthClient:             s.cfg.AuthClient,
+		CreateUser:             request.createUser,
+		Groups:                 request.groups,
+		ExtraCDPs:              s.cfg.ExtraCDPs,
+		SignatureAlgorithm:     s.cfg.CertSignatureAlgorithm,
+		CertificateTemplateOID: s.cfg.CertificateTemplateOID,
+		SANOtherNameOID:        s.cfg.SANOtherNameOID,
+		DesktopScope:           request.desktopName,
+	})
+}
+
+// PublishedCRL reads back the CRL that this service published in LDAP and
+// summarizes it, so that admins can confirm Active Directory holds what
+// Teleport generated.
+func (s *WindowsService) PublishedCRL(ctx context.Context) (*windows.CRLInfo, error) {
+	info, err := s.ca.ReadCRL(ctx)
+	return info, trace.Wrap(err)
+}
+
+// CertChainReport is the result of a [WindowsService.VerifyCertChain] check.
+type CertChainReport struct {
+	// CertSubject is the subject common name of the generated test certificate.
+	CertSubject string
+	// CRLDistributionPoint is the CRL endpoint embedded in the certificate.
+	CRLDistributionPoint string
+	// ChainVerified is true if the certificate chains to the cluster's user CA.
+	ChainVerified bool
+	// CRLSignatureVerified is true if the published CRL is signed by the user CA.
+	CRLSignatureVerified bool
+	// Revoked is true if the certificate's serial number appears on the
+	// published CRL.
+	Revoked bool
+}
+
+// VerifyCertChain generates a short-lived test certificate for username and
+// verifies it against the user CA and the CRL currently published in LDAP.
+// It is intended for troubleshooting setup problems: in a healthy deployment
+// the report shows the chain verified, the CRL signature valid and the
+// certificate not revoked.
+func (s *WindowsService) VerifyCertChain(ctx context.Context, username string) (*CertChainReport, error) {
+	certDER, _, err := s.generateCredentials(ctx, generateCredentialsRequest{
+		username: username,
+		domain:   s.cfg.Domain,
+		ttl:      windows.CertTTL,
 	})
+	if err != nil {
+		return nil, trace.Wrap(err, "generating test certificate")
+	}
+	cert, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	if len(cert.CRLDistributionPoints) == 0 {
+		return nil, trace.BadParameter("generated certificate has no CRL distribution point")
+	}
+
+	ca, err := s.cfg.AccessPoint.GetCertAuthority(ctx, types.CertAuthID{
+		Type:       types.UserCA,
+		DomainName: s.clusterName,
+	}, false)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	pool := x509.NewCertPool()
+	var caCerts []*x509.Certificate
+	for _, keyPair := range ca.GetTrustedTLSKeyPairs() {
+		caCert, err := tlsca.ParseCertificatePEM(keyPair.Cert)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		caCerts = append(caCerts, caCert)
+		pool.AddCert(caCert)
+	}
+
+	crlDER, err := s.readPublishedCRL(cert.CRLDistributionPoints[0])
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	crl, err := x509.ParseRevocationList(crlDER)
+	if err != nil {
+		return nil, trace.Wrap(err, "parsing published CRL")
+	}
+
+	return buildCertChainReport(cert, pool, caCerts, crl), nil
+}
+
+// readPublishedCRL fetches the raw CRL from the LDAP entry referenced by the
+// given CRL distribution point, e.g.
+// ldap:///CN=prod,CN=Teleport,...?certificateRevocationList?base?objectClass=cRLDistributionPoint
+func (s *WindowsService) readPublishedCRL(cdp string) ([]byte, error) {
+	rest, ok := strings.CutPrefix(cdp, "ldap:///")
+	if !ok {
+		return nil, trace.BadParameter("unsupported CRL distribution point %q", cdp)
+	}
+	dn, _, _ := strings.Cut(rest, "?")
+
+	entries, err := s.lc.ReadWithFilter(dn,
+		fmt.Sprintf("(%s=cRLDistributionPoint)", windows.AttrObjectClass),
+		[]string{"certificateRevocationList"})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	for _, entry := range entries {
+		if crl := entry.GetRawAttributeValue("certificateRevocationList"); len(crl) > 0 {
+			return crl, nil
+		}
+	}
+	return nil, trace.NotFound("no CRL published at %q", dn)
+}
+
+// buildCertChainReport verifies cert against the given CA pool and CRL and
+// summarizes the results.
+func buildCertChainReport(cert *x509.Certificate, pool *x509.CertPool, caCerts []*x509.Certificate, crl *x509.RevocationList) *CertChainReport {
+	report := &CertChainReport{
+		CertSubject: cert.Subject.CommonName,
+	}
+	if len(cert.CRLDistributionPoints) > 0 {
+		report.CRLDistributionPoint = cert.CRLDistributionPoints[0]
+	}
+
+	_, err := cert.Verify(x509.VerifyOptions{
+		Roots: pool,
+		// Desktop certs carry the smartcard logon key usage in a raw
+		// extension, which crypto/x509 can't check for us.
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	report.ChainVerified = err == nil
+
+	for _, caCert := range caCerts {
+		if crl.CheckSignatureFrom(caCert) == nil {
+			report.CRLSignatureVerified = true
+			break
+		}
+	}
+
+	for _, revoked := range crl.RevokedCertificateEntries {
+		if revoked.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+			report.Revoked = true
+			break
+		}
+	}
+
+	return report
 }
 
 // trackSession creates a session tracker for the given sessionID and
@@ -1302,6 +2729,147 @@ func (s *WindowsService) trackSession(ctx context.Context, id *tlsca.Identity, w
 	return nil
 }
 
+// desktopSession records an active desktop session and the cancel
+// function that tears down its connection.
+type desktopSession struct {
+	username  string
+	desktop   string
+	startedAt time.Time
+	cancel    context.CancelFunc
+}
+
+// registerSession adds a session to the set of active sessions on this
+// service so that it can be terminated out-of-band.
+func (s *WindowsService) registerSession(sessionID, username, desktopName string, cancel context.CancelFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.activeSessions[sessionID] = desktopSession{
+		username:  username,
+		desktop:   desktopName,
+		startedAt: s.cfg.Clock.Now(),
+		cancel:    cancel,
+	}
+}
+
+// SessionInfo describes an active desktop session held by this service.
+type SessionInfo struct {
+	// ID is the Teleport session ID.
+	ID string
+	// Username is the Teleport user driving the session.
+	Username string
+	// Desktop is the name of the desktop the session is connected to.
+	Desktop string
+	// StartedAt is when the session was registered with this service.
+	StartedAt time.Time
+}
+
+// SessionFilter selects a subset of the active sessions. Zero-valued
+// fields match every session.
+type SessionFilter struct {
+	// Username matches sessions driven by the given Teleport user.
+	Username string
+	// Desktop matches sessions connected to the given desktop.
+	Desktop string
+	// MinAge matches sessions that have been active for at least the
+	// given duration.
+	MinAge time.Duration
+}
+
+// matches reports whether a session with the given attributes passes the
+// filter at the given point in time.
+func (f SessionFilter) matches(session desktopSession, now time.Time) bool {
+	if f.Username != "" && session.username != f.Username {
+		return false
+	}
+	if f.Desktop != "" && session.desktop != f.Desktop {
+		return false
+	}
+	if f.MinAge > 0 && now.Sub(session.startedAt) < f.MinAge {
+		return false
+	}
+	return true
+}
+
+// QueryActiveSessions returns the active sessions matching the given
+// filter, oldest first, so that operators can export session state to
+// dashboards without terminating anything.
+func (s *WindowsService) QueryActiveSessions(filter SessionFilter) []SessionInfo {
+	now := s.cfg.Clock.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []SessionInfo
+	for id, session := range s.activeSessions {
+		if !filter.matches(session, now) {
+			continue
+		}
+		result = append(result, SessionInfo{
+			ID:        id,
+			Username:  session.username,
+			Desktop:   session.desktop,
+			StartedAt: session.startedAt,
+		})
+	}
+	slices.SortFunc(result, func(a, b SessionInfo) int {
+		return a.StartedAt.Compare(b.StartedAt)
+	})
+	return result
+}
+
+// unregisterSession removes a session from the set of active sessions.
+func (s *WindowsService) unregisterSession(sessionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.activeSessions, sessionID)
+}
+
+// TerminateUserSessions cancels all active desktop sessions belonging to
+// the given Teleport user and returns the number of sessions terminated.
+// Sessions for other users are unaffected.
+//
+// Canceling a session closes its connection through the same context that
+// the connection monitor uses when enforcing locks, so sessions terminated
+// here are torn down exactly as if a lock had matched them.
+func (s *WindowsService) TerminateUserSessions(username string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	terminated := 0
+	for id, session := range s.activeSessions {
+		if session.username == username {
+			session.cancel()
+			delete(s.activeSessions, id)
+			terminated++
+		}
+	}
+
+	return terminated
+}
+
+// TerminateDesktopSessions cancels every active session targeting the
+// given desktop and returns the number of sessions terminated, so that a
+// desktop can be drained of sessions before maintenance. Sessions to other
+// desktops are unaffected.
+//
+// Terminated sessions leave the registry immediately, so a graceful
+// shutdown waiting for sessions to end does not wait for them.
+func (s *WindowsService) TerminateDesktopSessions(desktopName string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	terminated := 0
+	for id, session := range s.activeSessions {
+		if session.desktop == desktopName {
+			session.cancel()
+			delete(s.activeSessions, id)
+			terminated++
+		}
+	}
+
+	return terminated
+}
+
 // monitorErrorSender implements the io.StringWriter
 // interface in order to allow us to pass connection
 // monitor disconnect messages back to the frontend
@@ -20,23 +20,51 @@ package desktop
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
 	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"io"
+	"math/big"
+	"net"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/go-ldap/ldap/v3"
+	"github.com/gravitational/trace"
 	"github.com/jonboulle/clockwork"
 	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
 	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 
+	"github.com/gravitational/teleport"
 	"github.com/gravitational/teleport/api/types"
 	"github.com/gravitational/teleport/api/types/events"
+	"github.com/gravitational/teleport/api/types/wrappers"
 	"github.com/gravitational/teleport/lib/auth"
 	"github.com/gravitational/teleport/lib/auth/windows"
+	"github.com/gravitational/teleport/lib/authz"
 	libevents "github.com/gravitational/teleport/lib/events"
 	"github.com/gravitational/teleport/lib/events/eventstest"
+	"github.com/gravitational/teleport/lib/limiter"
+	"github.com/gravitational/teleport/lib/multiplexer"
+	"github.com/gravitational/teleport/lib/service/servicecfg"
+	"github.com/gravitational/teleport/lib/services"
+	"github.com/gravitational/teleport/lib/srv/desktop/rdp/rdpclient"
 	"github.com/gravitational/teleport/lib/srv/desktop/tdp"
+	"github.com/gravitational/teleport/lib/tlsca"
+	"github.com/gravitational/teleport/lib/utils"
+	"github.com/gravitational/teleport/lib/utils/cert"
 )
 
 func TestConfigWildcardBaseDN(t *testing.T) {
@@ -202,158 +230,2324 @@ func TestGenerateCredentials(t *testing.T) {
 	}
 }
 
-func TestEmitsRecordingEventsOnSend(t *testing.T) {
-	clock := clockwork.NewFakeClock()
-	s := &WindowsService{
-		cfg: WindowsServiceConfig{
-			Clock: clock,
+func TestDesktopNameFromSNI(t *testing.T) {
+	for _, test := range []struct {
+		desc       string
+		serverName string
+		suffix     string
+		name       string
+		assert     require.ErrorAssertionFunc
+	}{
+		{
+			desc:       "OK - default suffix",
+			serverName: "example-desktop" + SNISuffix,
+			suffix:     SNISuffix,
+			name:       "example-desktop",
+			assert:     require.NoError,
 		},
+		{
+			desc:       "OK - custom suffix",
+			serverName: "example-desktop.custom.example.com",
+			suffix:     ".custom.example.com",
+			name:       "example-desktop",
+			assert:     require.NoError,
+		},
+		{
+			desc:       "NOK - suffix mismatch",
+			serverName: "example-desktop.custom.example.com",
+			suffix:     SNISuffix,
+			assert:     require.Error,
+		},
+		{
+			desc:       "NOK - suffix only",
+			serverName: SNISuffix,
+			suffix:     SNISuffix,
+			assert:     require.Error,
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			name, err := desktopNameFromSNI(test.serverName, test.suffix)
+			test.assert(t, err)
+			require.Equal(t, test.name, name)
+		})
 	}
-	emitter := &eventstest.MockRecorderEmitter{}
-	emitterPreparer := libevents.WithNoOpPreparer(emitter)
+}
 
-	// a fake PNG Frame message
-	encoded := []byte{byte(tdp.TypePNGFrame), 0x01, 0x02}
+// TestTerminateUserSessions verifies that all active sessions for a given
+// user are torn down while other users' sessions are unaffected.
+func TestTerminateUserSessions(t *testing.T) {
+	s := &WindowsService{
+		cfg:            WindowsServiceConfig{Clock: clockwork.NewFakeClock()},
+		activeSessions: make(map[string]desktopSession),
+	}
 
-	delay := func() int64 { return 0 }
-	handler := s.makeTDPSendHandler(context.Background(), emitterPreparer, delay, nil /* conn */, nil /* auditor */)
+	newSessionCtx := func(user, id string) context.Context {
+		ctx, cancel := context.WithCancel(context.Background())
+		s.registerSession(id, user, "test-desktop", cancel)
+		return ctx
+	}
 
-	// the handler accepts both the message structure and its encoded form,
-	// but our logic only depends on the encoded form, so pass a nil message
-	handler(nil /* message */, encoded)
+	alice1 := newSessionCtx("alice", "session-1")
+	alice2 := newSessionCtx("alice", "session-2")
+	bob := newSessionCtx("bob", "session-3")
 
-	e := emitter.LastEvent()
-	require.NotNil(t, e)
-	dr, ok := e.(*events.DesktopRecording)
-	require.True(t, ok)
-	require.Equal(t, encoded, dr.Message)
+	require.Equal(t, 2, s.TerminateUserSessions("alice"))
+	require.ErrorIs(t, alice1.Err(), context.Canceled)
+	require.ErrorIs(t, alice2.Err(), context.Canceled)
+	require.NoError(t, bob.Err())
+
+	// terminated sessions are removed from the registry
+	require.Equal(t, 0, s.TerminateUserSessions("alice"))
+	require.Equal(t, 1, s.TerminateUserSessions("bob"))
+	require.ErrorIs(t, bob.Err(), context.Canceled)
 }
 
-func TestSkipsExtremelyLargePNGs(t *testing.T) {
+// TestTerminateDesktopSessions verifies that all active sessions targeting
+// a given desktop are torn down while sessions to other desktops survive.
+func TestTerminateDesktopSessions(t *testing.T) {
+	s := &WindowsService{
+		cfg:            WindowsServiceConfig{Clock: clockwork.NewFakeClock()},
+		activeSessions: make(map[string]desktopSession),
+	}
+
+	newSessionCtx := func(user, desktop, id string) context.Context {
+		ctx, cancel := context.WithCancel(context.Background())
+		s.registerSession(id, user, desktop, cancel)
+		return ctx
+	}
+
+	prod1 := newSessionCtx("alice", "win-prod", "session-1")
+	prod2 := newSessionCtx("bob", "win-prod", "session-2")
+	dev := newSessionCtx("alice", "win-dev", "session-3")
+
+	require.Equal(t, 2, s.TerminateDesktopSessions("win-prod"))
+	require.ErrorIs(t, prod1.Err(), context.Canceled)
+	require.ErrorIs(t, prod2.Err(), context.Canceled)
+	require.NoError(t, dev.Err())
+
+	// terminated sessions are removed from the registry
+	require.Equal(t, 0, s.TerminateDesktopSessions("win-prod"))
+	require.Equal(t, 1, s.TerminateDesktopSessions("win-dev"))
+	require.ErrorIs(t, dev.Err(), context.Canceled)
+}
+
+// TestQueryActiveSessions verifies that the active-session query narrows
+// its results by user, desktop and session age.
+func TestQueryActiveSessions(t *testing.T) {
 	clock := clockwork.NewFakeClock()
 	s := &WindowsService{
-		cfg: WindowsServiceConfig{
-			Clock: clock,
-			Log:   &logrus.Logger{Out: io.Discard},
+		cfg:            WindowsServiceConfig{Clock: clock},
+		activeSessions: make(map[string]desktopSession),
+	}
+
+	// The sessions start half an hour apart, making session-1 an hour old
+	// by the time session-3 begins.
+	s.registerSession("session-1", "alice", "win-prod", func() {})
+	clock.Advance(30 * time.Minute)
+	s.registerSession("session-2", "alice", "win-dev", func() {})
+	clock.Advance(30 * time.Minute)
+	s.registerSession("session-3", "bob", "win-prod", func() {})
+
+	ids := func(sessions []SessionInfo) []string {
+		var ids []string
+		for _, session := range sessions {
+			ids = append(ids, session.ID)
+		}
+		return ids
+	}
+
+	for _, test := range []struct {
+		name    string
+		filter  SessionFilter
+		wantIDs []string
+	}{
+		{
+			name:    "no filter matches everything, oldest first",
+			wantIDs: []string{"session-1", "session-2", "session-3"},
+		},
+		{
+			name:    "by user",
+			filter:  SessionFilter{Username: "alice"},
+			wantIDs: []string{"session-1", "session-2"},
+		},
+		{
+			name:    "by desktop",
+			filter:  SessionFilter{Desktop: "win-prod"},
+			wantIDs: []string{"session-1", "session-3"},
+		},
+		{
+			name:    "by age",
+			filter:  SessionFilter{MinAge: time.Hour},
+			wantIDs: []string{"session-1"},
 		},
+		{
+			name:   "combined filters narrow further",
+			filter: SessionFilter{Username: "alice", Desktop: "win-dev"},
+			wantIDs: []string{
+				"session-2",
+			},
+		},
+		{
+			name:    "no match",
+			filter:  SessionFilter{Username: "carol"},
+			wantIDs: nil,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			sessions := s.QueryActiveSessions(test.filter)
+			require.Equal(t, test.wantIDs, ids(sessions))
+		})
 	}
-	emitter := &eventstest.MockRecorderEmitter{}
-	emitterPreparer := libevents.WithNoOpPreparer(emitter)
+}
 
-	// a fake PNG Frame message, which is way too big to be legitimate
-	maliciousPNG := make([]byte, libevents.MaxProtoMessageSizeBytes+1)
-	rand.Read(maliciousPNG)
-	maliciousPNG[0] = byte(tdp.TypePNGFrame)
+// loginGetterFunc is a loginGetter that calls itself.
+type loginGetterFunc func(resource services.AccessCheckable) ([]string, error)
 
-	delay := func() int64 { return 0 }
-	handler := s.makeTDPSendHandler(context.Background(), emitterPreparer, delay, nil /* conn */, nil /* auditor */)
+func (f loginGetterFunc) GetAllowedLoginsForResource(resource services.AccessCheckable) ([]string, error) {
+	return f(resource)
+}
 
-	// the handler accepts both the message structure and its encoded form,
-	// but our logic only depends on the encoded form, so pass a nil message
-	var msg tdp.Message
-	handler(msg, maliciousPNG)
+// TestPickWindowsLogin verifies the precedence of the ways a session's
+// Windows login can be determined.
+func TestPickWindowsLogin(t *testing.T) {
+	newDesktop := func(labels map[string]string) types.WindowsDesktop {
+		desktop, err := types.NewWindowsDesktopV3("test-desktop", labels,
+			types.WindowsDesktopSpecV3{Addr: "host.example.com:3389"})
+		require.NoError(t, err)
+		return desktop
+	}
+	allowLogins := func(logins ...string) loginGetter {
+		return loginGetterFunc(func(services.AccessCheckable) ([]string, error) {
+			return logins, nil
+		})
+	}
 
-	require.Nil(t, emitter.LastEvent())
+	for _, test := range []struct {
+		name      string
+		requested string
+		labels    map[string]string
+		logins    loginGetter
+		assert    require.ErrorAssertionFunc
+		expected  string
+	}{
+		{
+			name:      "explicit request wins",
+			requested: "bob",
+			labels:    map[string]string{defaultLoginLabel: "Administrator"},
+			logins:    allowLogins("svc-account"),
+			assert:    require.NoError,
+			expected:  "bob",
+		},
+		{
+			name:     "desktop label default",
+			labels:   map[string]string{defaultLoginLabel: "Administrator"},
+			logins:   allowLogins("svc-account"),
+			assert:   require.NoError,
+			expected: "Administrator",
+		},
+		{
+			name:     "sole role login",
+			logins:   allowLogins("svc-account"),
+			assert:   require.NoError,
+			expected: "svc-account",
+		},
+		{
+			name:   "ambiguous role logins",
+			logins: allowLogins("alice", "bob"),
+			assert: require.Error,
+		},
+		{
+			name:   "wildcard is not a default",
+			logins: allowLogins(types.Wildcard),
+			assert: require.Error,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			login, err := pickWindowsLogin(test.requested, newDesktop(test.labels), test.logins)
+			test.assert(t, err)
+			require.Equal(t, test.expected, login)
+		})
+	}
 }
 
-func TestEmitsRecordingEventsOnReceive(t *testing.T) {
+// TestDefaultWindowsLoginTrait verifies that a configured login trait
+// supplies the Windows login (and therefore the UPN of the issued
+// certificate) for SSO-provisioned users, overriding the other defaults.
+func TestDefaultWindowsLoginTrait(t *testing.T) {
+	desktop, err := types.NewWindowsDesktopV3("test-desktop",
+		map[string]string{defaultLoginLabel: "Administrator"},
+		types.WindowsDesktopSpecV3{Addr: "host.example.com:3389"})
+	require.NoError(t, err)
+	noLogins := loginGetterFunc(func(services.AccessCheckable) ([]string, error) {
+		return nil, nil
+	})
+
+	for _, test := range []struct {
+		name       string
+		loginTrait string
+		identity   tlsca.Identity
+		expected   string
+	}{
+		{
+			name:       "trait overrides the username",
+			loginTrait: "ad_login",
+			identity: tlsca.Identity{
+				Username: "alice@example.com",
+				Traits:   wrappers.Traits{"ad_login": []string{"alice.ad"}},
+			},
+			expected: "alice.ad",
+		},
+		{
+			name:       "missing trait falls back to the username",
+			loginTrait: "ad_login",
+			identity:   tlsca.Identity{Username: "alice@example.com"},
+			expected:   "alice@example.com",
+		},
+		{
+			name:       "empty trait values fall back to the username",
+			loginTrait: "ad_login",
+			identity: tlsca.Identity{
+				Username: "alice@example.com",
+				Traits:   wrappers.Traits{"ad_login": []string{""}},
+			},
+			expected: "alice@example.com",
+		},
+		{
+			name: "no trait configured keeps the label default",
+			identity: tlsca.Identity{
+				Username: "alice@example.com",
+				Traits:   wrappers.Traits{"ad_login": []string{"alice.ad"}},
+			},
+			expected: "Administrator",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			s := &WindowsService{cfg: WindowsServiceConfig{LoginTrait: test.loginTrait}}
+			login, err := s.defaultWindowsLogin(&test.identity, desktop, noLogins)
+			require.NoError(t, err)
+			require.Equal(t, test.expected, login)
+		})
+	}
+}
+
+// TestGracefulCloseFlipsReadiness verifies that a service begins
+// reporting not ready as soon as graceful shutdown starts, while its
+// active sessions keep running.
+func TestGracefulCloseFlipsReadiness(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
 	clock := clockwork.NewFakeClock()
 	s := &WindowsService{
 		cfg: WindowsServiceConfig{
+			Log:   logrus.New(),
 			Clock: clock,
 		},
+		lc:             &windows.LDAPClient{},
+		activeSessions: make(map[string]desktopSession),
+		closeCtx:       ctx,
+		close:          cancel,
 	}
-	emitter := &eventstest.MockRecorderEmitter{}
-	emitterPreparer := libevents.WithNoOpPreparer(emitter)
+	require.True(t, s.Health().Ready)
 
-	delay := func() int64 { return 0 }
-	handler := s.makeTDPReceiveHandler(context.Background(), emitterPreparer, delay, nil /* conn */, nil /* auditor */)
+	sessionCtx, sessionCancel := context.WithCancel(context.Background())
+	s.registerSession("session-1", "alice", "test-desktop", sessionCancel)
 
-	msg := tdp.MouseButton{
-		Button: tdp.LeftMouseButton,
-		State:  tdp.ButtonPressed,
+	closed := make(chan error, 1)
+	go func() {
+		closed <- s.GracefulClose(context.Background())
+	}()
+
+	// Readiness flips immediately, but the active session is untouched
+	// and the service is still running.
+	require.Eventually(t, func() bool {
+		return !s.Health().Ready
+	}, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, sessionCtx.Err())
+	require.NoError(t, s.closeCtx.Err())
+
+	// Once the last session ends, the service shuts down.
+	s.unregisterSession("session-1")
+	clock.BlockUntil(1)
+	clock.Advance(drainCheckInterval)
+	require.NoError(t, <-closed)
+	require.ErrorIs(t, s.closeCtx.Err(), context.Canceled)
+}
+
+// TestDefaultOnHeartbeat verifies that the default heartbeat callback
+// tracks the result of the most recent heartbeat attempt.
+func TestDefaultOnHeartbeat(t *testing.T) {
+	cfg := &HeartbeatConfig{
+		HostUUID:   "test-host-id",
+		PublicAddr: "desktop.example.com:3028",
 	}
-	handler(msg)
+	require.NoError(t, cfg.CheckAndSetDefaults())
 
-	e := emitter.LastEvent()
-	require.NotNil(t, e)
-	dr, ok := e.(*events.DesktopRecording)
-	require.True(t, ok)
-	decoded, err := tdp.Decode(dr.Message)
-	require.NoError(t, err)
-	require.Equal(t, msg, decoded)
+	s := &WindowsService{}
+
+	s.defaultOnHeartbeat(nil)
+	require.True(t, s.heartbeatHealthy)
+
+	s.defaultOnHeartbeat(trace.ConnectionProblem(nil, "lost connection to auth"))
+	require.False(t, s.heartbeatHealthy)
+
+	s.defaultOnHeartbeat(nil)
+	require.True(t, s.heartbeatHealthy)
 }
 
-func TestEmitsClipboardSendEvents(t *testing.T) {
-	_, audit := setup(testDesktop)
-	emitter := &eventstest.MockRecorderEmitter{}
+// fakeLDAPClient is an ldap.Client that does nothing, used to exercise
+// LDAP initialization without a real Active Directory environment.
+// Calls to methods other than Close panic.
+type fakeLDAPClient struct {
+	ldap.Client
+}
+
+func (fakeLDAPClient) Close() error { return nil }
+
+// TestInitializeLDAPWithFakeClient verifies that LDAP initialization can
+// complete with a fake LDAP client supplied via the service configuration,
+// without a real Active Directory environment.
+func TestInitializeLDAPWithFakeClient(t *testing.T) {
+	authServer, err := auth.NewTestAuthServer(auth.TestAuthServerConfig{
+		ClusterName: "test",
+		Dir:         t.TempDir(),
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, authServer.Close())
+	})
+
+	tlsServer, err := authServer.NewTestTLSServer()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, tlsServer.Close())
+	})
+
+	client, err := tlsServer.NewClient(auth.TestServerID(types.RoleWindowsDesktop, "test-host-id"))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, client.Close())
+	})
+
+	connectorCalled := false
 	s := &WindowsService{
+		clusterName: "test",
+		closeCtx:    context.Background(),
+		lc:          &windows.LDAPClient{},
 		cfg: WindowsServiceConfig{
-			Clock:   audit.clock,
-			Emitter: emitter,
+			Log:   logrus.New(),
+			Clock: clockwork.NewFakeClock(),
+			LDAPConfig: windows.LDAPConfig{
+				Addr:     "ldap.example.com:636",
+				Domain:   "test.example.com",
+				Username: `EXAMPLE\svc-teleport`,
+				SID:      "S-1-5-21-1329593140-2634913955-1900852804-500",
+			},
+			AuthClient: client,
+			LDAPConnector: func(tlsConfig *tls.Config) (ldap.Client, error) {
+				require.NotNil(t, tlsConfig)
+				connectorCalled = true
+				return fakeLDAPClient{}, nil
+			},
+			SkipCRLPublish: true,
 		},
 	}
+	t.Cleanup(s.lc.Close)
 
-	handler := s.makeTDPReceiveHandler(
-		context.Background(),
-		libevents.WithNoOpPreparer(&libevents.DiscardRecorder{}),
-		func() int64 { return 0 },
-		&tdp.Conn{},
-		audit,
-	)
-
-	fakeClipboardData := make([]byte, 1024)
-	rand.Read(fakeClipboardData)
+	require.NoError(t, s.initializeLDAP())
+	require.True(t, connectorCalled)
+	require.True(t, s.ldapReady())
+}
 
-	start := s.cfg.Clock.Now().UTC()
-	msg := tdp.ClipboardData(fakeClipboardData)
-	handler(msg)
+// notImplementedCRLAccessPoint simulates an older auth server that doesn't
+// support CRL generation.
+type notImplementedCRLAccessPoint struct {
+	auth.WindowsDesktopAccessPoint
+}
 
-	e := emitter.LastEvent()
-	require.NotNil(t, e)
-	cs, ok := e.(*events.DesktopClipboardSend)
-	require.True(t, ok)
-	require.Equal(t, int32(len(fakeClipboardData)), cs.Length)
-	require.Equal(t, audit.sessionID, cs.SessionID)
-	require.Equal(t, audit.desktop.GetAddr(), cs.DesktopAddr)
-	require.Equal(t, audit.clusterName, cs.ClusterName)
-	require.Equal(t, start, cs.Time)
+func (notImplementedCRLAccessPoint) GenerateCertAuthorityCRL(ctx context.Context, caType types.CertAuthType) ([]byte, error) {
+	return nil, trace.NotImplemented("CRL generation is not supported")
 }
 
-func TestEmitsClipboardReceiveEvents(t *testing.T) {
-	_, audit := setup(testDesktop)
-	emitter := &eventstest.MockRecorderEmitter{}
+// TestCRLPublishUnsupportedByAuth verifies that LDAP initialization still
+// succeeds when the auth server doesn't implement CRL generation, unless the
+// operator explicitly requires CRL publishing.
+func TestCRLPublishUnsupportedByAuth(t *testing.T) {
+	authServer, err := auth.NewTestAuthServer(auth.TestAuthServerConfig{
+		ClusterName: "test",
+		Dir:         t.TempDir(),
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, authServer.Close())
+	})
+
+	tlsServer, err := authServer.NewTestTLSServer()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, tlsServer.Close())
+	})
+
+	client, err := tlsServer.NewClient(auth.TestServerID(types.RoleWindowsDesktop, "test-host-id"))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, client.Close())
+	})
+
+	ldapConfig := windows.LDAPConfig{
+		Addr:     "ldap.example.com:636",
+		Domain:   "test.example.com",
+		Username: `EXAMPLE\svc-teleport`,
+		SID:      "S-1-5-21-1329593140-2634913955-1900852804-500",
+	}
 	s := &WindowsService{
+		clusterName: "test",
+		closeCtx:    context.Background(),
+		lc:          &windows.LDAPClient{},
 		cfg: WindowsServiceConfig{
-			Clock:   audit.clock,
-			Emitter: emitter,
+			Log:        logrus.New(),
+			Clock:      clockwork.NewFakeClock(),
+			LDAPConfig: ldapConfig,
+			AuthClient: client,
+			LDAPConnector: func(tlsConfig *tls.Config) (ldap.Client, error) {
+				return fakeLDAPClient{}, nil
+			},
 		},
 	}
+	t.Cleanup(s.lc.Close)
+	s.ca = windows.NewCertificateStoreClient(windows.CertificateStoreConfig{
+		AccessPoint: notImplementedCRLAccessPoint{},
+		LDAPConfig:  ldapConfig,
+		Log:         s.cfg.Log,
+		ClusterName: s.clusterName,
+		LC:          s.lc,
+	})
 
-	handler := s.makeTDPSendHandler(
-		context.Background(),
-		libevents.WithNoOpPreparer(&libevents.DiscardRecorder{}),
-		func() int64 { return 0 },
-		&tdp.Conn{},
-		audit,
-	)
+	// By default the unsupported CRL generation is only a warning.
+	require.NoError(t, s.initializeLDAP())
+	require.True(t, s.ldapReady())
 
-	fakeClipboardData := make([]byte, 512)
-	rand.Read(fakeClipboardData)
+	// With the flag on, the same error is fatal.
+	s.cfg.RequireCRLPublish = true
+	err = s.initializeLDAP()
+	require.Error(t, err)
+	require.True(t, trace.IsNotImplemented(err))
+}
 
-	start := s.cfg.Clock.Now().UTC()
-	msg := tdp.ClipboardData(fakeClipboardData)
-	encoded, err := msg.Encode()
-	require.NoError(t, err)
-	handler(msg, encoded)
+// adAccountTestClient is an LDAP client that returns canned search results
+// and records the number of searches performed.
+type adAccountTestClient struct {
+	ldap.Client
+	searches int
+	entries  []*ldap.Entry
+}
 
-	e := emitter.LastEvent()
-	require.NotNil(t, e)
-	cs, ok := e.(*events.DesktopClipboardReceive)
-	require.True(t, ok, "expected DesktopClipboardReceive, got %T", e)
-	require.Equal(t, int32(len(fakeClipboardData)), cs.Length)
-	require.Equal(t, audit.sessionID, cs.SessionID)
-	require.Equal(t, audit.desktop.GetAddr(), cs.DesktopAddr)
+func (c *adAccountTestClient) SearchWithPaging(req *ldap.SearchRequest, pagingSize uint32) (*ldap.SearchResult, error) {
+	c.searches++
+	return &ldap.SearchResult{Entries: c.entries}, nil
+}
+
+func (c *adAccountTestClient) Close() error { return nil }
+
+// TestLookupADAccount verifies the pre-flight Active Directory account
+// lookup performed before issuing certificates, including the caching of
+// both successful and failed lookups.
+func TestLookupADAccount(t *testing.T) {
+	// binary form of S-1-5-21 (revision 1, authority 5, sub-authority 21)
+	sidBytes := []byte{1, 1, 0, 0, 0, 0, 0, 5, 21, 0, 0, 0}
+
+	newService := func(t *testing.T, entries []*ldap.Entry) (*WindowsService, *adAccountTestClient) {
+		cache, err := utils.NewFnCache(utils.FnCacheConfig{
+			TTL:     adAccountCacheTTL,
+			Context: context.Background(),
+		})
+		require.NoError(t, err)
+
+		ldapClient := &adAccountTestClient{entries: entries}
+		lc := &windows.LDAPClient{Cfg: windows.LDAPConfig{Domain: "test.example.com"}}
+		lc.SetClient(ldapClient)
+
+		s := &WindowsService{
+			cfg: WindowsServiceConfig{
+				Log:        logrus.New(),
+				LDAPConfig: windows.LDAPConfig{Domain: "test.example.com"},
+			},
+			lc:             lc,
+			adAccountCache: cache,
+		}
+		return s, ldapClient
+	}
+
+	t.Run("account found", func(t *testing.T) {
+		entry := ldap.NewEntry("CN=alice,DC=test,DC=example,DC=com", map[string][]string{
+			windows.AttrObjectSid: {string(sidBytes)},
+		})
+		s, ldapClient := newService(t, []*ldap.Entry{entry})
+
+		sid, err := s.lookupADAccount(context.Background(), "alice")
+		require.NoError(t, err)
+		require.Equal(t, "S-1-5-21", sid)
+
+		// repeated lookups within the TTL are served from the cache
+		sid, err = s.lookupADAccount(context.Background(), "alice")
+		require.NoError(t, err)
+		require.Equal(t, "S-1-5-21", sid)
+		require.Equal(t, 1, ldapClient.searches)
+	})
+
+	t.Run("account missing", func(t *testing.T) {
+		s, ldapClient := newService(t, nil /* entries */)
+
+		_, err := s.lookupADAccount(context.Background(), "bob")
+		require.True(t, trace.IsNotFound(err))
+
+		// negative results are cached as well
+		_, err = s.lookupADAccount(context.Background(), "bob")
+		require.True(t, trace.IsNotFound(err))
+		require.Equal(t, 1, ldapClient.searches)
+	})
+}
+
+// TestConnectTimeout verifies that connection establishment is aborted
+// with a clear error when the RDP handshake stalls.
+func TestConnectTimeout(t *testing.T) {
+	clock := clockwork.NewFakeClock()
+
+	// stallC simulates an RDP host that accepts the connection
+	// but never completes the handshake.
+	stallC := make(chan struct{})
+	t.Cleanup(func() { close(stallC) })
+
+	errC := make(chan error, 1)
+	go func() {
+		_, err := newRDPClientWithTimeout(clock, defaultRDPConnectTimeout, func() (RDPClient, error) {
+			<-stallC
+			return nil, trace.ConnectionProblem(nil, "stalled")
+		})
+		errC <- err
+	}()
+
+	// wait for the timeout to be registered with the fake clock
+	// before advancing past it
+	clock.BlockUntil(1)
+	clock.Advance(defaultRDPConnectTimeout)
+
+	err := <-errC
+	require.Error(t, err)
+	require.True(t, trace.IsConnectionProblem(err))
+	require.ErrorContains(t, err, "was not established within")
+}
+
+// fakeRDPClient is a minimal RDPClient implementation that emits a single
+// notification over the TDP connection when the session runs.
+type fakeRDPClient struct {
+	username   string
+	conn       *tdp.Conn
+	lastActive time.Time
+}
+
+func (f *fakeRDPClient) Run(ctx context.Context) error {
+	return f.conn.WriteMessage(tdp.Notification{Message: "session started", Severity: tdp.SeverityInfo})
+}
+
+func (f *fakeRDPClient) GetClientUsername() string { return f.username }
+
+func (f *fakeRDPClient) GetClientLastActive() time.Time { return f.lastActive }
+
+func (f *fakeRDPClient) UpdateClientActivity() { f.lastActive = time.Now() }
+
+// TestRDPClientFactory verifies that an RDP backend supplied via
+// WindowsServiceConfig.NewRDPClient is wired into the connection path:
+// messages it writes are pumped through the TDP connection and captured
+// by the recording callbacks, without a real RDP stack.
+func TestRDPClientFactory(t *testing.T) {
+	s := &WindowsService{
+		cfg: WindowsServiceConfig{
+			Clock: clockwork.NewFakeClock(),
+		},
+	}
+
+	emitter := &eventstest.MockRecorderEmitter{}
+	emitterPreparer := libevents.WithNoOpPreparer(emitter)
+
+	// The server end of a TDP connection, wired with the same recording
+	// callback that connectRDP installs.
+	serverConn, clientConn := net.Pipe()
+	t.Cleanup(func() { serverConn.Close() })
+	tdpConn := tdp.NewConn(serverConn)
+	delay := func() int64 { return 0 }
+	tdpConn.OnSend = s.makeTDPSendHandler(context.Background(), emitterPreparer, delay, tdpConn, nil /* auditor */)
+
+	// Drain the other end of the pipe so writes don't block.
+	go io.Copy(io.Discard, clientConn)
+
+	fake := &fakeRDPClient{username: "alice"}
+	s.cfg.NewRDPClient = func(cfg rdpclient.Config) (RDPClient, error) {
+		fake.conn = cfg.Conn
+		return fake, nil
+	}
+
+	rdpc, err := newRDPClientWithTimeout(s.cfg.Clock, defaultRDPConnectTimeout, func() (RDPClient, error) {
+		return s.cfg.NewRDPClient(rdpclient.Config{Conn: tdpConn})
+	})
+	require.NoError(t, err)
+	require.Equal(t, "alice", rdpc.GetClientUsername())
+
+	// Running the fake session emits a notification over the TDP
+	// connection, which must show up in the session recording.
+	require.NoError(t, rdpc.Run(context.Background()))
+
+	e := emitter.LastEvent()
+	require.NotNil(t, e)
+	dr, ok := e.(*events.DesktopRecording)
+	require.True(t, ok)
+	require.NotEmpty(t, dr.Message)
+}
+
+// TestRestrictedAdminMode verifies that connection failures are tied to
+// Restricted Admin mode when the mode was requested, since hosts that don't
+// support the mode reject the security negotiation with an otherwise
+// generic protocol error.
+func TestRestrictedAdminMode(t *testing.T) {
+	negotiationErr := trace.ConnectionProblem(nil, "security negotiation failed")
+
+	t.Run("connection errors point at the mode", func(t *testing.T) {
+		cfg := rdpclient.Config{
+			Addr:                "host.example.com:3389",
+			RestrictedAdminMode: true,
+		}
+		err := cfg.ConnectError(negotiationErr)
+		require.True(t, trace.IsConnectionProblem(err))
+		require.ErrorContains(t, err, "Restricted Admin mode")
+		require.ErrorContains(t, err, "host.example.com:3389")
+	})
+
+	t.Run("errors pass through when the mode is off", func(t *testing.T) {
+		cfg := rdpclient.Config{Addr: "host.example.com:3389"}
+		err := cfg.ConnectError(negotiationErr)
+		require.ErrorContains(t, err, "security negotiation failed")
+		require.NotContains(t, err.Error(), "Restricted Admin mode")
+	})
+
+	t.Run("successful connections are not annotated", func(t *testing.T) {
+		cfg := rdpclient.Config{
+			Addr:                "host.example.com:3389",
+			RestrictedAdminMode: true,
+		}
+		require.NoError(t, cfg.ConnectError(nil))
+	})
+}
+
+// countingAccessPoint counts desktop listing and upsert calls so that tests
+// can compare the auth load of the heartbeat strategies.
+type countingAccessPoint struct {
+	auth.WindowsDesktopAccessPoint
+	getCalls    int
+	upsertCalls int
+}
+
+func (c *countingAccessPoint) GetWindowsDesktops(ctx context.Context, f types.WindowsDesktopFilter) ([]types.WindowsDesktop, error) {
+	c.getCalls++
+	return nil, nil
+}
+
+func (c *countingAccessPoint) UpsertWindowsDesktop(ctx context.Context, d types.WindowsDesktop) error {
+	c.upsertCalls++
+	return nil
+}
+
+// TestBatchedStaticHostHeartbeats compares the number of auth calls needed
+// to announce N unnamed static hosts per-host versus batched. The per-host
+// strategy performs a desktop listing per host to resolve names, while the
+// batched strategy fetches the listing once per announce cycle.
+func TestBatchedStaticHostHeartbeats(t *testing.T) {
+	const numHosts = 10
+
+	newService := func(ap *countingAccessPoint) *WindowsService {
+		hosts := make([]servicecfg.WindowsHost, 0, numHosts)
+		for i := 0; i < numHosts; i++ {
+			hosts = append(hosts, servicecfg.WindowsHost{
+				Address: *utils.MustParseAddr(fmt.Sprintf("host-%d.example.com:3389", i)),
+			})
+		}
+		return &WindowsService{
+			cfg: WindowsServiceConfig{
+				Clock:        clockwork.NewFakeClock(),
+				AccessPoint:  ap,
+				HostLabelsFn: func(string) map[string]string { return map[string]string{} },
+				Heartbeat: HeartbeatConfig{
+					HostUUID:    "00000000-0000-0000-0000-0000deadbeef",
+					StaticHosts: hosts,
+				},
+			},
+			closeCtx: context.Background(),
+		}
+	}
+
+	// Per-host: each host's GetServerInfo performs its own desktop
+	// listing, and its heartbeat follows up with one announce per host.
+	perHost := &countingAccessPoint{}
+	s := newService(perHost)
+	for _, host := range s.cfg.Heartbeat.StaticHosts {
+		info, err := s.staticHostHeartbeatInfo(host, s.cfg.HostLabelsFn)()
+		require.NoError(t, err)
+		desktop, ok := info.(types.WindowsDesktop)
+		require.True(t, ok)
+		require.NoError(t, perHost.UpsertWindowsDesktop(context.Background(), desktop))
+	}
+	require.Equal(t, numHosts, perHost.getCalls)
+	require.Equal(t, numHosts, perHost.upsertCalls)
+
+	// Batched: a single listing resolves names for the whole cycle, and
+	// every host still gets its own upsert with a fresh expiry.
+	batched := &countingAccessPoint{}
+	s = newService(batched)
+	require.NoError(t, s.announceStaticHosts(context.Background()))
+	require.Equal(t, 1, batched.getCalls)
+	require.Equal(t, numHosts, batched.upsertCalls)
+
+	require.Less(t, batched.getCalls+batched.upsertCalls, perHost.getCalls+perHost.upsertCalls)
+}
+
+// TestRDPHostCertPinning verifies that the certificate presented by an RDP
+// host is checked against the configured pins, and that strict mode rejects
+// desktops with no pin at all.
+func TestRDPHostCertPinning(t *testing.T) {
+	newHostCert := func(t *testing.T, cn string) *x509.Certificate {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: cn},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+		}, &x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: cn},
+		}, &key.PublicKey, key)
+		require.NoError(t, err)
+		cert, err := x509.ParseCertificate(der)
+		require.NoError(t, err)
+		return cert
+	}
+
+	hostCert := newHostCert(t, "desktop.example.com")
+	spoofedCert := newHostCert(t, "spoofed.example.com")
+
+	// Pins may come from tooling that prints fingerprints with colon
+	// separators and uppercase hex; both must be accepted.
+	fingerprint := sha256.Sum256(hostCert.Raw)
+	pin := strings.ToUpper(hex.EncodeToString(fingerprint[:]))
+	var parts []string
+	for i := 0; i < len(pin); i += 2 {
+		parts = append(parts, pin[i:i+2])
+	}
+	pin = strings.Join(parts, ":")
+
+	t.Run("matching cert is accepted", func(t *testing.T) {
+		cfg := rdpclient.Config{Addr: "desktop.example.com:3389", HostCertPins: []string{pin}}
+		require.NoError(t, cfg.VerifyServerCert(hostCert.Raw))
+	})
+
+	t.Run("mismatching cert is rejected", func(t *testing.T) {
+		cfg := rdpclient.Config{Addr: "desktop.example.com:3389", HostCertPins: []string{pin}}
+		err := cfg.VerifyServerCert(spoofedCert.Raw)
+		require.True(t, trace.IsAccessDenied(err), "expected AccessDenied, got %v", err)
+	})
+
+	t.Run("no pins accepts any cert", func(t *testing.T) {
+		cfg := rdpclient.Config{Addr: "desktop.example.com:3389"}
+		require.NoError(t, cfg.VerifyServerCert(spoofedCert.Raw))
+	})
+
+	t.Run("strict mode requires a pin", func(t *testing.T) {
+		s := &WindowsService{cfg: WindowsServiceConfig{
+			RequireHostCertPins: true,
+			HostCertificatePins: map[string][]string{
+				"pinned.example.com:3389": {pin},
+			},
+		}}
+
+		pinned, err := types.NewWindowsDesktopV3("pinned", nil,
+			types.WindowsDesktopSpecV3{Addr: "pinned.example.com:3389"})
+		require.NoError(t, err)
+		pins, err := s.hostCertPins(pinned)
+		require.NoError(t, err)
+		require.Equal(t, []string{pin}, pins)
+
+		unpinned, err := types.NewWindowsDesktopV3("unpinned", nil,
+			types.WindowsDesktopSpecV3{Addr: "unpinned.example.com:3389"})
+		require.NoError(t, err)
+		_, err = s.hostCertPins(unpinned)
+		require.True(t, trace.IsAccessDenied(err), "expected AccessDenied, got %v", err)
+	})
+
+	t.Run("invalid pin never matches", func(t *testing.T) {
+		_, err := rdpclient.NormalizeCertPin("not-a-fingerprint")
+		require.True(t, trace.IsBadParameter(err), "expected BadParameter, got %v", err)
+
+		cfg := rdpclient.Config{Addr: "desktop.example.com:3389", HostCertPins: []string{"not-a-fingerprint"}}
+		err = cfg.VerifyServerCert(hostCert.Raw)
+		require.True(t, trace.IsAccessDenied(err), "expected AccessDenied, got %v", err)
+	})
+}
+
+// TestRDPPerformanceProfiles verifies that the selected performance profile
+// resolves to the expected RDP performance flags.
+func TestRDPPerformanceProfiles(t *testing.T) {
+	for _, test := range []struct {
+		desc string
+		cfg  rdpclient.Config
+		want rdpclient.PerformanceFlags
+	}{
+		{
+			desc: "default is the balanced broadband profile",
+			cfg:  rdpclient.Config{},
+			want: rdpclient.PerfDisableWallpaper | rdpclient.PerfDisableFullWindowDrag |
+				rdpclient.PerfDisableMenuAnimations | rdpclient.PerfEnableFontSmoothing,
+		},
+		{
+			desc: "lan keeps every visual feature",
+			cfg: rdpclient.Config{
+				PerformanceProfile:   rdpclient.PerformanceProfileLAN,
+				ShowDesktopWallpaper: true,
+			},
+			want: rdpclient.PerfEnableFontSmoothing | rdpclient.PerfEnableDesktopComposition,
+		},
+		{
+			desc: "low-bandwidth disables everything",
+			cfg:  rdpclient.Config{PerformanceProfile: rdpclient.PerformanceProfileLowBandwidth},
+			want: rdpclient.PerfDisableWallpaper | rdpclient.PerfDisableFullWindowDrag |
+				rdpclient.PerfDisableMenuAnimations | rdpclient.PerfDisableTheming |
+				rdpclient.PerfDisableCursorShadow | rdpclient.PerfDisableCursorSettings,
+		},
+		{
+			desc: "custom flags are used verbatim",
+			cfg: rdpclient.Config{
+				PerformanceProfile:     rdpclient.PerformanceProfileCustom,
+				CustomPerformanceFlags: rdpclient.PerfDisableTheming | rdpclient.PerfEnableFontSmoothing,
+			},
+			want: rdpclient.PerfDisableWallpaper | rdpclient.PerfDisableTheming |
+				rdpclient.PerfEnableFontSmoothing,
+		},
+		{
+			desc: "wallpaper setting overrides the profile",
+			cfg: rdpclient.Config{
+				PerformanceProfile:   rdpclient.PerformanceProfileLowBandwidth,
+				ShowDesktopWallpaper: true,
+			},
+			want: rdpclient.PerfDisableFullWindowDrag | rdpclient.PerfDisableMenuAnimations |
+				rdpclient.PerfDisableTheming | rdpclient.PerfDisableCursorShadow |
+				rdpclient.PerfDisableCursorSettings,
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			require.Equal(t, test.want, test.cfg.PerformanceFlags())
+		})
+	}
+
+	t.Run("unknown profile is rejected", func(t *testing.T) {
+		cfg := &WindowsServiceConfig{PerformanceProfile: "dial-up"}
+		require.ErrorContains(t, cfg.CheckAndSetDefaults(), "unsupported RDP performance profile")
+	})
+}
+
+// TestTDPNegotiation verifies the version handshake performed at the
+// start of a desktop connection: compatible clients get a ServerHello
+// with the negotiated version, incompatible ones are rejected, and
+// legacy clients that don't send a hello are passed through untouched.
+func TestTDPNegotiation(t *testing.T) {
+	setup := func(t *testing.T) (*WindowsService, *tdp.Conn, *tdp.Conn) {
+		s := &WindowsService{}
+		serverEnd, clientEnd := net.Pipe()
+		t.Cleanup(func() { serverEnd.Close() })
+		t.Cleanup(func() { clientEnd.Close() })
+		return s, tdp.NewConn(serverEnd), tdp.NewConn(clientEnd)
+	}
+
+	t.Run("matched version", func(t *testing.T) {
+		s, serverConn, clientConn := setup(t)
+		negotiateErr := make(chan error, 1)
+		go func() {
+			negotiateErr <- s.negotiateTDP(serverConn)
+		}()
+		require.NoError(t, clientConn.WriteMessage(tdp.ClientHello{
+			MinVersion:   tdp.MinServerVersion,
+			MaxVersion:   tdp.MaxServerVersion,
+			Capabilities: tdp.CapabilityClipboard,
+		}))
+
+		msg, err := clientConn.ReadMessage()
+		require.NoError(t, err)
+		hello, ok := msg.(tdp.ServerHello)
+		require.True(t, ok)
+		require.Equal(t, tdp.MaxServerVersion, hello.Version)
+		require.Equal(t, tdp.CapabilityClipboard, hello.Capabilities)
+		require.NoError(t, <-negotiateErr)
+	})
+
+	t.Run("mismatched version", func(t *testing.T) {
+		s, serverConn, clientConn := setup(t)
+		go func() {
+			clientConn.WriteMessage(tdp.ClientHello{
+				MinVersion: tdp.MaxServerVersion + 1,
+				MaxVersion: tdp.MaxServerVersion + 1,
+			})
+		}()
+		err := s.negotiateTDP(serverConn)
+		require.Error(t, err)
+		require.ErrorContains(t, err, "this service supports versions")
+	})
+
+	t.Run("legacy client skips the handshake", func(t *testing.T) {
+		s, serverConn, clientConn := setup(t)
+		go func() {
+			clientConn.WriteMessage(tdp.ClientUsername{Username: "alice"})
+		}()
+		require.NoError(t, s.negotiateTDP(serverConn))
+
+		// The username message remains unconsumed for the rest of the
+		// connection flow.
+		msg, err := serverConn.ReadMessage()
+		require.NoError(t, err)
+		require.Equal(t, tdp.ClientUsername{Username: "alice"}, msg)
+	})
+}
+
+func TestEmitsRecordingEventsOnSend(t *testing.T) {
+	clock := clockwork.NewFakeClock()
+	s := &WindowsService{
+		cfg: WindowsServiceConfig{
+			Clock: clock,
+		},
+	}
+	emitter := &eventstest.MockRecorderEmitter{}
+	emitterPreparer := libevents.WithNoOpPreparer(emitter)
+
+	// a fake PNG Frame message
+	encoded := []byte{byte(tdp.TypePNGFrame), 0x01, 0x02}
+
+	delay := func() int64 { return 0 }
+	handler := s.makeTDPSendHandler(context.Background(), emitterPreparer, delay, nil /* conn */, nil /* auditor */)
+
+	// the handler accepts both the message structure and its encoded form,
+	// but our logic only depends on the encoded form, so pass a nil message
+	handler(nil /* message */, encoded)
+
+	e := emitter.LastEvent()
+	require.NotNil(t, e)
+	dr, ok := e.(*events.DesktopRecording)
+	require.True(t, ok)
+	require.Equal(t, encoded, dr.Message)
+}
+
+func TestSkipsExtremelyLargePNGs(t *testing.T) {
+	clock := clockwork.NewFakeClock()
+	s := &WindowsService{
+		cfg: WindowsServiceConfig{
+			Clock: clock,
+			Log:   &logrus.Logger{Out: io.Discard},
+		},
+	}
+	emitter := &eventstest.MockRecorderEmitter{}
+	emitterPreparer := libevents.WithNoOpPreparer(emitter)
+
+	// a fake PNG Frame message, which is way too big to be legitimate
+	maliciousPNG := make([]byte, libevents.MaxProtoMessageSizeBytes+1)
+	rand.Read(maliciousPNG)
+	maliciousPNG[0] = byte(tdp.TypePNGFrame)
+
+	delay := func() int64 { return 0 }
+	handler := s.makeTDPSendHandler(context.Background(), emitterPreparer, delay, nil /* conn */, nil /* auditor */)
+
+	// the handler accepts both the message structure and its encoded form,
+	// but our logic only depends on the encoded form, so pass a nil message
+	var msg tdp.Message
+	handler(msg, maliciousPNG)
+
+	require.Nil(t, emitter.LastEvent())
+}
+
+func TestEmitsRecordingEventsOnReceive(t *testing.T) {
+	clock := clockwork.NewFakeClock()
+	s := &WindowsService{
+		cfg: WindowsServiceConfig{
+			Clock: clock,
+		},
+	}
+	emitter := &eventstest.MockRecorderEmitter{}
+	emitterPreparer := libevents.WithNoOpPreparer(emitter)
+
+	delay := func() int64 { return 0 }
+	handler := s.makeTDPReceiveHandler(context.Background(), emitterPreparer, delay, nil /* conn */, nil /* auditor */)
+
+	msg := tdp.MouseButton{
+		Button: tdp.LeftMouseButton,
+		State:  tdp.ButtonPressed,
+	}
+	handler(msg)
+
+	e := emitter.LastEvent()
+	require.NotNil(t, e)
+	dr, ok := e.(*events.DesktopRecording)
+	require.True(t, ok)
+	decoded, err := tdp.Decode(dr.Message)
+	require.NoError(t, err)
+	require.Equal(t, msg, decoded)
+}
+
+func TestEmitsClipboardSendEvents(t *testing.T) {
+	_, audit := setup(testDesktop)
+	emitter := &eventstest.MockRecorderEmitter{}
+	s := &WindowsService{
+		cfg: WindowsServiceConfig{
+			Clock:   audit.clock,
+			Emitter: emitter,
+		},
+	}
+
+	handler := s.makeTDPReceiveHandler(
+		context.Background(),
+		libevents.WithNoOpPreparer(&libevents.DiscardRecorder{}),
+		func() int64 { return 0 },
+		&tdp.Conn{},
+		audit,
+	)
+
+	fakeClipboardData := make([]byte, 1024)
+	rand.Read(fakeClipboardData)
+
+	start := s.cfg.Clock.Now().UTC()
+	msg := tdp.ClipboardData(fakeClipboardData)
+	handler(msg)
+
+	e := emitter.LastEvent()
+	require.NotNil(t, e)
+	cs, ok := e.(*events.DesktopClipboardSend)
+	require.True(t, ok)
+	require.Equal(t, int32(len(fakeClipboardData)), cs.Length)
+	require.Equal(t, audit.sessionID, cs.SessionID)
+	require.Equal(t, audit.desktop.GetAddr(), cs.DesktopAddr)
+	require.Equal(t, audit.clusterName, cs.ClusterName)
+	require.Equal(t, start, cs.Time)
+}
+
+func TestEmitsClipboardReceiveEvents(t *testing.T) {
+	_, audit := setup(testDesktop)
+	emitter := &eventstest.MockRecorderEmitter{}
+	s := &WindowsService{
+		cfg: WindowsServiceConfig{
+			Clock:   audit.clock,
+			Emitter: emitter,
+		},
+	}
+
+	handler := s.makeTDPSendHandler(
+		context.Background(),
+		libevents.WithNoOpPreparer(&libevents.DiscardRecorder{}),
+		func() int64 { return 0 },
+		&tdp.Conn{},
+		audit,
+	)
+
+	fakeClipboardData := make([]byte, 512)
+	rand.Read(fakeClipboardData)
+
+	start := s.cfg.Clock.Now().UTC()
+	msg := tdp.ClipboardData(fakeClipboardData)
+	encoded, err := msg.Encode()
+	require.NoError(t, err)
+	handler(msg, encoded)
+
+	e := emitter.LastEvent()
+	require.NotNil(t, e)
+	cs, ok := e.(*events.DesktopClipboardReceive)
+	require.True(t, ok, "expected DesktopClipboardReceive, got %T", e)
+	require.Equal(t, int32(len(fakeClipboardData)), cs.Length)
+	require.Equal(t, audit.sessionID, cs.SessionID)
+	require.Equal(t, audit.desktop.GetAddr(), cs.DesktopAddr)
 	require.Equal(t, audit.clusterName, cs.ClusterName)
 	require.Equal(t, start, cs.Time)
 }
+
+func TestStripProxyProtocolHeader(t *testing.T) {
+	v2Header, err := (&multiplexer.ProxyLine{
+		Protocol:    multiplexer.TCP4,
+		Source:      net.TCPAddr{IP: net.ParseIP("10.1.2.3"), Port: 12345},
+		Destination: net.TCPAddr{IP: net.ParseIP("10.4.5.6"), Port: 3028},
+	}).Bytes()
+	require.NoError(t, err)
+
+	for _, test := range []struct {
+		name       string
+		header     []byte
+		remoteAddr string
+	}{
+		{
+			name:       "proxy protocol v1",
+			header:     []byte("PROXY TCP4 10.1.2.3 10.4.5.6 12345 3028\r\n"),
+			remoteAddr: "10.1.2.3:12345",
+		},
+		{
+			name:       "proxy protocol v2",
+			header:     v2Header,
+			remoteAddr: "10.1.2.3:12345",
+		},
+		{
+			name:       "no proxy protocol header",
+			header:     nil,
+			remoteAddr: "",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			server, client := net.Pipe()
+			t.Cleanup(func() {
+				server.Close()
+				client.Close()
+			})
+
+			payload := []byte("desktop connection bytes")
+			go func() {
+				client.Write(append(test.header, payload...))
+			}()
+
+			conn, err := stripProxyProtocolHeader(server)
+			require.NoError(t, err)
+
+			wantAddr := test.remoteAddr
+			if wantAddr == "" {
+				// Without a PROXY header the original address is preserved.
+				wantAddr = server.RemoteAddr().String()
+			}
+			require.Equal(t, wantAddr, conn.RemoteAddr().String())
+
+			// The header is consumed and the payload remains intact.
+			buf := make([]byte, len(payload))
+			_, err = io.ReadFull(conn, buf)
+			require.NoError(t, err)
+			require.Equal(t, payload, buf)
+		})
+	}
+}
+
+// crlTestClient serves a canned CRL entry for any search.
+type crlTestClient struct {
+	ldap.Client
+
+	entries []*ldap.Entry
+}
+
+func (c *crlTestClient) SearchWithPaging(req *ldap.SearchRequest, pageSize uint32) (*ldap.SearchResult, error) {
+	return &ldap.SearchResult{Entries: c.entries}, nil
+}
+
+func (c *crlTestClient) Close() error { return nil }
+
+// TestVerifyCertChain verifies that a freshly generated desktop certificate
+// validates against the user CA and the CRL published for the cluster.
+func TestVerifyCertChain(t *testing.T) {
+	const (
+		clusterName = "test"
+		user        = "test-user"
+		domain      = "test.example.com"
+	)
+
+	authServer, err := auth.NewTestAuthServer(auth.TestAuthServerConfig{
+		ClusterName: clusterName,
+		Dir:         t.TempDir(),
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, authServer.Close())
+	})
+
+	tlsServer, err := authServer.NewTestTLSServer()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, tlsServer.Close())
+	})
+
+	client, err := tlsServer.NewClient(auth.TestServerID(types.RoleWindowsDesktop, "test-host-id"))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, client.Close())
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	crlDER, err := client.GenerateCertAuthorityCRL(ctx, types.UserCA)
+	require.NoError(t, err)
+
+	crlEntryDN := "CN=test,CN=Teleport,CN=CDP,CN=Public Key Services,CN=Services,CN=Configuration,DC=test,DC=example,DC=com"
+	lc := &windows.LDAPClient{}
+	lc.SetClient(&crlTestClient{entries: []*ldap.Entry{
+		ldap.NewEntry(crlEntryDN, map[string][]string{
+			"certificateRevocationList": {string(crlDER)},
+		}),
+	}})
+
+	w := &WindowsService{
+		clusterName: clusterName,
+		cfg: WindowsServiceConfig{
+			LDAPConfig: windows.LDAPConfig{
+				Domain: domain,
+			},
+			AuthClient:  client,
+			AccessPoint: client,
+		},
+		lc: lc,
+	}
+
+	report, err := w.VerifyCertChain(ctx, user)
+	require.NoError(t, err)
+	require.Equal(t, user, report.CertSubject)
+	require.True(t, report.ChainVerified)
+	require.True(t, report.CRLSignatureVerified)
+	require.False(t, report.Revoked)
+}
+
+func TestBuildCertChainReport(t *testing.T) {
+	newCA := func(t *testing.T, cn string) (*x509.Certificate, *ecdsa.PrivateKey) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		template := &x509.Certificate{
+			SerialNumber:          big.NewInt(1),
+			Subject:               pkix.Name{CommonName: cn},
+			NotBefore:             time.Now().Add(-time.Hour),
+			NotAfter:              time.Now().Add(time.Hour),
+			IsCA:                  true,
+			KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+			BasicConstraintsValid: true,
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+		require.NoError(t, err)
+		cert, err := x509.ParseCertificate(der)
+		require.NoError(t, err)
+		return cert, key
+	}
+
+	caCert, caKey := newCA(t, "test-ca")
+	otherCACert, _ := newCA(t, "other-ca")
+
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	leafDER, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(42),
+		Subject:      pkix.Name{CommonName: "test-user"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}, caCert, &leafKey.PublicKey, caKey)
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(leafDER)
+	require.NoError(t, err)
+
+	newCRL := func(t *testing.T, revokedSerials ...int64) *x509.RevocationList {
+		var revoked []x509.RevocationListEntry
+		for _, serial := range revokedSerials {
+			revoked = append(revoked, x509.RevocationListEntry{
+				SerialNumber:   big.NewInt(serial),
+				RevocationTime: time.Now(),
+			})
+		}
+		der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+			Number:                    big.NewInt(1),
+			ThisUpdate:                time.Now().Add(-time.Hour),
+			NextUpdate:                time.Now().Add(time.Hour),
+			RevokedCertificateEntries: revoked,
+		}, caCert, caKey)
+		require.NoError(t, err)
+		crl, err := x509.ParseRevocationList(der)
+		require.NoError(t, err)
+		return crl
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(caCert)
+	otherPool := x509.NewCertPool()
+	otherPool.AddCert(otherCACert)
+
+	t.Run("valid chain", func(t *testing.T) {
+		report := buildCertChainReport(leaf, pool, []*x509.Certificate{caCert}, newCRL(t))
+		require.Equal(t, "test-user", report.CertSubject)
+		require.True(t, report.ChainVerified)
+		require.True(t, report.CRLSignatureVerified)
+		require.False(t, report.Revoked)
+	})
+
+	t.Run("revoked cert", func(t *testing.T) {
+		report := buildCertChainReport(leaf, pool, []*x509.Certificate{caCert}, newCRL(t, 42))
+		require.True(t, report.ChainVerified)
+		require.True(t, report.Revoked)
+	})
+
+	t.Run("unknown CA", func(t *testing.T) {
+		report := buildCertChainReport(leaf, otherPool, []*x509.Certificate{otherCACert}, newCRL(t))
+		require.False(t, report.ChainVerified)
+		require.False(t, report.CRLSignatureVerified)
+	})
+}
+
+// TestRoleMaxSessionTTLShortensCertTTL verifies that a role with a short
+// max_session_ttl shortens the TTL of the certificates issued for desktop
+// sessions.
+func TestRoleMaxSessionTTLShortensCertTTL(t *testing.T) {
+	const (
+		clusterName = "test"
+		user        = "test-user"
+		domain      = "test.example.com"
+	)
+
+	authServer, err := auth.NewTestAuthServer(auth.TestAuthServerConfig{
+		ClusterName: clusterName,
+		Dir:         t.TempDir(),
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, authServer.Close())
+	})
+
+	tlsServer, err := authServer.NewTestTLSServer()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, tlsServer.Close())
+	})
+
+	client, err := tlsServer.NewClient(auth.TestServerID(types.RoleWindowsDesktop, "test-host-id"))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, client.Close())
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	role, err := types.NewRole("short-sessions", types.RoleSpecV6{
+		Options: types.RoleOptions{
+			MaxSessionTTL: types.NewDuration(time.Minute),
+		},
+	})
+	require.NoError(t, err)
+
+	// The role-imposed maximum takes precedence over the service default.
+	certTTL := services.NewRoleSet(role).AdjustSessionTTL(windows.CertTTL)
+	require.Equal(t, time.Minute, certTTL)
+
+	w := &WindowsService{
+		clusterName: clusterName,
+		cfg: WindowsServiceConfig{
+			LDAPConfig: windows.LDAPConfig{
+				Domain: domain,
+			},
+			AuthClient: client,
+		},
+	}
+
+	certb, _, err := w.generateCredentials(ctx, generateCredentialsRequest{
+		username: user,
+		domain:   domain,
+		ttl:      certTTL,
+	})
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(certb)
+	require.NoError(t, err)
+	require.WithinDuration(t, time.Now().Add(certTTL), cert.NotAfter, 30*time.Second)
+}
+
+// TestTLSConfigForLDAPBindDN verifies that an explicitly configured BindDN is
+// used as the authentication identity for the LDAP session instead of the
+// friendly username.
+func TestTLSConfigForLDAPBindDN(t *testing.T) {
+	const (
+		clusterName = "test"
+		domain      = "test.example.com"
+	)
+
+	authServer, err := auth.NewTestAuthServer(auth.TestAuthServerConfig{
+		ClusterName: clusterName,
+		Dir:         t.TempDir(),
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, authServer.Close())
+	})
+
+	tlsServer, err := authServer.NewTestTLSServer()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, tlsServer.Close())
+	})
+
+	client, err := tlsServer.NewClient(auth.TestServerID(types.RoleWindowsDesktop, "test-host-id"))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, client.Close())
+	})
+
+	for _, test := range []struct {
+		name     string
+		username string
+		bindDN   string
+		wantCN   string
+	}{
+		{
+			name:     "username with NETBIOS prefix",
+			username: `EXAMPLE\svc-teleport`,
+			wantCN:   "svc-teleport",
+		},
+		{
+			name:     "explicit bind DN",
+			username: `EXAMPLE\svc-teleport`,
+			bindDN:   "CN=svc-teleport,OU=Service Accounts,DC=test,DC=example,DC=com",
+			wantCN:   "CN=svc-teleport,OU=Service Accounts,DC=test,DC=example,DC=com",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			w := &WindowsService{
+				clusterName: clusterName,
+				closeCtx:    context.Background(),
+				cfg: WindowsServiceConfig{
+					Log: logrus.New(),
+					LDAPConfig: windows.LDAPConfig{
+						Domain:   domain,
+						Username: test.username,
+						BindDN:   test.bindDN,
+						SID:      "S-1-5-21-1329593140-2634913955-1900852804-500",
+					},
+					AuthClient: client,
+				},
+			}
+
+			tc, err := w.tlsConfigForLDAP()
+			require.NoError(t, err)
+			require.Len(t, tc.Certificates, 1)
+
+			cert, err := x509.ParseCertificate(tc.Certificates[0].Certificate[0])
+			require.NoError(t, err)
+			require.Equal(t, test.wantCN, cert.Subject.CommonName)
+		})
+	}
+}
+
+// TestCertSkewAllowance verifies that a configured skew allowance backdates
+// the NotBefore time of issued smartcard certificates, and that excessive
+// allowances are rejected.
+func TestCertSkewAllowance(t *testing.T) {
+	const (
+		clusterName = "test"
+		user        = "test-user"
+		domain      = "test.example.com"
+	)
+
+	authServer, err := auth.NewTestAuthServer(auth.TestAuthServerConfig{
+		ClusterName: clusterName,
+		Dir:         t.TempDir(),
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, authServer.Close())
+	})
+
+	tlsServer, err := authServer.NewTestTLSServer()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, tlsServer.Close())
+	})
+
+	client, err := tlsServer.NewClient(auth.TestServerID(types.RoleWindowsDesktop, "test-host-id"))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, client.Close())
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	newService := func(skew time.Duration) *WindowsService {
+		return &WindowsService{
+			clusterName: clusterName,
+			cfg: WindowsServiceConfig{
+				CertSkewAllowance: skew,
+				LDAPConfig: windows.LDAPConfig{
+					Domain: domain,
+				},
+				AuthClient: client,
+			},
+		}
+	}
+
+	generate := func(w *WindowsService) (*x509.Certificate, error) {
+		certb, _, err := w.generateCredentials(ctx, generateCredentialsRequest{
+			username: user,
+			domain:   domain,
+			ttl:      windows.CertTTL,
+		})
+		if err != nil {
+			return nil, err
+		}
+		cert, err := x509.ParseCertificate(certb)
+		require.NoError(t, err)
+		return cert, nil
+	}
+
+	t.Run("backdates the certificate", func(t *testing.T) {
+		skew := 5 * time.Minute
+		cert, err := generate(newService(skew))
+		require.NoError(t, err)
+		require.WithinDuration(t, time.Now().Add(-skew), cert.NotBefore, 30*time.Second)
+	})
+
+	t.Run("default backdating when unset", func(t *testing.T) {
+		cert, err := generate(newService(0))
+		require.NoError(t, err)
+		require.WithinDuration(t, time.Now().Add(-time.Minute), cert.NotBefore, 30*time.Second)
+	})
+
+	t.Run("rejects excessive skew", func(t *testing.T) {
+		_, err := generate(newService(time.Hour))
+		require.ErrorContains(t, err, "exceeds the maximum")
+	})
+
+	t.Run("rejects negative skew at config time", func(t *testing.T) {
+		cfg := &WindowsServiceConfig{CertSkewAllowance: -time.Second}
+		require.ErrorContains(t, cfg.CheckAndSetDefaults(), "negative certificate skew allowance")
+	})
+}
+
+// TestCertValidityModeValidation verifies that unknown certificate validity
+// modes are rejected at config time.
+func TestCertValidityModeValidation(t *testing.T) {
+	cfg := &WindowsServiceConfig{CertValidityMode: "aligned-hour"}
+	require.ErrorContains(t, cfg.CheckAndSetDefaults(), "unsupported certificate validity mode")
+}
+
+// TestCRLConfig verifies that the CRL validity and refresh interval are
+// kept consistent with each other.
+func TestCRLConfig(t *testing.T) {
+	t.Run("refresh interval defaults to half the validity", func(t *testing.T) {
+		cfg := &WindowsServiceConfig{
+			Log:         logrus.New(),
+			CRLValidity: 48 * time.Hour,
+		}
+		require.NoError(t, cfg.checkAndSetCRLDefaults())
+		require.Equal(t, 24*time.Hour, cfg.CRLRefreshInterval)
+	})
+
+	t.Run("explicit refresh interval is kept", func(t *testing.T) {
+		cfg := &WindowsServiceConfig{
+			Log:                logrus.New(),
+			CRLValidity:        48 * time.Hour,
+			CRLRefreshInterval: time.Hour,
+		}
+		require.NoError(t, cfg.checkAndSetCRLDefaults())
+		require.Equal(t, time.Hour, cfg.CRLRefreshInterval)
+	})
+
+	t.Run("rejects negative validity", func(t *testing.T) {
+		cfg := &WindowsServiceConfig{CRLValidity: -time.Hour}
+		require.ErrorContains(t, cfg.CheckAndSetDefaults(), "negative CRL validity")
+	})
+
+	t.Run("rejects negative refresh interval", func(t *testing.T) {
+		cfg := &WindowsServiceConfig{CRLRefreshInterval: -time.Hour}
+		require.ErrorContains(t, cfg.CheckAndSetDefaults(), "negative CRL refresh interval")
+	})
+
+	t.Run("rejects extra CDP without a scheme", func(t *testing.T) {
+		cfg := &WindowsServiceConfig{ExtraCDPs: []string{"crl.example.com/example.crl"}}
+		require.ErrorContains(t, cfg.CheckAndSetDefaults(), "without a scheme")
+	})
+
+	t.Run("warns when refresh interval exceeds validity", func(t *testing.T) {
+		logger, hook := logtest.NewNullLogger()
+		cfg := &WindowsServiceConfig{
+			Log:                logger,
+			CRLValidity:        time.Hour,
+			CRLRefreshInterval: 2 * time.Hour,
+		}
+		require.NoError(t, cfg.checkAndSetCRLDefaults())
+		require.NotEmpty(t, hook.AllEntries())
+		require.Contains(t, hook.LastEntry().Message, "exceeds the CRL validity")
+	})
+}
+
+// flakyAccessPoint returns not-found for a number of desktop lookups before
+// succeeding, simulating a cache that hasn't yet seen a freshly registered
+// desktop.
+type flakyAccessPoint struct {
+	auth.WindowsDesktopAccessPoint
+
+	desktop  types.WindowsDesktop
+	failures int
+	calls    int
+}
+
+func (f *flakyAccessPoint) GetWindowsDesktops(ctx context.Context, filter types.WindowsDesktopFilter) ([]types.WindowsDesktop, error) {
+	f.calls++
+	if f.calls <= f.failures {
+		return nil, trace.NotFound("desktop not found")
+	}
+	return []types.WindowsDesktop{f.desktop}, nil
+}
+
+// TestFindDesktopRetriesNotFound verifies that desktop lookups are retried
+// when the desktop is briefly absent from the cache, and that the service
+// gives up after the configured number of retries.
+func TestFindDesktopRetriesNotFound(t *testing.T) {
+	desktop, err := types.NewWindowsDesktopV3("test-desktop", nil,
+		types.WindowsDesktopSpecV3{Addr: "host.example.com:3389"})
+	require.NoError(t, err)
+
+	for _, test := range []struct {
+		name      string
+		retries   int
+		failures  int
+		wantErr   bool
+		wantCalls int
+	}{
+		{name: "found on first attempt", retries: 1, failures: 0, wantCalls: 1},
+		{name: "found after one retry", retries: 1, failures: 1, wantCalls: 2},
+		{name: "gives up after configured retries", retries: 1, failures: 3, wantErr: true, wantCalls: 2},
+		{name: "extra retries smooth longer churn", retries: 3, failures: 3, wantCalls: 4},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			ap := &flakyAccessPoint{desktop: desktop, failures: test.failures}
+			s := &WindowsService{
+				cfg: WindowsServiceConfig{
+					AccessPoint:          ap,
+					Clock:                clockwork.NewRealClock(),
+					DesktopLookupRetries: test.retries,
+				},
+			}
+
+			got, err := s.findDesktop(context.Background(), "test-desktop")
+			if test.wantErr {
+				require.Error(t, err)
+				require.True(t, trace.IsNotFound(err))
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, desktop.GetName(), got.GetName())
+			}
+			require.Equal(t, test.wantCalls, ap.calls)
+		})
+	}
+}
+
+// TestServeBoundsConcurrentConnections verifies that Serve spawns no more
+// than MaxConcurrentConnections handler goroutines: connections in a burst
+// beyond the cap are closed immediately rather than queued.
+func TestServeBoundsConcurrentConnections(t *testing.T) {
+	connLimiter, err := limiter.NewConnectionsLimiter(limiter.Config{})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	s := &WindowsService{
+		cfg: WindowsServiceConfig{
+			Log:                      logrus.New(),
+			Clock:                    clockwork.NewRealClock(),
+			TLS:                      &tls.Config{},
+			ConnLimiter:              connLimiter,
+			MaxConcurrentConnections: 2,
+		},
+		middleware: &auth.Middleware{},
+		closeCtx:   ctx,
+		close:      cancel,
+	}
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { lis.Close() })
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Serve(lis)
+	}()
+
+	// Open a burst of connections that never speak TLS. The first two hold
+	// their handler goroutines in the TLS handshake, so the remaining three
+	// must be closed immediately by the accept loop.
+	const burst = 5
+	conns := make([]net.Conn, 0, burst)
+	for i := 0; i < burst; i++ {
+		conn, err := net.Dial("tcp", lis.Addr().String())
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+		conns = append(conns, conn)
+	}
+
+	closed := 0
+	for _, conn := range conns {
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+		if _, err := conn.Read(make([]byte, 1)); errors.Is(err, io.EOF) {
+			closed++
+		}
+	}
+	require.Equal(t, burst-s.cfg.MaxConcurrentConnections, closed,
+		"expected connections over the cap to be closed immediately")
+
+	lis.Close()
+	<-done
+}
+
+// modifyLDAPClient accepts modify requests, optionally failing them.
+type modifyLDAPClient struct {
+	ldap.Client
+
+	err error
+}
+
+func (c *modifyLDAPClient) Modify(req *ldap.ModifyRequest) error { return c.err }
+
+func (c *modifyLDAPClient) Close() error { return nil }
+
+// TestLDAPWriteAuditEvents verifies that writes the service makes against
+// the LDAP server, such as CRL updates, are reported to the audit log with
+// the operation, target DN and result - and without any attribute values.
+func TestLDAPWriteAuditEvents(t *testing.T) {
+	const crlDN = "CN=test-cluster,CN=Teleport,CN=CDP,CN=Public Key Services,CN=Services,CN=Configuration,DC=example,DC=com"
+
+	for _, test := range []struct {
+		name      string
+		ldapErr   error
+		checkData func(t *testing.T, data string)
+	}{
+		{
+			name: "successful CRL update",
+			checkData: func(t *testing.T, data string) {
+				require.JSONEq(t, `{"op":"update","dn":"`+crlDN+`","success":true}`, data)
+			},
+		},
+		{
+			name:    "failed CRL update",
+			ldapErr: ldap.NewError(ldap.LDAPResultInsufficientAccessRights, errors.New("insufficient access")),
+			checkData: func(t *testing.T, data string) {
+				require.Contains(t, data, `"success":false`)
+				require.Contains(t, data, crlDN)
+				require.Contains(t, data, "insufficient permissions")
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			emitter := &eventstest.MockRecorderEmitter{}
+			s := &WindowsService{
+				cfg: WindowsServiceConfig{
+					Emitter: emitter,
+					Log:     logrus.New(),
+					Clock:   clockwork.NewFakeClock(),
+				},
+				clusterName: "test-cluster",
+				closeCtx:    context.Background(),
+				lc:          &windows.LDAPClient{},
+			}
+			s.lc.OnWrite = s.onLDAPWrite
+			s.lc.SetClient(&modifyLDAPClient{err: test.ldapErr})
+
+			err := s.lc.Update(crlDN, map[string][]string{
+				"certificateRevocationList": {"secret-crl-bytes"},
+			})
+			if test.ldapErr != nil {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			event, ok := emitter.LastEvent().(*events.Unknown)
+			require.True(t, ok, "expected an Unknown event, got %T", emitter.LastEvent())
+			require.Equal(t, libevents.UnknownEvent, event.Metadata.Type)
+			require.Equal(t, "test-cluster", event.Metadata.ClusterName)
+			require.Equal(t, ldapWriteEventType, event.UnknownType)
+			require.NotContains(t, event.Data, "secret-crl-bytes")
+			test.checkData(t, event.Data)
+		})
+	}
+}
+
+// TestConnectionSpans verifies that the stages of a desktop connection are
+// reported as spans of a single trace when a tracer provider is configured.
+func TestConnectionSpans(t *testing.T) {
+	const (
+		clusterName = "test"
+		user        = "span-user"
+		domain      = "test.example.com"
+	)
+
+	authServer, err := auth.NewTestAuthServer(auth.TestAuthServerConfig{
+		ClusterName: clusterName,
+		Dir:         t.TempDir(),
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, authServer.Close())
+	})
+
+	tlsServer, err := authServer.NewTestTLSServer()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, tlsServer.Close())
+	})
+
+	client, err := tlsServer.NewClient(auth.TestServerID(types.RoleWindowsDesktop, "test-host-id"))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, client.Close())
+	})
+
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	s := &WindowsService{
+		clusterName: clusterName,
+		tracer:      tp.Tracer(teleport.ComponentWindowsDesktop),
+		cfg: WindowsServiceConfig{
+			LDAPConfig: windows.LDAPConfig{
+				Domain: domain,
+			},
+			AuthClient: client,
+		},
+	}
+
+	// The connection root span, as started by handleConnection.
+	ctx, root := s.startSpan(context.Background(), "windowsDesktop/handleConnection")
+	_, _, err = s.generateCredentials(ctx, generateCredentialsRequest{
+		username: user,
+		domain:   domain,
+		ttl:      time.Minute,
+	})
+	require.NoError(t, err)
+	root.End()
+
+	// The credential generation span belongs to the connection's trace.
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	require.Equal(t, "windowsDesktop/generateCredentials", spans[0].Name())
+	require.Equal(t, "windowsDesktop/handleConnection", spans[1].Name())
+	require.Equal(t, spans[1].SpanContext().SpanID(), spans[0].Parent().SpanID())
+	require.Equal(t, spans[1].SpanContext().TraceID(), spans[0].SpanContext().TraceID())
+}
+
+// TestStartSpanWithoutTracer verifies that a service constructed without a
+// tracer, as tests commonly do, produces no-op spans instead of panicking.
+func TestStartSpanWithoutTracer(t *testing.T) {
+	s := &WindowsService{}
+	_, span := s.startSpan(context.Background(), "windowsDesktop/handleConnection")
+	require.False(t, span.IsRecording())
+	span.End()
+}
+
+// bindRecordingLDAPClient reports a configurable TLS connection state and
+// records whether a bind was attempted.
+type bindRecordingLDAPClient struct {
+	ldap.Client
+
+	state      tls.ConnectionState
+	tlsActive  bool
+	bindCalled bool
+}
+
+func (c *bindRecordingLDAPClient) TLSConnectionState() (tls.ConnectionState, bool) {
+	return c.state, c.tlsActive
+}
+
+func (c *bindRecordingLDAPClient) Bind(username, password string) error {
+	c.bindCalled = true
+	return nil
+}
+
+// TestBindRefusedWithoutTLS verifies that a simple bind is refused when the
+// TLS layer was stripped or did not complete, so that the service account
+// password never crosses an unprotected connection.
+func TestBindRefusedWithoutTLS(t *testing.T) {
+	s := &WindowsService{
+		cfg: WindowsServiceConfig{
+			Log: logrus.New(),
+			LDAPConfig: windows.LDAPConfig{
+				Addr:     "ldap.example.com:636",
+				Domain:   "test.example.com",
+				Username: `EXAMPLE\svc-teleport`,
+				Password: "hunter2",
+			},
+		},
+	}
+
+	for _, test := range []struct {
+		name    string
+		conn    *bindRecordingLDAPClient
+		wantErr string
+	}{
+		{
+			name:    "TLS stripped",
+			conn:    &bindRecordingLDAPClient{tlsActive: false},
+			wantErr: "not protected by TLS",
+		},
+		{
+			name: "handshake not complete",
+			conn: &bindRecordingLDAPClient{
+				tlsActive: true,
+				state:     tls.ConnectionState{HandshakeComplete: false},
+			},
+			wantErr: "handshake",
+		},
+		{
+			name: "legacy protocol",
+			conn: &bindRecordingLDAPClient{
+				tlsActive: true,
+				state:     tls.ConnectionState{HandshakeComplete: true, Version: tls.VersionTLS10},
+			},
+			wantErr: "TLS 1.2",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			err := s.bindLDAP(test.conn)
+			require.True(t, trace.IsAccessDenied(err))
+			require.ErrorContains(t, err, test.wantErr)
+			require.False(t, test.conn.bindCalled)
+		})
+	}
+
+	t.Run("TLS active", func(t *testing.T) {
+		conn := &bindRecordingLDAPClient{
+			tlsActive: true,
+			state:     tls.ConnectionState{HandshakeComplete: true, Version: tls.VersionTLS13},
+		}
+		require.NoError(t, s.bindLDAP(conn))
+		require.True(t, conn.bindCalled)
+	})
+}
+
+// tempAcceptError is a transient accept failure, as reported by listeners
+// that hit resource limits.
+type tempAcceptError struct{}
+
+func (tempAcceptError) Error() string   { return "accept: too many open files" }
+func (tempAcceptError) Temporary() bool { return true }
+func (tempAcceptError) Timeout() bool   { return false }
+
+// temporaryAcceptListener fails the first few Accept calls with a temporary
+// error and records when each Accept call returns.
+type temporaryAcceptListener struct {
+	net.Listener
+
+	mu          sync.Mutex
+	tempErrs    int
+	acceptTimes []time.Time
+}
+
+func (l *temporaryAcceptListener) Accept() (net.Conn, error) {
+	l.mu.Lock()
+	fail := l.tempErrs > 0
+	if fail {
+		l.tempErrs--
+	}
+	l.mu.Unlock()
+
+	var conn net.Conn
+	var err error
+	if fail {
+		err = tempAcceptError{}
+	} else {
+		conn, err = l.Listener.Accept()
+	}
+
+	l.mu.Lock()
+	l.acceptTimes = append(l.acceptTimes, time.Now())
+	l.mu.Unlock()
+	return conn, err
+}
+
+func (l *temporaryAcceptListener) times() []time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]time.Time(nil), l.acceptTimes...)
+}
+
+// TestServeBacksOffOnTemporaryAcceptErrors verifies that temporary accept
+// errors don't terminate Serve and are retried after a short backoff
+// instead of in a tight loop.
+func TestServeBacksOffOnTemporaryAcceptErrors(t *testing.T) {
+	connLimiter, err := limiter.NewConnectionsLimiter(limiter.Config{})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	s := &WindowsService{
+		cfg: WindowsServiceConfig{
+			Log:         logrus.New(),
+			Clock:       clockwork.NewRealClock(),
+			TLS:         &tls.Config{},
+			ConnLimiter: connLimiter,
+		},
+		middleware: &auth.Middleware{},
+		closeCtx:   ctx,
+		close:      cancel,
+	}
+
+	inner, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { inner.Close() })
+	lis := &temporaryAcceptListener{Listener: inner, tempErrs: 2}
+
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(lis) }()
+
+	// The listener stays usable across the temporary errors.
+	conn, err := net.Dial("tcp", inner.Addr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	// Serve retries until it reaches the connection queued above.
+	require.Eventually(t, func() bool {
+		return len(lis.times()) >= 3
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// The retries were spaced out by the backoff rather than spinning.
+	times := lis.times()
+	require.GreaterOrEqual(t, times[2].Sub(times[0]), acceptBackoffInterval)
+
+	select {
+	case err := <-done:
+		t.Fatalf("Serve terminated unexpectedly: %v", err)
+	default:
+	}
+
+	inner.Close()
+	require.NoError(t, <-done)
+}
+
+// TestTimer verifies that recording delays are measured with the configured
+// clock, so that advancing a fake clock drives the reported elapsed time.
+func TestTimer(t *testing.T) {
+	clock := clockwork.NewFakeClock()
+	delay := timer(clock)
+
+	// The first call starts the timer.
+	require.Zero(t, delay())
+
+	clock.Advance(1500 * time.Millisecond)
+	require.Equal(t, int64(1500), delay())
+
+	clock.Advance(250 * time.Millisecond)
+	require.Equal(t, int64(1750), delay())
+}
+
+// TestLDAPAddrs verifies the order in which LDAP servers are tried when a
+// domain controller locator is configured.
+func TestLDAPAddrs(t *testing.T) {
+	newService := func(locator *windows.DomainControllerLocator) *WindowsService {
+		return &WindowsService{
+			cfg: WindowsServiceConfig{
+				Log:   logrus.New(),
+				Clock: clockwork.NewFakeClock(),
+				LDAPConfig: windows.LDAPConfig{
+					Addr:   "configured.example.com:636",
+					Domain: "example.com",
+				},
+				LDAPLocator: locator,
+			},
+			closeCtx: context.Background(),
+		}
+	}
+
+	t.Run("no locator uses the configured address", func(t *testing.T) {
+		addrs, err := newService(nil).ldapAddrs()
+		require.NoError(t, err)
+		require.Equal(t, []string{"configured.example.com:636"}, addrs)
+	})
+
+	t.Run("discovered controllers come first, configured address last", func(t *testing.T) {
+		s := newService(&windows.DomainControllerLocator{
+			LookupSRV: func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+				return "", []*net.SRV{
+					{Target: "dc1.example.com.", Port: 636, Priority: 0},
+					{Target: "dc2.example.com.", Port: 636, Priority: 10},
+				}, nil
+			},
+		})
+
+		addrs, err := s.ldapAddrs()
+		require.NoError(t, err)
+		require.Equal(t, []string{
+			"dc1.example.com:636",
+			"dc2.example.com:636",
+			"configured.example.com:636",
+		}, addrs)
+	})
+
+	t.Run("discovery failure falls back to the configured address", func(t *testing.T) {
+		s := newService(&windows.DomainControllerLocator{
+			LookupSRV: func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+				return "", nil, trace.ConnectionProblem(nil, "DNS unreachable")
+			},
+		})
+
+		addrs, err := s.ldapAddrs()
+		require.NoError(t, err)
+		require.Equal(t, []string{"configured.example.com:636"}, addrs)
+	})
+}
+
+// TestHealthLDAPDetails verifies that each LDAP failure mode surfaces a
+// specific problem description and remediation hint in the health report.
+func TestHealthLDAPDetails(t *testing.T) {
+	for _, test := range []struct {
+		name            string
+		err             error
+		wantDetail      string
+		wantRemediation string
+	}{
+		{
+			name: "healthy",
+			err:  nil,
+		},
+		{
+			name:            "unreachable",
+			err:             trace.ConnectionProblem(nil, "network error"),
+			wantDetail:      "unreachable",
+			wantRemediation: "domain controller is up",
+		},
+		{
+			name:            "timeout",
+			err:             trace.Wrap(context.DeadlineExceeded, "dial"),
+			wantDetail:      "did not respond in time",
+			wantRemediation: "firewall",
+		},
+		{
+			name:            "bad credentials",
+			err:             trace.AccessDenied("invalid credentials: bind failed"),
+			wantDetail:      "rejected Teleport's credentials",
+			wantRemediation: "NTAuth store",
+		},
+		{
+			name:            "insufficient rights",
+			err:             trace.AccessDenied("insufficient permissions: denied"),
+			wantDetail:      "rejected Teleport's credentials",
+			wantRemediation: "sufficient rights",
+		},
+		{
+			name:            "missing CDP container",
+			err:             trace.NotFound("CDP container not found"),
+			wantDetail:      "missing",
+			wantRemediation: "CDP container",
+		},
+		{
+			name:            "unclassified",
+			err:             trace.Errorf("something broke"),
+			wantDetail:      "something broke",
+			wantRemediation: "service logs",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			s := &WindowsService{
+				cfg: WindowsServiceConfig{
+					Log:   logrus.New(),
+					Clock: clockwork.NewFakeClock(),
+				},
+			}
+			s.mu.Lock()
+			s.ldapConfigured = true
+			s.ldapInitialized = test.err == nil
+			s.ldapLastError = test.err
+			s.mu.Unlock()
+
+			health := s.Health().LDAP
+			require.True(t, health.Configured)
+			require.Equal(t, test.err == nil, health.Connected)
+			if test.err == nil {
+				require.Empty(t, health.Detail)
+				require.Empty(t, health.Remediation)
+				return
+			}
+			require.Contains(t, health.Detail, test.wantDetail)
+			require.Contains(t, health.Remediation, test.wantRemediation)
+		})
+	}
+}
+
+// denyAuthorizer rejects every connection, terminating handleConnection
+// right after authentication in tests that only exercise routing.
+type denyAuthorizer struct{}
+
+func (denyAuthorizer) Authorize(ctx context.Context) (*authz.Context, error) {
+	return nil, trace.AccessDenied("access denied")
+}
+
+// TestServeALPNRouting verifies that when the listener is shared via ALPN
+// routing, connections negotiating the desktop protocol (or none at all)
+// reach handleConnection while connections for other protocols are ignored.
+func TestServeALPNRouting(t *testing.T) {
+	creds, err := cert.GenerateSelfSignedCert([]string{"localhost"}, nil)
+	require.NoError(t, err)
+	tlsCert, err := tls.X509KeyPair(creds.Cert, creds.PrivateKey)
+	require.NoError(t, err)
+
+	connLimiter, err := limiter.NewConnectionsLimiter(limiter.Config{})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	s := &WindowsService{
+		cfg: WindowsServiceConfig{
+			Log:         logrus.New(),
+			Clock:       clockwork.NewRealClock(),
+			TLS:         &tls.Config{Certificates: []tls.Certificate{tlsCert}},
+			ConnLimiter: connLimiter,
+			Authorizer:  denyAuthorizer{},
+		},
+		middleware: &auth.Middleware{},
+		closeCtx:   ctx,
+		close:      cancel,
+	}
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { lis.Close() })
+	go s.Serve(lis)
+
+	dial := func(protos []string) (*tls.Conn, error) {
+		return tls.Dial("tcp", lis.Addr().String(), &tls.Config{
+			InsecureSkipVerify: true,
+			NextProtos:         protos,
+		})
+	}
+
+	// handledByService reports whether the service answered on conn: an
+	// unauthenticated connection that reaches handleConnection receives a
+	// TDP error notification before being closed.
+	handledByService := func(conn *tls.Conn) bool {
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+		buf := make([]byte, 1)
+		n, err := conn.Read(buf)
+		return err == nil && n > 0
+	}
+
+	t.Run("desktop protocol is handled", func(t *testing.T) {
+		conn, err := dial([]string{desktopALPNProtocol})
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+		require.Equal(t, desktopALPNProtocol, conn.ConnectionState().NegotiatedProtocol)
+		require.True(t, handledByService(conn))
+	})
+
+	t.Run("no ALPN preserves dedicated listener behavior", func(t *testing.T) {
+		conn, err := dial(nil)
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+		require.Empty(t, conn.ConnectionState().NegotiatedProtocol)
+		require.True(t, handledByService(conn))
+	})
+
+	t.Run("other protocols are ignored", func(t *testing.T) {
+		conn, err := dial([]string{"teleport-reversetunnel"})
+		if err != nil {
+			// The handshake is rejected outright when no common
+			// protocol can be negotiated.
+			return
+		}
+		t.Cleanup(func() { conn.Close() })
+		require.False(t, handledByService(conn))
+	})
+}
@@ -19,12 +19,16 @@
 package apiserver
 
 import (
+	"crypto/tls"
 	"fmt"
 	"net"
+	"strings"
 
 	"github.com/gravitational/trace"
 	log "github.com/sirupsen/logrus"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 
 	api "github.com/gravitational/teleport/gen/proto/go/teleport/lib/teleterm/v1"
 	vnetapi "github.com/gravitational/teleport/gen/proto/go/teleport/lib/teleterm/vnet/v1"
@@ -42,7 +46,7 @@ func New(cfg Config) (*APIServer, error) {
 
 	// Create the listener, set up the server.
 
-	ls, err := newListener(cfg.HostAddr, cfg.ListeningC)
+	ls, err := newListener(cfg.HostAddr, cfg.TLS, cfg.ListeningC)
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
@@ -68,11 +72,19 @@ func New(cfg Config) (*APIServer, error) {
 	api.RegisterTerminalServiceServer(grpcServer, serviceHandler)
 	vnetapi.RegisterVnetServiceServer(grpcServer, vnetService)
 
+	// Expose the standard health-check protocol so that clients can probe and
+	// watch daemon readiness. The daemon has loaded profiles by the time the
+	// server is constructed, so the overall status starts out as SERVING.
+	healthService := health.NewServer()
+	healthpb.RegisterHealthServer(grpcServer, healthService)
+	healthService.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+
 	return &APIServer{
-		Config:      cfg,
-		ls:          ls,
-		grpcServer:  grpcServer,
-		vnetService: vnetService,
+		Config:        cfg,
+		ls:            ls,
+		grpcServer:    grpcServer,
+		vnetService:   vnetService,
+		healthService: healthService,
 	}, nil
 }
 
@@ -83,11 +95,31 @@ func (s *APIServer) Serve() error {
 
 // Stop stops the server and closes all listeners
 func (s *APIServer) Stop() {
+	// Flip the health status first so that clients watching the health service
+	// observe NOT_SERVING while in-flight RPCs are drained.
+	s.healthService.Shutdown()
 	s.grpcServer.GracefulStop()
 	s.vnetService.Close()
 }
 
-func newListener(hostAddr string, listeningC chan<- utils.NetAddr) (net.Listener, error) {
+func newListener(hostAddr string, tlsConfig *tls.Config, listeningC chan<- utils.NetAddr) (net.Listener, error) {
+	// Named pipes are access-controlled by the OS (only the owning user and
+	// SYSTEM can connect), so neither TLS nor peer-cred auth applies.
+	if pipePath, ok := strings.CutPrefix(hostAddr, "npipe://"); ok {
+		lis, err := newPipeListener(pipePath)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+
+		addr := utils.FromAddr(lis.Addr())
+		if listeningC != nil {
+			listeningC <- addr
+		}
+
+		log.Infof("tsh daemon is listening on named pipe %v.", pipePath)
+		return lis, nil
+	}
+
 	uri, err := utils.ParseAddr(hostAddr)
 
 	if err != nil {
@@ -99,6 +131,19 @@ func newListener(hostAddr string, listeningC chan<- utils.NetAddr) (net.Listener
 		return nil, trace.Wrap(err)
 	}
 
+	if tlsConfig != nil {
+		if uri.Network() == "tcp" {
+			// The unix-socket peer-cred auth doesn't apply to TCP, so require
+			// a valid client cert instead to keep the port from being open to
+			// any local process.
+			tlsConfig = tlsConfig.Clone()
+			tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+			lis = tls.NewListener(lis, tlsConfig)
+		} else {
+			log.Warn("TLS configuration is ignored for non-TCP listeners, relying on peer credentials instead.")
+		}
+	}
+
 	addr := utils.FromAddr(lis.Addr())
 	sendBoundNetworkPortToStdout(addr)
 	if listeningC != nil {
@@ -119,7 +164,8 @@ func sendBoundNetworkPortToStdout(addr utils.NetAddr) {
 type APIServer struct {
 	Config
 	// ls is the server listener
-	ls          net.Listener
-	grpcServer  *grpc.Server
-	vnetService *vnet.Service
+	ls            net.Listener
+	grpcServer    *grpc.Server
+	vnetService   *vnet.Service
+	healthService *health.Server
 }
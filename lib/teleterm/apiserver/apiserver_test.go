@@ -0,0 +1,251 @@
+// Teleport
+// Copyright (C) 2024 Gravitational, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package apiserver
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/gravitational/teleport/api/utils/keys"
+	"github.com/gravitational/teleport/lib/client"
+	"github.com/gravitational/teleport/lib/teleterm/clusters"
+	"github.com/gravitational/teleport/lib/teleterm/daemon"
+	"github.com/gravitational/teleport/lib/utils/cert"
+)
+
+// TestHealthService verifies that the server implements the standard
+// grpc_health_v1 protocol: it reports SERVING once it is ready to accept
+// RPCs and notifies watchers with NOT_SERVING when it is stopped.
+func TestHealthService(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+
+	storage, err := clusters.NewStorage(clusters.Config{
+		Dir:                t.TempDir(),
+		InsecureSkipVerify: true,
+		ClientStore:        client.NewMemClientStore(),
+	})
+	require.NoError(t, err)
+
+	daemonService, err := daemon.New(daemon.Config{
+		Storage:        storage,
+		KubeconfigsDir: t.TempDir(),
+		AgentsDir:      t.TempDir(),
+	})
+	require.NoError(t, err)
+
+	sockPath := filepath.Join(t.TempDir(), "api.sock")
+	srv, err := New(Config{
+		HostAddr:        "unix://" + sockPath,
+		Daemon:          daemonService,
+		TshdServerCreds: grpc.Creds(insecure.NewCredentials()),
+	})
+	require.NoError(t, err)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.Serve()
+	}()
+
+	conn, err := grpc.DialContext(ctx, "unix://"+sockPath,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	healthClient := healthpb.NewHealthClient(conn)
+
+	// The empty service name refers to the overall server health.
+	resp, err := healthClient.Check(ctx, &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	require.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+
+	watch, err := healthClient.Watch(ctx, &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	update, err := watch.Recv()
+	require.NoError(t, err)
+	require.Equal(t, healthpb.HealthCheckResponse_SERVING, update.Status)
+
+	// Stop blocks until open streams are closed, so watch for the status
+	// transition while the server drains.
+	stopped := make(chan struct{})
+	go func() {
+		srv.Stop()
+		close(stopped)
+	}()
+
+	update, err = watch.Recv()
+	require.NoError(t, err)
+	require.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, update.Status)
+
+	require.NoError(t, conn.Close())
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for the server to stop")
+	}
+	require.NoError(t, <-serveErr)
+}
+
+// TestShutdownRequest verifies that a shutdown requested through the daemon
+// stops the API server, mirroring the wiring in teleterm.Serve.
+func TestShutdownRequest(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+
+	storage, err := clusters.NewStorage(clusters.Config{
+		Dir:                t.TempDir(),
+		InsecureSkipVerify: true,
+		ClientStore:        client.NewMemClientStore(),
+	})
+	require.NoError(t, err)
+
+	daemonService, err := daemon.New(daemon.Config{
+		Storage:        storage,
+		KubeconfigsDir: t.TempDir(),
+		AgentsDir:      t.TempDir(),
+	})
+	require.NoError(t, err)
+
+	sockPath := filepath.Join(t.TempDir(), "api.sock")
+	srv, err := New(Config{
+		HostAddr:        "unix://" + sockPath,
+		Daemon:          daemonService,
+		TshdServerCreds: grpc.Creds(insecure.NewCredentials()),
+	})
+	require.NoError(t, err)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.Serve()
+	}()
+	go func() {
+		<-daemonService.ShutdownRequests()
+		daemonService.Stop()
+		srv.Stop()
+	}()
+
+	conn, err := grpc.DialContext(ctx, "unix://"+sockPath,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	healthClient := healthpb.NewHealthClient(conn)
+
+	// Make sure the server is up before requesting the shutdown.
+	resp, err := healthClient.Check(ctx, &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	require.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+
+	daemonService.RequestShutdown()
+
+	select {
+	case err := <-serveErr:
+		require.NoError(t, err)
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for the server to stop serving")
+	}
+
+	// The server no longer accepts RPCs.
+	_, err = healthClient.Check(ctx, &healthpb.HealthCheckRequest{})
+	require.Error(t, err)
+}
+
+// TestTCPListenerRequiresClientCert verifies that a TCP listener with a TLS
+// config rejects connections without a valid client cert and accepts
+// connections that present one.
+func TestTCPListenerRequiresClientCert(t *testing.T) {
+	serverCreds, err := cert.GenerateSelfSignedCert([]string{"localhost"}, nil)
+	require.NoError(t, err)
+	serverCert, err := keys.X509KeyPair(serverCreds.Cert, serverCreds.PrivateKey)
+	require.NoError(t, err)
+
+	clientCreds, err := cert.GenerateSelfSignedCert([]string{"localhost"}, nil, x509.ExtKeyUsageClientAuth)
+	require.NoError(t, err)
+	clientCert, err := keys.X509KeyPair(clientCreds.Cert, clientCreds.PrivateKey)
+	require.NoError(t, err)
+
+	clientCAs := x509.NewCertPool()
+	require.True(t, clientCAs.AppendCertsFromPEM(clientCreds.Cert))
+	rootCAs := x509.NewCertPool()
+	require.True(t, rootCAs.AppendCertsFromPEM(serverCreds.Cert))
+
+	ls, err := newListener("tcp://localhost:0", &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientCAs:    clientCAs,
+	}, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { ls.Close() })
+
+	// Echo every accepted connection so that clients can verify they got
+	// past the handshake.
+	go func() {
+		for {
+			conn, err := ls.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	t.Run("without client cert", func(t *testing.T) {
+		conn, err := tls.Dial("tcp", ls.Addr().String(), &tls.Config{
+			RootCAs: rootCAs,
+		})
+		// Depending on the TLS version, the server's rejection surfaces
+		// either during the handshake or on the first read.
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		require.NoError(t, conn.SetDeadline(time.Now().Add(5*time.Second)))
+		conn.Write([]byte("ping"))
+		_, err = conn.Read(make([]byte, 4))
+		require.Error(t, err)
+	})
+
+	t.Run("with client cert", func(t *testing.T) {
+		conn, err := tls.Dial("tcp", ls.Addr().String(), &tls.Config{
+			RootCAs:      rootCAs,
+			Certificates: []tls.Certificate{clientCert},
+		})
+		require.NoError(t, err)
+		defer conn.Close()
+		require.NoError(t, conn.SetDeadline(time.Now().Add(5*time.Second)))
+
+		_, err = conn.Write([]byte("ping"))
+		require.NoError(t, err)
+
+		buf := make([]byte, 4)
+		_, err = io.ReadFull(conn, buf)
+		require.NoError(t, err)
+		require.Equal(t, "ping", string(buf))
+	})
+}
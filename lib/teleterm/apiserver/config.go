@@ -19,6 +19,8 @@
 package apiserver
 
 import (
+	"crypto/tls"
+
 	"github.com/gravitational/trace"
 	"github.com/sirupsen/logrus"
 	"google.golang.org/grpc"
@@ -32,6 +34,11 @@ import (
 type Config struct {
 	// HostAddr is the APIServer host address
 	HostAddr string
+	// TLS is the TLS configuration used for TCP listeners, with ClientCAs
+	// carrying the CA that client certs are verified against. Connections
+	// without a valid client cert are rejected. It is ignored for unix
+	// sockets, which rely on peer credentials instead.
+	TLS *tls.Config
 	// Daemon is the terminal daemon service
 	Daemon *daemon.Service
 	// Log is a component logger
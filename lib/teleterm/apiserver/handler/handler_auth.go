@@ -116,12 +116,7 @@ func (s *Handler) Logout(ctx context.Context, req *api.LogoutRequest) (*api.Empt
 
 // GetAuthSettings returns cluster auth preferences
 func (s *Handler) GetAuthSettings(ctx context.Context, req *api.GetAuthSettingsRequest) (*api.AuthSettings, error) {
-	cluster, _, err := s.DaemonService.ResolveCluster(req.ClusterUri)
-	if err != nil {
-		return nil, trace.Wrap(err)
-	}
-
-	preferences, err := cluster.SyncAuthPreference(ctx)
+	preferences, err := s.DaemonService.GetAuthSettings(ctx, req.ClusterUri, false /* refresh */)
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
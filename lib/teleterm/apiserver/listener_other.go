@@ -0,0 +1,33 @@
+//go:build !windows
+
+/*
+ * Teleport
+ * Copyright (C) 2024  Gravitational, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package apiserver
+
+import (
+	"net"
+
+	"github.com/gravitational/trace"
+)
+
+// newPipeListener is only supported on Windows, where the named pipe's ACL
+// restricts connections to the owning user and SYSTEM.
+func newPipeListener(pipePath string) (net.Listener, error) {
+	return nil, trace.NotImplemented("named pipe listeners are only supported on Windows")
+}
@@ -0,0 +1,59 @@
+//go:build windows
+
+/*
+ * Teleport
+ * Copyright (C) 2024  Gravitational, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package apiserver
+
+import (
+	"fmt"
+	"net"
+	"os/user"
+
+	"github.com/Microsoft/go-winio"
+	"github.com/gravitational/trace"
+)
+
+// newPipeListener listens on a Windows named pipe whose ACL admits only the
+// current user and SYSTEM. Other users are rejected by the OS when they try
+// to open the pipe, so no application-level auth is needed for them.
+func newPipeListener(pipePath string) (net.Listener, error) {
+	sd, err := pipeSecurityDescriptor()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	lis, err := winio.ListenPipe(pipePath, &winio.PipeConfig{
+		SecurityDescriptor: sd,
+	})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return lis, nil
+}
+
+// pipeSecurityDescriptor builds an SDDL security descriptor granting full
+// access to the owning user's SID and SYSTEM only. The DACL is protected so
+// that no inherited ACEs can widen it.
+func pipeSecurityDescriptor() (string, error) {
+	u, err := user.Current()
+	if err != nil {
+		return "", trace.Wrap(err)
+	}
+	// On Windows, user.Current reports the SID as the Uid.
+	return fmt.Sprintf("D:P(A;;GA;;;SY)(A;;GA;;;%s)", u.Uid), nil
+}
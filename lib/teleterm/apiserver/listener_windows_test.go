@@ -0,0 +1,50 @@
+//go:build windows
+
+/*
+ * Teleport
+ * Copyright (C) 2024  Gravitational, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package apiserver
+
+import (
+	"os/user"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestPipeSecurityDescriptor verifies that the named pipe's DACL admits
+// only the owning user and SYSTEM, and doesn't include any of the broad
+// well-known groups that would let other users connect.
+func TestPipeSecurityDescriptor(t *testing.T) {
+	u, err := user.Current()
+	require.NoError(t, err)
+
+	sd, err := pipeSecurityDescriptor()
+	require.NoError(t, err)
+
+	require.Contains(t, sd, "(A;;GA;;;SY)")
+	require.Contains(t, sd, "(A;;GA;;;"+u.Uid+")")
+	// The DACL must be protected against inherited ACEs.
+	require.Contains(t, sd, "D:P")
+
+	// Everyone, Authenticated Users, Builtin Users and Interactive Users
+	// must not be granted anything.
+	for _, sid := range []string{"WD", "AU", "BU", "IU"} {
+		require.NotContains(t, sd, ";;;"+sid+")")
+	}
+}
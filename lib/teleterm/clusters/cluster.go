@@ -20,6 +20,7 @@ package clusters
 
 import (
 	"context"
+	"time"
 
 	"github.com/gravitational/trace"
 	"github.com/jonboulle/clockwork"
@@ -30,6 +31,7 @@ import (
 
 	"github.com/gravitational/teleport/api/client/proto"
 	"github.com/gravitational/teleport/api/client/webclient"
+	"github.com/gravitational/teleport/api/constants"
 	"github.com/gravitational/teleport/api/types"
 	api "github.com/gravitational/teleport/gen/proto/go/teleport/lib/teleterm/v1"
 	"github.com/gravitational/teleport/lib/auth"
@@ -46,6 +48,18 @@ type Cluster struct {
 	Name string
 	// ProfileName is the name of the tsh profile
 	ProfileName string
+	// Metadata holds user-defined notes and tags for this cluster. It is
+	// managed by the daemon and stored alongside the profile, not in it.
+	Metadata map[string]string
+	// IsDefault indicates that this is the default cluster, preselected by
+	// the UI on startup. Like Metadata, it is managed by the daemon.
+	IsDefault bool
+	// ClockSkew is the difference between the local clock and the clock
+	// reported by the cluster's proxy, as measured by the last ping.
+	// Positive values mean the local clock runs ahead. It is zero when the
+	// skew is below the warning threshold or has not been measured; see
+	// [DetectClockSkew].
+	ClockSkew time.Duration
 	// Log is a component logger
 	Log *logrus.Entry
 	// dir is the directory where cluster certificates are stored
@@ -56,6 +70,12 @@ type Cluster struct {
 	clusterClient *client.TeleportClient
 	// clock is a clock for time-related operations
 	clock clockwork.Clock
+	// allowedSSOProviders optionally restricts which SSO connectors can be
+	// used for login. Empty means every connector is allowed.
+	allowedSSOProviders []string
+	// browserLauncher optionally overrides how the SSO login URL is
+	// presented to the user. When nil, the system browser is opened.
+	browserLauncher func(url string) error
 }
 
 type ClusterWithDetails struct {
@@ -82,6 +102,41 @@ func (c *Cluster) Connected() bool {
 	return c.status.Name != "" && !c.status.IsExpired(c.clock.Now())
 }
 
+// RefreshStatus pings the cluster's proxy to verify that it is reachable.
+// It does not require a valid cert, so it can be used to check connectivity
+// to clusters the user is logged out of. As a side effect, it measures the
+// skew between the local clock and the proxy's clock.
+func (c *Cluster) RefreshStatus(ctx context.Context) error {
+	pingResponse, err := c.clusterClient.Ping(ctx)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	c.ClockSkew = DetectClockSkew(c.clock.Now(), pingResponse.ServerTime)
+	return nil
+}
+
+// clockSkewThreshold is the smallest clock difference that is surfaced as
+// skew. Network latency and the second resolution of the proxy-reported
+// time make smaller differences indistinguishable from noise, and only
+// larger ones get certificate validity windows visibly wrong.
+const clockSkewThreshold = time.Minute
+
+// DetectClockSkew returns the skew between the local clock and the clock
+// reported by the proxy, positive when the local clock runs ahead. It
+// returns zero when the skew is below the warning threshold or when the
+// proxy did not report a time.
+func DetectClockSkew(localTime, serverTime time.Time) time.Duration {
+	if serverTime.IsZero() {
+		return 0
+	}
+	skew := localTime.Sub(serverTime)
+	if skew > -clockSkewThreshold && skew < clockSkewThreshold {
+		return 0
+	}
+	return skew
+}
+
 // GetWithDetails makes requests to the auth server to return details of the current
 // Cluster that cannot be found on the disk only, including details about the user
 // and enabled enterprise features. This method requires a valid cert.
@@ -276,6 +331,69 @@ func (c *Cluster) GetLoggedInUser() LoggedInUser {
 	}
 }
 
+// GetUserWithTraits returns the current user with the traits and roles from
+// the active certificate merged in. The stored user resource reflects only
+// static assignments, while the certificate carries the effective values for
+// this session, including any assumed access requests.
+func (c *Cluster) GetUserWithTraits(ctx context.Context, authClient auth.ClientI) (types.User, error) {
+	var user types.User
+	err := AddMetadataToRetryableError(ctx, func() error {
+		res, err := authClient.GetCurrentUser(ctx)
+		user = res
+		return trace.Wrap(err)
+	})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	user.SetTraits(c.mergeSessionTraits(user.GetTraits()))
+	if len(c.status.Roles) > 0 {
+		user.SetRoles(c.status.Roles)
+	}
+
+	return user, nil
+}
+
+// mergeSessionTraits overlays the traits from the active certificate on top
+// of the traits stored in the user resource.
+func (c *Cluster) mergeSessionTraits(traits map[string][]string) map[string][]string {
+	merged := make(map[string][]string, len(traits))
+	for trait, values := range traits {
+		merged[trait] = values
+	}
+	for trait, values := range c.status.Traits {
+		merged[trait] = values
+	}
+	if len(c.status.Logins) > 0 {
+		merged[constants.TraitLogins] = c.status.Logins
+	}
+	if len(c.status.KubeGroups) > 0 {
+		merged[constants.TraitKubeGroups] = c.status.KubeGroups
+	}
+	if len(c.status.KubeUsers) > 0 {
+		merged[constants.TraitKubeUsers] = c.status.KubeUsers
+	}
+	return merged
+}
+
+// Identity is a stored set of session credentials for a cluster. A cluster
+// can hold several identities at once — for example the certificate from the
+// original login next to one issued for an assumed access request — with
+// exactly one of them active at a time.
+type Identity struct {
+	// Name identifies the identity within its cluster.
+	Name string
+	// Status is the profile status captured when the identity was stored.
+	Status client.ProfileStatus
+}
+
+// ApplyIdentity makes the given identity's certificate the active one for
+// this cluster, so that status-derived values such as roles, traits and
+// logins reflect it.
+func (c *Cluster) ApplyIdentity(identity Identity) {
+	c.status = identity.Status
+}
+
 // GetProxyHost returns proxy address (hostname:port) of the root cluster, even when called on a
 // Cluster that represents a leaf cluster.
 func (c *Cluster) GetProxyHost() string {
@@ -234,7 +234,7 @@ func (c *Cluster) AssumeRole(ctx context.Context, rootProxyClient *client.ProxyC
 		return trace.Wrap(err)
 	}
 
-	err = c.clusterClient.SaveProfile(true)
+	err = c.saveProfile(true)
 	return trace.Wrap(err)
 }
 
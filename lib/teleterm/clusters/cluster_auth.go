@@ -22,7 +22,9 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"slices"
 	"sort"
+	"time"
 
 	"github.com/gravitational/trace"
 	"github.com/sirupsen/logrus"
@@ -52,7 +54,7 @@ func (c *Cluster) SyncAuthPreference(ctx context.Context) (*webclient.WebConfigA
 		c.Log.WithField("response", string(pingResponseJSON)).Debugln("Got ping response")
 	}
 
-	if err := c.clusterClient.SaveProfile(false); err != nil {
+	if err := c.saveProfile(false); err != nil {
 		return nil, trace.Wrap(err)
 	}
 
@@ -64,6 +66,130 @@ func (c *Cluster) SyncAuthPreference(ctx context.Context) (*webclient.WebConfigA
 	return &cfg.Auth, nil
 }
 
+// LoginChallenge describes the ways a user can log in to the cluster.
+// It bundles the SSO connectors usable for login with the local auth
+// settings so that the UI can offer every login method in a single step,
+// without a separate call for the auth settings.
+type LoginChallenge struct {
+	// LocalAuthEnabled indicates whether local logins are allowed.
+	LocalAuthEnabled bool
+	// SecondFactor is the type of second factor used in local logins.
+	SecondFactor constants.SecondFactorType
+	// Connectors lists the SSO connectors usable for login. It is empty
+	// for clusters that only allow local login.
+	Connectors []LoginChallengeConnector
+}
+
+// LoginChallengeConnector describes a single SSO connector usable for login.
+type LoginChallengeConnector struct {
+	// Name is the connector resource name.
+	Name string
+	// Type is the connector type (github, oidc or saml).
+	Type string
+	// DisplayName is the human-readable connector name.
+	DisplayName string
+}
+
+// CreateLoginChallenge returns a login challenge for this cluster, with the
+// available SSO connectors populated from the cluster's auth preference.
+func (c *Cluster) CreateLoginChallenge(ctx context.Context) (*LoginChallenge, error) {
+	authSettings, err := c.SyncAuthPreference(ctx)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	return newLoginChallenge(authSettings), nil
+}
+
+func newLoginChallenge(authSettings *webclient.WebConfigAuthSettings) *LoginChallenge {
+	challenge := &LoginChallenge{
+		LocalAuthEnabled: authSettings.LocalAuthEnabled,
+		SecondFactor:     authSettings.SecondFactor,
+		Connectors:       make([]LoginChallengeConnector, 0, len(authSettings.Providers)),
+	}
+
+	for _, provider := range authSettings.Providers {
+		challenge.Connectors = append(challenge.Connectors, LoginChallengeConnector{
+			Name:        provider.Name,
+			Type:        provider.Type,
+			DisplayName: provider.DisplayName,
+		})
+	}
+
+	return challenge
+}
+
+// Branding describes the user-facing branding the cluster's proxy serves
+// to its web UI.
+type Branding struct {
+	// ClusterName is the name the cluster presents to users.
+	ClusterName string
+	// ProxyVersion is the Teleport version the proxy is running.
+	ProxyVersion string
+	// CustomTheme is the name of the custom web UI theme. It is empty for
+	// clusters without custom branding.
+	CustomTheme string
+}
+
+// GetBranding fetches the cluster's branding from its proxy. Clusters
+// without custom branding yield just the name and version reported by the
+// ping endpoint.
+func (c *Cluster) GetBranding(ctx context.Context) (*Branding, error) {
+	pingResponse, err := c.clusterClient.Ping(ctx)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	branding := &Branding{
+		ClusterName:  pingResponse.ClusterName,
+		ProxyVersion: pingResponse.ServerVersion,
+	}
+
+	// The web config is best-effort decoration: a proxy that can't serve
+	// it still yields the branding derived from the ping response.
+	webConfig, err := c.clusterClient.GetWebConfig(ctx)
+	if err != nil {
+		c.Log.WithError(err).Debug("Could not fetch the web config for branding")
+		return branding, nil
+	}
+	if webConfig.ProxyClusterName != "" {
+		branding.ClusterName = webConfig.ProxyClusterName
+	}
+	branding.CustomTheme = webConfig.CustomTheme
+
+	return branding, nil
+}
+
+// ErrCertsExpired indicates that the user's certificates have fully
+// expired, so they cannot be renewed and a new login is required.
+var ErrCertsExpired = errors.New("the cluster certificates have expired, a new login is required")
+
+// RenewCerts reissues the user's certificates using the existing
+// credentials, without a full re-login, and refreshes the cluster's
+// profile status. It returns the new certificate expiry.
+//
+// Renewal is only possible while the current credentials are still
+// valid; once they have fully expired, [ErrCertsExpired] is returned.
+func (c *Cluster) RenewCerts(ctx context.Context) (time.Time, error) {
+	if c.status.Name == "" || c.status.IsExpired(c.clock.Now()) {
+		return time.Time{}, trace.Wrap(ErrCertsExpired)
+	}
+
+	if err := c.clusterClient.ReissueUserCerts(ctx, client.CertCacheKeep, client.ReissueParams{
+		RouteToCluster: c.clusterClient.SiteName,
+	}); err != nil {
+		return time.Time{}, trace.Wrap(err)
+	}
+
+	status, err := c.clusterClient.ProfileStatus()
+	if err != nil {
+		return time.Time{}, trace.Wrap(err)
+	}
+	c.status = *status
+
+	return c.status.ValidUntil, nil
+}
+
 // Logout deletes all cluster certificates
 func (c *Cluster) Logout(ctx context.Context) error {
 	// Delete db certs
@@ -130,6 +256,10 @@ func (c *Cluster) LocalLogin(ctx context.Context, user, password, otpToken strin
 
 // SSOLogin logs in a user to the Teleport cluster using supported SSO provider
 func (c *Cluster) SSOLogin(ctx context.Context, providerType, providerName string) error {
+	if err := c.checkSSOProviderAllowed(providerName); err != nil {
+		return trace.Wrap(err)
+	}
+
 	if _, err := c.updateClientFromPingResponse(ctx); err != nil {
 		return trace.Wrap(err)
 	}
@@ -143,6 +273,21 @@ func (c *Cluster) SSOLogin(ctx context.Context, providerType, providerName strin
 	return nil
 }
 
+// checkSSOProviderAllowed enforces the optional SSO connector allow-list
+// configured on the daemon. An empty allow-list permits every connector.
+func (c *Cluster) checkSSOProviderAllowed(providerName string) error {
+	if len(c.allowedSSOProviders) == 0 {
+		return nil
+	}
+
+	if slices.Contains(c.allowedSSOProviders, providerName) {
+		return nil
+	}
+
+	return trace.AccessDenied("SSO provider %q is not allowed by the daemon configuration, allowed providers: %v",
+		providerName, c.allowedSSOProviders)
+}
+
 // PasswordlessLogin processes passwordless logins for this cluster.
 func (c *Cluster) PasswordlessLogin(ctx context.Context, stream api.TerminalService_LoginPasswordlessServer) error {
 	if _, err := c.updateClientFromPingResponse(ctx); err != nil {
@@ -207,7 +352,7 @@ func (c *Cluster) login(ctx context.Context, sshLoginFunc client.SSHLoginFunc) e
 		return trace.Wrap(err)
 	}
 
-	if err := c.clusterClient.SaveProfile(true); err != nil {
+	if err := c.saveProfile(true); err != nil {
 		return trace.Wrap(err)
 	}
 
@@ -277,10 +422,11 @@ func (c *Cluster) ssoLogin(providerType, providerName string) client.SSHLoginFun
 				Compatibility:     c.clusterClient.CertificateFormat,
 				KubernetesCluster: c.clusterClient.KubernetesCluster,
 			},
-			ConnectorID: providerName,
-			Protocol:    providerType,
-			BindAddr:    c.clusterClient.BindAddr,
-			Browser:     c.clusterClient.Browser,
+			ConnectorID:     providerName,
+			Protocol:        providerType,
+			BindAddr:        c.clusterClient.BindAddr,
+			Browser:         c.clusterClient.Browser,
+			BrowserLauncher: c.browserLauncher,
 		}, nil)
 		if err != nil {
 			return nil, trace.Wrap(err)
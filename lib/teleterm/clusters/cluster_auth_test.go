@@ -20,20 +20,123 @@ package clusters
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gravitational/trace"
+	"github.com/jonboulle/clockwork"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
 
 	"github.com/gravitational/teleport"
+	"github.com/gravitational/teleport/api/client/webclient"
+	"github.com/gravitational/teleport/api/constants"
 	api "github.com/gravitational/teleport/gen/proto/go/teleport/lib/teleterm/v1"
 	wancli "github.com/gravitational/teleport/lib/auth/webauthncli"
+	"github.com/gravitational/teleport/lib/client"
 )
 
 var log = logrus.WithField(teleport.ComponentKey, "cluster_auth_test")
 
+func TestRenewCerts(t *testing.T) {
+	clock := clockwork.NewFakeClockAt(time.Now())
+
+	t.Run("expired credentials cannot be renewed", func(t *testing.T) {
+		c := &Cluster{
+			Log:   log,
+			clock: clock,
+			status: client.ProfileStatus{
+				Name:       "alice",
+				ValidUntil: clock.Now().Add(-time.Minute),
+			},
+		}
+		_, err := c.RenewCerts(context.Background())
+		require.ErrorIs(t, err, ErrCertsExpired)
+	})
+
+	t.Run("never logged in", func(t *testing.T) {
+		c := &Cluster{Log: log, clock: clock}
+		_, err := c.RenewCerts(context.Background())
+		require.ErrorIs(t, err, ErrCertsExpired)
+	})
+
+	t.Run("valid credentials attempt a reissue", func(t *testing.T) {
+		cfg := client.MakeDefaultConfig()
+		cfg.WebProxyAddr = "localhost:1"
+		cfg.Username = "alice"
+		cfg.ClientStore = client.NewMemClientStore()
+		tc, err := client.NewClient(cfg)
+		require.NoError(t, err)
+
+		c := &Cluster{
+			Log:           log,
+			clock:         clock,
+			clusterClient: tc,
+			status: client.ProfileStatus{
+				Name:       "alice",
+				ValidUntil: clock.Now().Add(time.Hour),
+			},
+		}
+
+		// The credentials pass the expiry gate; the reissue itself fails
+		// only because nothing is listening on the proxy address.
+		_, err = c.RenewCerts(context.Background())
+		require.Error(t, err)
+		require.NotErrorIs(t, err, ErrCertsExpired)
+	})
+}
+
+func TestSSOLoginProviderAllowList(t *testing.T) {
+	ctx := context.Background()
+
+	newCluster := func(allowedProviders []string) *Cluster {
+		cfg := client.MakeDefaultConfig()
+		cfg.WebProxyAddr = "localhost:1"
+		cfg.Username = "alice"
+		cfg.ClientStore = client.NewMemClientStore()
+		tc, err := client.NewClient(cfg)
+		require.NoError(t, err)
+
+		return &Cluster{
+			Log:                 log,
+			clock:               clockwork.NewFakeClockAt(time.Now()),
+			clusterClient:       tc,
+			allowedSSOProviders: allowedProviders,
+		}
+	}
+
+	t.Run("disallowed provider is rejected", func(t *testing.T) {
+		c := newCluster([]string{"github"})
+
+		err := c.SSOLogin(ctx, constants.SAML, "okta")
+		require.True(t, trace.IsAccessDenied(err))
+		require.ErrorContains(t, err, "okta")
+	})
+
+	t.Run("allowed provider proceeds", func(t *testing.T) {
+		c := newCluster([]string{"okta"})
+
+		// The provider passes the allow-list gate; the login itself fails
+		// only because nothing is listening on the proxy address.
+		err := c.SSOLogin(ctx, constants.SAML, "okta")
+		require.Error(t, err)
+		require.False(t, trace.IsAccessDenied(err))
+	})
+
+	t.Run("empty allow-list permits every provider", func(t *testing.T) {
+		c := newCluster(nil)
+
+		err := c.SSOLogin(ctx, constants.SAML, "okta")
+		require.Error(t, err)
+		require.False(t, trace.IsAccessDenied(err))
+	})
+}
+
 func TestPwdlessLoginPrompt_PromptPIN(t *testing.T) {
 	stream := &mockLoginPwdlessStream{}
 
@@ -145,3 +248,70 @@ func (m *mockLoginPwdlessStream) Recv() (*api.LoginPasswordlessRequest, error) {
 	}
 	return nil, trace.NotImplemented("serverReq not implemented")
 }
+
+func TestNewLoginChallenge(t *testing.T) {
+	t.Run("includes connectors when present", func(t *testing.T) {
+		challenge := newLoginChallenge(&webclient.WebConfigAuthSettings{
+			LocalAuthEnabled: true,
+			SecondFactor:     constants.SecondFactorOTP,
+			Providers: []webclient.WebConfigAuthProvider{
+				{Name: "github", Type: constants.Github, DisplayName: "GitHub"},
+				{Name: "okta", Type: constants.SAML, DisplayName: "Okta"},
+			},
+		})
+
+		require.True(t, challenge.LocalAuthEnabled)
+		require.Equal(t, constants.SecondFactorOTP, challenge.SecondFactor)
+		require.Equal(t, []LoginChallengeConnector{
+			{Name: "github", Type: constants.Github, DisplayName: "GitHub"},
+			{Name: "okta", Type: constants.SAML, DisplayName: "Okta"},
+		}, challenge.Connectors)
+	})
+
+	t.Run("empty connectors for local-only clusters", func(t *testing.T) {
+		challenge := newLoginChallenge(&webclient.WebConfigAuthSettings{
+			LocalAuthEnabled: true,
+			SecondFactor:     constants.SecondFactorOff,
+		})
+
+		require.True(t, challenge.LocalAuthEnabled)
+		require.Empty(t, challenge.Connectors)
+	})
+}
+
+func TestGetBranding(t *testing.T) {
+	ctx := context.Background()
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/webapi/ping"):
+			err := json.NewEncoder(w).Encode(webclient.PingResponse{
+				ClusterName:   "example.com",
+				ServerVersion: "15.0.0",
+			})
+			require.NoError(t, err)
+		case strings.HasSuffix(r.URL.Path, "config.js"):
+			_, err := w.Write([]byte(`var GRV_CONFIG = {"proxyCluster":"Example Corp","customTheme":"example-dark"};`))
+			require.NoError(t, err)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	storage, err := NewStorage(Config{
+		Dir:                t.TempDir(),
+		InsecureSkipVerify: true,
+		ClientStore:        client.NewMemClientStore(),
+	})
+	require.NoError(t, err)
+
+	cluster, _, err := storage.Add(ctx, strings.TrimPrefix(srv.URL, "https://"))
+	require.NoError(t, err)
+
+	branding, err := cluster.GetBranding(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "Example Corp", branding.ClusterName)
+	require.Equal(t, "15.0.0", branding.ProxyVersion)
+	require.Equal(t, "example-dark", branding.CustomTheme)
+}
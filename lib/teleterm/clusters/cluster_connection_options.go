@@ -0,0 +1,109 @@
+/*
+ * Teleport
+ * Copyright (C) 2024  Gravitational, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package clusters
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gravitational/trace"
+
+	"github.com/gravitational/teleport/lib/auth"
+	"github.com/gravitational/teleport/lib/services"
+	"github.com/gravitational/teleport/lib/teleterm/api/uri"
+)
+
+// ConnectionOptions describe how the current user may connect to a
+// resource: which logins or database users their roles allow and whether
+// per-session MFA must be performed. The UI uses them to pre-fill the
+// connect dialog without another round trip.
+type ConnectionOptions struct {
+	// Logins are the SSH logins the user may use on a server.
+	Logins []string
+	// DatabaseUsers are the database users the user may connect as.
+	DatabaseUsers []string
+	// DatabaseNames are the database names the user may connect to.
+	DatabaseNames []string
+	// MFARequired is true when per-session MFA is required to connect to
+	// the resource.
+	MFARequired bool
+}
+
+// GetConnectionOptions derives the connection parameters for a server or
+// database from the current user's access checker.
+func (c *Cluster) GetConnectionOptions(ctx context.Context, authClient auth.ClientI, resourceURI uri.ResourceURI) (*ConnectionOptions, error) {
+	accessChecker, err := services.NewAccessCheckerForRemoteCluster(ctx, c.status.AccessInfo(), c.status.Cluster, authClient)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	authPref, err := authClient.GetAuthPreference(ctx)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	opts := &ConnectionOptions{
+		MFARequired: authPref.GetRequireMFAType().IsSessionMFARequired(),
+	}
+
+	switch {
+	case resourceURI.GetServerUUID() != "":
+		server, err := c.GetServer(ctx, authClient, resourceURI)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		logins, err := accessChecker.GetAllowedLoginsForResource(server)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		opts.Logins = logins
+		if !opts.MFARequired && len(logins) > 0 {
+			opts.MFARequired = mfaRequiredForAccess(accessChecker, server, services.NewLoginMatcher(logins[0]))
+		}
+
+	case resourceURI.GetDbName() != "":
+		db, err := c.GetDatabase(ctx, authClient, resourceURI)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		dbUsers, err := accessChecker.EnumerateDatabaseUsers(db)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		opts.DatabaseUsers = dbUsers.Allowed()
+		dbNames := accessChecker.EnumerateDatabaseNames(db)
+		opts.DatabaseNames = dbNames.Allowed()
+		if !opts.MFARequired {
+			opts.MFARequired = mfaRequiredForAccess(accessChecker, db)
+		}
+
+	default:
+		return nil, trace.BadParameter("connection options are not supported for %v", resourceURI)
+	}
+
+	return opts, nil
+}
+
+// mfaRequiredForAccess reports whether access to the resource is denied
+// without a verified MFA device but would be allowed with one, mirroring
+// the auth server's IsMFARequired check.
+func mfaRequiredForAccess(accessChecker services.AccessChecker, resource services.AccessCheckable, matchers ...services.RoleMatcher) bool {
+	err := accessChecker.CheckAccess(resource, services.AccessState{MFAVerified: false}, matchers...)
+	return errors.Is(err, services.ErrSessionMFARequired)
+}
@@ -0,0 +1,195 @@
+// Teleport
+// Copyright (C) 2024 Gravitational, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package clusters
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gravitational/teleport/api/client/proto"
+	"github.com/gravitational/teleport/api/types"
+	"github.com/gravitational/teleport/lib/auth"
+	"github.com/gravitational/teleport/lib/client"
+	"github.com/gravitational/teleport/lib/teleterm/api/uri"
+)
+
+// fakeConnectionOptionsAuthClient serves canned resources and the remote
+// cluster's view of the current user's roles.
+type fakeConnectionOptionsAuthClient struct {
+	auth.ClientI
+
+	roles     []types.Role
+	nodes     []*types.ServerV2
+	databases []*types.DatabaseServerV3
+}
+
+func (f *fakeConnectionOptionsAuthClient) GetCurrentUser(ctx context.Context) (types.User, error) {
+	user, err := types.NewUser("alice")
+	return user, err
+}
+
+func (f *fakeConnectionOptionsAuthClient) GetCurrentUserRoles(ctx context.Context) ([]types.Role, error) {
+	return f.roles, nil
+}
+
+func (f *fakeConnectionOptionsAuthClient) GetAuthPreference(ctx context.Context) (types.AuthPreference, error) {
+	return types.DefaultAuthPreference(), nil
+}
+
+func (f *fakeConnectionOptionsAuthClient) GetResources(ctx context.Context, req *proto.ListResourcesRequest) (*proto.ListResourcesResponse, error) {
+	resp := &proto.ListResourcesResponse{}
+	switch req.ResourceType {
+	case types.KindNode:
+		for _, node := range f.nodes {
+			if req.PredicateExpression != "" && req.PredicateExpression != `name == "`+node.GetName()+`"` {
+				continue
+			}
+			resp.Resources = append(resp.Resources, &proto.PaginatedResource{
+				Resource: &proto.PaginatedResource_Node{Node: node},
+			})
+		}
+	case types.KindDatabaseServer:
+		for _, db := range f.databases {
+			if req.PredicateExpression != "" && req.PredicateExpression != `name == "`+db.GetName()+`"` {
+				continue
+			}
+			resp.Resources = append(resp.Resources, &proto.PaginatedResource{
+				Resource: &proto.PaginatedResource_DatabaseServer{DatabaseServer: db},
+			})
+		}
+	}
+	resp.TotalCount = int32(len(resp.Resources))
+	return resp, nil
+}
+
+func TestGetConnectionOptions(t *testing.T) {
+	node, err := types.NewServerWithLabels("node-1", types.KindNode,
+		types.ServerSpecV2{Hostname: "node-1.example.com"}, map[string]string{"env": "prod"})
+	require.NoError(t, err)
+
+	db, err := types.NewDatabaseV3(types.Metadata{
+		Name:   "db-1",
+		Labels: map[string]string{"env": "prod"},
+	}, types.DatabaseSpecV3{
+		Protocol: "postgres",
+		URI:      "localhost:5432",
+	})
+	require.NoError(t, err)
+	dbServer, err := types.NewDatabaseServerV3(types.Metadata{
+		Name: "db-1",
+	}, types.DatabaseServerSpecV3{
+		Database: db,
+		HostID:   "host-1",
+		Hostname: "host-1.example.com",
+	})
+	require.NoError(t, err)
+
+	newRole := func(t *testing.T, name string, spec types.RoleSpecV6) types.Role {
+		role, err := types.NewRole(name, spec)
+		require.NoError(t, err)
+		return role
+	}
+	accessRole := newRole(t, "access", types.RoleSpecV6{
+		Allow: types.RoleConditions{
+			Logins:         []string{"root", "ubuntu"},
+			NodeLabels:     types.Labels{types.Wildcard: []string{types.Wildcard}},
+			DatabaseLabels: types.Labels{types.Wildcard: []string{types.Wildcard}},
+			DatabaseUsers:  []string{"reader", "writer"},
+			DatabaseNames:  []string{"main"},
+		},
+	})
+	mfaRole := newRole(t, "access-mfa", types.RoleSpecV6{
+		Options: types.RoleOptions{
+			RequireMFAType: types.RequireMFAType_SESSION,
+		},
+		Allow: types.RoleConditions{
+			Logins:         []string{"root"},
+			NodeLabels:     types.Labels{types.Wildcard: []string{types.Wildcard}},
+			DatabaseLabels: types.Labels{types.Wildcard: []string{types.Wildcard}},
+			DatabaseUsers:  []string{"reader"},
+			DatabaseNames:  []string{"main"},
+		},
+	})
+
+	newCluster := func() *Cluster {
+		return &Cluster{
+			URI:           uri.NewClusterURI("example.com"),
+			Name:          "example.com",
+			status:        client.ProfileStatus{Cluster: "example.com", Username: "alice"},
+			clusterClient: &client.TeleportClient{},
+		}
+	}
+
+	t.Run("server logins reflect roles", func(t *testing.T) {
+		authClient := &fakeConnectionOptionsAuthClient{
+			roles: []types.Role{accessRole},
+			nodes: []*types.ServerV2{node.(*types.ServerV2)},
+		}
+		cluster := newCluster()
+
+		opts, err := cluster.GetConnectionOptions(context.Background(), authClient,
+			cluster.URI.AppendServer("node-1"))
+		require.NoError(t, err)
+		require.ElementsMatch(t, []string{"root", "ubuntu"}, opts.Logins)
+		require.False(t, opts.MFARequired)
+	})
+
+	t.Run("database users and names reflect roles", func(t *testing.T) {
+		authClient := &fakeConnectionOptionsAuthClient{
+			roles:     []types.Role{accessRole},
+			databases: []*types.DatabaseServerV3{dbServer},
+		}
+		cluster := newCluster()
+
+		opts, err := cluster.GetConnectionOptions(context.Background(), authClient,
+			cluster.URI.AppendDB("db-1"))
+		require.NoError(t, err)
+		require.ElementsMatch(t, []string{"reader", "writer"}, opts.DatabaseUsers)
+		require.ElementsMatch(t, []string{"main"}, opts.DatabaseNames)
+		require.False(t, opts.MFARequired)
+	})
+
+	t.Run("per-session MFA requirement is reported", func(t *testing.T) {
+		authClient := &fakeConnectionOptionsAuthClient{
+			roles:     []types.Role{mfaRole},
+			nodes:     []*types.ServerV2{node.(*types.ServerV2)},
+			databases: []*types.DatabaseServerV3{dbServer},
+		}
+		cluster := newCluster()
+
+		opts, err := cluster.GetConnectionOptions(context.Background(), authClient,
+			cluster.URI.AppendServer("node-1"))
+		require.NoError(t, err)
+		require.True(t, opts.MFARequired)
+
+		opts, err = cluster.GetConnectionOptions(context.Background(), authClient,
+			cluster.URI.AppendDB("db-1"))
+		require.NoError(t, err)
+		require.True(t, opts.MFARequired)
+	})
+
+	t.Run("unsupported resource", func(t *testing.T) {
+		authClient := &fakeConnectionOptionsAuthClient{roles: []types.Role{accessRole}}
+		cluster := newCluster()
+
+		_, err := cluster.GetConnectionOptions(context.Background(), authClient,
+			cluster.URI.AppendKube("kube-1"))
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,91 @@
+// Teleport
+// Copyright (C) 2024 Gravitational, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package clusters
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gravitational/trace"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gravitational/teleport/api/client/proto"
+	"github.com/gravitational/teleport/api/types"
+	"github.com/gravitational/teleport/lib/auth"
+	"github.com/gravitational/teleport/lib/client"
+	"github.com/gravitational/teleport/lib/teleterm/api/uri"
+)
+
+// fakeDatabaseAuthClient serves a canned set of database servers, honoring
+// the name predicate used by get-by-name lookups.
+type fakeDatabaseAuthClient struct {
+	auth.ClientI
+
+	databases []*types.DatabaseServerV3
+}
+
+func (f *fakeDatabaseAuthClient) GetResources(ctx context.Context, req *proto.ListResourcesRequest) (*proto.ListResourcesResponse, error) {
+	resp := &proto.ListResourcesResponse{}
+	for _, db := range f.databases {
+		if req.PredicateExpression != "" && req.PredicateExpression != `name == "`+db.GetName()+`"` {
+			continue
+		}
+		resp.Resources = append(resp.Resources, &proto.PaginatedResource{
+			Resource: &proto.PaginatedResource_DatabaseServer{DatabaseServer: db},
+		})
+	}
+	resp.TotalCount = int32(len(resp.Resources))
+	return resp, nil
+}
+
+func TestGetDatabase(t *testing.T) {
+	db, err := types.NewDatabaseV3(types.Metadata{
+		Name: "db-1",
+	}, types.DatabaseSpecV3{
+		Protocol: "postgres",
+		URI:      "localhost:5432",
+	})
+	require.NoError(t, err)
+
+	dbServer, err := types.NewDatabaseServerV3(types.Metadata{
+		Name: "db-1",
+	}, types.DatabaseServerSpecV3{
+		Database: db,
+		HostID:   "host-1",
+		Hostname: "host-1.example.com",
+	})
+	require.NoError(t, err)
+
+	cluster := &Cluster{
+		URI:           uri.NewClusterURI("example.com"),
+		Name:          "example.com",
+		clusterClient: &client.TeleportClient{},
+	}
+	authClient := &fakeDatabaseAuthClient{databases: []*types.DatabaseServerV3{dbServer}}
+
+	t.Run("found", func(t *testing.T) {
+		got, err := cluster.GetDatabase(context.Background(), authClient, cluster.URI.AppendDB("db-1"))
+		require.NoError(t, err)
+		require.Equal(t, cluster.URI.AppendDB("db-1"), got.URI)
+		require.Equal(t, "postgres", got.GetProtocol())
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := cluster.GetDatabase(context.Background(), authClient, cluster.URI.AppendDB("missing"))
+		require.True(t, trace.IsNotFound(err))
+	})
+}
@@ -0,0 +1,79 @@
+/*
+ * Teleport
+ * Copyright (C) 2024  Gravitational, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package clusters
+
+import (
+	"context"
+	"time"
+)
+
+// Diagnostics is a redacted report of a cluster's local state, suitable for
+// attaching to support tickets. It contains profile metadata, certificate
+// expiry and the result of a live connectivity check. It is assembled from
+// the profile status and the proxy's ping response only, so it can never
+// contain private keys or other secrets.
+type Diagnostics struct {
+	// ClusterName is the name of the cluster.
+	ClusterName string
+	// ProxyHost is the address of the cluster's proxy.
+	ProxyHost string
+	// Username is the Teleport username from the profile, empty when the
+	// user never logged in.
+	Username string
+	// LoggedIn is true when the profile holds unexpired credentials.
+	LoggedIn bool
+	// CertValidUntil is the expiry time of the user's certificate. The
+	// zero value means the user never logged in.
+	CertValidUntil time.Time
+	// Roles are the Teleport roles from the profile.
+	Roles []string
+	// ProxyReachable is true when the proxy responded to a ping.
+	ProxyReachable bool
+	// ProxyVersion is the Teleport version reported by the proxy, set only
+	// when it is reachable.
+	ProxyVersion string
+	// ConnectivityError describes why the proxy could not be reached, if
+	// the connectivity check failed.
+	ConnectivityError string
+}
+
+// Diagnostics gathers a diagnostic report for this cluster. The
+// connectivity check pings the proxy, so the report reflects whether the
+// proxy is reachable right now; a failure to reach it is part of the
+// report rather than an error.
+func (c *Cluster) Diagnostics(ctx context.Context) *Diagnostics {
+	report := &Diagnostics{
+		ClusterName:    c.Name,
+		ProxyHost:      c.GetProxyHost(),
+		Username:       c.status.Username,
+		LoggedIn:       c.Connected(),
+		CertValidUntil: c.status.ValidUntil,
+		Roles:          c.status.Roles,
+	}
+
+	pingResponse, err := c.clusterClient.Ping(ctx)
+	if err != nil {
+		report.ConnectivityError = err.Error()
+		return report
+	}
+	report.ProxyReachable = true
+	report.ProxyVersion = pingResponse.ServerVersion
+
+	return report
+}
@@ -0,0 +1,106 @@
+/*
+ * Teleport
+ * Copyright (C) 2024  Gravitational, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package clusters
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gravitational/teleport/api/client/webclient"
+	"github.com/gravitational/teleport/lib/client"
+)
+
+func TestDiagnostics(t *testing.T) {
+	ctx := context.Background()
+	clock := clockwork.NewFakeClockAt(time.Now())
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/webapi/ping") {
+			err := json.NewEncoder(w).Encode(webclient.PingResponse{
+				ClusterName:   "example.com",
+				ServerVersion: "15.0.0",
+			})
+			require.NoError(t, err)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	newCluster := func(webProxyAddr string) *Cluster {
+		cfg := client.MakeDefaultConfig()
+		cfg.WebProxyAddr = webProxyAddr
+		cfg.Username = "alice"
+		cfg.InsecureSkipVerify = true
+		cfg.ClientStore = client.NewMemClientStore()
+		tc, err := client.NewClient(cfg)
+		require.NoError(t, err)
+
+		return &Cluster{
+			Name:          "example.com",
+			Log:           log,
+			clock:         clock,
+			clusterClient: tc,
+			status: client.ProfileStatus{
+				Name:       "example.com",
+				Username:   "alice",
+				ValidUntil: clock.Now().Add(10 * time.Hour),
+				Roles:      []string{"access"},
+			},
+		}
+	}
+
+	t.Run("reachable proxy", func(t *testing.T) {
+		c := newCluster(strings.TrimPrefix(srv.URL, "https://"))
+
+		report := c.Diagnostics(ctx)
+		require.Equal(t, "example.com", report.ClusterName)
+		require.Equal(t, "alice", report.Username)
+		require.True(t, report.LoggedIn)
+		require.Equal(t, c.status.ValidUntil, report.CertValidUntil)
+		require.Equal(t, []string{"access"}, report.Roles)
+		require.True(t, report.ProxyReachable)
+		require.Equal(t, "15.0.0", report.ProxyVersion)
+		require.Empty(t, report.ConnectivityError)
+
+		// The report must never carry key material.
+		out, err := json.Marshal(report)
+		require.NoError(t, err)
+		require.NotContains(t, string(out), "PRIVATE KEY")
+		require.NotContains(t, string(out), "-----BEGIN")
+	})
+
+	t.Run("unreachable proxy", func(t *testing.T) {
+		// Nothing is listening on this address, so the connectivity
+		// check fails and the failure becomes part of the report.
+		c := newCluster("localhost:1")
+
+		report := c.Diagnostics(ctx)
+		require.False(t, report.ProxyReachable)
+		require.NotEmpty(t, report.ConnectivityError)
+	})
+}
@@ -41,34 +41,52 @@ type CreateGatewayParams struct {
 	// name on a database server.
 	TargetSubresourceName string
 	// LocalPort is the gateway local port
-	LocalPort            string
+	LocalPort string
+	// FriendlyName is an optional user-provided name for the gateway, so that
+	// the UI can tell apart multiple gateways to the same target.
+	FriendlyName         string
 	TCPPortAllocator     gateway.TCPPortAllocator
 	OnExpiredCert        gateway.OnExpiredCertFunc
 	KubeconfigsDir       string
 	MFAPromptConstructor func(cfg *libmfa.PromptConfig) mfa.Prompt
 	ProxyClient          *client.ProxyClient
+	// WebAppLocalProxy starts an app gateway as a local HTTPS reverse proxy
+	// serving a browser-openable URL instead of a raw TCP tunnel. Supported
+	// only for HTTP apps.
+	WebAppLocalProxy bool
 }
 
 // CreateGateway creates a gateway
 func (c *Cluster) CreateGateway(ctx context.Context, params CreateGatewayParams) (gateway.Gateway, error) {
 	c.clusterClient.MFAPromptConstructor = params.MFAPromptConstructor
 
+	var gw gateway.Gateway
+	var err error
 	switch {
 	case params.TargetURI.IsDB():
-		gateway, err := c.createDBGateway(ctx, params)
-		return gateway, trace.Wrap(err)
+		gw, err = c.createDBGateway(ctx, params)
 
 	case params.TargetURI.IsKube():
-		gateway, err := c.createKubeGateway(ctx, params)
-		return gateway, trace.Wrap(err)
+		gw, err = c.createKubeGateway(ctx, params)
 
 	case params.TargetURI.IsApp():
-		gateway, err := c.createAppGateway(ctx, params)
-		return gateway, trace.Wrap(err)
+		gw, err = c.createAppGateway(ctx, params)
 
 	default:
 		return nil, trace.NotImplemented("gateway not supported for %v", params.TargetURI)
 	}
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	// Establishing the tunnel might have raced with the caller canceling the
+	// request, in which case the gateway needs to be closed rather than
+	// returned so that it doesn't keep a listener open with no one using it.
+	if ctx.Err() != nil {
+		return nil, trace.NewAggregate(trace.Wrap(ctx.Err()), gw.Close())
+	}
+
+	return gw, nil
 }
 
 func (c *Cluster) createDBGateway(ctx context.Context, params CreateGatewayParams) (gateway.Gateway, error) {
@@ -96,6 +114,7 @@ func (c *Cluster) createDBGateway(ctx context.Context, params CreateGatewayParam
 		TargetUser:                    params.TargetUser,
 		TargetName:                    db.GetName(),
 		TargetSubresourceName:         params.TargetSubresourceName,
+		FriendlyName:                  params.FriendlyName,
 		Protocol:                      db.GetProtocol(),
 		KeyPath:                       c.status.KeyPath(),
 		CertPath:                      c.status.DatabaseCertPathForCluster(c.clusterClient.SiteName, db.GetName()),
@@ -138,6 +157,7 @@ func (c *Cluster) createKubeGateway(ctx context.Context, params CreateGatewayPar
 		LocalPort:                     params.LocalPort,
 		TargetURI:                     params.TargetURI,
 		TargetName:                    kube,
+		FriendlyName:                  params.FriendlyName,
 		Cert:                          cert,
 		Insecure:                      c.clusterClient.InsecureSkipVerify,
 		WebProxyAddr:                  c.clusterClient.WebProxyAddr,
@@ -175,6 +195,7 @@ func (c *Cluster) createAppGateway(ctx context.Context, params CreateGatewayPara
 		LocalPort:                     params.LocalPort,
 		TargetURI:                     params.TargetURI,
 		TargetName:                    appName,
+		FriendlyName:                  params.FriendlyName,
 		Cert:                          cert,
 		Protocol:                      app.GetProtocol(),
 		Insecure:                      c.clusterClient.InsecureSkipVerify,
@@ -187,6 +208,7 @@ func (c *Cluster) createAppGateway(ctx context.Context, params CreateGatewayPara
 		RootClusterCACertPoolFunc:     c.clusterClient.RootClusterCACertPool,
 		ClusterName:                   c.Name,
 		Username:                      c.status.Username,
+		WebAppLocalProxy:              params.WebAppLocalProxy,
 	})
 	return gw, trace.Wrap(err)
 }
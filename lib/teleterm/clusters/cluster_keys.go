@@ -0,0 +1,184 @@
+/*
+ * Teleport
+ * Copyright (C) 2024  Gravitational, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package clusters
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"time"
+
+	"github.com/gravitational/trace"
+
+	"github.com/gravitational/teleport/lib/client"
+	"github.com/gravitational/teleport/lib/tlsca"
+)
+
+// Types of key material kept by the daemon for a cluster, as reported by
+// StoredKeys and accepted by DeleteStoredKey.
+const (
+	// StoredKeyTypeSSH is the user SSH certificate.
+	StoredKeyTypeSSH = "ssh"
+	// StoredKeyTypeTLS is the user TLS certificate used to authenticate to
+	// the Teleport APIs.
+	StoredKeyTypeTLS = "tls"
+	// StoredKeyTypeKube is a TLS certificate for a kubernetes cluster.
+	StoredKeyTypeKube = "kube"
+	// StoredKeyTypeDB is a TLS certificate for a database.
+	StoredKeyTypeDB = "db"
+	// StoredKeyTypeApp is a TLS certificate for an application.
+	StoredKeyTypeApp = "app"
+)
+
+// StoredKey describes a single certificate kept by the daemon for a cluster.
+type StoredKey struct {
+	// ClusterName is the name of the cluster the certificate was issued for.
+	ClusterName string
+	// Type is one of the StoredKeyType constants.
+	Type string
+	// Name identifies the resource the certificate belongs to (kubernetes
+	// cluster, database service or application name). It is empty for the
+	// user SSH and TLS certificates.
+	Name string
+	// ValidUntil is the expiry time of the certificate.
+	ValidUntil time.Time
+	// Fingerprint is the hex-encoded SHA-256 digest of the raw certificate.
+	Fingerprint string
+}
+
+// StoredKeys enumerates the certificates kept for this cluster by the
+// client key store. It returns an empty list when the user is logged out.
+func (c *Cluster) StoredKeys() ([]StoredKey, error) {
+	key, err := c.clusterClient.LocalAgent().GetKey(c.clusterClient.SiteName, client.WithAllCerts...)
+	if err != nil {
+		if trace.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, trace.Wrap(err)
+	}
+
+	var storedKeys []StoredKey
+
+	if len(key.Cert) > 0 {
+		sshCert, err := key.SSHCert()
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		storedKeys = append(storedKeys, StoredKey{
+			ClusterName: key.ClusterName,
+			Type:        StoredKeyTypeSSH,
+			ValidUntil:  time.Unix(int64(sshCert.ValidBefore), 0),
+			Fingerprint: hex.EncodeToString(sha256Digest(sshCert.Marshal())),
+		})
+	}
+
+	if len(key.TLSCert) > 0 {
+		storedKey, err := storedKeyFromTLSCert(key.ClusterName, StoredKeyTypeTLS, "", key.TLSCert)
+		if err != nil {
+			return nil, trace.Wrap(err)
+		}
+		storedKeys = append(storedKeys, storedKey)
+	}
+
+	for _, certs := range []struct {
+		keyType string
+		certs   map[string][]byte
+	}{
+		{StoredKeyTypeKube, key.KubeTLSCerts},
+		{StoredKeyTypeDB, key.DBTLSCerts},
+		{StoredKeyTypeApp, key.AppTLSCerts},
+	} {
+		for name, certPEM := range certs.certs {
+			storedKey, err := storedKeyFromTLSCert(key.ClusterName, certs.keyType, name, certPEM)
+			if err != nil {
+				return nil, trace.Wrap(err)
+			}
+			storedKeys = append(storedKeys, storedKey)
+		}
+	}
+
+	sort.Slice(storedKeys, func(i, j int) bool {
+		if storedKeys[i].Type != storedKeys[j].Type {
+			return storedKeys[i].Type < storedKeys[j].Type
+		}
+		return storedKeys[i].Name < storedKeys[j].Name
+	})
+
+	return storedKeys, nil
+}
+
+// DeleteStoredKey removes a certificate reported by StoredKeys from the
+// client key store. Removing the user TLS certificate deletes the whole key
+// for the profile and leaves the cluster in the logged-out state. The name
+// argument selects the kubernetes cluster, database or application for the
+// per-resource certificate types and must be empty otherwise.
+func (c *Cluster) DeleteStoredKey(keyType, name string) error {
+	switch keyType {
+	case StoredKeyTypeSSH, StoredKeyTypeTLS:
+		if name != "" {
+			return trace.BadParameter("key type %q does not accept a resource name", keyType)
+		}
+	}
+
+	switch keyType {
+	case StoredKeyTypeSSH:
+		return trace.Wrap(c.clusterClient.LocalAgent().DeleteUserCerts(c.clusterClient.SiteName, client.WithSSHCerts{}))
+	case StoredKeyTypeTLS:
+		if err := c.clusterClient.Logout(); err != nil && !trace.IsNotFound(err) {
+			return trace.Wrap(err)
+		}
+		// Without the TLS certificate the user cannot reach the cluster
+		// anymore, so reflect that in the cluster status right away instead
+		// of waiting for the profile to be reloaded.
+		c.status = client.ProfileStatus{}
+		return nil
+	case StoredKeyTypeKube:
+		// The key store does not keep kubernetes certificates addressable by
+		// name, so they can only be removed all at once.
+		if name != "" {
+			return trace.BadParameter("kubernetes certificates can only be removed all at once, leave the resource name empty")
+		}
+		return trace.Wrap(c.clusterClient.LocalAgent().DeleteUserCerts(c.clusterClient.SiteName, client.WithKubeCerts{}))
+	case StoredKeyTypeDB:
+		return trace.Wrap(c.clusterClient.LogoutDatabase(name))
+	case StoredKeyTypeApp:
+		return trace.Wrap(c.clusterClient.LogoutApp(name))
+	default:
+		return trace.BadParameter("unknown key type %q", keyType)
+	}
+}
+
+func storedKeyFromTLSCert(clusterName, keyType, name string, certPEM []byte) (StoredKey, error) {
+	cert, err := tlsca.ParseCertificatePEM(certPEM)
+	if err != nil {
+		return StoredKey{}, trace.Wrap(err)
+	}
+	return StoredKey{
+		ClusterName: clusterName,
+		Type:        keyType,
+		Name:        name,
+		ValidUntil:  cert.NotAfter,
+		Fingerprint: hex.EncodeToString(sha256Digest(cert.Raw)),
+	}, nil
+}
+
+func sha256Digest(data []byte) []byte {
+	digest := sha256.Sum256(data)
+	return digest[:]
+}
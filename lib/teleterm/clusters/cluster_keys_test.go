@@ -0,0 +1,178 @@
+/*
+ * Teleport
+ * Copyright (C) 2024  Gravitational, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package clusters
+
+import (
+	"crypto/x509/pkix"
+	"testing"
+	"time"
+
+	"github.com/gravitational/trace"
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/gravitational/teleport/lib/auth/testauthority"
+	"github.com/gravitational/teleport/lib/client"
+	"github.com/gravitational/teleport/lib/defaults"
+	"github.com/gravitational/teleport/lib/services"
+	"github.com/gravitational/teleport/lib/tlsca"
+)
+
+// newTestKey builds a client key for the "root" cluster with user SSH and
+// TLS certificates and a single app certificate.
+func newTestKey(t *testing.T) *client.Key {
+	t.Helper()
+
+	keygen := testauthority.New()
+	privateKey, err := keygen.GeneratePrivateKey()
+	require.NoError(t, err)
+
+	caCert, err := tlsca.GenerateSelfSignedCAWithSigner(privateKey, pkix.Name{
+		CommonName:   "root",
+		Organization: []string{"localhost"},
+	}, nil, defaults.CATTL)
+	require.NoError(t, err)
+	ca, err := tlsca.FromCertAndSigner(caCert, privateKey)
+	require.NoError(t, err)
+
+	identity := tlsca.Identity{
+		Username: "alice",
+		Groups:   []string{"access"},
+	}
+	subject, err := identity.Subject()
+	require.NoError(t, err)
+
+	tlsCert, err := ca.GenerateCertificate(tlsca.CertificateRequest{
+		Clock:     clockwork.NewRealClock(),
+		PublicKey: privateKey.Public(),
+		Subject:   subject,
+		NotAfter:  time.Now().UTC().Add(time.Hour),
+	})
+	require.NoError(t, err)
+
+	caKey, err := keygen.GeneratePrivateKey()
+	require.NoError(t, err)
+	caSigner, err := ssh.NewSignerFromKey(caKey)
+	require.NoError(t, err)
+
+	sshCert, err := keygen.GenerateUserCert(services.UserCertParams{
+		CASigner:      caSigner,
+		PublicUserKey: ssh.MarshalAuthorizedKey(privateKey.SSHPublicKey()),
+		Username:      "alice",
+		AllowedLogins: []string{"alice"},
+		TTL:           time.Hour,
+	})
+	require.NoError(t, err)
+
+	key := client.NewKey(privateKey)
+	key.KeyIndex = client.KeyIndex{
+		ProxyHost:   "localhost",
+		Username:    "alice",
+		ClusterName: "root",
+	}
+	key.Cert = sshCert
+	key.TLSCert = tlsCert
+	key.AppTLSCerts = map[string][]byte{"grafana": tlsCert}
+
+	return key
+}
+
+func TestStoredKeys(t *testing.T) {
+	clock := clockwork.NewFakeClockAt(time.Now())
+
+	newClusterWithKey := func(t *testing.T) *Cluster {
+		// The FS store is what the daemon uses; the memory store requires a
+		// fully specified key index for deletions, which Logout does not use.
+		clientStore := client.NewFSClientStore(t.TempDir())
+		require.NoError(t, clientStore.AddKey(newTestKey(t)))
+
+		cfg := client.MakeDefaultConfig()
+		cfg.WebProxyAddr = "localhost:1"
+		cfg.Username = "alice"
+		cfg.SiteName = "root"
+		cfg.ClientStore = clientStore
+		tc, err := client.NewClient(cfg)
+		require.NoError(t, err)
+
+		return &Cluster{
+			Name:          "root",
+			Log:           log,
+			clock:         clock,
+			clusterClient: tc,
+			status: client.ProfileStatus{
+				Name:       "alice",
+				ValidUntil: clock.Now().Add(time.Hour),
+			},
+		}
+	}
+
+	t.Run("listing shows the stored certificates", func(t *testing.T) {
+		c := newClusterWithKey(t)
+
+		storedKeys, err := c.StoredKeys()
+		require.NoError(t, err)
+		require.Len(t, storedKeys, 3)
+
+		// The list is sorted by type, then by name.
+		require.Equal(t, StoredKeyTypeApp, storedKeys[0].Type)
+		require.Equal(t, "grafana", storedKeys[0].Name)
+		require.Equal(t, StoredKeyTypeSSH, storedKeys[1].Type)
+		require.Equal(t, StoredKeyTypeTLS, storedKeys[2].Type)
+
+		for _, storedKey := range storedKeys {
+			require.Equal(t, "root", storedKey.ClusterName)
+			require.True(t, storedKey.ValidUntil.After(clock.Now()))
+			// Hex-encoded SHA-256.
+			require.Len(t, storedKey.Fingerprint, 64)
+		}
+	})
+
+	t.Run("deleting an app certificate removes only that certificate", func(t *testing.T) {
+		c := newClusterWithKey(t)
+
+		require.NoError(t, c.DeleteStoredKey(StoredKeyTypeApp, "grafana"))
+
+		storedKeys, err := c.StoredKeys()
+		require.NoError(t, err)
+		require.Len(t, storedKeys, 2)
+		require.Equal(t, StoredKeyTypeSSH, storedKeys[0].Type)
+		require.Equal(t, StoredKeyTypeTLS, storedKeys[1].Type)
+		require.True(t, c.Connected())
+	})
+
+	t.Run("deleting the user TLS certificate logs the user out", func(t *testing.T) {
+		c := newClusterWithKey(t)
+		require.True(t, c.Connected())
+
+		require.NoError(t, c.DeleteStoredKey(StoredKeyTypeTLS, ""))
+
+		storedKeys, err := c.StoredKeys()
+		require.NoError(t, err)
+		require.Empty(t, storedKeys)
+		require.False(t, c.Connected())
+	})
+
+	t.Run("unknown key type is rejected", func(t *testing.T) {
+		c := newClusterWithKey(t)
+
+		err := c.DeleteStoredKey("hsm", "")
+		require.True(t, trace.IsBadParameter(err))
+	})
+}
@@ -0,0 +1,78 @@
+// Teleport
+// Copyright (C) 2024 Gravitational, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package clusters
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gravitational/teleport/api/client/proto"
+	"github.com/gravitational/teleport/api/types"
+	api "github.com/gravitational/teleport/gen/proto/go/teleport/lib/teleterm/v1"
+	"github.com/gravitational/teleport/lib/auth"
+	"github.com/gravitational/teleport/lib/teleterm/api/uri"
+)
+
+// fakeKubeAuthClient returns a canned set of kube clusters for any resource listing.
+type fakeKubeAuthClient struct {
+	auth.ClientI
+
+	kubes []*types.KubernetesClusterV3
+}
+
+func (f *fakeKubeAuthClient) GetResources(ctx context.Context, req *proto.ListResourcesRequest) (*proto.ListResourcesResponse, error) {
+	resp := &proto.ListResourcesResponse{TotalCount: int32(len(f.kubes))}
+	for _, kube := range f.kubes {
+		resp.Resources = append(resp.Resources, &proto.PaginatedResource{
+			Resource: &proto.PaginatedResource_KubeCluster{KubeCluster: kube},
+		})
+	}
+	return resp, nil
+}
+
+func TestGetKubes(t *testing.T) {
+	kubeA, err := types.NewKubernetesClusterV3(types.Metadata{
+		Name:   "kubeA",
+		Labels: map[string]string{"env": "prod"},
+	}, types.KubernetesClusterSpecV3{})
+	require.NoError(t, err)
+
+	kubeB, err := types.NewKubernetesClusterV3(types.Metadata{
+		Name: "kubeB",
+	}, types.KubernetesClusterSpecV3{})
+	require.NoError(t, err)
+
+	cluster := &Cluster{
+		URI:  uri.NewClusterURI("example.com"),
+		Name: "example.com",
+	}
+	authClient := &fakeKubeAuthClient{kubes: []*types.KubernetesClusterV3{kubeA, kubeB}}
+
+	resp, err := cluster.GetKubes(context.Background(), authClient, &api.GetKubesRequest{
+		ClusterUri: cluster.URI.String(),
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, 2, resp.TotalCount)
+	require.Len(t, resp.Kubes, 2)
+	require.Equal(t, cluster.URI.AppendKube("kubeA"), resp.Kubes[0].URI)
+	require.Equal(t, "kubeA", resp.Kubes[0].KubernetesCluster.GetName())
+	require.Equal(t, map[string]string{"env": "prod"}, resp.Kubes[0].KubernetesCluster.GetStaticLabels())
+	require.Equal(t, cluster.URI.AppendKube("kubeB"), resp.Kubes[1].URI)
+}
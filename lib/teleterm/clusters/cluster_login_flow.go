@@ -0,0 +1,116 @@
+/*
+ * Teleport
+ * Copyright (C) 2024  Gravitational, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package clusters
+
+import (
+	"context"
+
+	"github.com/gravitational/trace"
+
+	"github.com/gravitational/teleport/api/constants"
+)
+
+// LoginStep identifies a single step of a login flow.
+type LoginStep string
+
+const (
+	// LoginStepCredentials collects the username and password. For clusters
+	// using OTP the code is entered together with the password, so OTP does
+	// not add a step of its own.
+	LoginStepCredentials LoginStep = "credentials"
+	// LoginStepChooseDevice has the user pick one of their registered MFA
+	// devices.
+	LoginStepChooseDevice LoginStep = "choose-device"
+	// LoginStepTap waits for the user to tap the chosen device.
+	LoginStepTap LoginStep = "tap"
+	// LoginStepDone indicates that the flow has no further steps.
+	LoginStepDone LoginStep = "done"
+)
+
+// LoginFlow tracks a user's progress through a multi-step login, so that the
+// UI can drive password → device-pick → tap sequences one step at a time.
+// Like [gateway.Gateway], it is not safe for concurrent use in itself; access
+// is gated by daemon.Service.
+type LoginFlow struct {
+	steps   []LoginStep
+	current int
+}
+
+// StartLoginFlow creates a login challenge for this cluster and derives a
+// login flow from it.
+func (c *Cluster) StartLoginFlow(ctx context.Context) (*LoginFlow, error) {
+	challenge, err := c.CreateLoginChallenge(ctx)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	flow, err := newLoginFlow(challenge)
+	return flow, trace.Wrap(err)
+}
+
+// newLoginFlow derives the step sequence from the login challenge. Clusters
+// without a second factor, or with OTP only, collapse to a single
+// credentials step.
+func newLoginFlow(challenge *LoginChallenge) (*LoginFlow, error) {
+	if !challenge.LocalAuthEnabled {
+		return nil, trace.BadParameter("local auth is disabled for this cluster")
+	}
+
+	steps := []LoginStep{LoginStepCredentials}
+	switch challenge.SecondFactor {
+	case constants.SecondFactorOff, constants.SecondFactorOTP:
+	case constants.SecondFactorU2F, constants.SecondFactorWebauthn,
+		constants.SecondFactorOn, constants.SecondFactorOptional:
+		steps = append(steps, LoginStepChooseDevice, LoginStepTap)
+	default:
+		return nil, trace.BadParameter("unsupported second factor type: %q", challenge.SecondFactor)
+	}
+
+	return &LoginFlow{steps: steps}, nil
+}
+
+// CurrentStep returns the step the flow is waiting on, or [LoginStepDone]
+// once every step has been solved.
+func (f *LoginFlow) CurrentStep() LoginStep {
+	if f.current >= len(f.steps) {
+		return LoginStepDone
+	}
+	return f.steps[f.current]
+}
+
+// Solve marks the given step as completed and returns the next step the UI
+// should drive. Steps must be solved in order; solving any other step than
+// the current one fails without advancing the flow.
+func (f *LoginFlow) Solve(step LoginStep) (LoginStep, error) {
+	current := f.CurrentStep()
+	if current == LoginStepDone {
+		return LoginStepDone, trace.BadParameter("the login flow has already completed")
+	}
+	if step != current {
+		return current, trace.BadParameter("expected step %q to be solved next, got %q", current, step)
+	}
+
+	f.current++
+	return f.CurrentStep(), nil
+}
+
+// Done reports whether every step of the flow has been solved.
+func (f *LoginFlow) Done() bool {
+	return f.CurrentStep() == LoginStepDone
+}
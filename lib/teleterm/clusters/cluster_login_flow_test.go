@@ -0,0 +1,80 @@
+/*
+ * Teleport
+ * Copyright (C) 2024  Gravitational, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package clusters
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gravitational/teleport/api/constants"
+)
+
+func TestLoginFlow(t *testing.T) {
+	t.Run("multi-step flow walks to completion in order", func(t *testing.T) {
+		flow, err := newLoginFlow(&LoginChallenge{
+			LocalAuthEnabled: true,
+			SecondFactor:     constants.SecondFactorWebauthn,
+		})
+		require.NoError(t, err)
+		require.Equal(t, LoginStepCredentials, flow.CurrentStep())
+
+		// Steps cannot be solved out of order.
+		next, err := flow.Solve(LoginStepTap)
+		require.Error(t, err)
+		require.Equal(t, LoginStepCredentials, next)
+		require.False(t, flow.Done())
+
+		next, err = flow.Solve(LoginStepCredentials)
+		require.NoError(t, err)
+		require.Equal(t, LoginStepChooseDevice, next)
+
+		next, err = flow.Solve(LoginStepChooseDevice)
+		require.NoError(t, err)
+		require.Equal(t, LoginStepTap, next)
+
+		next, err = flow.Solve(LoginStepTap)
+		require.NoError(t, err)
+		require.Equal(t, LoginStepDone, next)
+		require.True(t, flow.Done())
+
+		// A completed flow rejects further solves.
+		_, err = flow.Solve(LoginStepTap)
+		require.Error(t, err)
+	})
+
+	t.Run("single-step flow collapses to one solve", func(t *testing.T) {
+		flow, err := newLoginFlow(&LoginChallenge{
+			LocalAuthEnabled: true,
+			SecondFactor:     constants.SecondFactorOTP,
+		})
+		require.NoError(t, err)
+		require.Equal(t, LoginStepCredentials, flow.CurrentStep())
+
+		next, err := flow.Solve(LoginStepCredentials)
+		require.NoError(t, err)
+		require.Equal(t, LoginStepDone, next)
+		require.True(t, flow.Done())
+	})
+
+	t.Run("local auth disabled", func(t *testing.T) {
+		_, err := newLoginFlow(&LoginChallenge{LocalAuthEnabled: false})
+		require.Error(t, err)
+	})
+}
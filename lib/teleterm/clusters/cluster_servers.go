@@ -20,6 +20,7 @@ package clusters
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/gravitational/trace"
 
@@ -41,6 +42,38 @@ type Server struct {
 	types.Server
 }
 
+// GetServer returns a single server by URI, for detail views that don't
+// need a full listing.
+func (c *Cluster) GetServer(ctx context.Context, authClient auth.ClientI, serverURI uri.ResourceURI) (*Server, error) {
+	var server types.Server
+	serverName := serverURI.GetServerUUID()
+	err := AddMetadataToRetryableError(ctx, func() error {
+		servers, err := apiclient.GetAllResources[types.Server](ctx, authClient, &proto.ListResourcesRequest{
+			Namespace:           defaults.Namespace,
+			ResourceType:        types.KindNode,
+			PredicateExpression: fmt.Sprintf(`name == "%s"`, serverName),
+		})
+		if err != nil {
+			return trace.Wrap(err)
+		}
+
+		if len(servers) == 0 {
+			return trace.NotFound("server %q not found", serverName)
+		}
+
+		server = servers[0]
+		return nil
+	})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	return &Server{
+		URI:    c.URI.AppendServer(server.GetName()),
+		Server: server,
+	}, nil
+}
+
 // GetServers returns a paginated list of servers.
 func (c *Cluster) GetServers(ctx context.Context, r *api.GetServersRequest, authClient auth.ClientI) (*GetServersResponse, error) {
 	var (
@@ -0,0 +1,79 @@
+// Teleport
+// Copyright (C) 2024 Gravitational, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package clusters
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gravitational/trace"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gravitational/teleport/api/client/proto"
+	"github.com/gravitational/teleport/api/types"
+	"github.com/gravitational/teleport/lib/auth"
+	"github.com/gravitational/teleport/lib/teleterm/api/uri"
+)
+
+// fakeServerAuthClient serves a canned set of nodes, honoring the name
+// predicate used by get-by-name lookups.
+type fakeServerAuthClient struct {
+	auth.ClientI
+
+	servers []*types.ServerV2
+}
+
+func (f *fakeServerAuthClient) GetResources(ctx context.Context, req *proto.ListResourcesRequest) (*proto.ListResourcesResponse, error) {
+	resp := &proto.ListResourcesResponse{}
+	for _, server := range f.servers {
+		if req.PredicateExpression != "" && req.PredicateExpression != `name == "`+server.GetName()+`"` {
+			continue
+		}
+		resp.Resources = append(resp.Resources, &proto.PaginatedResource{
+			Resource: &proto.PaginatedResource_Node{Node: server},
+		})
+	}
+	resp.TotalCount = int32(len(resp.Resources))
+	return resp, nil
+}
+
+func TestGetServer(t *testing.T) {
+	server, err := types.NewServer("node-1", types.KindNode, types.ServerSpecV2{
+		Hostname: "node-1.example.com",
+	})
+	require.NoError(t, err)
+	serverV2, ok := server.(*types.ServerV2)
+	require.True(t, ok)
+
+	cluster := &Cluster{
+		URI:  uri.NewClusterURI("example.com"),
+		Name: "example.com",
+	}
+	authClient := &fakeServerAuthClient{servers: []*types.ServerV2{serverV2}}
+
+	t.Run("found", func(t *testing.T) {
+		got, err := cluster.GetServer(context.Background(), authClient, cluster.URI.AppendServer("node-1"))
+		require.NoError(t, err)
+		require.Equal(t, cluster.URI.AppendServer("node-1"), got.URI)
+		require.Equal(t, "node-1.example.com", got.GetHostname())
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := cluster.GetServer(context.Background(), authClient, cluster.URI.AppendServer("missing"))
+		require.True(t, trace.IsNotFound(err))
+	})
+}
@@ -0,0 +1,135 @@
+// Teleport
+// Copyright (C) 2024 Gravitational, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package clusters
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gravitational/teleport/api/constants"
+	"github.com/gravitational/teleport/api/types"
+	"github.com/gravitational/teleport/lib/auth"
+	"github.com/gravitational/teleport/lib/client"
+)
+
+func TestMergeSessionTraits(t *testing.T) {
+	cluster := Cluster{
+		status: client.ProfileStatus{
+			Logins:     []string{"root", "ubuntu"},
+			KubeGroups: []string{"system:masters"},
+			KubeUsers:  []string{"kube-user"},
+			Traits: map[string][]string{
+				constants.TraitDBUsers: {"postgres"},
+			},
+		},
+	}
+
+	userTraits := map[string][]string{
+		// stale values from the user resource, expected to be replaced
+		// with the values from the active certificate
+		constants.TraitLogins:  {"root"},
+		constants.TraitDBUsers: {"alice"},
+		// not present in the certificate, expected to be kept as is
+		"custom": {"value"},
+	}
+
+	merged := cluster.mergeSessionTraits(userTraits)
+	require.Equal(t, map[string][]string{
+		constants.TraitLogins:     {"root", "ubuntu"},
+		constants.TraitKubeGroups: {"system:masters"},
+		constants.TraitKubeUsers:  {"kube-user"},
+		constants.TraitDBUsers:    {"postgres"},
+		"custom":                  {"value"},
+	}, merged)
+}
+
+// fakeCurrentUserClient serves a canned user for GetCurrentUser.
+type fakeCurrentUserClient struct {
+	auth.ClientI
+
+	user types.User
+}
+
+func (f *fakeCurrentUserClient) GetCurrentUser(ctx context.Context) (types.User, error) {
+	return f.user, nil
+}
+
+func TestApplyIdentityChangesEffectiveRoles(t *testing.T) {
+	user, err := types.NewUser("alice")
+	require.NoError(t, err)
+	authClient := &fakeCurrentUserClient{user: user}
+
+	cluster := Cluster{
+		status: client.ProfileStatus{Roles: []string{"access"}},
+	}
+
+	got, err := cluster.GetUserWithTraits(context.Background(), authClient)
+	require.NoError(t, err)
+	require.Equal(t, []string{"access"}, got.GetRoles())
+
+	// Switching to the identity from an assumed access request changes the
+	// roles reported for the user.
+	cluster.ApplyIdentity(Identity{
+		Name:   "admin-request",
+		Status: client.ProfileStatus{Roles: []string{"access", "admin"}},
+	})
+
+	got, err = cluster.GetUserWithTraits(context.Background(), authClient)
+	require.NoError(t, err)
+	require.Equal(t, []string{"access", "admin"}, got.GetRoles())
+}
+
+// TestDetectClockSkew verifies that a skew between the local clock and the
+// time reported by the proxy's ping response is surfaced only once it
+// exceeds the warning threshold.
+func TestDetectClockSkew(t *testing.T) {
+	now := time.Date(2024, 4, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, test := range []struct {
+		name       string
+		serverTime time.Time
+		want       time.Duration
+	}{
+		{
+			name:       "local clock ahead",
+			serverTime: now.Add(-5 * time.Minute),
+			want:       5 * time.Minute,
+		},
+		{
+			name:       "local clock behind",
+			serverTime: now.Add(5 * time.Minute),
+			want:       -5 * time.Minute,
+		},
+		{
+			name:       "skew below the threshold is noise",
+			serverTime: now.Add(-30 * time.Second),
+			want:       0,
+		},
+		{
+			name:       "proxy did not report a time",
+			serverTime: time.Time{},
+			want:       0,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.want, DetectClockSkew(now, test.serverTime))
+		})
+	}
+}
@@ -40,6 +40,19 @@ type Config struct {
 	// WebauthnLogin allows tests to override the Webauthn Login func.
 	// Defaults to wancli.Login.
 	WebauthnLogin client.WebauthnLoginFunc
+	// ClientStore is the storage for client profiles and keys. Defaults to a
+	// filesystem-based store rooted at Dir. Embedders can provide an
+	// alternative implementation, e.g. an in-memory store.
+	ClientStore *client.Store
+	// AllowedSSOProviders optionally restricts which SSO connectors the
+	// daemon will initiate logins with. Each entry is a connector resource
+	// name. When empty, every connector offered by the cluster is allowed.
+	AllowedSSOProviders []string
+	// BrowserLauncher is an optional callback invoked with the SSO login URL
+	// instead of opening the system browser, so that the embedding app
+	// controls how the URL is presented to the user, e.g. in headless
+	// environments. When nil, the system browser is opened.
+	BrowserLauncher func(url string) error
 }
 
 // CheckAndSetDefaults checks the configuration for its validity and sets default values if needed
@@ -56,5 +69,9 @@ func (c *Config) CheckAndSetDefaults() error {
 		c.Log = logrus.WithField(teleport.ComponentKey, "conn:storage")
 	}
 
+	if c.ClientStore == nil {
+		c.ClientStore = client.NewFSClientStore(c.Dir)
+	}
+
 	return nil
 }
@@ -0,0 +1,58 @@
+/*
+ * Teleport
+ * Copyright (C) 2024  Gravitational, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package clusters
+
+import (
+	"sync"
+
+	"github.com/gravitational/trace"
+)
+
+// profileDirLocks hands out one mutex per profile directory. Profile writes
+// touch files shared between clusters (most notably the current-profile
+// pointer), so concurrent logins or syncs against the same directory must
+// not interleave their writes.
+var profileDirLocks = struct {
+	mu   sync.Mutex
+	dirs map[string]*sync.Mutex
+}{dirs: make(map[string]*sync.Mutex)}
+
+// profileDirLock returns the mutex serializing profile writes for dir.
+func profileDirLock(dir string) *sync.Mutex {
+	profileDirLocks.mu.Lock()
+	defer profileDirLocks.mu.Unlock()
+
+	lock, ok := profileDirLocks.dirs[dir]
+	if !ok {
+		lock = &sync.Mutex{}
+		profileDirLocks.dirs[dir] = lock
+	}
+
+	return lock
+}
+
+// saveProfile persists the cluster profile, serializing the write with other
+// clusters that share the same profile directory.
+func (c *Cluster) saveProfile(makeCurrent bool) error {
+	lock := profileDirLock(c.dir)
+	lock.Lock()
+	defer lock.Unlock()
+
+	return trace.Wrap(c.clusterClient.SaveProfile(makeCurrent))
+}
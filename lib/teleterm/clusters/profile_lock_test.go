@@ -0,0 +1,108 @@
+/*
+ * Teleport
+ * Copyright (C) 2024  Gravitational, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package clusters
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gravitational/teleport/api/client/webclient"
+)
+
+// TestConcurrentSaveProfile runs profile writes for two clusters sharing the
+// same directory concurrently and verifies the store stays consistent. Run
+// with the race detector to catch unsynchronized writes.
+func TestConcurrentSaveProfile(t *testing.T) {
+	ctx := context.Background()
+
+	newPingServer := func(clusterName string) *httptest.Server {
+		srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasPrefix(r.URL.Path, "/webapi/ping") {
+				err := json.NewEncoder(w).Encode(webclient.PingResponse{
+					ClusterName:   clusterName,
+					ServerVersion: "15.0.0",
+				})
+				require.NoError(t, err)
+				return
+			}
+			http.NotFound(w, r)
+		}))
+		t.Cleanup(srv.Close)
+		return srv
+	}
+
+	srvA := newPingServer("cluster-a.example.com")
+	srvB := newPingServer("cluster-b.example.com")
+
+	// The default filesystem client store is used on purpose: the test is
+	// about interleaved writes to shared profile files on disk.
+	storage, err := NewStorage(Config{
+		Dir:                t.TempDir(),
+		InsecureSkipVerify: true,
+	})
+	require.NoError(t, err)
+
+	clusterA, _, err := storage.Add(ctx, strings.TrimPrefix(srvA.URL, "https://"))
+	require.NoError(t, err)
+
+	// Profiles are keyed by proxy hostname, so reach the second server via
+	// localhost to get a distinct profile in the same directory.
+	urlB, err := url.Parse(srvB.URL)
+	require.NoError(t, err)
+	clusterB, _, err := storage.Add(ctx, net.JoinHostPort("localhost", urlB.Port()))
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for i := 0; i < cap(errs); i++ {
+		cluster := clusterA
+		if i%2 == 1 {
+			cluster = clusterB
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- cluster.saveProfile(true)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	clusters, err := storage.ReadAll()
+	require.NoError(t, err)
+	require.Len(t, clusters, 2)
+
+	// Every write made its profile current, so the pointer must name one of
+	// the two profiles rather than a torn mix.
+	current, err := storage.ClientStore.CurrentProfile()
+	require.NoError(t, err)
+	require.Contains(t, []string{clusterA.ProfileName, clusterB.ProfileName}, current)
+}
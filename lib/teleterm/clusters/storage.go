@@ -25,6 +25,7 @@ import (
 
 	"github.com/gravitational/trace"
 
+	"github.com/gravitational/teleport/api/client/webclient"
 	"github.com/gravitational/teleport/api/profile"
 	"github.com/gravitational/teleport/lib/client"
 	"github.com/gravitational/teleport/lib/teleterm/api/uri"
@@ -41,7 +42,7 @@ func NewStorage(cfg Config) (*Storage, error) {
 
 // ReadAll reads clusters from profiles
 func (s *Storage) ReadAll() ([]*Cluster, error) {
-	pfNames, err := profile.ListProfileNames(s.Dir)
+	pfNames, err := s.ClientStore.ListProfiles()
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
@@ -117,7 +118,7 @@ func (s *Storage) Remove(ctx context.Context, profileName string) error {
 // clusters.Cluster a regular struct with no extra behavior and a much smaller interface.
 // https://github.com/gravitational/teleport/issues/13278
 func (s *Storage) Add(ctx context.Context, webProxyAddress string) (*Cluster, *client.TeleportClient, error) {
-	profiles, err := profile.ListProfileNames(s.Dir)
+	profiles, err := s.ClientStore.ListProfiles()
 	if err != nil {
 		return nil, nil, trace.Wrap(err)
 	}
@@ -126,10 +127,18 @@ func (s *Storage) Add(ctx context.Context, webProxyAddress string) (*Cluster, *c
 	for _, pname := range profiles {
 		if pname == clusterName {
 			cluster, clusterClient, err := s.fromProfile(clusterName, "")
-			if err != nil {
+			if err == nil {
+				return cluster, clusterClient, nil
+			}
+			// The profile exists but cannot be loaded, most likely because a
+			// previous attempt to add this cluster failed partway through
+			// saving it. Remove the leftover so that retrying starts clean
+			// instead of tripping over the partial write again.
+			s.Log.WithError(err).Warnf("Removing unreadable profile %v.", clusterName)
+			if err := s.Remove(ctx, clusterName); err != nil {
 				return nil, nil, trace.Wrap(err)
 			}
-			return cluster, clusterClient, nil
+			break
 		}
 	}
 
@@ -179,7 +188,15 @@ func (s *Storage) addCluster(ctx context.Context, dir, webProxyAddress string) (
 		clusterLog.WithField("response", string(pingResponseJSON)).Debugln("Got ping response")
 	}
 
-	if err := clusterClient.SaveProfile(false); err != nil {
+	if err := func() error {
+		// Serialize the write with other clusters sharing this profile
+		// directory, the same way Cluster.saveProfile does.
+		lock := profileDirLock(s.Dir)
+		lock.Lock()
+		defer lock.Unlock()
+
+		return clusterClient.SaveProfile(false)
+	}(); err != nil {
 		return nil, nil, trace.Wrap(err)
 	}
 
@@ -187,15 +204,57 @@ func (s *Storage) addCluster(ctx context.Context, dir, webProxyAddress string) (
 		URI: clusterURI,
 		// The cluster name cannot be deduced from the web proxy address alone. The name of the cluster
 		// might be different than the address of the proxy.
-		Name:          pingResponse.ClusterName,
-		ProfileName:   profileName,
-		clusterClient: clusterClient,
-		dir:           s.Dir,
-		clock:         s.Clock,
-		Log:           clusterLog,
+		Name:                pingResponse.ClusterName,
+		ProfileName:         profileName,
+		clusterClient:       clusterClient,
+		dir:                 s.Dir,
+		clock:               s.Clock,
+		allowedSSOProviders: s.AllowedSSOProviders,
+		browserLauncher:     s.BrowserLauncher,
+		Log:                 clusterLog,
 	}, clusterClient, nil
 }
 
+// PreflightResult describes what a preflight ping learned about a web
+// proxy address before any profile was saved.
+type PreflightResult struct {
+	// ClusterName is the name of the cluster behind the proxy.
+	ClusterName string
+	// ProxyVersion is the Teleport version the proxy is running.
+	ProxyVersion string
+	// AuthSettings describes the authentication methods the cluster
+	// supports.
+	AuthSettings webclient.AuthenticationSettings
+}
+
+// Preflight pings the proxy at webProxyAddress and reports what it found.
+// Unlike Add, it saves no profile and leaves no other traces on disk, so
+// the UI can validate an address before actually adding the cluster.
+func (s *Storage) Preflight(ctx context.Context, webProxyAddress string) (*PreflightResult, error) {
+	if webProxyAddress == "" {
+		return nil, trace.BadParameter("cluster address is missing")
+	}
+
+	cfg := s.makeDefaultClientConfig()
+	cfg.WebProxyAddr = webProxyAddress
+
+	clusterClient, err := client.NewClient(cfg)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	pingResponse, err := clusterClient.Ping(ctx)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	return &PreflightResult{
+		ClusterName:  pingResponse.ClusterName,
+		ProxyVersion: pingResponse.ServerVersion,
+		AuthSettings: pingResponse.Auth,
+	}, nil
+}
+
 // fromProfile creates a new cluster from its profile
 func (s *Storage) fromProfile(profileName, leafClusterName string) (*Cluster, *client.TeleportClient, error) {
 	if profileName == "" {
@@ -205,10 +264,8 @@ func (s *Storage) fromProfile(profileName, leafClusterName string) (*Cluster, *c
 	clusterNameForKey := profileName
 	clusterURI := uri.NewClusterURI(profileName)
 
-	profileStore := client.NewFSProfileStore(s.Dir)
-
 	cfg := s.makeDefaultClientConfig()
-	if err := cfg.LoadProfile(profileStore, profileName); err != nil {
+	if err := cfg.LoadProfile(s.ClientStore, profileName); err != nil {
 		return nil, nil, trace.Wrap(err)
 	}
 
@@ -229,14 +286,16 @@ func (s *Storage) fromProfile(profileName, leafClusterName string) (*Cluster, *c
 	}
 
 	return &Cluster{
-		URI:           clusterURI,
-		Name:          clusterClient.SiteName,
-		ProfileName:   profileName,
-		clusterClient: clusterClient,
-		dir:           s.Dir,
-		clock:         s.Clock,
-		status:        *status,
-		Log:           s.Log.WithField("cluster", clusterURI),
+		URI:                 clusterURI,
+		Name:                clusterClient.SiteName,
+		ProfileName:         profileName,
+		clusterClient:       clusterClient,
+		dir:                 s.Dir,
+		clock:               s.Clock,
+		allowedSSOProviders: s.AllowedSSOProviders,
+		browserLauncher:     s.BrowserLauncher,
+		status:              *status,
+		Log:                 s.Log.WithField("cluster", clusterURI),
 	}, clusterClient, nil
 }
 
@@ -272,6 +331,7 @@ func (s *Storage) makeDefaultClientConfig() *client.Config {
 
 	cfg.HomePath = s.Dir
 	cfg.KeysDir = s.Dir
+	cfg.ClientStore = s.ClientStore
 	cfg.InsecureSkipVerify = s.InsecureSkipVerify
 	cfg.WebauthnLogin = s.WebauthnLogin
 	// Set AllowStdinHijack to true to enable daemon.mfaPrompt to ask for both TOTP and Webauthn at
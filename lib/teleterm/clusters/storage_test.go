@@ -0,0 +1,134 @@
+// Teleport
+// Copyright (C) 2024 Gravitational, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package clusters
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gravitational/teleport/api/client/webclient"
+	"github.com/gravitational/teleport/api/profile"
+	"github.com/gravitational/teleport/lib/client"
+	"github.com/gravitational/teleport/lib/teleterm/api/uri"
+)
+
+func TestStorageWithMemClientStore(t *testing.T) {
+	clientStore := client.NewMemClientStore()
+	err := clientStore.SaveProfile(&profile.Profile{
+		WebProxyAddr: "example.com:3080",
+		Username:     "alice",
+		SiteName:     "example.com",
+	}, true)
+	require.NoError(t, err)
+
+	storage, err := NewStorage(Config{
+		Dir:                t.TempDir(),
+		InsecureSkipVerify: true,
+		ClientStore:        clientStore,
+	})
+	require.NoError(t, err)
+
+	// The cluster read back from the in-memory store should match the saved profile.
+	clusters, err := storage.ReadAll()
+	require.NoError(t, err)
+	require.Len(t, clusters, 1)
+	require.Equal(t, "example.com", clusters[0].ProfileName)
+	require.Equal(t, uri.NewClusterURI("example.com"), clusters[0].URI)
+
+	cluster, _, err := storage.GetByURI(uri.NewClusterURI("example.com"))
+	require.NoError(t, err)
+	require.Equal(t, "example.com", cluster.Name)
+}
+
+func TestAddCleansUpPartialProfile(t *testing.T) {
+	dir := t.TempDir()
+	storage, err := NewStorage(Config{
+		Dir:                dir,
+		InsecureSkipVerify: true,
+		ClientStore:        client.NewFSClientStore(dir),
+	})
+	require.NoError(t, err)
+
+	// Simulate a partially written profile left behind by a save that
+	// failed halfway through.
+	profilePath := filepath.Join(dir, "example.com.yaml")
+	require.NoError(t, os.WriteFile(profilePath, []byte("web_proxy_addr: [truncated"), 0o660))
+
+	// The retried Add fails to reach the cluster (nothing is listening on
+	// the proxy address), but it must not trip over the partial profile
+	// and must remove it so further retries start clean.
+	_, _, err = storage.Add(context.Background(), "example.com:3080")
+	require.Error(t, err)
+	require.NotContains(t, err.Error(), "yaml")
+	require.NoFileExists(t, profilePath)
+}
+
+func TestPreflight(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/webapi/ping") {
+			http.NotFound(w, r)
+			return
+		}
+		err := json.NewEncoder(w).Encode(webclient.PingResponse{
+			ClusterName:   "example.com",
+			ServerVersion: "15.0.0",
+			Auth:          webclient.AuthenticationSettings{Type: "local"},
+		})
+		require.NoError(t, err)
+	}))
+	t.Cleanup(srv.Close)
+
+	storage, err := NewStorage(Config{
+		Dir:                t.TempDir(),
+		InsecureSkipVerify: true,
+		ClientStore:        client.NewMemClientStore(),
+	})
+	require.NoError(t, err)
+
+	t.Run("reachable proxy", func(t *testing.T) {
+		result, err := storage.Preflight(context.Background(), strings.TrimPrefix(srv.URL, "https://"))
+		require.NoError(t, err)
+		require.Equal(t, "example.com", result.ClusterName)
+		require.Equal(t, "15.0.0", result.ProxyVersion)
+		require.Equal(t, "local", result.AuthSettings.Type)
+
+		// The preflight must not leave a profile behind.
+		profiles, err := storage.ClientStore.ListProfiles()
+		require.NoError(t, err)
+		require.Empty(t, profiles)
+	})
+
+	t.Run("unreachable address", func(t *testing.T) {
+		// Grab an address that nothing is listening on.
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		addr := lis.Addr().String()
+		require.NoError(t, lis.Close())
+
+		_, err = storage.Preflight(context.Background(), addr)
+		require.Error(t, err)
+	})
+}
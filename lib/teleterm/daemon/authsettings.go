@@ -0,0 +1,101 @@
+/*
+ * Teleport
+ * Copyright (C) 2024  Gravitational, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package daemon
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gravitational/trace"
+	"github.com/jonboulle/clockwork"
+
+	"github.com/gravitational/teleport/api/client/webclient"
+	"github.com/gravitational/teleport/lib/teleterm/api/uri"
+)
+
+// defaultAuthSettingsTTL is how long cached cluster auth settings stay
+// fresh when no TTL is configured.
+const defaultAuthSettingsTTL = time.Minute
+
+// authSettingsCache caches per-cluster auth settings so that repeated
+// lookups don't ping the proxy every time, while a TTL keeps the settings
+// reasonably fresh after an admin changes the auth config on the cluster.
+type authSettingsCache struct {
+	clock clockwork.Clock
+	ttl   time.Duration
+
+	mu      sync.Mutex
+	entries map[uri.ResourceURI]authSettingsEntry
+}
+
+// authSettingsEntry is a single cached auth settings lookup.
+type authSettingsEntry struct {
+	settings  *webclient.WebConfigAuthSettings
+	fetchedAt time.Time
+}
+
+// newAuthSettingsCache returns an empty cache whose entries stay fresh for
+// ttl. A non-positive ttl falls back to defaultAuthSettingsTTL.
+func newAuthSettingsCache(clock clockwork.Clock, ttl time.Duration) *authSettingsCache {
+	if ttl <= 0 {
+		ttl = defaultAuthSettingsTTL
+	}
+	return &authSettingsCache{
+		clock:   clock,
+		ttl:     ttl,
+		entries: make(map[uri.ResourceURI]authSettingsEntry),
+	}
+}
+
+// Get returns the auth settings of the given cluster, calling fetch when
+// there is no cached entry yet, the cached entry is older than the TTL, or
+// a refresh is explicitly requested. A failed fetch leaves any previously
+// cached entry in place.
+func (c *authSettingsCache) Get(ctx context.Context, clusterURI uri.ResourceURI, refresh bool, fetch func(context.Context) (*webclient.WebConfigAuthSettings, error)) (*webclient.WebConfigAuthSettings, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[clusterURI]
+	c.mu.Unlock()
+	if ok && !refresh && c.clock.Now().Sub(entry.fetchedAt) < c.ttl {
+		return entry.settings, nil
+	}
+
+	// The fetch pings the proxy, so it runs without holding mu. Concurrent
+	// lookups for the same cluster may fetch twice, which is harmless.
+	settings, err := fetch(ctx)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[clusterURI] = authSettingsEntry{
+		settings:  settings,
+		fetchedAt: c.clock.Now(),
+	}
+	return settings, nil
+}
+
+// Evict removes the cached entry of the given cluster, if any.
+func (c *authSettingsCache) Evict(clusterURI uri.ResourceURI) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, clusterURI)
+}
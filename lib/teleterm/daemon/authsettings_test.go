@@ -0,0 +1,97 @@
+/*
+ * Teleport
+ * Copyright (C) 2024  Gravitational, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package daemon
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gravitational/trace"
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gravitational/teleport/api/client/webclient"
+	"github.com/gravitational/teleport/api/constants"
+	"github.com/gravitational/teleport/lib/teleterm/api/uri"
+)
+
+func TestAuthSettingsCache(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	clusterURI := uri.NewClusterURI("foo")
+	ttl := time.Minute
+
+	// fetch counts its calls and reports the second factor it was configured
+	// with at call time, standing in for an admin changing the auth config.
+	fetches := 0
+	secondFactor := constants.SecondFactorOTP
+	fetch := func(context.Context) (*webclient.WebConfigAuthSettings, error) {
+		fetches++
+		return &webclient.WebConfigAuthSettings{SecondFactor: secondFactor}, nil
+	}
+
+	clock := clockwork.NewFakeClock()
+	cache := newAuthSettingsCache(clock, ttl)
+
+	settings, err := cache.Get(ctx, clusterURI, false, fetch)
+	require.NoError(t, err)
+	require.Equal(t, constants.SecondFactorOTP, settings.SecondFactor)
+	require.Equal(t, 1, fetches)
+
+	// A fresh entry is served from the cache, even after the settings
+	// change on the cluster.
+	secondFactor = constants.SecondFactorWebauthn
+	settings, err = cache.Get(ctx, clusterURI, false, fetch)
+	require.NoError(t, err)
+	require.Equal(t, constants.SecondFactorOTP, settings.SecondFactor)
+	require.Equal(t, 1, fetches)
+
+	// Once the entry is older than the TTL, a lookup fetches again and
+	// observes the new settings.
+	clock.Advance(ttl)
+	settings, err = cache.Get(ctx, clusterURI, false, fetch)
+	require.NoError(t, err)
+	require.Equal(t, constants.SecondFactorWebauthn, settings.SecondFactor)
+	require.Equal(t, 2, fetches)
+
+	// An explicit refresh bypasses a fresh entry.
+	secondFactor = constants.SecondFactorOff
+	settings, err = cache.Get(ctx, clusterURI, true, fetch)
+	require.NoError(t, err)
+	require.Equal(t, constants.SecondFactorOff, settings.SecondFactor)
+	require.Equal(t, 3, fetches)
+
+	// A failed fetch leaves the cached entry in place.
+	_, err = cache.Get(ctx, clusterURI, true, func(context.Context) (*webclient.WebConfigAuthSettings, error) {
+		return nil, trace.ConnectionProblem(nil, "the proxy is unreachable")
+	})
+	require.Error(t, err)
+	settings, err = cache.Get(ctx, clusterURI, false, fetch)
+	require.NoError(t, err)
+	require.Equal(t, constants.SecondFactorOff, settings.SecondFactor)
+	require.Equal(t, 3, fetches)
+
+	// Evicting the entry forces the next lookup to fetch.
+	cache.Evict(clusterURI)
+	_, err = cache.Get(ctx, clusterURI, false, fetch)
+	require.NoError(t, err)
+	require.Equal(t, 4, fetches)
+}
@@ -0,0 +1,146 @@
+/*
+ * Teleport
+ * Copyright (C) 2024  Gravitational, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package daemon
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+
+	"github.com/gravitational/trace"
+
+	"github.com/gravitational/teleport/lib/teleterm/api/uri"
+)
+
+const (
+	// maxClusterMetadataEntries caps the number of metadata entries per
+	// cluster.
+	maxClusterMetadataEntries = 32
+	// maxClusterMetadataKeyLen caps the length of a metadata key.
+	maxClusterMetadataKeyLen = 64
+	// maxClusterMetadataValueLen caps the length of a metadata value. It is
+	// roomy enough for a free-form note.
+	maxClusterMetadataValueLen = 4096
+)
+
+// clusterMetadata stores user-defined notes and tags per root cluster. When
+// a path is configured, the metadata is persisted to disk alongside the
+// profiles so that it survives daemon restarts.
+type clusterMetadata struct {
+	path string
+
+	mu sync.Mutex
+	// entries maps a root cluster URI to its metadata.
+	entries map[string]map[string]string
+}
+
+// newClusterMetadata returns a metadata store backed by the file at path,
+// loading any previously persisted entries. An empty path keeps the metadata
+// in memory only.
+func newClusterMetadata(path string) (*clusterMetadata, error) {
+	m := &clusterMetadata{
+		path:    path,
+		entries: make(map[string]map[string]string),
+	}
+	if path == "" {
+		return m, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return m, nil
+		}
+		return nil, trace.ConvertSystemError(err)
+	}
+	if err := json.Unmarshal(data, &m.entries); err != nil {
+		return nil, trace.Wrap(err, "parsing cluster metadata %v", path)
+	}
+	return m, nil
+}
+
+// Set replaces the metadata of the given root cluster. An empty map removes
+// the cluster's metadata entirely.
+func (m *clusterMetadata) Set(clusterURI uri.ResourceURI, metadata map[string]string) error {
+	if err := validateClusterMetadata(metadata); err != nil {
+		return trace.Wrap(err)
+	}
+
+	cluster := clusterURI.GetRootClusterURI().String()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(metadata) == 0 {
+		delete(m.entries, cluster)
+	} else {
+		entry := make(map[string]string, len(metadata))
+		for k, v := range metadata {
+			entry[k] = v
+		}
+		m.entries[cluster] = entry
+	}
+
+	return trace.Wrap(m.save())
+}
+
+// Get returns the metadata of the given root cluster. The returned map is a
+// copy, never nil.
+func (m *clusterMetadata) Get(clusterURI uri.ResourceURI) map[string]string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry := m.entries[clusterURI.GetRootClusterURI().String()]
+	out := make(map[string]string, len(entry))
+	for k, v := range entry {
+		out[k] = v
+	}
+	return out
+}
+
+// save persists the metadata to disk. It must be called with mu held.
+func (m *clusterMetadata) save() error {
+	if m.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(m.entries)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.ConvertSystemError(os.WriteFile(m.path, data, 0600))
+}
+
+// validateClusterMetadata enforces the size limits on metadata entries.
+func validateClusterMetadata(metadata map[string]string) error {
+	if len(metadata) > maxClusterMetadataEntries {
+		return trace.BadParameter("cluster metadata has %d entries, maximum is %d", len(metadata), maxClusterMetadataEntries)
+	}
+	for k, v := range metadata {
+		if k == "" {
+			return trace.BadParameter("cluster metadata keys may not be empty")
+		}
+		if len(k) > maxClusterMetadataKeyLen {
+			return trace.BadParameter("cluster metadata key %q exceeds the maximum length of %d", k, maxClusterMetadataKeyLen)
+		}
+		if len(v) > maxClusterMetadataValueLen {
+			return trace.BadParameter("cluster metadata value for key %q exceeds the maximum length of %d", k, maxClusterMetadataValueLen)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+/*
+ * Teleport
+ * Copyright (C) 2024  Gravitational, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package daemon
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gravitational/trace"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gravitational/teleport/lib/client"
+	"github.com/gravitational/teleport/lib/teleterm/api/uri"
+	"github.com/gravitational/teleport/lib/teleterm/clusters"
+)
+
+// fakeListStorage serves a fixed set of root clusters.
+type fakeListStorage struct {
+	Storage
+	clusters []*clusters.Cluster
+}
+
+func (f fakeListStorage) ReadAll() ([]*clusters.Cluster, error) {
+	return f.clusters, nil
+}
+
+func (f fakeListStorage) GetByResourceURI(resourceURI uri.ResourceURI) (*clusters.Cluster, *client.TeleportClient, error) {
+	for _, c := range f.clusters {
+		if c.URI.String() == resourceURI.GetRootClusterURI().String() {
+			return c, &client.TeleportClient{}, nil
+		}
+	}
+	return nil, nil, trace.NotFound("cluster %v not found", resourceURI)
+}
+
+func TestClusterMetadata(t *testing.T) {
+	t.Parallel()
+
+	t.Run("round-trips through save and load", func(t *testing.T) {
+		metadataPath := filepath.Join(t.TempDir(), "cluster_metadata.json")
+		clusterURI := uri.NewClusterURI("foo")
+
+		m, err := newClusterMetadata(metadataPath)
+		require.NoError(t, err)
+		require.NoError(t, m.Set(clusterURI, map[string]string{
+			"note":  "production, be careful",
+			"color": "red",
+		}))
+
+		// The metadata survives a restart.
+		reloaded, err := newClusterMetadata(metadataPath)
+		require.NoError(t, err)
+		require.Equal(t, m.Get(clusterURI), reloaded.Get(clusterURI))
+		require.Equal(t, "red", reloaded.Get(clusterURI)["color"])
+
+		// An empty map removes the metadata.
+		require.NoError(t, m.Set(clusterURI, nil))
+		require.Empty(t, m.Get(clusterURI))
+	})
+
+	t.Run("size limits are enforced", func(t *testing.T) {
+		m, err := newClusterMetadata("")
+		require.NoError(t, err)
+		clusterURI := uri.NewClusterURI("foo")
+
+		tooMany := make(map[string]string)
+		for i := 0; i < maxClusterMetadataEntries+1; i++ {
+			tooMany[strings.Repeat("k", i+1)] = "v"
+		}
+		require.True(t, trace.IsBadParameter(m.Set(clusterURI, tooMany)))
+
+		require.True(t, trace.IsBadParameter(m.Set(clusterURI, map[string]string{
+			strings.Repeat("k", maxClusterMetadataKeyLen+1): "v",
+		})))
+		require.True(t, trace.IsBadParameter(m.Set(clusterURI, map[string]string{
+			"note": strings.Repeat("v", maxClusterMetadataValueLen+1),
+		})))
+		require.True(t, trace.IsBadParameter(m.Set(clusterURI, map[string]string{"": "v"})))
+	})
+
+	t.Run("appears in ListRootClusters", func(t *testing.T) {
+		clusterURI := uri.NewClusterURI("foo")
+		daemon, err := New(Config{
+			Storage: fakeListStorage{
+				clusters: []*clusters.Cluster{{URI: clusterURI, Name: "foo"}},
+			},
+			KubeconfigsDir:      t.TempDir(),
+			AgentsDir:           t.TempDir(),
+			ClusterMetadataPath: filepath.Join(t.TempDir(), "cluster_metadata.json"),
+			CreateClientCacheFunc: func(resolver ResolveClusterFunc) ClientCache {
+				return fakeClientCache{}
+			},
+		})
+		require.NoError(t, err)
+
+		require.NoError(t, daemon.SetClusterMetadata(clusterURI.String(), map[string]string{"note": "dev"}))
+
+		listed, err := daemon.ListRootClusters(context.Background())
+		require.NoError(t, err)
+		require.Len(t, listed, 1)
+		require.Equal(t, map[string]string{"note": "dev"}, listed[0].Metadata)
+
+		metadata, err := daemon.GetClusterMetadata(clusterURI.String())
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{"note": "dev"}, metadata)
+	})
+}
@@ -20,6 +20,7 @@ package daemon
 
 import (
 	"context"
+	"time"
 
 	"github.com/gravitational/trace"
 	"github.com/jonboulle/clockwork"
@@ -40,6 +41,7 @@ type Storage interface {
 
 	ReadAll() ([]*clusters.Cluster, error)
 	Add(ctx context.Context, webProxyAddress string) (*clusters.Cluster, *client.TeleportClient, error)
+	Preflight(ctx context.Context, webProxyAddress string) (*clusters.PreflightResult, error)
 	Remove(ctx context.Context, profileName string) error
 	GetByResourceURI(resourceURI uri.ResourceURI) (*clusters.Cluster, *client.TeleportClient, error)
 }
@@ -59,6 +61,22 @@ type Config struct {
 	KubeconfigsDir string
 	// AgentsDir contains agent config files and data directories for Connect My Computer.
 	AgentsDir string
+	// ConnectionHistoryPath is the file where the recent-connections list is
+	// persisted, typically kept alongside the profiles. When empty, the
+	// history is kept in memory only.
+	ConnectionHistoryPath string
+	// ClusterMetadataPath is the file where user-defined cluster notes and
+	// tags are persisted, typically kept alongside the profiles. When empty,
+	// the metadata is kept in memory only.
+	ClusterMetadataPath string
+	// DefaultClusterPath is the file where the default-cluster setting is
+	// persisted, typically kept alongside the profiles. When empty, the
+	// setting is kept in memory only.
+	DefaultClusterPath string
+	// AuthSettingsTTL is how long cached cluster auth settings stay fresh
+	// before a lookup fetches them from the proxy again. When zero, a
+	// default of one minute is used.
+	AuthSettingsTTL time.Duration
 
 	GatewayCreator GatewayCreator
 	// CreateTshdEventsClientCredsFunc lazily creates creds for the tshd events server ran by the
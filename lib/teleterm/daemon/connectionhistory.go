@@ -0,0 +1,136 @@
+/*
+ * Teleport
+ * Copyright (C) 2024  Gravitational, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package daemon
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gravitational/trace"
+	"github.com/jonboulle/clockwork"
+
+	"github.com/gravitational/teleport/lib/teleterm/api/uri"
+)
+
+// defaultConnectionHistoryCap is the maximum number of connection records
+// kept per root cluster.
+const defaultConnectionHistoryCap = 10
+
+// ConnectionRecord describes a single connection to a resource.
+type ConnectionRecord struct {
+	// ResourceURI is the URI of the resource that was connected to.
+	ResourceURI string `json:"resource_uri"`
+	// ConnectedAt is when the connection was initiated.
+	ConnectedAt time.Time `json:"connected_at"`
+}
+
+// connectionHistory is a size-capped list of recently connected resources
+// per root cluster. When a path is configured, the history is persisted to
+// disk alongside the profiles so that it survives daemon restarts.
+type connectionHistory struct {
+	clock clockwork.Clock
+	path  string
+	cap   int
+
+	mu sync.Mutex
+	// records maps a root cluster URI to its connection records, newest first.
+	records map[string][]ConnectionRecord
+}
+
+// newConnectionHistory returns a connection history backed by the file at
+// path, loading any previously persisted records. An empty path keeps the
+// history in memory only.
+func newConnectionHistory(path string, cap int, clock clockwork.Clock) (*connectionHistory, error) {
+	h := &connectionHistory{
+		clock:   clock,
+		path:    path,
+		cap:     cap,
+		records: make(map[string][]ConnectionRecord),
+	}
+	if path == "" {
+		return h, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return h, nil
+		}
+		return nil, trace.ConvertSystemError(err)
+	}
+	if err := json.Unmarshal(data, &h.records); err != nil {
+		return nil, trace.Wrap(err, "parsing connection history %v", path)
+	}
+	return h, nil
+}
+
+// Record prepends a connection to resourceURI to the history of its root
+// cluster, dropping the oldest record when the history is over its cap.
+func (h *connectionHistory) Record(resourceURI uri.ResourceURI) error {
+	cluster := resourceURI.GetRootClusterURI().String()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	records := h.records[cluster]
+	// Remove an earlier record for the same resource, so that reconnecting
+	// moves it to the front instead of filling the list with duplicates.
+	for i, r := range records {
+		if r.ResourceURI == resourceURI.String() {
+			records = append(records[:i], records[i+1:]...)
+			break
+		}
+	}
+	records = append([]ConnectionRecord{{
+		ResourceURI: resourceURI.String(),
+		ConnectedAt: h.clock.Now().UTC(),
+	}}, records...)
+	if len(records) > h.cap {
+		records = records[:h.cap]
+	}
+	h.records[cluster] = records
+
+	return trace.Wrap(h.save())
+}
+
+// RecentConnections returns the connection history of the given root
+// cluster, newest first.
+func (h *connectionHistory) RecentConnections(clusterURI uri.ResourceURI) []ConnectionRecord {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	records := h.records[clusterURI.GetRootClusterURI().String()]
+	out := make([]ConnectionRecord, len(records))
+	copy(out, records)
+	return out
+}
+
+// save persists the history to disk. It must be called with mu held.
+func (h *connectionHistory) save() error {
+	if h.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(h.records)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.ConvertSystemError(os.WriteFile(h.path, data, 0600))
+}
@@ -26,11 +26,13 @@ import (
 	"time"
 
 	"github.com/gravitational/trace"
+	"golang.org/x/sync/errgroup"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
 	"github.com/gravitational/teleport/api/client/proto"
+	"github.com/gravitational/teleport/api/client/webclient"
 	accesslistv1 "github.com/gravitational/teleport/api/gen/proto/go/teleport/accesslist/v1"
 	"github.com/gravitational/teleport/api/types"
 	"github.com/gravitational/teleport/api/types/accesslist"
@@ -77,6 +79,24 @@ func New(cfg Config) (*Service, error) {
 
 	go connectUsageReporter.Run(closeContext)
 
+	connectionHistory, err := newConnectionHistory(cfg.ConnectionHistoryPath, defaultConnectionHistoryCap, cfg.Clock)
+	if err != nil {
+		cancel()
+		return nil, trace.Wrap(err)
+	}
+
+	clusterMetadata, err := newClusterMetadata(cfg.ClusterMetadataPath)
+	if err != nil {
+		cancel()
+		return nil, trace.Wrap(err)
+	}
+
+	defaultCluster, err := newDefaultCluster(cfg.DefaultClusterPath)
+	if err != nil {
+		cancel()
+		return nil, trace.Wrap(err)
+	}
+
 	service := &Service{
 		cfg:                    &cfg,
 		closeContext:           closeContext,
@@ -84,6 +104,16 @@ func New(cfg Config) (*Service, error) {
 		gateways:               make(map[string]gateway.Gateway),
 		usageReporter:          connectUsageReporter,
 		headlessWatcherClosers: make(map[string]context.CancelFunc),
+		clientActivity:         make(map[string]time.Time),
+		connectionHistory:      connectionHistory,
+		clusterMetadata:        clusterMetadata,
+		defaultCluster:         defaultCluster,
+		authSettings:           newAuthSettingsCache(cfg.Clock, cfg.AuthSettingsTTL),
+		shutdownRequests:       make(chan struct{}, 1),
+		identities:             make(map[string]map[string]clusters.Identity),
+		activeIdentities:       make(map[string]string),
+		branding:               make(map[string]*clusters.Branding),
+		clockSkews:             make(map[string]time.Duration),
 	}
 
 	// TODO(gzdunek): The client cache should be created outside of daemon.New.
@@ -161,9 +191,86 @@ func (s *Service) ListRootClusters(ctx context.Context) ([]*clusters.Cluster, er
 		return nil, trace.Wrap(err)
 	}
 
+	defaultURI := s.defaultCluster.Get()
+	for _, cluster := range clusters {
+		cluster.Metadata = s.clusterMetadata.Get(cluster.URI)
+		cluster.IsDefault = defaultURI != "" && cluster.URI.String() == defaultURI
+		cluster.ClockSkew = s.getClockSkew(cluster.URI)
+	}
+
 	return clusters, nil
 }
 
+// setClockSkew records the clock skew measured against the given cluster's
+// proxy so that it can be surfaced when clusters are listed.
+func (s *Service) setClockSkew(clusterURI uri.ResourceURI, skew time.Duration) {
+	s.clockSkewsMu.Lock()
+	defer s.clockSkewsMu.Unlock()
+	s.clockSkews[clusterURI.GetRootClusterURI().String()] = skew
+}
+
+// getClockSkew returns the clock skew measured against the given cluster's
+// proxy by the last refresh, zero if none was measured.
+func (s *Service) getClockSkew(clusterURI uri.ResourceURI) time.Duration {
+	s.clockSkewsMu.Lock()
+	defer s.clockSkewsMu.Unlock()
+	return s.clockSkews[clusterURI.GetRootClusterURI().String()]
+}
+
+// SetDefaultCluster marks the given root cluster as the default one, which
+// the UI preselects on startup. The setting is persisted and survives
+// daemon restarts.
+func (s *Service) SetDefaultCluster(path string) error {
+	cluster, _, err := s.ResolveCluster(path)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	return trace.Wrap(s.defaultCluster.Set(cluster.URI))
+}
+
+// GetDefaultCluster returns the URI of the default cluster. An empty string
+// means no default has been set.
+func (s *Service) GetDefaultCluster() string {
+	return s.defaultCluster.Get()
+}
+
+// GetAuthSettings returns the auth settings of the given cluster, using a
+// cached copy as long as it is fresher than the configured TTL. Set refresh
+// to force a fetch from the proxy regardless of the cache.
+func (s *Service) GetAuthSettings(ctx context.Context, clusterURI string, refresh bool) (*webclient.WebConfigAuthSettings, error) {
+	cluster, _, err := s.ResolveCluster(clusterURI)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	settings, err := s.authSettings.Get(ctx, cluster.URI, refresh, cluster.SyncAuthPreference)
+	return settings, trace.Wrap(err)
+}
+
+// SetClusterMetadata replaces the user-defined notes and tags of the given
+// cluster. An empty map removes the cluster's metadata. The metadata is
+// persisted alongside the profiles and survives daemon restarts.
+func (s *Service) SetClusterMetadata(clusterURI string, metadata map[string]string) error {
+	cluster, _, err := s.ResolveCluster(clusterURI)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	return trace.Wrap(s.clusterMetadata.Set(cluster.URI, metadata))
+}
+
+// GetClusterMetadata returns the user-defined notes and tags of the given
+// cluster. The returned map is never nil.
+func (s *Service) GetClusterMetadata(clusterURI string) (map[string]string, error) {
+	cluster, _, err := s.ResolveCluster(clusterURI)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	return s.clusterMetadata.Get(cluster.URI), nil
+}
+
 // ListLeafClusters returns a list of leaf clusters
 func (s *Service) ListLeafClusters(ctx context.Context, uri string) ([]clusters.LeafCluster, error) {
 	cluster, _, err := s.ResolveCluster(uri)
@@ -199,6 +306,108 @@ func (s *Service) AddCluster(ctx context.Context, webProxyAddress string) (*clus
 	return cluster, nil
 }
 
+// PreflightCluster pings the proxy at webProxyAddress without saving a
+// profile, so the UI can validate an address before adding the cluster.
+func (s *Service) PreflightCluster(ctx context.Context, webProxyAddress string) (*clusters.PreflightResult, error) {
+	result, err := s.cfg.Storage.Preflight(ctx, webProxyAddress)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	return result, nil
+}
+
+// AddClusterResult is the outcome of adding a single cluster in a batch.
+type AddClusterResult struct {
+	// WebProxyAddress is the address the cluster was added by.
+	WebProxyAddress string
+	// Cluster is the added cluster, set only if Err is nil.
+	Cluster *clusters.Cluster
+	// Err is the error encountered while adding this cluster, if any.
+	Err error
+}
+
+// AddClusters adds profiles for multiple clusters in a single call. Each
+// address is processed independently, so a failure to add one cluster does
+// not prevent the others from being added. The returned results are in the
+// same order as the input addresses.
+func (s *Service) AddClusters(ctx context.Context, webProxyAddresses []string) []AddClusterResult {
+	results := make([]AddClusterResult, 0, len(webProxyAddresses))
+	for _, webProxyAddress := range webProxyAddresses {
+		result := AddClusterResult{WebProxyAddress: webProxyAddress}
+		cluster, _, err := s.cfg.Storage.Add(ctx, webProxyAddress)
+		if err != nil {
+			result.Err = trace.Wrap(err)
+		} else {
+			result.Cluster = cluster
+		}
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// RefreshResult is the outcome of refreshing a single cluster's status.
+type RefreshResult struct {
+	// ClusterURI is the uri of the refreshed cluster.
+	ClusterURI uri.ResourceURI
+	// Err is the error encountered while refreshing this cluster, if any.
+	// A nil Err means the cluster's proxy responded.
+	Err error
+}
+
+// maxConcurrentClusterRefreshes bounds how many clusters RefreshAll pings
+// at the same time.
+const maxConcurrentClusterRefreshes = 5
+
+// RefreshAll refreshes the connection status of every cluster by pinging
+// their proxies concurrently. Clusters are refreshed independently, so a
+// slow or unreachable cluster does not block the others; its failure is
+// reported in its own result. Callers should set a deadline on ctx to bound
+// how long the slowest refresh may take.
+func (s *Service) RefreshAll(ctx context.Context) ([]RefreshResult, error) {
+	clusters, err := s.cfg.Storage.ReadAll()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	// The default cluster is what the UI shows first, so refresh it ahead
+	// of the others.
+	if defaultURI := s.defaultCluster.Get(); defaultURI != "" {
+		for i, cluster := range clusters {
+			if cluster.URI.String() == defaultURI {
+				clusters[0], clusters[i] = clusters[i], clusters[0]
+				break
+			}
+		}
+	}
+
+	results := make([]RefreshResult, len(clusters))
+	// Deliberately not errgroup.WithContext: a failed refresh belongs in
+	// that cluster's result and must not cancel the remaining refreshes.
+	var group errgroup.Group
+	group.SetLimit(maxConcurrentClusterRefreshes)
+	for i, cluster := range clusters {
+		i, cluster := i, cluster
+		group.Go(func() error {
+			err := cluster.RefreshStatus(ctx)
+			results[i] = RefreshResult{
+				ClusterURI: cluster.URI,
+				Err:        err,
+			}
+			if err == nil {
+				s.setClockSkew(cluster.URI, cluster.ClockSkew)
+			}
+			return nil
+		})
+	}
+	// Wait never returns an error because the goroutines record failures
+	// in their results instead.
+	_ = group.Wait()
+
+	return results, nil
+}
+
 // RemoveCluster removes cluster
 func (s *Service) RemoveCluster(ctx context.Context, uri string) error {
 	cluster, _, err := s.ResolveCluster(uri)
@@ -216,6 +425,16 @@ func (s *Service) RemoveCluster(ctx context.Context, uri string) error {
 		return trace.Wrap(err)
 	}
 
+	// If the removed cluster was the default one, the setting points at
+	// nothing and must be cleared.
+	if s.defaultCluster.Get() == cluster.URI.GetRootClusterURI().String() {
+		if err := s.defaultCluster.Clear(); err != nil {
+			return trace.Wrap(err)
+		}
+	}
+
+	s.authSettings.Evict(cluster.URI)
+
 	return nil
 }
 
@@ -247,6 +466,14 @@ func (s *Service) ResolveClusterURI(uri uri.ResourceURI) (*clusters.Cluster, *cl
 	// Custom MFAPromptConstructor gets removed during the calls to Login and LoginPasswordless RPCs.
 	// Those RPCs assume that the default CLI prompt is in use.
 	clusterClient.MFAPromptConstructor = s.NewMFAPromptConstructor(cluster.URI.String())
+
+	// The profile on disk reflects the certificate from the original login.
+	// When the user has switched to another stored identity, overlay its
+	// status so that status-derived values reflect the active certificate.
+	if identity, ok := s.activeIdentity(cluster.URI); ok {
+		cluster.ApplyIdentity(identity)
+	}
+
 	return cluster, clusterClient, nil
 }
 
@@ -271,6 +498,85 @@ func (s *Service) ResolveClusterWithDetails(ctx context.Context, uri string) (*c
 	return withDetails, clusterClient, nil
 }
 
+// GetUserWithTraits returns the user for the given cluster with the traits
+// and roles from the active session merged in.
+func (s *Service) GetUserWithTraits(ctx context.Context, clusterURI string) (types.User, error) {
+	cluster, _, err := s.ResolveCluster(clusterURI)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	proxyClient, err := s.GetCachedClient(ctx, cluster.URI)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	user, err := cluster.GetUserWithTraits(ctx, proxyClient.CurrentCluster())
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	return user, nil
+}
+
+// GetDiagnostics returns a redacted diagnostic report for the given
+// cluster, suitable for attaching to support tickets.
+func (s *Service) GetDiagnostics(ctx context.Context, clusterURI string) (*clusters.Diagnostics, error) {
+	cluster, _, err := s.ResolveCluster(clusterURI)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	return cluster.Diagnostics(ctx), nil
+}
+
+// GetBranding returns the branding the given cluster's proxy serves to its
+// web UI. The result is cached per root cluster for the lifetime of the
+// daemon, since branding rarely changes and the UI asks for it often.
+func (s *Service) GetBranding(ctx context.Context, clusterURI string) (*clusters.Branding, error) {
+	rootURI, err := parseRootClusterURI(clusterURI)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	s.brandingMu.Lock()
+	branding, ok := s.branding[rootURI.String()]
+	s.brandingMu.Unlock()
+	if ok {
+		return branding, nil
+	}
+
+	cluster, _, err := s.ResolveClusterURI(rootURI)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	branding, err = cluster.GetBranding(ctx)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	s.brandingMu.Lock()
+	s.branding[rootURI.String()] = branding
+	s.brandingMu.Unlock()
+
+	return branding, nil
+}
+
+// RenewSession reissues certs for the cluster using the existing
+// credentials, without a full re-login, and returns the new cert expiry.
+// It fails with [clusters.ErrCertsExpired] when the credentials have
+// fully expired and renewal is no longer possible.
+func (s *Service) RenewSession(ctx context.Context, uri string) (time.Time, error) {
+	cluster, _, err := s.ResolveCluster(uri)
+	if err != nil {
+		return time.Time{}, trace.Wrap(err)
+	}
+
+	expires, err := cluster.RenewCerts(ctx)
+	return expires, trace.Wrap(err)
+}
+
 // ClusterLogout logs a user out from the cluster
 func (s *Service) ClusterLogout(ctx context.Context, uri string) error {
 	cluster, _, err := s.ResolveCluster(uri)
@@ -289,7 +595,43 @@ func (s *Service) ClusterLogout(ctx context.Context, uri string) error {
 	return trace.Wrap(s.ClearCachedClientsForRoot(cluster.URI))
 }
 
-// CreateGateway creates a gateway to given targetURI
+// ListStoredKeys enumerates the certificates kept by the daemon for the
+// given cluster.
+func (s *Service) ListStoredKeys(ctx context.Context, uri string) ([]clusters.StoredKey, error) {
+	cluster, _, err := s.ResolveCluster(uri)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	storedKeys, err := cluster.StoredKeys()
+	return storedKeys, trace.Wrap(err)
+}
+
+// DeleteStoredKey removes a single certificate kept by the daemon for the
+// given cluster. Removing the user TLS certificate logs the user out of the
+// cluster, so cached clients for it are dropped as well.
+func (s *Service) DeleteStoredKey(ctx context.Context, uri, keyType, keyName string) error {
+	cluster, _, err := s.ResolveCluster(uri)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	if err := cluster.DeleteStoredKey(keyType, keyName); err != nil {
+		return trace.Wrap(err)
+	}
+
+	if keyType == clusters.StoredKeyTypeTLS {
+		return trace.Wrap(s.ClearCachedClientsForRoot(cluster.URI))
+	}
+
+	return nil
+}
+
+// CreateGateway creates a gateway to given targetURI.
+//
+// Multiple gateways to the same target can coexist, each with its own URI and
+// local port; if no local port is requested, an ephemeral one is assigned.
+// Kube gateways are the exception and are shared per target.
 func (s *Service) CreateGateway(ctx context.Context, params CreateGatewayParams) (gateway.Gateway, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -327,10 +669,12 @@ func (s *Service) createGateway(ctx context.Context, params CreateGatewayParams)
 		TargetUser:            params.TargetUser,
 		TargetSubresourceName: params.TargetSubresourceName,
 		LocalPort:             params.LocalPort,
+		FriendlyName:          params.FriendlyName,
 		OnExpiredCert:         s.reissueGatewayCerts,
 		KubeconfigsDir:        s.cfg.KubeconfigsDir,
 		MFAPromptConstructor:  s.NewMFAPromptConstructor(targetURI.String()),
 		ProxyClient:           proxyClient,
+		WebAppLocalProxy:      params.WebAppLocalProxy,
 	}
 
 	gateway, err := s.cfg.GatewayCreator.CreateGateway(ctx, clusterCreateGatewayParams)
@@ -338,6 +682,13 @@ func (s *Service) createGateway(ctx context.Context, params CreateGatewayParams)
 		return nil, trace.Wrap(err)
 	}
 
+	// The caller may have given up while the gateway was being created. Close
+	// the gateway instead of registering it so that its local listener doesn't
+	// leak.
+	if ctx.Err() != nil {
+		return nil, trace.NewAggregate(trace.Wrap(ctx.Err()), gateway.Close())
+	}
+
 	go func() {
 		if err := gateway.Serve(); err != nil {
 			gateway.Log().WithError(err).Warn("Failed to handle a gateway connection.")
@@ -346,9 +697,28 @@ func (s *Service) createGateway(ctx context.Context, params CreateGatewayParams)
 
 	s.gateways[gateway.URI().String()] = gateway
 
+	if err := s.connectionHistory.Record(gateway.TargetURI()); err != nil {
+		s.cfg.Log.WithError(err).Warn("Failed to record the connection in the history.")
+	}
+
 	return gateway, nil
 }
 
+// RecordConnection adds the given resource to the recent-connections list of
+// its root cluster. Gateway connections are recorded automatically; callers
+// initiating other connection types (nodes, desktops) should record them
+// here.
+func (s *Service) RecordConnection(resourceURI uri.ResourceURI) error {
+	return trace.Wrap(s.connectionHistory.Record(resourceURI))
+}
+
+// RecentConnections returns the resources most recently connected to in the
+// given root cluster, newest first. The list is capped and survives daemon
+// restarts.
+func (s *Service) RecentConnections(clusterURI uri.ResourceURI) []ConnectionRecord {
+	return s.connectionHistory.RecentConnections(clusterURI)
+}
+
 // reissueGatewayCerts tries to reissue gateway certs. It handles asking the user to relogin and
 // per-session MFA checks.
 func (s *Service) reissueGatewayCerts(ctx context.Context, g gateway.Gateway) (tls.Certificate, error) {
@@ -576,6 +946,58 @@ func (s *Service) GetServers(ctx context.Context, req *api.GetServersRequest) (*
 	return response, nil
 }
 
+// GetServer returns a single server, for detail views that don't need
+// a full listing.
+func (s *Service) GetServer(ctx context.Context, serverURI uri.ResourceURI) (*clusters.Server, error) {
+	cluster, _, err := s.ResolveClusterURI(serverURI)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	proxyClient, err := s.GetCachedClient(ctx, cluster.URI)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	server, err := cluster.GetServer(ctx, proxyClient.CurrentCluster(), serverURI)
+	return server, trace.Wrap(err)
+}
+
+// GetDatabase returns a single database, for detail views that don't need
+// a full listing.
+func (s *Service) GetDatabase(ctx context.Context, dbURI uri.ResourceURI) (*clusters.Database, error) {
+	cluster, _, err := s.ResolveClusterURI(dbURI)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	proxyClient, err := s.GetCachedClient(ctx, cluster.URI)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	db, err := cluster.GetDatabase(ctx, proxyClient.CurrentCluster(), dbURI)
+	return db, trace.Wrap(err)
+}
+
+// GetConnectionOptions returns the parameters the current user may use to
+// connect to the given server or database, so that the connect dialog can
+// be pre-filled right after a listing.
+func (s *Service) GetConnectionOptions(ctx context.Context, resourceURI uri.ResourceURI) (*clusters.ConnectionOptions, error) {
+	cluster, _, err := s.ResolveClusterURI(resourceURI)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	proxyClient, err := s.GetCachedClient(ctx, cluster.URI)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	opts, err := cluster.GetConnectionOptions(ctx, proxyClient.CurrentCluster(), resourceURI)
+	return opts, trace.Wrap(err)
+}
+
 func (s *Service) GetRequestableRoles(ctx context.Context, req *api.GetRequestableRolesRequest) (*api.GetRequestableRolesResponse, error) {
 	cluster, _, err := s.ResolveCluster(req.ClusterUri)
 	if err != nil {
@@ -800,6 +1222,26 @@ func (s *Service) ReportUsageEvent(req *api.ReportUsageEventRequest) error {
 	return nil
 }
 
+// RequestShutdown asks the process hosting the daemon to shut down
+// gracefully. It returns immediately; the shutdown itself is carried out by
+// whoever consumes ShutdownRequests, typically by stopping the API server
+// and then the daemon. The API server accepts connections only from the
+// local user (unix socket peer creds, named pipe ACLs or mTLS), so the
+// request cannot come from an arbitrary process.
+func (s *Service) RequestShutdown() {
+	select {
+	case s.shutdownRequests <- struct{}{}:
+	default:
+		// A shutdown request is already pending.
+	}
+}
+
+// ShutdownRequests returns the channel on which requests made via
+// RequestShutdown are delivered.
+func (s *Service) ShutdownRequests() <-chan struct{} {
+	return s.shutdownRequests
+}
+
 // Stop terminates all cluster open connections
 func (s *Service) Stop() {
 	s.mu.RLock()
@@ -1095,10 +1537,53 @@ func (s *Service) findGatewayByTargetURI(targetURI uri.ResourceURI) (gateway.Gat
 // GetCachedClient returns a client from the cache if it exists,
 // otherwise it dials the remote server.
 func (s *Service) GetCachedClient(ctx context.Context, clusterURI uri.ResourceURI) (*client.ProxyClient, error) {
+	s.refreshClientIfStale(clusterURI)
 	clt, err := s.clientCache.Get(ctx, clusterURI)
 	return clt, trace.Wrap(err)
 }
 
+// staleClientThreshold is how long the daemon can go without calls to a
+// cluster before its cached client is considered suspect. A machine that
+// slept or switched networks typically comes back after a gap longer than
+// this, with TCP connections that are silently dead.
+const staleClientThreshold = 2 * time.Minute
+
+// refreshClientIfStale drops the cached client of the cluster when it
+// hasn't been used for longer than staleClientThreshold — typically after
+// the machine slept or switched networks — so that the next call dials a
+// fresh connection and re-establishes its watches instead of failing on a
+// dead one.
+func (s *Service) refreshClientIfStale(clusterURI uri.ResourceURI) {
+	rootURI := clusterURI.GetRootClusterURI()
+	now := s.cfg.Clock.Now()
+
+	s.clientActivityMu.Lock()
+	last, ok := s.clientActivity[rootURI.String()]
+	s.clientActivity[rootURI.String()] = now
+	s.clientActivityMu.Unlock()
+
+	if !ok || now.Sub(last) < staleClientThreshold {
+		return
+	}
+
+	s.cfg.Log.Infof("No calls to %v for %v, refreshing its connection.", rootURI, now.Sub(last))
+	if err := s.clientCache.ClearForRoot(rootURI); err != nil {
+		s.cfg.Log.WithError(err).Warn("Failed to clear the cached clients for a stale cluster connection.")
+	}
+}
+
+// NotifyNetworkChange is called by the host app when it detects that the
+// machine's network changed (interface switch, wake from sleep). All cached
+// clients are closed so that subsequent calls re-establish their
+// connections and watches instead of timing out on dead ones.
+func (s *Service) NotifyNetworkChange() error {
+	s.clientActivityMu.Lock()
+	s.clientActivity = make(map[string]time.Time)
+	s.clientActivityMu.Unlock()
+
+	return trace.Wrap(s.clientCache.Clear())
+}
+
 // ClearCachedClientsForRoot closes and removes clients from the cache
 // for the root cluster and its leaf clusters.
 func (s *Service) ClearCachedClientsForRoot(clusterURI uri.ResourceURI) error {
@@ -1138,6 +1623,42 @@ type Service struct {
 	headlessWatcherClosers   map[string]context.CancelFunc
 	headlessWatcherClosersMu sync.Mutex
 	clientCache              ClientCache
+	// clientActivityMu guards clientActivity.
+	clientActivityMu sync.Mutex
+	// clientActivity maps a root cluster URI to the time of the last call
+	// that used its cached client, so that clients left idle through a
+	// sleep or network change can be refreshed before use.
+	clientActivity map[string]time.Time
+	// connectionHistory keeps the persisted recent-connections list.
+	connectionHistory *connectionHistory
+	// clusterMetadata keeps the persisted user-defined notes and tags per
+	// root cluster.
+	clusterMetadata *clusterMetadata
+	// defaultCluster keeps the persisted default-cluster setting.
+	defaultCluster *defaultCluster
+	// authSettings caches per-cluster auth settings with a TTL.
+	authSettings *authSettingsCache
+	// shutdownRequests delivers requests made via RequestShutdown to the
+	// process hosting the daemon.
+	shutdownRequests chan struct{}
+	// identitiesMu guards identities and activeIdentities.
+	identitiesMu sync.Mutex
+	// identities stores the identities of each root cluster, keyed by the
+	// cluster URI and then by the identity name.
+	identities map[string]map[string]clusters.Identity
+	// activeIdentities maps a root cluster URI to the name of its active
+	// identity.
+	activeIdentities map[string]string
+	// brandingMu guards branding.
+	brandingMu sync.Mutex
+	// branding caches the branding fetched from each root cluster's proxy.
+	branding map[string]*clusters.Branding
+	// clockSkewsMu guards clockSkews.
+	clockSkewsMu sync.Mutex
+	// clockSkews maps a root cluster URI to the clock skew against its
+	// proxy measured by the last refresh, so that the warning can be
+	// surfaced when clusters are listed without another ping.
+	clockSkews map[string]time.Duration
 }
 
 type CreateGatewayParams struct {
@@ -1145,6 +1666,13 @@ type CreateGatewayParams struct {
 	TargetUser            string
 	TargetSubresourceName string
 	LocalPort             string
+	// FriendlyName is an optional user-provided name for the gateway, so that
+	// the UI can tell apart multiple gateways to the same target.
+	FriendlyName string
+	// WebAppLocalProxy starts an app gateway as a local HTTPS reverse proxy
+	// serving a browser-openable URL instead of a raw TCP tunnel. Supported
+	// only for HTTP apps.
+	WebAppLocalProxy bool
 }
 
 // waitSemaphore is a semaphore that waits for a specified duration between acquisitions.
@@ -20,15 +20,19 @@ package daemon
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/gravitational/trace"
+	"github.com/jonboulle/clockwork"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -37,6 +41,7 @@ import (
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/status"
 
+	"github.com/gravitational/teleport/api/client/webclient"
 	"github.com/gravitational/teleport/api/types"
 	api "github.com/gravitational/teleport/gen/proto/go/teleport/lib/teleterm/v1"
 	"github.com/gravitational/teleport/lib/client"
@@ -69,6 +74,7 @@ func (m *mockGatewayCreator) CreateGateway(ctx context.Context, params clusters.
 		TargetUser:            params.TargetUser,
 		TargetName:            params.TargetURI.GetDbName() + params.TargetURI.GetKubeName(),
 		TargetSubresourceName: params.TargetSubresourceName,
+		FriendlyName:          params.FriendlyName,
 		Protocol:              defaults.ProtocolPostgres,
 		Insecure:              true,
 		WebProxyAddr:          hs.Listener.Addr().String(),
@@ -159,6 +165,37 @@ func TestGatewayCRUD(t *testing.T) {
 				require.Contains(t, gatewayURIs, c.nameToGateway["gateway2"].URI())
 			},
 		},
+		{
+			name:                   "FriendlyName round-trips through create and list",
+			gatewayNamesToCreate:   []string{},
+			appendGatewayTargetURI: uri.NewClusterURI("foo").AppendDB,
+			testFunc: func(t *testing.T, c *gatewayCRUDTestContext, daemon *Service) {
+				const friendlyName = "prod-db (read replica)"
+				created, err := daemon.CreateGateway(context.Background(), CreateGatewayParams{
+					TargetURI:    uri.NewClusterURI("foo").AppendDB("named-db").String(),
+					TargetUser:   "alice",
+					FriendlyName: friendlyName,
+				})
+				require.NoError(t, err)
+				require.Equal(t, friendlyName, created.FriendlyName())
+
+				var listed gateway.Gateway
+				for _, gw := range daemon.ListGateways() {
+					if gw.URI() == created.URI() {
+						listed = gw
+					}
+				}
+				require.NotNil(t, listed, "created gateway missing from ListGateways")
+				require.Equal(t, friendlyName, listed.FriendlyName())
+
+				_, err = daemon.CreateGateway(context.Background(), CreateGatewayParams{
+					TargetURI:    uri.NewClusterURI("foo").AppendDB("other-db").String(),
+					TargetUser:   "alice",
+					FriendlyName: strings.Repeat("x", 65),
+				})
+				require.ErrorContains(t, err, "friendly name")
+			},
+		},
 		{
 			name:                   "RemoveGateway",
 			gatewayNamesToCreate:   []string{"gatewayToRemove", "gatewayToKeep"},
@@ -238,6 +275,36 @@ func TestGatewayCRUD(t *testing.T) {
 				require.Equal(t, 1, c.mockTCPPortAllocator.CallCount)
 			},
 		},
+		{
+			name:                   "CreateGateway allows multiple gateways to the same db target",
+			gatewayNamesToCreate:   []string{"db-gateway"},
+			appendGatewayTargetURI: uri.NewClusterURI("foo").AppendDB,
+			testFunc: func(t *testing.T, c *gatewayCRUDTestContext, daemon *Service) {
+				firstGateway := c.nameToGateway["db-gateway"]
+				secondGateway, err := daemon.CreateGateway(context.Background(), CreateGatewayParams{
+					TargetURI:  firstGateway.TargetURI().String(),
+					TargetUser: "alice",
+				})
+				require.NoError(t, err)
+
+				// Each gateway gets its own URI and its own ephemeral port.
+				require.NotEqual(t, firstGateway.URI(), secondGateway.URI())
+				require.NotEmpty(t, secondGateway.LocalPort())
+				require.NotEqual(t, firstGateway.LocalPort(), secondGateway.LocalPort())
+				require.Len(t, daemon.ListGateways(), 2)
+
+				// Removing one of the gateways must not affect the other.
+				err = daemon.RemoveGateway(secondGateway.URI().String())
+				require.NoError(t, err)
+
+				_, err = daemon.findGateway(secondGateway.URI().String())
+				require.True(t, trace.IsNotFound(err), "removed gateway is still registered")
+
+				foundGateway, err := daemon.findGateway(firstGateway.URI().String())
+				require.NoError(t, err)
+				require.Equal(t, firstGateway.URI(), foundGateway.URI())
+			},
+		},
 		{
 			name:                   "CreateGateway returns existing kube gateway if targetURI is the same",
 			gatewayNamesToCreate:   []string{"kube-gateway"},
@@ -302,6 +369,196 @@ func TestGatewayCRUD(t *testing.T) {
 	}
 }
 
+// cancelingGatewayCreator cancels the given context right after the gateway
+// has been created, simulating a caller that gives up while the tunnel is
+// being established.
+type cancelingGatewayCreator struct {
+	inner  *mockGatewayCreator
+	cancel context.CancelFunc
+}
+
+func (c *cancelingGatewayCreator) CreateGateway(ctx context.Context, params clusters.CreateGatewayParams) (gateway.Gateway, error) {
+	gateway, err := c.inner.CreateGateway(ctx, params)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	c.cancel()
+	return gateway, nil
+}
+
+func TestCreateGatewayHonorsContextCancellation(t *testing.T) {
+	t.Parallel()
+
+	tcpPortAllocator := &gatewaytest.MockTCPPortAllocator{}
+	gatewayCreator := &cancelingGatewayCreator{
+		inner: &mockGatewayCreator{
+			t:                t,
+			tcpPortAllocator: tcpPortAllocator,
+		},
+	}
+
+	daemon, err := New(Config{
+		Storage:        fakeStorage{},
+		GatewayCreator: gatewayCreator,
+		KubeconfigsDir: t.TempDir(),
+		AgentsDir:      t.TempDir(),
+		CreateClientCacheFunc: func(resolver ResolveClusterFunc) ClientCache {
+			return fakeClientCache{}
+		},
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	gatewayCreator.cancel = cancel
+
+	_, err = daemon.CreateGateway(ctx, CreateGatewayParams{
+		TargetURI:  uri.NewClusterURI("foo").AppendDB("db").String(),
+		TargetUser: "alice",
+	})
+	require.ErrorIs(t, err, context.Canceled)
+
+	// The canceled gateway must not be registered and its listener must have
+	// been closed, otherwise it would leak.
+	require.Empty(t, daemon.ListGateways())
+	require.Equal(t, 1, tcpPortAllocator.RecentListener().CloseCallCount)
+}
+
+// countingClientCache counts cache operations so that tests can observe
+// when cached clients get invalidated.
+type countingClientCache struct {
+	fakeClientCache
+	getCalls          int
+	clearForRootCalls int
+	clearCalls        int
+}
+
+func (c *countingClientCache) Get(ctx context.Context, clusterURI uri.ResourceURI) (*client.ProxyClient, error) {
+	c.getCalls++
+	return &client.ProxyClient{}, nil
+}
+
+func (c *countingClientCache) ClearForRoot(clusterURI uri.ResourceURI) error {
+	c.clearForRootCalls++
+	return nil
+}
+
+func (c *countingClientCache) Clear() error {
+	c.clearCalls++
+	return nil
+}
+
+// TestClientRefreshAfterIdleAndNetworkChange verifies that cached cluster
+// clients are dropped after an idle gap (sleep, network switch) and on an
+// explicit network-change notification, so that the next call dials a
+// fresh connection instead of failing on a dead one.
+func TestClientRefreshAfterIdleAndNetworkChange(t *testing.T) {
+	t.Parallel()
+
+	clock := clockwork.NewFakeClock()
+	cache := &countingClientCache{}
+	daemon, err := New(Config{
+		Clock:          clock,
+		Storage:        fakeStorage{},
+		KubeconfigsDir: t.TempDir(),
+		AgentsDir:      t.TempDir(),
+		CreateClientCacheFunc: func(resolver ResolveClusterFunc) ClientCache {
+			return cache
+		},
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	clusterURI := uri.NewClusterURI("foo")
+
+	// The first use dials a fresh client; there is nothing to refresh yet.
+	_, err = daemon.GetCachedClient(ctx, clusterURI)
+	require.NoError(t, err)
+	require.Equal(t, 0, cache.clearForRootCalls)
+
+	// Calls within the threshold keep using the cached client.
+	clock.Advance(staleClientThreshold / 2)
+	_, err = daemon.GetCachedClient(ctx, clusterURI)
+	require.NoError(t, err)
+	require.Equal(t, 0, cache.clearForRootCalls)
+
+	// After an idle gap the cached client is dropped before use.
+	clock.Advance(staleClientThreshold + time.Second)
+	_, err = daemon.GetCachedClient(ctx, clusterURI)
+	require.NoError(t, err)
+	require.Equal(t, 1, cache.clearForRootCalls)
+
+	// A network-change notification closes every cached client at once and
+	// resets the idle tracking, so the next call doesn't clear again.
+	require.NoError(t, daemon.NotifyNetworkChange())
+	require.Equal(t, 1, cache.clearCalls)
+
+	_, err = daemon.GetCachedClient(ctx, clusterURI)
+	require.NoError(t, err)
+	require.Equal(t, 1, cache.clearForRootCalls)
+	require.Equal(t, 4, cache.getCalls)
+}
+
+func TestConnectionHistory(t *testing.T) {
+	t.Parallel()
+
+	t.Run("records are capped, deduplicated and persisted", func(t *testing.T) {
+		clock := clockwork.NewFakeClock()
+		historyPath := filepath.Join(t.TempDir(), "connection_history.json")
+		clusterURI := uri.NewClusterURI("foo")
+
+		h, err := newConnectionHistory(historyPath, 3, clock)
+		require.NoError(t, err)
+		for _, name := range []string{"db1", "db2", "db3", "db4"} {
+			require.NoError(t, h.Record(clusterURI.AppendDB(name)))
+		}
+
+		records := h.RecentConnections(clusterURI)
+		require.Len(t, records, 3)
+		require.Equal(t, clusterURI.AppendDB("db4").String(), records[0].ResourceURI)
+		require.Equal(t, clusterURI.AppendDB("db2").String(), records[2].ResourceURI)
+
+		// Reconnecting moves a resource to the front without duplicating it.
+		require.NoError(t, h.Record(clusterURI.AppendDB("db2")))
+		records = h.RecentConnections(clusterURI)
+		require.Len(t, records, 3)
+		require.Equal(t, clusterURI.AppendDB("db2").String(), records[0].ResourceURI)
+
+		// The history survives a restart.
+		reloaded, err := newConnectionHistory(historyPath, 3, clock)
+		require.NoError(t, err)
+		require.Equal(t, records, reloaded.RecentConnections(clusterURI))
+	})
+
+	t.Run("creating a gateway records the connection", func(t *testing.T) {
+		daemon, err := New(Config{
+			Storage: fakeStorage{},
+			GatewayCreator: &mockGatewayCreator{
+				t:                t,
+				tcpPortAllocator: &gatewaytest.MockTCPPortAllocator{},
+			},
+			KubeconfigsDir:        t.TempDir(),
+			AgentsDir:             t.TempDir(),
+			ConnectionHistoryPath: filepath.Join(t.TempDir(), "connection_history.json"),
+			CreateClientCacheFunc: func(resolver ResolveClusterFunc) ClientCache {
+				return fakeClientCache{}
+			},
+		})
+		require.NoError(t, err)
+
+		dbURI := uri.NewClusterURI("foo").AppendDB("postgres")
+		_, err = daemon.CreateGateway(context.Background(), CreateGatewayParams{
+			TargetURI:  dbURI.String(),
+			TargetUser: "alice",
+		})
+		require.NoError(t, err)
+
+		records := daemon.RecentConnections(uri.NewClusterURI("foo"))
+		require.Len(t, records, 1)
+		require.Equal(t, dbURI.String(), records[0].ResourceURI)
+	})
+}
+
 func TestUpdateTshdEventsServerAddress(t *testing.T) {
 	homeDir := t.TempDir()
 
@@ -740,6 +997,60 @@ func (f fakeStorage) GetByResourceURI(resourceURI uri.ResourceURI) (*clusters.Cl
 	return &clusters.Cluster{}, &client.TeleportClient{}, nil
 }
 
+// fakeAddStorage records added clusters and fails on duplicate or empty
+// addresses, mimicking profile storage for batch add tests.
+type fakeAddStorage struct {
+	Storage
+	addedAddresses []string
+}
+
+func (f *fakeAddStorage) Add(ctx context.Context, webProxyAddress string) (*clusters.Cluster, *client.TeleportClient, error) {
+	if webProxyAddress == "" {
+		return nil, nil, trace.BadParameter("cluster address is missing")
+	}
+	for _, addr := range f.addedAddresses {
+		if addr == webProxyAddress {
+			return nil, nil, trace.AlreadyExists("cluster %q already exists", webProxyAddress)
+		}
+	}
+	f.addedAddresses = append(f.addedAddresses, webProxyAddress)
+	return &clusters.Cluster{ProfileName: webProxyAddress}, &client.TeleportClient{}, nil
+}
+
+func TestAddClustersReturnsPerClusterResults(t *testing.T) {
+	storage := &fakeAddStorage{}
+	daemon, err := New(Config{
+		Storage:        storage,
+		KubeconfigsDir: t.TempDir(),
+		AgentsDir:      t.TempDir(),
+		CreateClientCacheFunc: func(resolver ResolveClusterFunc) ClientCache {
+			return fakeClientCache{}
+		},
+	})
+	require.NoError(t, err)
+
+	results := daemon.AddClusters(context.Background(), []string{
+		"teleport-1.example.com",
+		"teleport-2.example.com",
+		"teleport-1.example.com",
+		"",
+	})
+	require.Len(t, results, 4)
+
+	require.NoError(t, results[0].Err)
+	require.Equal(t, "teleport-1.example.com", results[0].Cluster.ProfileName)
+	require.NoError(t, results[1].Err)
+	require.Equal(t, "teleport-2.example.com", results[1].Cluster.ProfileName)
+
+	// the failed additions must not prevent the others from being added
+	require.True(t, trace.IsAlreadyExists(results[2].Err))
+	require.Nil(t, results[2].Cluster)
+	require.True(t, trace.IsBadParameter(results[3].Err))
+	require.Nil(t, results[3].Cluster)
+
+	require.Equal(t, []string{"teleport-1.example.com", "teleport-2.example.com"}, storage.addedAddresses)
+}
+
 type fakeClientCache struct {
 	ClientCache
 }
@@ -747,3 +1058,140 @@ type fakeClientCache struct {
 func (f fakeClientCache) Get(ctx context.Context, clusterURI uri.ResourceURI) (*client.ProxyClient, error) {
 	return &client.ProxyClient{}, nil
 }
+
+func (f fakeClientCache) ClearForRoot(clusterURI uri.ResourceURI) error {
+	return nil
+}
+
+// fakeIdentityStorage resolves any cluster URI to a bare cluster, so that
+// identity tests can exercise the overlay applied during resolution.
+type fakeIdentityStorage struct {
+	Storage
+}
+
+func (f fakeIdentityStorage) GetByResourceURI(resourceURI uri.ResourceURI) (*clusters.Cluster, *client.TeleportClient, error) {
+	return &clusters.Cluster{URI: resourceURI.GetClusterURI()}, &client.TeleportClient{}, nil
+}
+
+func TestSetActiveIdentity(t *testing.T) {
+	daemon, err := New(Config{
+		Storage:        fakeIdentityStorage{},
+		KubeconfigsDir: t.TempDir(),
+		AgentsDir:      t.TempDir(),
+		CreateClientCacheFunc: func(resolver ResolveClusterFunc) ClientCache {
+			return fakeClientCache{}
+		},
+	})
+	require.NoError(t, err)
+
+	clusterURI := uri.NewClusterURI("example.com").String()
+
+	// Switching is an error before any identity is stored.
+	err = daemon.SetActiveIdentity(clusterURI, "base")
+	require.True(t, trace.IsNotFound(err))
+
+	// The first stored identity becomes active.
+	require.NoError(t, daemon.StoreIdentity(clusterURI, clusters.Identity{
+		Name:   "base",
+		Status: client.ProfileStatus{Roles: []string{"access"}},
+	}))
+	require.NoError(t, daemon.StoreIdentity(clusterURI, clusters.Identity{
+		Name:   "admin-request",
+		Status: client.ProfileStatus{Roles: []string{"access", "admin"}},
+	}))
+
+	names, active, err := daemon.ListIdentities(clusterURI)
+	require.NoError(t, err)
+	require.Equal(t, []string{"admin-request", "base"}, names)
+	require.Equal(t, "base", active)
+
+	cluster, _, err := daemon.ResolveCluster(clusterURI)
+	require.NoError(t, err)
+	require.Equal(t, []string{"access"}, cluster.GetLoggedInUser().Roles)
+
+	// Switching identities changes the roles reported by clusters resolved
+	// afterwards.
+	require.NoError(t, daemon.SetActiveIdentity(clusterURI, "admin-request"))
+	cluster, _, err = daemon.ResolveCluster(clusterURI)
+	require.NoError(t, err)
+	require.Equal(t, []string{"access", "admin"}, cluster.GetLoggedInUser().Roles)
+
+	// Unknown identities are rejected without changing the active one.
+	err = daemon.SetActiveIdentity(clusterURI, "missing")
+	require.True(t, trace.IsNotFound(err))
+	_, active, err = daemon.ListIdentities(clusterURI)
+	require.NoError(t, err)
+	require.Equal(t, "admin-request", active)
+}
+
+// TestRefreshAll verifies that cluster statuses are refreshed independently,
+// so a cluster whose proxy hangs reports its own failure without preventing
+// results for the clusters that respond.
+func TestRefreshAll(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	pingHandler := func(slow *atomic.Bool) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if slow != nil && slow.Load() {
+				// Hold the request until the client gives up.
+				<-r.Context().Done()
+				return
+			}
+			err := json.NewEncoder(w).Encode(webclient.PingResponse{})
+			assert.NoError(t, err)
+		})
+	}
+
+	fastSrv := httptest.NewTLSServer(pingHandler(nil))
+	t.Cleanup(fastSrv.Close)
+
+	var slow atomic.Bool
+	slowSrv := httptest.NewTLSServer(pingHandler(&slow))
+	t.Cleanup(slowSrv.Close)
+
+	storage, err := clusters.NewStorage(clusters.Config{
+		Dir:                t.TempDir(),
+		InsecureSkipVerify: true,
+		ClientStore:        client.NewMemClientStore(),
+	})
+	require.NoError(t, err)
+
+	fastCluster, _, err := storage.Add(ctx, strings.TrimPrefix(fastSrv.URL, "https://"))
+	require.NoError(t, err)
+
+	// Profiles are keyed by hostname, so reach the second proxy via
+	// localhost to keep the two clusters distinct.
+	_, slowPort, err := net.SplitHostPort(strings.TrimPrefix(slowSrv.URL, "https://"))
+	require.NoError(t, err)
+	slowCluster, _, err := storage.Add(ctx, net.JoinHostPort("localhost", slowPort))
+	require.NoError(t, err)
+
+	// The slow proxy answered the ping during Add and hangs from now on.
+	slow.Store(true)
+
+	daemon, err := New(Config{
+		Storage:        storage,
+		KubeconfigsDir: t.TempDir(),
+		AgentsDir:      t.TempDir(),
+		CreateClientCacheFunc: func(resolver ResolveClusterFunc) ClientCache {
+			return fakeClientCache{}
+		},
+	})
+	require.NoError(t, err)
+
+	refreshCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	results, err := daemon.RefreshAll(refreshCtx)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+
+	resultErrs := make(map[uri.ResourceURI]error, len(results))
+	for _, result := range results {
+		resultErrs[result.ClusterURI] = result.Err
+	}
+	require.NoError(t, resultErrs[fastCluster.URI])
+	require.Error(t, resultErrs[slowCluster.URI])
+}
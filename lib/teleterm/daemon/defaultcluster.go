@@ -0,0 +1,109 @@
+/*
+ * Teleport
+ * Copyright (C) 2024  Gravitational, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package daemon
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+
+	"github.com/gravitational/trace"
+
+	"github.com/gravitational/teleport/lib/teleterm/api/uri"
+)
+
+// defaultCluster stores which root cluster the UI preselects on startup.
+// When a path is configured, the setting is persisted to disk alongside the
+// profiles so that it survives daemon restarts.
+type defaultCluster struct {
+	path string
+
+	mu sync.Mutex
+	// uri is the URI of the default root cluster, empty when no default
+	// has been set.
+	uri string
+}
+
+// defaultClusterFile is the on-disk format of the default-cluster setting.
+type defaultClusterFile struct {
+	ClusterURI string `json:"clusterUri"`
+}
+
+// newDefaultCluster returns a default-cluster setting backed by the file at
+// path, loading any previously persisted value. An empty path keeps the
+// setting in memory only.
+func newDefaultCluster(path string) (*defaultCluster, error) {
+	d := &defaultCluster{path: path}
+	if path == "" {
+		return d, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return d, nil
+		}
+		return nil, trace.ConvertSystemError(err)
+	}
+	var file defaultClusterFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, trace.Wrap(err, "parsing default cluster setting %v", path)
+	}
+	d.uri = file.ClusterURI
+	return d, nil
+}
+
+// Set marks the root cluster of the given URI as the default.
+func (d *defaultCluster) Set(clusterURI uri.ResourceURI) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.uri = clusterURI.GetRootClusterURI().String()
+	return trace.Wrap(d.save())
+}
+
+// Clear removes the default-cluster setting.
+func (d *defaultCluster) Clear() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.uri = ""
+	return trace.Wrap(d.save())
+}
+
+// Get returns the URI of the default root cluster, empty when no default
+// has been set.
+func (d *defaultCluster) Get() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.uri
+}
+
+// save persists the setting to disk. It must be called with mu held.
+func (d *defaultCluster) save() error {
+	if d.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(defaultClusterFile{ClusterURI: d.uri})
+	if err != nil {
+		return trace.Wrap(err)
+	}
+	return trace.ConvertSystemError(os.WriteFile(d.path, data, 0600))
+}
@@ -0,0 +1,112 @@
+/*
+ * Teleport
+ * Copyright (C) 2024  Gravitational, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package daemon
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/gravitational/teleport/lib/teleterm/api/uri"
+	"github.com/gravitational/teleport/lib/teleterm/clusters"
+)
+
+// fakeRemoveStorage is a fakeListStorage that also accepts profile removals.
+type fakeRemoveStorage struct {
+	fakeListStorage
+}
+
+func (f *fakeRemoveStorage) Remove(ctx context.Context, profileName string) error {
+	return nil
+}
+
+func TestDefaultCluster(t *testing.T) {
+	t.Parallel()
+
+	t.Run("round-trips through save and load", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "default_cluster.json")
+		clusterURI := uri.NewClusterURI("foo")
+
+		d, err := newDefaultCluster(path)
+		require.NoError(t, err)
+		require.Empty(t, d.Get())
+		require.NoError(t, d.Set(clusterURI))
+
+		// The setting survives a restart.
+		reloaded, err := newDefaultCluster(path)
+		require.NoError(t, err)
+		require.Equal(t, clusterURI.String(), reloaded.Get())
+
+		require.NoError(t, reloaded.Clear())
+		reloaded, err = newDefaultCluster(path)
+		require.NoError(t, err)
+		require.Empty(t, reloaded.Get())
+	})
+
+	t.Run("persists and is cleared when the cluster is removed", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "default_cluster.json")
+		fooURI := uri.NewClusterURI("foo")
+		barURI := uri.NewClusterURI("bar")
+
+		newDaemon := func(t *testing.T) *Service {
+			daemon, err := New(Config{
+				Storage: &fakeRemoveStorage{fakeListStorage{
+					clusters: []*clusters.Cluster{
+						{URI: fooURI, Name: "foo"},
+						{URI: barURI, Name: "bar"},
+					},
+				}},
+				KubeconfigsDir:     t.TempDir(),
+				AgentsDir:          t.TempDir(),
+				DefaultClusterPath: path,
+				CreateClientCacheFunc: func(resolver ResolveClusterFunc) ClientCache {
+					return fakeClientCache{}
+				},
+			})
+			require.NoError(t, err)
+			return daemon
+		}
+
+		daemon := newDaemon(t)
+
+		// A cluster without a profile cannot become the default.
+		require.Error(t, daemon.SetDefaultCluster(uri.NewClusterURI("unknown").String()))
+
+		require.NoError(t, daemon.SetDefaultCluster(fooURI.String()))
+		require.Equal(t, fooURI.String(), daemon.GetDefaultCluster())
+
+		listed, err := daemon.ListRootClusters(context.Background())
+		require.NoError(t, err)
+		require.Len(t, listed, 2)
+		require.True(t, listed[0].IsDefault)
+		require.False(t, listed[1].IsDefault)
+
+		// The default survives a daemon restart.
+		daemon = newDaemon(t)
+		require.Equal(t, fooURI.String(), daemon.GetDefaultCluster())
+
+		// Removing the default cluster clears the setting, also on disk.
+		require.NoError(t, daemon.RemoveCluster(context.Background(), fooURI.String()))
+		require.Empty(t, daemon.GetDefaultCluster())
+		daemon = newDaemon(t)
+		require.Empty(t, daemon.GetDefaultCluster())
+	})
+}
@@ -0,0 +1,132 @@
+/*
+ * Teleport
+ * Copyright (C) 2024  Gravitational, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package daemon
+
+import (
+	"sort"
+
+	"github.com/gravitational/trace"
+
+	"github.com/gravitational/teleport/lib/teleterm/api/uri"
+	"github.com/gravitational/teleport/lib/teleterm/clusters"
+)
+
+// StoreIdentity saves an identity under the given root cluster so that it
+// can later be made active with SetActiveIdentity. Storing an identity with
+// the name of an existing one replaces it. The first identity stored for a
+// cluster becomes its active identity.
+func (s *Service) StoreIdentity(clusterURI string, identity clusters.Identity) error {
+	if identity.Name == "" {
+		return trace.BadParameter("identity name is missing")
+	}
+
+	rootURI, err := parseRootClusterURI(clusterURI)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	s.identitiesMu.Lock()
+	defer s.identitiesMu.Unlock()
+
+	if s.identities[rootURI.String()] == nil {
+		s.identities[rootURI.String()] = make(map[string]clusters.Identity)
+	}
+	s.identities[rootURI.String()][identity.Name] = identity
+
+	if _, ok := s.activeIdentities[rootURI.String()]; !ok {
+		s.activeIdentities[rootURI.String()] = identity.Name
+	}
+
+	return nil
+}
+
+// ListIdentities returns the names of the identities stored for the given
+// root cluster in alphabetical order, along with the name of the active one.
+func (s *Service) ListIdentities(clusterURI string) (names []string, active string, err error) {
+	rootURI, err := parseRootClusterURI(clusterURI)
+	if err != nil {
+		return nil, "", trace.Wrap(err)
+	}
+
+	s.identitiesMu.Lock()
+	defer s.identitiesMu.Unlock()
+
+	for name := range s.identities[rootURI.String()] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names, s.activeIdentities[rootURI.String()], nil
+}
+
+// SetActiveIdentity switches the active identity of the given root cluster.
+// Clusters resolved after the switch report the roles, traits and logins of
+// the new identity's certificate. Cached clients for the cluster are dropped
+// since they hold connections authenticated as the previous identity.
+func (s *Service) SetActiveIdentity(clusterURI, name string) error {
+	rootURI, err := parseRootClusterURI(clusterURI)
+	if err != nil {
+		return trace.Wrap(err)
+	}
+
+	s.identitiesMu.Lock()
+	identities, ok := s.identities[rootURI.String()]
+	if !ok {
+		s.identitiesMu.Unlock()
+		return trace.NotFound("no identities stored for cluster %v", rootURI)
+	}
+	if _, ok := identities[name]; !ok {
+		s.identitiesMu.Unlock()
+		return trace.NotFound("identity %q not found for cluster %v", name, rootURI)
+	}
+	if s.activeIdentities[rootURI.String()] == name {
+		s.identitiesMu.Unlock()
+		return nil
+	}
+	s.activeIdentities[rootURI.String()] = name
+	s.identitiesMu.Unlock()
+
+	return trace.Wrap(s.ClearCachedClientsForRoot(rootURI))
+}
+
+// activeIdentity returns the active identity stored for the root cluster of
+// the given URI, if there is one.
+func (s *Service) activeIdentity(clusterURI uri.ResourceURI) (clusters.Identity, bool) {
+	rootURI := clusterURI.GetRootClusterURI().String()
+
+	s.identitiesMu.Lock()
+	defer s.identitiesMu.Unlock()
+
+	name, ok := s.activeIdentities[rootURI]
+	if !ok {
+		return clusters.Identity{}, false
+	}
+	identity, ok := s.identities[rootURI][name]
+	return identity, ok
+}
+
+// parseRootClusterURI parses the given URI and reduces it to the URI of its
+// root cluster, under which identities are keyed.
+func parseRootClusterURI(clusterURI string) (uri.ResourceURI, error) {
+	parsed, err := uri.Parse(clusterURI)
+	if err != nil {
+		return uri.ResourceURI{}, trace.Wrap(err)
+	}
+	return parsed.GetRootClusterURI(), nil
+}
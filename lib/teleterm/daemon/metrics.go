@@ -0,0 +1,116 @@
+/*
+ * Teleport
+ * Copyright (C) 2024  Gravitational, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package daemon
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/gravitational/trace"
+)
+
+// GatewayMetrics describes the current state of a single gateway.
+type GatewayMetrics struct {
+	// URI is the URI of the gateway.
+	URI string
+	// TargetURI is the URI of the gateway target.
+	TargetURI string
+	// TargetName is the name of the gateway target.
+	TargetName string
+	// Protocol is the protocol of the gateway target.
+	Protocol string
+	// Duration is how long the gateway has been open.
+	Duration time.Duration
+	// BytesIn is the total number of bytes read from local client connections.
+	BytesIn uint64
+	// BytesOut is the total number of bytes written back to local client
+	// connections.
+	BytesOut uint64
+	// Open indicates whether the gateway is still accepting connections.
+	Open bool
+}
+
+// StreamGatewayMetrics returns a channel which periodically receives a snapshot of the metrics of
+// all gateways, sorted by gateway URI. The first snapshot is sent immediately and subsequent ones
+// on each tick of interval.
+//
+// The channel is buffered and stale snapshots are replaced with fresh ones if the receiver doesn't
+// keep up, so a slow receiver observes only the latest state rather than a growing backlog. The
+// channel is closed when ctx is canceled, which is the only way to stop the stream.
+func (s *Service) StreamGatewayMetrics(ctx context.Context, interval time.Duration) (<-chan []GatewayMetrics, error) {
+	if interval <= 0 {
+		return nil, trace.BadParameter("interval must be greater than zero")
+	}
+
+	metricsC := make(chan []GatewayMetrics, 1)
+
+	send := func(metrics []GatewayMetrics) {
+		// Drop the stale snapshot, if any, before sending the fresh one.
+		select {
+		case <-metricsC:
+		default:
+		}
+		metricsC <- metrics
+	}
+
+	go func() {
+		defer close(metricsC)
+
+		send(s.snapshotGatewayMetrics())
+
+		ticker := s.cfg.Clock.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.Chan():
+				send(s.snapshotGatewayMetrics())
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return metricsC, nil
+}
+
+func (s *Service) snapshotGatewayMetrics() []GatewayMetrics {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := s.cfg.Clock.Now()
+	metrics := make([]GatewayMetrics, 0, len(s.gateways))
+	for _, gateway := range s.gateways {
+		stats := gateway.Stats()
+		metrics = append(metrics, GatewayMetrics{
+			URI:        gateway.URI().String(),
+			TargetURI:  gateway.TargetURI().String(),
+			TargetName: gateway.TargetName(),
+			Protocol:   gateway.Protocol(),
+			Duration:   now.Sub(stats.CreatedAt),
+			BytesIn:    stats.BytesIn,
+			BytesOut:   stats.BytesOut,
+			Open:       stats.Open,
+		})
+	}
+
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].URI < metrics[j].URI })
+	return metrics
+}
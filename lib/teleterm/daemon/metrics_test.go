@@ -0,0 +1,106 @@
+/*
+ * Teleport
+ * Copyright (C) 2024  Gravitational, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package daemon
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gravitational/trace"
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gravitational/teleport/lib/teleterm/api/uri"
+	"github.com/gravitational/teleport/lib/teleterm/gateway"
+)
+
+// metricsTestGateway reports canned stats which the test can swap out between
+// ticks.
+type metricsTestGateway struct {
+	fakeGateway
+	uri   uri.ResourceURI
+	stats gateway.Stats
+}
+
+func (g *metricsTestGateway) URI() uri.ResourceURI { return g.uri }
+func (g *metricsTestGateway) Stats() gateway.Stats { return g.stats }
+
+func TestStreamGatewayMetrics(t *testing.T) {
+	t.Parallel()
+
+	clock := clockwork.NewFakeClock()
+	daemon, err := New(Config{
+		Storage:        fakeStorage{},
+		Clock:          clock,
+		KubeconfigsDir: t.TempDir(),
+		AgentsDir:      t.TempDir(),
+		CreateClientCacheFunc: func(resolver ResolveClusterFunc) ClientCache {
+			return fakeClientCache{}
+		},
+	})
+	require.NoError(t, err)
+
+	gw := &metricsTestGateway{
+		fakeGateway: fakeGateway{targetURI: uri.NewClusterURI("profile").AppendDB("db")},
+		uri:         uri.NewGatewayURI("gateway1"),
+		stats: gateway.Stats{
+			CreatedAt: clock.Now().Add(-time.Minute),
+			BytesIn:   10,
+			BytesOut:  20,
+			Open:      true,
+		},
+	}
+	daemon.gateways[gw.URI().String()] = gw
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	_, err = daemon.StreamGatewayMetrics(ctx, 0)
+	require.True(t, trace.IsBadParameter(err), "expected BadParameter, got %v", err)
+
+	metricsC, err := daemon.StreamGatewayMetrics(ctx, time.Second)
+	require.NoError(t, err)
+
+	// The first snapshot arrives without advancing the clock.
+	metrics := <-metricsC
+	require.Len(t, metrics, 1)
+	require.Equal(t, gw.URI().String(), metrics[0].URI)
+	require.Equal(t, gw.TargetURI().String(), metrics[0].TargetURI)
+	require.Equal(t, time.Minute, metrics[0].Duration)
+	require.Equal(t, uint64(10), metrics[0].BytesIn)
+	require.Equal(t, uint64(20), metrics[0].BytesOut)
+	require.True(t, metrics[0].Open)
+
+	// Subsequent snapshots observe updated counters.
+	gw.stats.BytesIn = 100
+	gw.stats.Open = false
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+
+	metrics = <-metricsC
+	require.Len(t, metrics, 1)
+	require.Equal(t, uint64(100), metrics[0].BytesIn)
+	require.False(t, metrics[0].Open)
+
+	// Canceling the context ends the stream.
+	cancel()
+	_, ok := <-metricsC
+	require.False(t, ok)
+}
@@ -54,6 +54,7 @@ func makeAppGateway(cfg Config) (Gateway, error) {
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
+	listener = a.withConnStats(listener)
 
 	middleware := &appMiddleware{
 		log: a.cfg.Log,
@@ -24,6 +24,8 @@ import (
 	"fmt"
 	"net"
 	"strconv"
+	"sync/atomic"
+	"time"
 
 	"github.com/gravitational/trace"
 	"github.com/sirupsen/logrus"
@@ -48,6 +50,10 @@ func New(cfg Config) (Gateway, error) {
 		return gateway, trace.Wrap(err)
 
 	case cfg.TargetURI.IsApp():
+		if cfg.WebAppLocalProxy {
+			gateway, err := makeWebAppGateway(cfg)
+			return gateway, trace.Wrap(err)
+		}
 		gateway, err := makeAppGateway(cfg)
 		return gateway, trace.Wrap(err)
 
@@ -88,6 +94,7 @@ func newBase(cfg Config) (*base, error) {
 		cfg:          &cfg,
 		closeContext: closeContext,
 		closeCancel:  closeCancel,
+		createdAt:    cfg.Clock.Now(),
 	}, nil
 }
 
@@ -153,6 +160,10 @@ func (b *base) TargetName() string {
 	return b.cfg.TargetName
 }
 
+func (b *base) FriendlyName() string {
+	return b.cfg.FriendlyName
+}
+
 func (b *base) Protocol() string {
 	return b.cfg.Protocol
 }
@@ -263,6 +274,13 @@ type base struct {
 	// that the local proxy is now closed and to release any resources.
 	closeContext context.Context
 	closeCancel  context.CancelFunc
+	// createdAt is the time the gateway was created, for reporting the
+	// gateway's uptime in Stats.
+	createdAt time.Time
+	// bytesIn and bytesOut count the traffic of local client connections.
+	// They are updated by countingConn, see withConnStats.
+	bytesIn  atomic.Uint64
+	bytesOut atomic.Uint64
 }
 
 type TCPPortAllocator interface {
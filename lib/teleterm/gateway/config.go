@@ -37,6 +37,10 @@ import (
 	"github.com/gravitational/teleport/lib/tlsca"
 )
 
+// maxFriendlyNameLength limits user-provided gateway names to something
+// that still renders well in the UI.
+const maxFriendlyNameLength = 64
+
 // Config describes gateway configuration
 type Config struct {
 	// URI is the gateway URI
@@ -52,6 +56,9 @@ type Config struct {
 	// TargetSubresourceName points at a subresource of the remote resource, for example a database
 	// name on a database server. It is used only for generating the CLI command.
 	TargetSubresourceName string
+	// FriendlyName is an optional user-provided name for the gateway, so that
+	// the UI can tell apart multiple gateways to the same target.
+	FriendlyName string
 
 	// Port is the gateway port
 	LocalPort string
@@ -102,6 +109,11 @@ type Config struct {
 	RootClusterCACertPoolFunc alpnproxy.GetClusterCACertPoolFunc
 	// KubeconfigsDir is the directory containing kubeconfigs for kube gateways.
 	KubeconfigsDir string
+	// WebAppLocalProxy starts an app gateway as a local HTTPS reverse proxy
+	// which injects the app certificate into proxied requests, instead of a
+	// raw TCP tunnel. The gateway then serves a URL that can be opened
+	// directly in a browser. Supported only for HTTP apps.
+	WebAppLocalProxy bool
 }
 
 // OnExpiredCertFunc is the type of a function that is called when a new downstream connection is
@@ -177,6 +189,10 @@ func (c *Config) CheckAndSetDefaults() error {
 		return trace.BadParameter("missing target name")
 	}
 
+	if len(c.FriendlyName) > maxFriendlyNameLength {
+		return trace.BadParameter("friendly name cannot exceed %v characters", maxFriendlyNameLength)
+	}
+
 	if c.TargetURI.String() == "" {
 		return trace.BadParameter("missing target URI")
 	}
@@ -63,6 +63,7 @@ func makeDatabaseGateway(cfg Config) (Database, error) {
 	if err != nil {
 		return nil, trace.Wrap(err)
 	}
+	listener = d.withConnStats(listener)
 
 	localProxyConfig := alpnproxy.LocalProxyConfig{
 		InsecureSkipVerify:      d.cfg.Insecure,
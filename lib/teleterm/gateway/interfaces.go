@@ -40,6 +40,9 @@ type Gateway interface {
 	URI() uri.ResourceURI
 	TargetURI() uri.ResourceURI
 	TargetName() string
+	// FriendlyName is an optional user-provided name for the gateway, so
+	// that the UI can tell apart multiple gateways to the same target.
+	FriendlyName() string
 	Protocol() string
 	TargetUser() string
 	TargetSubresourceName() string
@@ -48,6 +51,8 @@ type Gateway interface {
 	LocalAddress() string
 	LocalPort() string
 	LocalPortInt() int
+	// Stats returns a snapshot of the gateway's traffic counters.
+	Stats() Stats
 }
 
 // AsDatabase converts provided gateway to a database gateway.
@@ -141,6 +141,9 @@ func (k *kube) makeForwardProxyForKube() error {
 	if err != nil {
 		return trace.Wrap(err)
 	}
+	// The forward proxy listener is the user-facing one, so count client
+	// traffic there.
+	listener = k.withConnStats(listener)
 
 	// Use provided listener with user configured port for the forward proxy.
 	k.forwardProxy, err = alpnproxy.NewKubeForwardProxy(alpnproxy.KubeForwardProxyConfig{
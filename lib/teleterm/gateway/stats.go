@@ -0,0 +1,84 @@
+/*
+ * Teleport
+ * Copyright (C) 2024  Gravitational, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package gateway
+
+import (
+	"net"
+	"time"
+)
+
+// Stats is a point-in-time snapshot of the gateway's traffic counters.
+type Stats struct {
+	// CreatedAt is the time the gateway was created.
+	CreatedAt time.Time
+	// BytesIn is the total number of bytes read from local client
+	// connections.
+	BytesIn uint64
+	// BytesOut is the total number of bytes written back to local client
+	// connections.
+	BytesOut uint64
+	// Open indicates whether the gateway is still accepting connections.
+	Open bool
+}
+
+// Stats returns a snapshot of the gateway's traffic counters.
+func (b *base) Stats() Stats {
+	return Stats{
+		CreatedAt: b.createdAt,
+		BytesIn:   b.bytesIn.Load(),
+		BytesOut:  b.bytesOut.Load(),
+		Open:      b.closeContext.Err() == nil,
+	}
+}
+
+// withConnStats wraps the user-facing listener of the gateway so that all
+// accepted connections update the gateway's traffic counters.
+func (b *base) withConnStats(listener net.Listener) net.Listener {
+	return &countingListener{Listener: listener, base: b}
+}
+
+type countingListener struct {
+	net.Listener
+	base *base
+}
+
+func (l *countingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &countingConn{Conn: conn, base: l.base}, nil
+}
+
+type countingConn struct {
+	net.Conn
+	base *base
+}
+
+func (c *countingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.base.bytesIn.Add(uint64(n))
+	return n, err
+}
+
+func (c *countingConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.base.bytesOut.Add(uint64(n))
+	return n, err
+}
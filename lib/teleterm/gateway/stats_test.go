@@ -0,0 +1,75 @@
+/*
+ * Teleport
+ * Copyright (C) 2024  Gravitational, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package gateway
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStats(t *testing.T) {
+	t.Parallel()
+
+	closeContext, closeCancel := context.WithCancel(context.Background())
+	b := &base{
+		closeContext: closeContext,
+		closeCancel:  closeCancel,
+		createdAt:    time.Now(),
+	}
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	// Echo any client traffic back through the counting listener, the same way
+	// the local proxy pipes traffic between the client and the remote proxy.
+	countingListener := b.withConnStats(listener)
+	go func() {
+		conn, err := countingListener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.Copy(conn, conn)
+	}()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	payload := []byte("ping")
+	_, err = conn.Write(payload)
+	require.NoError(t, err)
+	_, err = io.ReadFull(conn, make([]byte, len(payload)))
+	require.NoError(t, err)
+
+	stats := b.Stats()
+	require.Equal(t, b.createdAt, stats.CreatedAt)
+	require.Equal(t, uint64(len(payload)), stats.BytesIn)
+	require.Equal(t, uint64(len(payload)), stats.BytesOut)
+	require.True(t, stats.Open)
+
+	closeCancel()
+	require.False(t, b.Stats().Open)
+}
@@ -0,0 +1,131 @@
+// Teleport
+// Copyright (C) 2024 Gravitational, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gateway
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+
+	"github.com/gravitational/trace"
+
+	apiclient "github.com/gravitational/teleport/api/client"
+	alpncommon "github.com/gravitational/teleport/lib/srv/alpnproxy/common"
+	"github.com/gravitational/teleport/lib/utils"
+	"github.com/gravitational/teleport/lib/utils/cert"
+)
+
+// webApp is a gateway that serves a Teleport-protected web application over a
+// local HTTPS reverse proxy, so that the target can be opened directly in a
+// browser at the gateway's URL. The app certificate obtained when the gateway
+// was created is injected into every proxied request; the Proxy Service routes
+// the request to the app session encoded in the certificate.
+type webApp struct {
+	*base
+	httpServer  *http.Server
+	tlsListener net.Listener
+}
+
+// LocalProxyURL returns the URL of the local proxy.
+func (w *webApp) LocalProxyURL() string {
+	proxyURL := url.URL{
+		Scheme: "https",
+		Host:   w.LocalAddress() + ":" + w.LocalPort(),
+	}
+	return proxyURL.String()
+}
+
+func makeWebAppGateway(cfg Config) (Gateway, error) {
+	base, err := newBase(cfg)
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	w := &webApp{base: base}
+
+	if alpnProtocolForApp(w.cfg.Protocol) != alpncommon.ProtocolHTTP {
+		return nil, trace.BadParameter("local web proxies are supported only for HTTP apps, app %q uses protocol %q", w.cfg.TargetName, w.cfg.Protocol)
+	}
+
+	listener, err := w.cfg.makeListener()
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+	listener = w.withConnStats(listener)
+
+	// Browsers expect web apps to be served over HTTPS, so the local listener
+	// terminates TLS with a self-signed certificate for the local address.
+	localCreds, err := cert.GenerateSelfSignedCert([]string{w.cfg.LocalAddress}, nil)
+	if err != nil {
+		return nil, trace.NewAggregate(err, listener.Close())
+	}
+	localCert, err := tls.X509KeyPair(localCreds.Cert, localCreds.PrivateKey)
+	if err != nil {
+		return nil, trace.NewAggregate(err, listener.Close())
+	}
+	w.tlsListener = tls.NewListener(listener, &tls.Config{Certificates: []tls.Certificate{localCert}})
+	w.onCloseFuncs = append(w.onCloseFuncs, func() error {
+		if err := w.tlsListener.Close(); err != nil && !utils.IsUseOfClosedNetworkError(err) {
+			return trace.Wrap(err)
+		}
+		return nil
+	})
+
+	proxy := httputil.NewSingleHostReverseProxy(&url.URL{
+		Scheme: "https",
+		Host:   w.cfg.WebProxyAddr,
+	})
+	proxy.Transport = &http.Transport{
+		DialTLSContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := apiclient.DialALPN(ctx, w.cfg.WebProxyAddr, apiclient.ALPNDialerConfig{
+				ALPNConnUpgradeRequired: w.cfg.TLSRoutingConnUpgradeRequired,
+				GetClusterCAs:           apiclient.GetClusterCAsFunc(w.cfg.RootClusterCACertPoolFunc),
+				TLSConfig: &tls.Config{
+					NextProtos:         alpncommon.ProtocolsToString([]alpncommon.Protocol{alpncommon.ProtocolHTTP}),
+					InsecureSkipVerify: w.cfg.Insecure,
+					Certificates:       []tls.Certificate{w.cfg.Cert},
+				},
+			})
+			return conn, trace.Wrap(err)
+		},
+	}
+	w.httpServer = &http.Server{Handler: proxy}
+
+	return w, nil
+}
+
+// Serve starts the local HTTPS reverse proxy. Blocks until closeContext is
+// canceled.
+func (w *webApp) Serve() error {
+	w.cfg.Log.Info("Gateway is open.")
+	defer w.cfg.Log.Info("Gateway has closed.")
+
+	go func() {
+		<-w.closeContext.Done()
+		w.httpServer.Close()
+	}()
+
+	err := w.httpServer.Serve(w.tlsListener)
+	if err != nil && !errors.Is(err, http.ErrServerClosed) && !utils.IsUseOfClosedNetworkError(err) {
+		return trace.Wrap(err)
+	}
+	return nil
+}
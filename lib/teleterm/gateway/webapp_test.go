@@ -0,0 +1,112 @@
+// Teleport
+// Copyright (C) 2024 Gravitational, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gateway
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gravitational/trace"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gravitational/teleport/api/types"
+	"github.com/gravitational/teleport/lib/teleterm/api/uri"
+	"github.com/gravitational/teleport/lib/teleterm/gatewaytest"
+	"github.com/gravitational/teleport/lib/utils/cert"
+)
+
+func TestWebAppGateway(t *testing.T) {
+	backend := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "hello from app")
+	}))
+	t.Cleanup(backend.Close)
+
+	gateway, err := New(Config{
+		TargetName:       "grafana",
+		TargetURI:        uri.NewClusterURI("foo").AppendApp("grafana"),
+		Protocol:         "HTTP",
+		Cert:             mustGenSelfSignedCert(t),
+		Insecure:         true,
+		WebProxyAddr:     backend.Listener.Addr().String(),
+		TCPPortAllocator: &gatewaytest.MockTCPPortAllocator{},
+		WebAppLocalProxy: true,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		if err := gateway.Close(); err != nil {
+			t.Logf("Ignoring error from gateway.Close() during cleanup, it appears the gateway was already closed. The error was: %s", err)
+		}
+	})
+
+	app, err := AsApp(gateway)
+	require.NoError(t, err)
+	require.Equal(t, "https://"+net.JoinHostPort(gateway.LocalAddress(), gateway.LocalPort()), app.LocalProxyURL())
+
+	serveErr := make(chan error)
+	go func() {
+		serveErr <- gateway.Serve()
+	}()
+
+	// The listener was opened by New, so connections made before Serve gets
+	// a chance to run are simply queued rather than refused.
+	client := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+		Timeout: 10 * time.Second,
+	}
+	resp, err := client.Get(app.LocalProxyURL())
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "hello from app", string(body))
+
+	require.NoError(t, gateway.Close())
+	require.NoError(t, <-serveErr)
+}
+
+func TestWebAppGatewayRejectsTCPApps(t *testing.T) {
+	_, err := New(Config{
+		TargetName:       "tcp-app",
+		TargetURI:        uri.NewClusterURI("foo").AppendApp("tcp-app"),
+		Protocol:         types.ApplicationProtocolTCP,
+		Cert:             mustGenSelfSignedCert(t),
+		Insecure:         true,
+		WebProxyAddr:     "localhost:3080",
+		TCPPortAllocator: &gatewaytest.MockTCPPortAllocator{},
+		WebAppLocalProxy: true,
+	})
+	require.True(t, trace.IsBadParameter(err), "expected BadParameter, got %v", err)
+	require.ErrorContains(t, err, "only for HTTP apps")
+}
+
+func mustGenSelfSignedCert(t *testing.T) tls.Certificate {
+	t.Helper()
+	creds, err := cert.GenerateSelfSignedCert([]string{"localhost"}, nil)
+	require.NoError(t, err)
+	tlsCert, err := tls.X509KeyPair(creds.Cert, creds.PrivateKey)
+	require.NoError(t, err)
+	return tlsCert
+}
@@ -20,17 +20,30 @@ import (
 	"context"
 	"slices"
 	"sync"
+	"time"
 
 	"github.com/gravitational/trace"
+	"github.com/jonboulle/clockwork"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/sync/singleflight"
 
 	"github.com/gravitational/teleport"
+	"github.com/gravitational/teleport/api/utils/retryutils"
 	"github.com/gravitational/teleport/lib/client"
 	"github.com/gravitational/teleport/lib/teleterm/api/uri"
 	"github.com/gravitational/teleport/lib/teleterm/clusters"
 )
 
+const (
+	// connectRetryBase is the starting backoff between dial attempts.
+	connectRetryBase = 500 * time.Millisecond
+	// connectRetryMax caps the backoff between dial attempts.
+	connectRetryMax = 5 * time.Second
+	// connectMaxAttempts is the number of dial attempts made before giving
+	// up and returning the last error.
+	connectMaxAttempts = 3
+)
+
 // Cache stores clients keyed by cluster URI.
 // Safe for concurrent access.
 // Closes all clients and wipes the cache on Clear.
@@ -47,16 +60,36 @@ type Cache struct {
 
 type ResolveClusterFunc func(uri uri.ResourceURI) (*clusters.Cluster, *client.TeleportClient, error)
 
+// ConnectToProxyFunc makes a single dial attempt to the proxy of the given
+// cluster client.
+type ConnectToProxyFunc func(ctx context.Context, clusterClient *client.TeleportClient) (*client.ProxyClient, error)
+
 // Config describes the client cache configuration.
 type Config struct {
 	ResolveClusterFunc ResolveClusterFunc
 	Log                logrus.FieldLogger
+	// Clock is used to control backoff timing in tests.
+	Clock clockwork.Clock
+	// ConnectToProxyFunc dials the proxy of a cluster. Defaults to
+	// [*client.TeleportClient.ConnectToProxy]. Tests use it to simulate
+	// dial failures.
+	ConnectToProxyFunc ConnectToProxyFunc
 }
 
 func (c *Config) checkAndSetDefaults() {
 	if c.Log == nil {
 		c.Log = logrus.WithField(teleport.ComponentKey, "clientcache")
 	}
+	if c.Clock == nil {
+		c.Clock = clockwork.NewRealClock()
+	}
+	if c.ConnectToProxyFunc == nil {
+		c.ConnectToProxyFunc = func(ctx context.Context, clusterClient *client.TeleportClient) (*client.ProxyClient, error) {
+			//nolint:staticcheck // SA1019. TODO(gzdunek): Update to use client.ClusterClient.
+			proxyClient, err := clusterClient.ConnectToProxy(ctx)
+			return proxyClient, trace.Wrap(err)
+		}
+	}
 }
 
 // New creates an instance of Cache.
@@ -85,8 +118,7 @@ func (c *Cache) Get(ctx context.Context, clusterURI uri.ResourceURI) (*client.Pr
 
 		var newProxyClient *client.ProxyClient
 		if err := clusters.AddMetadataToRetryableError(ctx, func() error {
-			//nolint:staticcheck // SA1019. TODO(gzdunek): Update to use client.ClusterClient.
-			proxyClient, err := clusterClient.ConnectToProxy(ctx)
+			proxyClient, err := c.connectWithRetry(ctx, clusterClient)
 			if err != nil {
 				return trace.Wrap(err)
 			}
@@ -117,6 +149,43 @@ func (c *Cache) Get(ctx context.Context, clusterURI uri.ResourceURI) (*client.Pr
 	return clt, nil
 }
 
+// connectWithRetry dials the proxy, retrying with jittered exponential
+// backoff when the connection cannot be established. It returns the last
+// dial error once the retry budget or the context is exhausted.
+func (c *Cache) connectWithRetry(ctx context.Context, clusterClient *client.TeleportClient) (*client.ProxyClient, error) {
+	retry, err := retryutils.NewRetryV2(retryutils.RetryV2Config{
+		Driver: retryutils.NewExponentialDriver(connectRetryBase),
+		Max:    connectRetryMax,
+		Jitter: retryutils.NewHalfJitter(),
+		Clock:  c.cfg.Clock,
+	})
+	if err != nil {
+		return nil, trace.Wrap(err)
+	}
+
+	var lastErr error
+	for attempt := 1; ; attempt++ {
+		proxyClient, err := c.cfg.ConnectToProxyFunc(ctx, clusterClient)
+		if err == nil {
+			return proxyClient, nil
+		}
+		lastErr = err
+		if attempt == connectMaxAttempts {
+			break
+		}
+
+		c.cfg.Log.WithError(err).Debugf("Failed to connect to proxy on attempt %v of %v, retrying.", attempt, connectMaxAttempts)
+		retry.Inc()
+		select {
+		case <-retry.After():
+		case <-ctx.Done():
+			return nil, trace.NewAggregate(lastErr, ctx.Err())
+		}
+	}
+
+	return nil, trace.Wrap(lastErr)
+}
+
 // ClearForRoot closes and removes clients from the cache
 // for the root cluster and its leaf clusters.
 func (c *Cache) ClearForRoot(clusterURI uri.ResourceURI) error {
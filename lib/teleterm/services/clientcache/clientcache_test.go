@@ -0,0 +1,128 @@
+// Teleport
+// Copyright (C) 2024 Gravitational, Inc.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package clientcache
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gravitational/trace"
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gravitational/teleport/lib/client"
+	"github.com/gravitational/teleport/lib/teleterm/api/uri"
+	"github.com/gravitational/teleport/lib/teleterm/clusters"
+)
+
+func TestGetRetriesDroppedConnections(t *testing.T) {
+	clusterURI := uri.NewClusterURI("example.com")
+	resolveCluster := func(resourceURI uri.ResourceURI) (*clusters.Cluster, *client.TeleportClient, error) {
+		return &clusters.Cluster{URI: clusterURI}, &client.TeleportClient{}, nil
+	}
+
+	t.Run("recovers after a couple of attempts", func(t *testing.T) {
+		clock := clockwork.NewFakeClock()
+		attempts := 0
+		cache := New(Config{
+			ResolveClusterFunc: resolveCluster,
+			Clock:              clock,
+			ConnectToProxyFunc: func(ctx context.Context, clusterClient *client.TeleportClient) (*client.ProxyClient, error) {
+				attempts++
+				if attempts < 3 {
+					return nil, trace.ConnectionProblem(nil, "connection dropped")
+				}
+				return &client.ProxyClient{}, nil
+			},
+		})
+
+		type result struct {
+			clt *client.ProxyClient
+			err error
+		}
+		resultC := make(chan result, 1)
+		go func() {
+			clt, err := cache.Get(context.Background(), clusterURI)
+			resultC <- result{clt: clt, err: err}
+		}()
+
+		// Release the backoff timer between the failed attempts.
+		for i := 0; i < 2; i++ {
+			clock.BlockUntil(1)
+			clock.Advance(connectRetryMax)
+		}
+
+		res := <-resultC
+		require.NoError(t, res.err)
+		require.NotNil(t, res.clt)
+		require.Equal(t, 3, attempts)
+	})
+
+	t.Run("returns the last error when the retry budget is exhausted", func(t *testing.T) {
+		clock := clockwork.NewFakeClock()
+		attempts := 0
+		cache := New(Config{
+			ResolveClusterFunc: resolveCluster,
+			Clock:              clock,
+			ConnectToProxyFunc: func(ctx context.Context, clusterClient *client.TeleportClient) (*client.ProxyClient, error) {
+				attempts++
+				return nil, trace.ConnectionProblem(nil, "connection dropped")
+			},
+		})
+
+		errC := make(chan error, 1)
+		go func() {
+			_, err := cache.Get(context.Background(), clusterURI)
+			errC <- err
+		}()
+
+		for i := 0; i < connectMaxAttempts-1; i++ {
+			clock.BlockUntil(1)
+			clock.Advance(connectRetryMax)
+		}
+
+		err := <-errC
+		require.ErrorContains(t, err, "connection dropped")
+		require.Equal(t, connectMaxAttempts, attempts)
+	})
+
+	t.Run("gives up when the context is canceled", func(t *testing.T) {
+		clock := clockwork.NewFakeClock()
+		ctx, cancel := context.WithCancel(context.Background())
+		cache := New(Config{
+			ResolveClusterFunc: resolveCluster,
+			Clock:              clock,
+			ConnectToProxyFunc: func(ctx context.Context, clusterClient *client.TeleportClient) (*client.ProxyClient, error) {
+				return nil, trace.ConnectionProblem(nil, "connection dropped")
+			},
+		})
+
+		errC := make(chan error, 1)
+		go func() {
+			_, err := cache.Get(ctx, clusterURI)
+			errC <- err
+		}()
+
+		// Cancel the context while Get waits out the first backoff.
+		clock.BlockUntil(1)
+		cancel()
+
+		err := <-errC
+		require.ErrorContains(t, err, "connection dropped")
+		require.ErrorContains(t, err, context.Canceled.Error())
+	})
+}
@@ -61,6 +61,7 @@ func Serve(ctx context.Context, cfg Config) error {
 		PrehogAddr:                      cfg.PrehogAddr,
 		KubeconfigsDir:                  cfg.KubeconfigsDir,
 		AgentsDir:                       cfg.AgentsDir,
+		ConnectionHistoryPath:           filepath.Join(cfg.HomeDir, "connection_history.json"),
 	})
 	if err != nil {
 		return trace.Wrap(err)
@@ -93,6 +94,8 @@ func Serve(ctx context.Context, cfg Config) error {
 			log.Info("Context closed, stopping service.")
 		case sig := <-c:
 			log.Infof("Captured %s, stopping service.", sig)
+		case <-daemonService.ShutdownRequests():
+			log.Info("Shutdown requested over the API, stopping service.")
 		}
 
 		daemonService.Stop()
@@ -518,6 +518,10 @@ var (
 	// RequestedDatabaseRolesExtensionOID is an extension OID used when
 	// encoding/decoding requested database roles.
 	RequestedDatabaseRolesExtensionOID = asn1.ObjectIdentifier{1, 3, 9999, 2, 19}
+
+	// DesktopScopeExtensionOID is an extension OID used to restrict a Windows
+	// desktop certificate to the single desktop it was issued for.
+	DesktopScopeExtensionOID = asn1.ObjectIdentifier{1, 3, 9999, 2, 20}
 )
 
 // Device Trust OIDs.
@@ -1138,6 +1142,10 @@ type CertificateRequest struct {
 	// NotAfter is a time after which the issued certificate
 	// will be no longer valid
 	NotAfter time.Time
+	// Optional. NotBefore is a time before which the issued certificate
+	// is not yet valid. If unset, it defaults to one minute in the past
+	// to account for minor clock skew.
+	NotBefore time.Time
 	// DNSNames is a list of DNS names to add to certificate
 	DNSNames []string
 	// Optional. ExtraExtensions to populate.
@@ -1189,15 +1197,20 @@ func (ca *CertAuthority) GenerateCertificate(req CertificateRequest) ([]byte, er
 		"key_usage": req.KeyUsage,
 	}).Infof("Generating TLS certificate %v", req.Subject.String())
 
+	// NotBefore defaults to one minute in the past to prevent "Not yet valid"
+	// errors on time skewed clusters.
+	notBefore := req.NotBefore
+	if notBefore.IsZero() {
+		notBefore = req.Clock.Now().UTC().Add(-1 * time.Minute)
+	}
+
 	template := &x509.Certificate{
 		SerialNumber: serialNumber,
 		Subject:      req.Subject,
-		// NotBefore is one minute in the past to prevent "Not yet valid" errors on
-		// time skewed clusters.
-		NotBefore:   req.Clock.Now().UTC().Add(-1 * time.Minute),
-		NotAfter:    req.NotAfter,
-		KeyUsage:    req.KeyUsage,
-		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		NotBefore:    notBefore,
+		NotAfter:     req.NotAfter,
+		KeyUsage:     req.KeyUsage,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
 		// BasicConstraintsValid is true to not allow any intermediate certs.
 		BasicConstraintsValid: true,
 		IsCA:                  false,
@@ -75,6 +75,26 @@ const (
 	// MetricHeartbeatsMissed counts the nodes that failed to heartbeat
 	MetricHeartbeatsMissed = "heartbeats_missed_total"
 
+	// MetricWindowsDesktopHeartbeatsMissed counts failed heartbeat attempts
+	// from the Windows desktop service
+	MetricWindowsDesktopHeartbeatsMissed = "windows_desktop_heartbeats_missed_total"
+
+	// MetricWindowsDesktopSessionTxBytes counts the bytes sent to desktop
+	// clients by the Windows desktop service
+	MetricWindowsDesktopSessionTxBytes = "windows_desktop_session_tx_bytes_total"
+
+	// MetricWindowsDesktopSessionRxBytes counts the bytes received from
+	// desktop clients by the Windows desktop service
+	MetricWindowsDesktopSessionRxBytes = "windows_desktop_session_rx_bytes_total"
+
+	// MetricWindowsDesktopSessionDuration measures the duration of completed
+	// desktop sessions
+	MetricWindowsDesktopSessionDuration = "windows_desktop_session_duration_seconds"
+
+	// MetricWindowsDesktopAcceptErrors counts failed attempts to accept an
+	// incoming desktop service connection
+	MetricWindowsDesktopAcceptErrors = "windows_desktop_accept_errors_total"
+
 	// MetricWatcherEventsEmitted counts watcher events that are emitted
 	MetricWatcherEventsEmitted = "watcher_events"
 